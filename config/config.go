@@ -1,7 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/nats-io/gnatsd/server"
 
@@ -57,7 +61,124 @@ type AssetConfig struct {
 	// Fields required as part of the data
 	RequiredFields []string `json:"required_fields"`
 	// Fields required with the mapped values.
-	EnforcedFields map[string][]string `json:"enforced_fields"`
+	EnforcedFields map[string]FieldRule `json:"enforced_fields"`
+	// Fields whose values must be unique across assets of a type
+	UniqueFields []UniqueKey `json:"unique_fields,omitempty"`
+	// Relations to other assets keyed by relation name
+	Links map[string]LinkConfig `json:"links,omitempty"`
+	// Server side generation of asset ids
+	IdGeneration *IdGenerationConfig `json:"id_generation,omitempty"`
+	// Per asset type rules keyed by type
+	Types map[string]TypeAssetConfig `json:"types,omitempty"`
+}
+
+/*
+	Required, enforced and unique fields, links and id generation for a single asset type.  By
+	default these are added to the global ones with enforced values, links and id generation
+	replacing the global ones.  If override is set the global rules are not applied to the type at all.
+*/
+type TypeAssetConfig struct {
+	Override       bool                  `json:"override,omitempty"`
+	RequiredFields []string              `json:"required_fields,omitempty"`
+	EnforcedFields map[string]FieldRule  `json:"enforced_fields,omitempty"`
+	UniqueFields   []UniqueKey           `json:"unique_fields,omitempty"`
+	Links          map[string]LinkConfig `json:"links,omitempty"`
+	IdGeneration   *IdGenerationConfig   `json:"id_generation,omitempty"`
+}
+
+/*
+	One or more fields whose combined values must be unique across assets of a type.  A single
+	field can be specified as a string i.e. "serial" is the same as ["serial"]
+*/
+type UniqueKey []string
+
+func (uk *UniqueKey) UnmarshalJSON(b []byte) error {
+	var field string
+	if err := json.Unmarshal(b, &field); err == nil {
+		*uk = UniqueKey{field}
+		return nil
+	}
+
+	var fields []string
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return fmt.Errorf("Unique key must be a field or list of fields: %s", err)
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("Unique key must contain at least 1 field")
+	}
+	*uk = UniqueKey(fields)
+	return nil
+}
+
+func (uk UniqueKey) String() string {
+	return strings.Join(uk, ", ")
+}
+
+/* Asset config with the rules for the given type applied */
+func (ac *AssetConfig) ForType(assetType string) *AssetConfig {
+	resolved := &AssetConfig{
+		RequiredFields: ac.RequiredFields,
+		EnforcedFields: ac.EnforcedFields,
+		UniqueFields:   ac.UniqueFields,
+		Links:          ac.Links,
+		IdGeneration:   ac.IdGeneration,
+	}
+	if tc, ok := ac.Types[assetType]; ok {
+		resolved = resolved.Apply(tc)
+	}
+	return resolved
+}
+
+/* New asset config with the type rules applied. Per type rules of the config are not carried over. */
+func (ac *AssetConfig) Apply(tc TypeAssetConfig) *AssetConfig {
+	resolved := &AssetConfig{
+		RequiredFields: []string{},
+		EnforcedFields: map[string]FieldRule{},
+		UniqueFields:   []UniqueKey{},
+		Links:          map[string]LinkConfig{},
+	}
+
+	if !tc.Override {
+		resolved.RequiredFields = append(resolved.RequiredFields, ac.RequiredFields...)
+		for k, v := range ac.EnforcedFields {
+			resolved.EnforcedFields[k] = v
+		}
+		resolved.UniqueFields = append(resolved.UniqueFields, ac.UniqueFields...)
+		for k, v := range ac.Links {
+			resolved.Links[k] = v
+		}
+		resolved.IdGeneration = ac.IdGeneration
+	}
+
+	for _, v := range tc.RequiredFields {
+		if !resolved.IsRequiredField(v) {
+			resolved.RequiredFields = append(resolved.RequiredFields, v)
+		}
+	}
+	for k, v := range tc.EnforcedFields {
+		resolved.EnforcedFields[k] = v
+	}
+	for _, v := range tc.UniqueFields {
+		if !resolved.hasUniqueKey(v) {
+			resolved.UniqueFields = append(resolved.UniqueFields, v)
+		}
+	}
+	for k, v := range tc.Links {
+		resolved.Links[k] = v
+	}
+	if tc.IdGeneration != nil {
+		resolved.IdGeneration = tc.IdGeneration
+	}
+	return resolved
+}
+
+func (ac *AssetConfig) hasUniqueKey(key UniqueKey) bool {
+	for _, v := range ac.UniqueFields {
+		if v.String() == key.String() {
+			return true
+		}
+	}
+	return false
 }
 
 func (ac *AssetConfig) IsRequiredField(field string) bool {
@@ -74,16 +195,137 @@ type PluginConfig struct {
 	Enabled bool        `json:"enabled"`
 }
 
+// Defaults for ansible dynamic inventories
+type AnsibleConfig struct {
+	// Field containing the host name.  The asset id is used if empty.
+	HostField string `json:"host_field,omitempty"`
+	// Fields whose values become groups
+	GroupBy []string `json:"group_by,omitempty"`
+}
+
+// Defaults for prometheus service discovery targets
+type PrometheusConfig struct {
+	// Field containing the target address.  The asset id is used if empty.
+	TargetField string `json:"target_field,omitempty"`
+	// Port appended to targets without one
+	TargetPort int `json:"target_port,omitempty"`
+	// Fields added as labels.  A label name can be given after a colon i.e. labels.env:env
+	LabelFields []string `json:"label_fields,omitempty"`
+	// file_sd target files kept up to date on changes
+	Files []PrometheusFileConfig `json:"files,omitempty"`
+}
+
+// file_sd target file written from a search
+type PrometheusFileConfig struct {
+	Path string `json:"path"`
+	// Optional asset type to search within
+	AssetType string `json:"asset_type,omitempty"`
+	// Search params as a url query string i.e. environment=production&target_port=9100
+	Query string `json:"query,omitempty"`
+}
+
+// Check target files have a path and a valid query
+func (pc *PrometheusConfig) Validate() error {
+	for _, f := range pc.Files {
+		if len(f.Path) == 0 {
+			return fmt.Errorf("Prometheus target file path required")
+		}
+		if _, err := url.ParseQuery(f.Query); err != nil {
+			return fmt.Errorf("Invalid prometheus target file query '%s': %s", f.Query, err)
+		}
+	}
+	return nil
+}
+
+// Defaults for DNS zone and hosts file rendering
+type DNSConfig struct {
+	// Field containing the record name.  The asset id is used if the asset does not have it.
+	NameField string `json:"name_field,omitempty"`
+	// Field containing the record type i.e. A, CNAME
+	TypeField string `json:"type_field,omitempty"`
+	// Field containing the record data.  Array fields give a record per element.
+	ValueField string `json:"value_field,omitempty"`
+	// Field containing the record ttl
+	TTLField string `json:"ttl_field,omitempty"`
+
+	// Zone origin i.e. example.org.
+	Origin string `json:"origin,omitempty"`
+	// Default ttl of the zone
+	TTL int `json:"ttl,omitempty"`
+	// Name servers of the zone.  The first is the primary.
+	Nameservers []string `json:"nameservers,omitempty"`
+	// Zone contact i.e. hostmaster@example.org
+	Hostmaster string `json:"hostmaster,omitempty"`
+	// SOA timers in seconds
+	Refresh int `json:"refresh,omitempty"`
+	Retry   int `json:"retry,omitempty"`
+	Expire  int `json:"expire,omitempty"`
+	Minimum int `json:"minimum,omitempty"`
+}
+
+// Snapshots of the indices into an elasticsearch filesystem repository
+type SnapshotConfig struct {
+	// Repository name.  Defaults to vindalu_backup.
+	Repository string `json:"repository,omitempty"`
+	// Repository directory on the elasticsearch nodes.  It must be listed in their `path.repo`
+	// setting.  Snapshots are disabled if empty.
+	Location string `json:"location,omitempty"`
+	// Compress the snapshot metadata files
+	Compress bool `json:"compress,omitempty"`
+	// Time between scheduled snapshots i.e. 24h.  Snapshots are only taken on request if empty.
+	Interval string `json:"interval,omitempty"`
+	// Number of snapshots kept.  Older ones are removed after each snapshot.  All are kept if 0.
+	Retention int `json:"retention,omitempty"`
+}
+
+func (sc *SnapshotConfig) Enabled() bool {
+	return len(sc.Location) > 0
+}
+
+// Time between scheduled snapshots.  0 if snapshots are not scheduled.
+func (sc *SnapshotConfig) IntervalDuration() time.Duration {
+	d, _ := time.ParseDuration(sc.Interval)
+	return d
+}
+
+// Check the schedule and retention are valid and only set along with a location
+func (sc *SnapshotConfig) Validate() error {
+	if !sc.Enabled() {
+		if len(sc.Interval) > 0 || sc.Retention > 0 {
+			return fmt.Errorf("Snapshot location required")
+		}
+		return nil
+	}
+	if len(sc.Interval) > 0 {
+		d, err := time.ParseDuration(sc.Interval)
+		if err != nil {
+			return fmt.Errorf("Invalid snapshot interval '%s': %s", sc.Interval, err)
+		}
+		if d < time.Minute {
+			return fmt.Errorf("Snapshot interval must be at least 1m: %s", sc.Interval)
+		}
+	}
+	if sc.Retention < 0 {
+		return fmt.Errorf("Invalid snapshot retention: %d", sc.Retention)
+	}
+	return nil
+}
+
 type InventoryConfig struct {
-	Auth              AuthConfig      `json:"auth"`
-	AssetCfg          AssetConfig     `json:"asset"`
-	Datastore         DatastoreConfig `json:"datastore"`
-	DefaultResultSize int64           `json:"default_result_size"`
-	Endpoints         EndpointsConfig `json:"endpoints"`
-	Events            EventsConfig    `json:"events"`
-	ListenAddr        string          // address api server will listen on. comes from cli
-	Version           string          `json:"version"`
-	Webroot           string          `json:"webroot"`
+	Ansible           AnsibleConfig     `json:"ansible"`
+	Auth              AuthConfig        `json:"auth"`
+	AssetCfg          AssetConfig       `json:"asset"`
+	Datastore         DatastoreConfig   `json:"datastore"`
+	DefaultResultSize int64             `json:"default_result_size"`
+	DNS               DNSConfig         `json:"dns"`
+	Endpoints         EndpointsConfig   `json:"endpoints"`
+	Events            EventsConfig      `json:"events"`
+	ListenAddr        string            // address api server will listen on. comes from cli
+	Prometheus        PrometheusConfig  `json:"prometheus"`
+	Snapshots         SnapshotConfig    `json:"snapshots"`
+	Templates         map[string]string `json:"templates"` // export template files by name
+	Version           string            `json:"version"`
+	Webroot           string            `json:"webroot"`
 }
 
 /* Datastructure use to deliver configs to client via /config endpoint */
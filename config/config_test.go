@@ -1,8 +1,11 @@
 package config
 
 import (
-	"github.com/vindalu/vindalu/logging"
+	"encoding/json"
 	"testing"
+	"time"
+
+	"github.com/vindalu/vindalu/logging"
 )
 
 var (
@@ -22,6 +25,48 @@ func Test_InventoryConfig_RequiredFields(t *testing.T) {
 	}
 }
 
+func Test_AssetConfig_ForType(t *testing.T) {
+	ac := AssetConfig{
+		RequiredFields: []string{"status"},
+		EnforcedFields: map[string]FieldRule{"status": FieldRule{Values: []string{"enabled", "disabled"}}},
+		Types: map[string]TypeAssetConfig{
+			"server": TypeAssetConfig{
+				RequiredFields: []string{"environment", "status"},
+				EnforcedFields: map[string]FieldRule{"status": FieldRule{Values: []string{"enabled"}}},
+			},
+			"dnsrecord": TypeAssetConfig{
+				Override:       true,
+				RequiredFields: []string{"zone", "ttl"},
+			},
+		},
+	}
+
+	srv := ac.ForType("server")
+	if len(srv.RequiredFields) != 2 || !srv.IsRequiredField("status") || !srv.IsRequiredField("environment") {
+		t.Fatalf("Wrong required fields: %v", srv.RequiredFields)
+	}
+	if len(srv.EnforcedFields["status"].Values) != 1 {
+		t.Fatalf("Type values should replace global: %v", srv.EnforcedFields)
+	}
+	// Global config should be untouched
+	if len(ac.EnforcedFields["status"].Values) != 2 {
+		t.Fatalf("Global config modified: %v", ac.EnforcedFields)
+	}
+
+	dns := ac.ForType("dnsrecord")
+	if dns.IsRequiredField("status") || !dns.IsRequiredField("zone") || !dns.IsRequiredField("ttl") {
+		t.Fatalf("Wrong required fields: %v", dns.RequiredFields)
+	}
+	if len(dns.EnforcedFields) != 0 {
+		t.Fatalf("Global enforced fields should be overridden: %v", dns.EnforcedFields)
+	}
+
+	other := ac.ForType("foo")
+	if len(other.RequiredFields) != 1 || len(other.EnforcedFields) != 1 || other.Types != nil {
+		t.Fatalf("Should only have global rules: %#v", other)
+	}
+}
+
 /*
 func Test_InventoryConfig_GetDatastore(t *testing.T) {
 	testCfg.Datastore.Config.MappingsDir = "../etc/mappings"
@@ -50,3 +95,67 @@ func Test_InventoryConfig_GetAuthenticator_error(t *testing.T) {
 		t.Fatal("Should have failed")
 	}
 }
+
+func Test_UniqueKey_UnmarshalJSON(t *testing.T) {
+	var keys []UniqueKey
+	if err := json.Unmarshal([]byte(`["serial", ["rack", "slot"]]`), &keys); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(keys) != 2 || len(keys[0]) != 1 || keys[1].String() != "rack, slot" {
+		t.Fatalf("Wrong keys: %v", keys)
+	}
+
+	if err := json.Unmarshal([]byte(`[[]]`), &keys); err == nil {
+		t.Fatalf("Empty key should fail")
+	}
+}
+
+func Test_AssetConfig_ForType_unique(t *testing.T) {
+	ac := AssetConfig{
+		UniqueFields: []UniqueKey{UniqueKey{"serial"}},
+		Types: map[string]TypeAssetConfig{
+			"server": TypeAssetConfig{UniqueFields: []UniqueKey{UniqueKey{"serial"}, UniqueKey{"rack", "slot"}}},
+		},
+	}
+	if keys := ac.ForType("server").UniqueFields; len(keys) != 2 {
+		t.Fatalf("Keys should be merged: %v", keys)
+	}
+}
+
+func Test_PrometheusConfig_Validate(t *testing.T) {
+	pc := PrometheusConfig{Files: []PrometheusFileConfig{{Path: "/tmp/targets.json", Query: "environment=production"}}}
+	if err := pc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	pc.Files = append(pc.Files, PrometheusFileConfig{Query: "environment=production"})
+	if err := pc.Validate(); err == nil {
+		t.Fatalf("Should require path")
+	}
+
+	pc.Files = []PrometheusFileConfig{{Path: "/tmp/targets.json", Query: "environment=%zz"}}
+	if err := pc.Validate(); err == nil {
+		t.Fatalf("Should fail on invalid query")
+	}
+}
+
+func Test_SnapshotConfig_Validate(t *testing.T) {
+	sc := SnapshotConfig{Location: "/var/backups/vindalu", Interval: "24h", Retention: 7}
+	if err := sc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sc.IntervalDuration() != 24*time.Hour {
+		t.Fatalf("Wrong interval: %s", sc.IntervalDuration())
+	}
+
+	for _, c := range []SnapshotConfig{
+		{Interval: "24h"},
+		{Location: "/var/backups/vindalu", Interval: "daily"},
+		{Location: "/var/backups/vindalu", Interval: "10s"},
+		{Location: "/var/backups/vindalu", Retention: -1},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("Should fail: %#v", c)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Value types that can be enforced on a field
+var FIELD_RULE_TYPES = []string{"string", "number", "integer", "boolean", "array"}
+
+/*
+	Rule an enforced field must satisfy.  All specified constraints must be met.  When the field
+	value is an array each element is checked against the rule.
+
+	For backwards compatibility a rule can also be specified as a list of allowed values
+	i.e. ["enabled", "disabled"] is the same as {"values": ["enabled", "disabled"]}
+*/
+type FieldRule struct {
+	// Allowed values.  Numbers and booleans are compared using their string form.
+	Values []string `json:"values,omitempty"`
+	// Regex string values must match
+	Pattern string `json:"pattern,omitempty"`
+	// Inclusive numeric range
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Value type. One of FIELD_RULE_TYPES
+	Type string `json:"type,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Used to avoid recursion when (un)marshalling
+type fieldRule FieldRule
+
+func (fr *FieldRule) UnmarshalJSON(b []byte) (err error) {
+	var values []string
+	if err = json.Unmarshal(b, &values); err == nil {
+		*fr = FieldRule{Values: values}
+		return
+	}
+
+	var rule fieldRule
+	if err = json.Unmarshal(b, &rule); err != nil {
+		return fmt.Errorf("Enforced field rule must be a list of values or an object: %s", err)
+	}
+	*fr = FieldRule(rule)
+	return fr.compile()
+}
+
+/* Rules only containing values are written as a list to remain compatible with older clients */
+func (fr FieldRule) MarshalJSON() ([]byte, error) {
+	if fr.IsValuesOnly() {
+		return json.Marshal(fr.Values)
+	}
+	return json.Marshal(fieldRule(fr))
+}
+
+func (fr FieldRule) String() string {
+	b, _ := json.Marshal(fr)
+	return string(b)
+}
+
+// Whether the rule is a plain list of allowed values
+func (fr FieldRule) IsValuesOnly() bool {
+	return len(fr.Pattern) == 0 && fr.Min == nil && fr.Max == nil && len(fr.Type) == 0
+}
+
+// Compiled pattern. nil if the rule does not have one.
+func (fr *FieldRule) Regexp() *regexp.Regexp {
+	if fr.regex == nil && len(fr.Pattern) > 0 {
+		fr.regex, _ = regexp.Compile(fr.Pattern)
+	}
+	return fr.regex
+}
+
+/* Validate and compile the rule */
+func (fr *FieldRule) compile() (err error) {
+	if len(fr.Type) > 0 {
+		found := false
+		for _, t := range FIELD_RULE_TYPES {
+			if t == fr.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Invalid enforced field type '%s'. Must be one of: %v", fr.Type, FIELD_RULE_TYPES)
+		}
+	}
+
+	if fr.Min != nil && fr.Max != nil && *fr.Min > *fr.Max {
+		return fmt.Errorf("Enforced field min (%v) greater than max (%v)", *fr.Min, *fr.Max)
+	}
+
+	if len(fr.Pattern) > 0 {
+		if fr.regex, err = regexp.Compile(fr.Pattern); err != nil {
+			return fmt.Errorf("Invalid enforced field pattern '%s': %s", fr.Pattern, err)
+		}
+	}
+	return
+}
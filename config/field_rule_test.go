@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_FieldRule_UnmarshalJSON(t *testing.T) {
+	var rules map[string]FieldRule
+	err := json.Unmarshal([]byte(`{
+		"status": ["enabled", "disabled"],
+		"hostname": {"pattern": "^[a-z0-9\\-]+$"},
+		"port": {"type": "integer", "min": 1, "max": 65535}
+	}`), &rules)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(rules["status"].Values) != 2 || !rules["status"].IsValuesOnly() {
+		t.Fatalf("List should be values: %#v", rules["status"])
+	}
+	hostname := rules["hostname"]
+	if hostname.Regexp() == nil || !hostname.Regexp().MatchString("web-01") {
+		t.Fatalf("Pattern not compiled: %#v", hostname)
+	}
+	if rules["port"].Type != "integer" || *rules["port"].Min != 1 || *rules["port"].Max != 65535 {
+		t.Fatalf("Wrong rule: %#v", rules["port"])
+	}
+}
+
+func Test_FieldRule_UnmarshalJSON_error(t *testing.T) {
+	invalid := []string{
+		`{"type": "date"}`,
+		`{"pattern": "[a-z"}`,
+		`{"min": 10, "max": 1}`,
+		`"enabled"`,
+	}
+	for _, v := range invalid {
+		var rule FieldRule
+		if err := json.Unmarshal([]byte(v), &rule); err == nil {
+			t.Fatalf("Should have failed: %s", v)
+		}
+	}
+}
+
+func Test_FieldRule_MarshalJSON(t *testing.T) {
+	b, _ := json.Marshal(FieldRule{Values: []string{"enabled", "disabled"}})
+	if string(b) != `["enabled","disabled"]` {
+		t.Fatalf("Values only rule should be a list: %s", b)
+	}
+
+	max := float64(10)
+	b, _ = json.Marshal(map[string]FieldRule{"count": FieldRule{Type: "integer", Max: &max}})
+	if string(b) != `{"count":{"max":10,"type":"integer"}}` {
+		t.Fatalf("Wrong json: %s", b)
+	}
+}
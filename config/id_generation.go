@@ -0,0 +1,52 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Strategies to generate asset ids with
+const (
+	ID_STRATEGY_UUID     = "uuid"     // random (version 4) UUID
+	ID_STRATEGY_ULID     = "ulid"     // lexicographically sortable ULID
+	ID_STRATEGY_SEQUENCE = "sequence" // zero padded counter per type i.e. CHG-000123
+)
+
+// Max zero padded width of sequence ids
+const MAX_ID_SEQUENCE_WIDTH = 20
+
+/*
+	Server side generation of asset ids.  Assets of types with id generation configured can be
+	created without an id by posting to the type.
+*/
+type IdGenerationConfig struct {
+	Strategy string `json:"strategy"`
+	// Prepended to generated ids
+	Prefix string `json:"prefix,omitempty"`
+	// Zero padded width of sequence numbers
+	Width int `json:"width,omitempty"`
+}
+
+// Used to avoid recursion when unmarshalling
+type idGenerationConfig IdGenerationConfig
+
+func (ic *IdGenerationConfig) UnmarshalJSON(b []byte) error {
+	var tmp idGenerationConfig
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+
+	switch tmp.Strategy {
+	case ID_STRATEGY_UUID, ID_STRATEGY_ULID, ID_STRATEGY_SEQUENCE:
+	default:
+		return fmt.Errorf("Invalid id generation strategy '%s'. Must be one of: %s, %s, %s",
+			tmp.Strategy, ID_STRATEGY_UUID, ID_STRATEGY_ULID, ID_STRATEGY_SEQUENCE)
+	}
+
+	if tmp.Width < 0 || tmp.Width > MAX_ID_SEQUENCE_WIDTH {
+		return fmt.Errorf("Id generation width must be between 0 and %d", MAX_ID_SEQUENCE_WIDTH)
+	}
+
+	*ic = IdGenerationConfig(tmp)
+	return nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_IdGenerationConfig_UnmarshalJSON(t *testing.T) {
+	var ic IdGenerationConfig
+	if err := json.Unmarshal([]byte(`{"strategy": "sequence", "prefix": "CHG-", "width": 6}`), &ic); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ic.Strategy != ID_STRATEGY_SEQUENCE || ic.Prefix != "CHG-" || ic.Width != 6 {
+		t.Fatalf("Wrong config: %#v", ic)
+	}
+
+	for _, s := range []string{`{}`, `{"strategy": "random"}`, `{"strategy": "sequence", "width": 21}`} {
+		if err := json.Unmarshal([]byte(s), &ic); err == nil {
+			t.Errorf("Should be invalid: %s", s)
+		}
+	}
+}
+
+func Test_AssetConfig_ForType_idGeneration(t *testing.T) {
+	ac := AssetConfig{
+		IdGeneration: &IdGenerationConfig{Strategy: ID_STRATEGY_UUID},
+		Types: map[string]TypeAssetConfig{
+			"change": {IdGeneration: &IdGenerationConfig{Strategy: ID_STRATEGY_SEQUENCE, Prefix: "CHG-"}},
+			"server": {Override: true},
+		},
+	}
+
+	if cfg := ac.ForType("lease"); cfg.IdGeneration == nil || cfg.IdGeneration.Strategy != ID_STRATEGY_UUID {
+		t.Fatalf("Global id generation should apply: %#v", cfg.IdGeneration)
+	}
+	if cfg := ac.ForType("change"); cfg.IdGeneration == nil || cfg.IdGeneration.Strategy != ID_STRATEGY_SEQUENCE {
+		t.Fatalf("Type id generation should apply: %#v", cfg.IdGeneration)
+	}
+	if cfg := ac.ForType("server"); cfg.IdGeneration != nil {
+		t.Fatalf("Override should drop global id generation: %#v", cfg.IdGeneration)
+	}
+}
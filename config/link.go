@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Behaviors when an asset referenced by a link is removed
+const (
+	ON_DELETE_BLOCK   = "block"   // removal fails while the asset is referenced
+	ON_DELETE_CASCADE = "cascade" // referencing assets are removed as well
+	ON_DELETE_NULLIFY = "nullify" // the reference is removed from referencing assets
+)
+
+/*
+	Relation from assets of a type to other assets.  Relations that are not configured can
+	reference assets of any type and block removal of the referenced asset.
+*/
+type LinkConfig struct {
+	// Type the relation must reference.  Any type if empty.
+	Type string `json:"type,omitempty"`
+	// Behavior when the referenced asset is removed. Defaults to block.
+	OnDelete string `json:"on_delete,omitempty"`
+}
+
+// Used to avoid recursion when unmarshalling
+type linkConfig LinkConfig
+
+func (lc *LinkConfig) UnmarshalJSON(b []byte) error {
+	var tmp linkConfig
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+
+	switch tmp.OnDelete {
+	case "", ON_DELETE_BLOCK, ON_DELETE_CASCADE, ON_DELETE_NULLIFY:
+		*lc = LinkConfig(tmp)
+		return nil
+	}
+	return fmt.Errorf("Invalid link on_delete '%s'. Must be one of: %s, %s, %s",
+		tmp.OnDelete, ON_DELETE_BLOCK, ON_DELETE_CASCADE, ON_DELETE_NULLIFY)
+}
+
+// Removal behavior with the default applied
+func (lc LinkConfig) OnDeleteBehavior() string {
+	if len(lc.OnDelete) == 0 {
+		return ON_DELETE_BLOCK
+	}
+	return lc.OnDelete
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_LinkConfig_UnmarshalJSON(t *testing.T) {
+	var links map[string]LinkConfig
+	if err := json.Unmarshal([]byte(`{"pool": {"type": "pool", "on_delete": "nullify"}, "rack": {}}`), &links); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if links["pool"].OnDeleteBehavior() != ON_DELETE_NULLIFY || links["rack"].OnDeleteBehavior() != ON_DELETE_BLOCK {
+		t.Fatalf("Wrong behavior: %#v", links)
+	}
+
+	if err := json.Unmarshal([]byte(`{"pool": {"on_delete": "ignore"}}`), &links); err == nil {
+		t.Fatalf("Should fail on invalid on_delete")
+	}
+}
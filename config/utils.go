@@ -24,6 +24,18 @@ func normalizeConfigPaths(cfg *InventoryConfig) {
 	if !filepath.IsAbs(cfg.Events.ConfigFile) {
 		cfg.Events.ConfigFile, _ = filepath.Abs(cfg.Events.ConfigFile)
 	}
+
+	for i, f := range cfg.Prometheus.Files {
+		if len(f.Path) > 0 && !filepath.IsAbs(f.Path) {
+			cfg.Prometheus.Files[i].Path, _ = filepath.Abs(f.Path)
+		}
+	}
+
+	for name, path := range cfg.Templates {
+		if len(path) > 0 && !filepath.IsAbs(path) {
+			cfg.Templates[name], _ = filepath.Abs(path)
+		}
+	}
 }
 
 /* Append prefix to endpoints */
@@ -53,6 +65,14 @@ func LoadConfig(cfgfile string, cfg *InventoryConfig) (err error) {
 
 	configureEnpoints(cfg)
 
+	if err = cfg.Prometheus.Validate(); err != nil {
+		return
+	}
+
+	if err = cfg.Snapshots.Validate(); err != nil {
+		return
+	}
+
 	if cfg.Auth.Token.SigningKey, err = GetExternalField(cfg.Auth.Token.SigningKey); err != nil {
 		return
 	}
@@ -0,0 +1,612 @@
+package core
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/simple-ess"
+)
+
+// Version of the archive layout.  Archives of a different format cannot be imported.
+const ARCHIVE_FORMAT_VERSION = 1
+
+/*
+	Entries of an inventory archive in the order they are written.  The manifest must be the
+	first entry and the mappings must precede the documents.
+*/
+const (
+	ARCHIVE_MANIFEST = "manifest.json"
+	ARCHIVE_MAPPINGS = "mappings.json"
+	ARCHIVE_META     = "meta.ndjson"
+	ARCHIVE_VERSIONS = "versions.ndjson"
+	ARCHIVE_ASSETS   = "assets.ndjson"
+)
+
+// Documents read per scroll request and written per bulk request
+const ARCHIVE_BATCH_SIZE = 500
+
+// How long scroll contexts are kept between requests
+const ARCHIVE_SCROLL_TIMEOUT = "5m"
+
+// Executes elasticsearch requests i.e. the elastigo connection or the typeless client
+type essCommander interface {
+	DoCommand(method, path string, args map[string]interface{}, data interface{}) ([]byte, error)
+}
+
+// Summary of an archive.  Counts are those of the documents in the archive.
+type ArchiveManifest struct {
+	Format         int      `json:"format"`
+	VindaluVersion string   `json:"vindalu_version"`
+	Index          string   `json:"index"`
+	Created        int64    `json:"created"`
+	Types          []string `json:"types"`
+	Assets         int64    `json:"assets"`
+	Versions       int64    `json:"versions"`
+	MetaDocs       int64    `json:"meta_docs"`
+	// Written by a typeless datastore.  Property mappings are only imported by the same kind.
+	Typeless bool `json:"typeless,omitempty"`
+}
+
+// Check the archive can be imported by this version
+func (m *ArchiveManifest) Validate() error {
+	if m.Format != ARCHIVE_FORMAT_VERSION {
+		return fmt.Errorf("Unsupported archive format: %d (expected %d)", m.Format, ARCHIVE_FORMAT_VERSION)
+	}
+	return nil
+}
+
+// Type mappings of the primary and version indices
+type archiveMappings struct {
+	Index    map[string]*json.RawMessage `json:"index"`
+	Versions map[string]*json.RawMessage `json:"versions"`
+}
+
+// Document of the meta index i.e. a saved query, schema or sequence.  Sequences are the version.
+type archiveMetaDoc struct {
+	Type    string           `json:"type"`
+	Id      string           `json:"id"`
+	Version int64            `json:"version"`
+	Doc     *json.RawMessage `json:"doc"`
+}
+
+type archiveHit struct {
+	Type    string                 `json:"_type"`
+	Id      string                 `json:"_id"`
+	Version int64                  `json:"_version"`
+	Source  *json.RawMessage       `json:"_source"`
+	Fields  map[string]interface{} `json:"fields"`
+}
+
+func (h archiveHit) asset() (asset BaseAsset, err error) {
+	asset = BaseAsset{Id: h.Id, Type: h.Type, Timestamp: h.Fields["_timestamp"]}
+	if h.Source != nil {
+		err = json.Unmarshal(*h.Source, &asset.Data)
+	}
+	return
+}
+
+// Named NDJSON file spooled to disk as the size of tar entries must be known up front
+type archivePart struct {
+	name string
+	file *os.File
+}
+
+/*
+	Write all types, their mappings, the meta documents, the complete version history and the
+	current assets to a tar archive.  Everything is read from the datastore before anything is
+	written so a failed export does not write a partial archive.
+*/
+func (e *ElasticsearchDatastore) ExportArchive(w io.Writer) (*ArchiveManifest, error) {
+	manifest := &ArchiveManifest{
+		Format:         ARCHIVE_FORMAT_VERSION,
+		VindaluVersion: config.VERSION,
+		Index:          e.Index,
+		Created:        time.Now().UnixNano() / 1000000,
+		Types:          []string{},
+	}
+
+	var (
+		mappings archiveMappings
+		err      error
+	)
+	if mappings.Index, err = e.indexMappings(e.Index); err != nil {
+		return nil, err
+	}
+	if mappings.Versions, err = e.indexMappings(e.VersionIndex); err != nil {
+		return nil, err
+	}
+	for name := range mappings.Index {
+		manifest.Types = append(manifest.Types, name)
+	}
+	sort.Strings(manifest.Types)
+
+	parts := []*archivePart{{name: ARCHIVE_META}, {name: ARCHIVE_VERSIONS}, {name: ARCHIVE_ASSETS}}
+	defer func() {
+		for _, p := range parts {
+			if p.file != nil {
+				p.file.Close()
+				os.Remove(p.file.Name())
+			}
+		}
+	}()
+
+	if parts[0].file, err = spoolNDJSON(func(enc *json.Encoder) error {
+		return e.scanIndex(e.MetaIndex, func(h archiveHit) error {
+			manifest.MetaDocs++
+			return enc.Encode(archiveMetaDoc{Type: h.Type, Id: h.Id, Version: h.Version, Doc: h.Source})
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if parts[1].file, err = spoolNDJSON(func(enc *json.Encoder) error {
+		return e.scanIndex(e.VersionIndex, func(h archiveHit) error {
+			asset, err := h.asset()
+			if err != nil {
+				return err
+			}
+			asset.Id = assetIdFromVersionId(asset.Id)
+			manifest.Versions++
+			return enc.Encode(asset)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if parts[2].file, err = spoolNDJSON(func(enc *json.Encoder) error {
+		return e.scanIndex(e.Index, func(h archiveHit) error {
+			asset, err := h.asset()
+			if err != nil {
+				return err
+			}
+			manifest.Assets++
+			return enc.Encode(asset)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return manifest, writeArchive(w, manifest, mappings, parts)
+}
+
+func writeArchive(w io.Writer, manifest *ArchiveManifest, mappings archiveMappings, parts []*archivePart) error {
+	tw := tar.NewWriter(w)
+	modTime := time.Unix(0, manifest.Created*1000000)
+
+	for _, entry := range []struct {
+		name string
+		v    interface{}
+	}{{ARCHIVE_MANIFEST, manifest}, {ARCHIVE_MAPPINGS, mappings}} {
+		b, err := json.MarshalIndent(entry.v, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(b)), ModTime: modTime}); err != nil {
+			return err
+		}
+		if _, err = tw.Write(b); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range parts {
+		fi, err := p.file.Stat()
+		if err != nil {
+			return err
+		}
+		if err = tw.WriteHeader(&tar.Header{Name: p.name, Mode: 0644, Size: fi.Size(), ModTime: modTime}); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, p.file); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// Write NDJSON to a temporary file positioned at the start
+func spoolNDJSON(write func(*json.Encoder) error) (*os.File, error) {
+	f, err := ioutil.TempFile("", "vindalu-archive-")
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(f)
+	if err = write(json.NewEncoder(bw)); err == nil {
+		if err = bw.Flush(); err == nil {
+			_, err = f.Seek(0, 0)
+		}
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+/*
+	Restore an archive written by ExportArchive into an empty datastore.  Version numbers,
+	timestamps and all asset data including `created_by` and `updated_by` are preserved.  Archives
+	may be gzip compressed.  If the import fails the datastore has to be emptied before retrying.
+*/
+func (e *ElasticsearchDatastore) ImportArchive(r io.Reader) (*ArchiveManifest, error) {
+	if err := checkIndicesEmpty(e.Conn, e.Index, e.VersionIndex); err != nil {
+		return nil, err
+	}
+
+	r, err := archiveReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		manifest *ArchiveManifest
+		imported = &ArchiveManifest{}
+		tr       = tar.NewReader(r)
+	)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Invalid archive: %s", err)
+		}
+
+		name := path.Base(hdr.Name)
+		if manifest == nil && name != ARCHIVE_MANIFEST {
+			return nil, fmt.Errorf("Invalid archive: %s must be the first entry", ARCHIVE_MANIFEST)
+		}
+
+		switch name {
+		case ARCHIVE_MANIFEST:
+			if manifest, err = readArchiveManifest(tr); err != nil {
+				return nil, err
+			}
+			*imported = *manifest
+			imported.Assets, imported.Versions, imported.MetaDocs = 0, 0, 0
+		case ARCHIVE_MAPPINGS:
+			var mappings archiveMappings
+			if err = json.NewDecoder(tr).Decode(&mappings); err != nil {
+				return nil, fmt.Errorf("Invalid archive mappings: %s", err)
+			}
+			if manifest.Typeless {
+				if mappings.Index, err = archiveMappingsMeta(mappings.Index); err != nil {
+					return nil, err
+				}
+				mappings.Versions = mappings.Index
+			}
+			if err = e.putMappings(e.Index, mappings.Index); err != nil {
+				return nil, err
+			}
+			if err = e.putMappings(e.VersionIndex, mappings.Versions); err != nil {
+				return nil, err
+			}
+		case ARCHIVE_META:
+			imported.MetaDocs, err = bulkImport(e.Conn, tr, func(line []byte) (map[string]interface{}, interface{}, error) {
+				var doc archiveMetaDoc
+				if err := json.Unmarshal(line, &doc); err != nil {
+					return nil, nil, err
+				}
+				action := map[string]interface{}{"_index": e.MetaIndex, "_type": doc.Type, "_id": doc.Id}
+				if doc.Version > 0 {
+					action["_version"] = doc.Version
+					action["_version_type"] = "external"
+				}
+				return action, doc.Doc, nil
+			})
+		case ARCHIVE_VERSIONS:
+			imported.Versions, err = bulkImport(e.Conn, tr, func(line []byte) (map[string]interface{}, interface{}, error) {
+				asset, err := decodeArchiveAsset(line)
+				if err != nil {
+					return nil, nil, err
+				}
+				ver := asset.GetVersion()
+				if ver < 1 {
+					return nil, nil, fmt.Errorf("Version missing: %s/%s", asset.Type, asset.Id)
+				}
+				return archiveAssetAction(e.VersionIndex, asset, fmt.Sprintf("%s.%d", asset.Id, ver)), asset.Data, nil
+			})
+		case ARCHIVE_ASSETS:
+			imported.Assets, err = bulkImport(e.Conn, tr, func(line []byte) (map[string]interface{}, interface{}, error) {
+				asset, err := decodeArchiveAsset(line)
+				if err != nil {
+					return nil, nil, err
+				}
+				return archiveAssetAction(e.Index, asset, asset.Id), asset.Data, nil
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to import %s: %s", name, err)
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("Invalid archive: %s missing", ARCHIVE_MANIFEST)
+	}
+	if _, err = e.Conn.DoCommand("POST",
+		fmt.Sprintf("/%s,%s,%s/_refresh", e.Index, e.VersionIndex, e.MetaIndex), nil, nil); err != nil {
+		return nil, err
+	}
+	if imported.Assets != manifest.Assets || imported.Versions != manifest.Versions || imported.MetaDocs != manifest.MetaDocs {
+		return imported, fmt.Errorf("Incomplete archive: imported %d/%d assets, %d/%d versions, %d/%d meta documents",
+			imported.Assets, manifest.Assets, imported.Versions, manifest.Versions, imported.MetaDocs, manifest.MetaDocs)
+	}
+	return imported, nil
+}
+
+// Reader of the archive contents decompressing gzip archives
+func archiveReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid archive: %s", err)
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+func readArchiveManifest(r io.Reader) (*ArchiveManifest, error) {
+	var manifest ArchiveManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("Invalid archive manifest: %s", err)
+	}
+	return &manifest, manifest.Validate()
+}
+
+func decodeArchiveAsset(line []byte) (asset BaseAsset, err error) {
+	if err = json.Unmarshal(line, &asset); err == nil && (len(asset.Type) == 0 || len(asset.Id) == 0) {
+		err = fmt.Errorf("Asset type and id required")
+	}
+	return
+}
+
+// Bulk index action for an asset keeping its timestamp
+func archiveAssetAction(index string, asset BaseAsset, id string) map[string]interface{} {
+	action := map[string]interface{}{"_index": index, "_type": asset.Type, "_id": id}
+	switch asset.Timestamp.(type) {
+	case float64:
+		action["_timestamp"] = int64(asset.Timestamp.(float64))
+	}
+	return action
+}
+
+/*
+	Index the NDJSON documents read from r with bulk requests.  `parse` returns the bulk action
+	metadata and the document for a line.  Returns the number of documents indexed.
+*/
+func bulkImport(conn essCommander, r io.Reader, parse func([]byte) (map[string]interface{}, interface{}, error)) (count int64, err error) {
+	var (
+		buf     bytes.Buffer
+		pending int64
+		br      = bufio.NewReader(r)
+	)
+	for {
+		line, rerr := br.ReadBytes('\n')
+		if rerr != nil && rerr != io.EOF {
+			return count, rerr
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			action, doc, err := parse(line)
+			if err != nil {
+				return count, fmt.Errorf("line %d: %s", count+pending+1, err)
+			}
+			if err = writeBulkItem(&buf, action, doc); err != nil {
+				return count, err
+			}
+
+			if pending++; pending >= ARCHIVE_BATCH_SIZE {
+				if err = execBulk(conn, buf.String()); err != nil {
+					return count, err
+				}
+				count += pending
+				pending = 0
+				buf.Reset()
+			}
+		}
+
+		if rerr == io.EOF {
+			break
+		}
+	}
+
+	if pending > 0 {
+		if err = execBulk(conn, buf.String()); err == nil {
+			count += pending
+		}
+	}
+	return
+}
+
+func writeBulkItem(buf *bytes.Buffer, action map[string]interface{}, doc interface{}) error {
+	b, err := json.Marshal(map[string]interface{}{"index": action})
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	buf.WriteByte('\n')
+
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	buf.Write(b)
+	buf.WriteByte('\n')
+	return nil
+}
+
+func execBulk(conn essCommander, body string) error {
+	b, err := conn.DoCommand("POST", "/_bulk", nil, body)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Type  string      `json:"_type"`
+			Id    string      `json:"_id"`
+			Error interface{} `json:"error"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+	if resp.Errors {
+		for _, item := range resp.Items {
+			for _, res := range item {
+				if res.Error != nil {
+					return fmt.Errorf("%s/%s: %v", res.Type, res.Id, res.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Call fn for every document of an index using a scan and scroll search
+func (e *ElasticsearchDatastore) scanIndex(index string, fn func(archiveHit) error) error {
+	var rslt struct {
+		ScrollId string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []archiveHit `json:"hits"`
+		} `json:"hits"`
+	}
+
+	b, err := e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_search", index), map[string]interface{}{
+		"search_type": "scan",
+		"scroll":      ARCHIVE_SCROLL_TIMEOUT,
+		"fields":      "_source,_timestamp",
+	}, map[string]interface{}{
+		"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+		"size":    ARCHIVE_BATCH_SIZE,
+		"version": true,
+	})
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, &rslt); err != nil {
+		return err
+	}
+
+	for {
+		scrollId := rslt.ScrollId
+		rslt.Hits.Hits = nil
+		if b, err = e.Conn.DoCommand("POST", "/_search/scroll",
+			map[string]interface{}{"scroll": ARCHIVE_SCROLL_TIMEOUT}, scrollId); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, &rslt); err != nil {
+			return err
+		}
+		if len(rslt.Hits.Hits) == 0 {
+			// Free the scroll context rather than waiting for it to expire
+			e.Conn.DoCommand("DELETE", "/_search/scroll", nil, rslt.ScrollId)
+			return nil
+		}
+
+		for _, h := range rslt.Hits.Hits {
+			if err = fn(h); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Mappings of all types of an index other than the default mapping
+func (e *ElasticsearchDatastore) indexMappings(index string) (map[string]*json.RawMessage, error) {
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/%s/_mapping", index), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp map[string]struct {
+		Mappings map[string]*json.RawMessage `json:"mappings"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+
+	// Keyed by the concrete index if index is an alias
+	indices := make([]string, 0, len(resp))
+	for idx := range resp {
+		indices = append(indices, idx)
+	}
+	mappings := resp[simpless.CurrentIndex(index, indices)].Mappings
+	if mappings == nil {
+		mappings = map[string]*json.RawMessage{}
+	}
+	delete(mappings, "_default_")
+	return mappings, nil
+}
+
+/*
+	Type mappings with only their metadata.  Property definitions are specific to the
+	elasticsearch version, so they are dropped when importing into the other datastore.
+*/
+func archiveMappingsMeta(mappings map[string]*json.RawMessage) (map[string]*json.RawMessage, error) {
+	metaOnly := make(map[string]*json.RawMessage, len(mappings))
+	for name, raw := range mappings {
+		var mapping struct {
+			Meta *json.RawMessage `json:"_meta,omitempty"`
+		}
+		if raw != nil {
+			if err := json.Unmarshal(*raw, &mapping); err != nil {
+				return nil, fmt.Errorf("Invalid mapping (%s): %s", name, err)
+			}
+		}
+		b, err := json.Marshal(mapping)
+		if err != nil {
+			return nil, err
+		}
+		m := json.RawMessage(b)
+		metaOnly[name] = &m
+	}
+	return metaOnly, nil
+}
+
+// Put type mappings ignoring conflicts with those applied from the mappings dir
+func (e *ElasticsearchDatastore) putMappings(index string, mappings map[string]*json.RawMessage) error {
+	for name, mapping := range mappings {
+		if _, err := e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/_mapping/%s", index, name),
+			map[string]interface{}{"ignore_conflicts": true},
+			map[string]*json.RawMessage{name: mapping}); err != nil {
+			return fmt.Errorf("Failed to put mapping (%s/%s): %s", index, name, err)
+		}
+	}
+	return nil
+}
+
+// Archives are only imported into a datastore without assets or versions
+func checkIndicesEmpty(conn essCommander, indices ...string) error {
+	for _, index := range indices {
+		b, err := conn.DoCommand("GET", fmt.Sprintf("/%s/_count", index), nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var resp struct {
+			Count int64 `json:"count"`
+		}
+		if err = json.Unmarshal(b, &resp); err != nil {
+			return err
+		}
+		if resp.Count > 0 {
+			return &ConflictError{Reason: fmt.Sprintf("Datastore not empty: %s has %d document(s)", index, resp.Count)}
+		}
+	}
+	return nil
+}
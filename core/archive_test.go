@@ -0,0 +1,122 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_writeArchive(t *testing.T) {
+	manifest := &ArchiveManifest{Format: ARCHIVE_FORMAT_VERSION, Index: "vindalu", Types: []string{"server"}, Assets: 1}
+	raw := json.RawMessage(`{"properties":{"host":{"type":"string"}}}`)
+	mappings := archiveMappings{Index: map[string]*json.RawMessage{"server": &raw}}
+
+	asset := `{"id":"web01","type":"server","timestamp":1444862521000,"data":{"created_by":"alice"}}`
+	f, err := spoolNDJSON(func(enc *json.Encoder) error {
+		var v map[string]interface{}
+		json.Unmarshal([]byte(asset), &v)
+		return enc.Encode(v)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var buf bytes.Buffer
+	if err = writeArchive(&buf, manifest, mappings, []*archivePart{{name: ARCHIVE_ASSETS, file: f}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Compressed archives are read as is
+	var gzBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzBuf)
+	gz.Write(buf.Bytes())
+	gz.Close()
+
+	r, err := archiveReader(&gzBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(r)
+	names := []string{}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+
+		switch hdr.Name {
+		case ARCHIVE_MANIFEST:
+			m, err := readArchiveManifest(tr)
+			if err != nil || m.Index != "vindalu" || m.Assets != 1 {
+				t.Fatalf("Wrong manifest: %#v %v", m, err)
+			}
+		case ARCHIVE_MAPPINGS:
+			var m archiveMappings
+			if err = json.NewDecoder(tr).Decode(&m); err != nil || m.Index["server"] == nil {
+				t.Fatalf("Wrong mappings: %#v %v", m, err)
+			}
+		case ARCHIVE_ASSETS:
+			b, _ := ioutil.ReadAll(tr)
+			a, err := decodeArchiveAsset(bytes.TrimSpace(b))
+			if err != nil || a.Id != "web01" || a.Data["created_by"] != "alice" {
+				t.Fatalf("Wrong asset: %#v %v", a, err)
+			}
+		}
+	}
+	if strings.Join(names, ",") != "manifest.json,mappings.json,assets.ndjson" {
+		t.Fatalf("Wrong entries: %v", names)
+	}
+}
+
+func Test_readArchiveManifest(t *testing.T) {
+	if _, err := readArchiveManifest(strings.NewReader(`{"format": 99}`)); err == nil {
+		t.Fatal("Unsupported format should fail")
+	}
+	if _, err := readArchiveManifest(strings.NewReader(`{`)); err == nil {
+		t.Fatal("Invalid manifest should fail")
+	}
+	if _, err := archiveReader(strings.NewReader("")); err == nil {
+		t.Fatal("Empty archive should fail")
+	}
+}
+
+func Test_archiveAssetAction(t *testing.T) {
+	asset, err := decodeArchiveAsset([]byte(`{"id":"web01","type":"server","timestamp":1444862521000,"data":{"version":3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = writeBulkItem(&buf, archiveAssetAction("vindalu_versions", asset, "web01.3"), asset.Data); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"index":{"_id":"web01.3","_index":"vindalu_versions","_timestamp":1444862521000,"_type":"server"}}` + "\n" +
+		`{"version":3}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("Wrong bulk item: %s", buf.String())
+	}
+
+	if _, err = decodeArchiveAsset([]byte(`{"id":"web01","data":{}}`)); err == nil {
+		t.Fatal("Asset without type should fail")
+	}
+}
+
+func Test_archiveMappingsMeta(t *testing.T) {
+	raw := json.RawMessage(`{"properties": {"ip": {"type": "string"}}, "_meta": {"description": "Servers"}}`)
+	mappings, err := archiveMappingsMeta(map[string]*json.RawMessage{"server": &raw, "pool": nil})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if string(*mappings["server"]) != `{"_meta":{"description":"Servers"}}` || string(*mappings["pool"]) != `{}` {
+		t.Fatalf("Wrong mappings: %s %s", *mappings["server"], *mappings["pool"])
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	elastigo "github.com/mattbaird/elastigo/lib"
+
+	"github.com/vindalu/vindalu/simple-ess"
 )
 
 type ClusterHealth struct {
@@ -50,6 +52,13 @@ type VindaluClusterStatus struct {
 
 	RoutingNodes map[string]interface{} `json:"routing_nodes"`
 	RoutingTable map[string]interface{} `json:"routing_table"`
+
+	// Set when snapshots are configured
+	Snapshots *SnapshotStatus `json:"snapshots,omitempty"`
+	// Set once a reindex has been started on this node
+	Reindex *ReindexStatus `json:"reindex,omitempty"`
+	// Elasticsearch hosts used by this node
+	Hosts []simpless.HostStatus `json:"hosts,omitempty"`
 }
 
 /*
@@ -57,27 +66,31 @@ type VindaluClusterStatus struct {
 		Ip addresses for all cluster nodes
 */
 func (cs *VindaluClusterStatus) ClusterMemberAddrs() (addrs []string) {
-	addrs = make([]string, len(cs.Nodes))
-	i := 0
+	addrs = []string{}
 	for _, v := range cs.Nodes {
-		//addrs[i] = v.TransportAddr.Address
-		parts := strings.Split(v.TransportAddress, "/")
-		if len(parts) < 2 {
-			continue
-		}
-
-		hostPort := strings.Split(strings.TrimSuffix(parts[1], "]"), ":")
-		if len(hostPort) < 2 {
-			continue
+		if host := transportHost(v.TransportAddress); len(host) > 0 {
+			addrs = append(addrs, host)
 		}
-
-		addrs[i] = hostPort[0]
-		i++
 	}
 	return
 }
 
-func GetClusterStatus(conn *elastigo.Conn) (cs VindaluClusterStatus, err error) {
+/*
+	Host of a node transport address.  Elasticsearch 1.x reports addresses as inet[/<ip>:<port>]
+	while later versions and OpenSearch report <ip>:<port>.
+*/
+func transportHost(addr string) string {
+	if i := strings.Index(addr, "/"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	hostPort := strings.Split(strings.TrimSuffix(addr, "]"), ":")
+	if len(hostPort) < 2 {
+		return ""
+	}
+	return hostPort[0]
+}
+
+func GetClusterStatus(conn *simpless.ExtendedEssConn) (cs VindaluClusterStatus, err error) {
 	// Call this manually as I can't seem to get at the info from the framework
 	var b []byte
 	if b, err = conn.DoCommand("GET", "/_cluster/state", nil, nil); err != nil {
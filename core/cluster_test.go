@@ -0,0 +1,18 @@
+package core
+
+import (
+	"testing"
+)
+
+func Test_transportHost(t *testing.T) {
+	addrs := map[string]string{
+		"inet[/10.0.0.1:9300]": "10.0.0.1",
+		"10.0.0.2:9300":        "10.0.0.2",
+		"":                     "",
+	}
+	for addr, host := range addrs {
+		if h := transportHost(addr); h != host {
+			t.Fatalf("%s: expected '%s' got '%s'", addr, host, h)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"io"
+
+	"github.com/vindalu/vindalu/simple-ess"
+	"github.com/vindalu/vindalu/types"
+)
+
+/*
+	Storage backend for assets, their versions and types as well as vindalu specific data such
+	as saved queries, schemas and sequences.  Assets that do not exist are reported with
+	elastigo.RecordNotFound.
+*/
+type IDatastore interface {
+	// Create an asset or, if the version is > 0, the given version of it
+	Create(asset BaseAsset, version int64) (string, error)
+	Get(assetType, assetId string, version int64) (BaseAsset, error)
+	Edit(updatedAsset *BaseAsset, delFields ...string) (string, error)
+	Remove(assetType, assetId string) error
+	Query(assetType string, query map[string]interface{}, opts *types.QueryOptions, versionQuery bool) (interface{}, error)
+
+	GetVersions(assetType, assetId string, count int64) ([]BaseAsset, error)
+	ListAllVersions(assetType, assetId string) ([]BaseAsset, error)
+	LatestVersionTimestamp(assetType string) (float64, error)
+	RemoveVersion(assetType, assetId string, version int64) error
+
+	CreateType(assetType string, opts map[string]interface{}) error
+	UpdateType(assetType string, props map[string]interface{}, meta *AssetTypeMetadata) error
+	GetTypeMetadata(assetType string) (*AssetTypeMetadata, error)
+	RemoveType(assetType string) error
+	TypeExists(assetType string) error
+	ListTypes() ([]ResourceType, error)
+	CountAssets(assetType string) (int64, error)
+	ListTypeProperties(assetType string) ([]string, error)
+	ListTypePropertyDetails(assetType string) ([]PropertyDetail, error)
+	// Mapping type of each field of a type by its dotted path.  Only the mapping is read.
+	TypeFieldTypes(assetType string) (map[string]string, error)
+
+	// Names of the saved queries selecting each asset.  Evaluated with a single request.
+	MatchSavedQueries(queries []SavedQuery, assets []AssetRef) (map[AssetRef][]string, error)
+	FindDuplicates(assetType, assetId string, values map[string]interface{}) ([]string, error)
+	ListDuplicates(assetType string, fields []string) ([]UniqueViolation, error)
+	ListReferrers(target AssetRef) ([]AssetLink, error)
+	// Reserve a unique key for an asset.  Returns the asset holding it if already reserved.
+	ReserveUniqueKey(key string, holder AssetRef) (*AssetRef, error)
+	// Remove the reservation of a unique key if it is held by the given asset
+	ReleaseUniqueKey(key string, holder AssetRef) error
+
+	NextSequence(name string) (int64, error)
+
+	PutTypeSchema(ts AssetTypeSchema) error
+	GetTypeSchema(assetType string) (AssetTypeSchema, error)
+	RemoveTypeSchema(assetType string) error
+
+	PutSavedQuery(sq SavedQuery) error
+	GetSavedQuery(name string) (SavedQuery, error)
+	RemoveSavedQuery(name string) error
+	ListSavedQueries(owner string) ([]SavedQuery, error)
+
+	ClusterStatus() (VindaluClusterStatus, error)
+	// Make recent writes searchable
+	Refresh() error
+	Close() error
+}
+
+// Implemented by datastores that can export and import archives of the complete inventory.
+type Archiver interface {
+	ExportArchive(w io.Writer) (*ArchiveManifest, error)
+	ImportArchive(r io.Reader) (*ArchiveManifest, error)
+}
+
+// Implemented by datastores connecting to elasticsearch through a connection pool.
+type PooledDatastore interface {
+	Pool() *simpless.Pool
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vindalu/vindalu/schema"
+)
+
+// Returned when a user is not allowed to perform the requested operation.
+type AccessDeniedError struct {
+	User   string
+	Reason string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("User '%s' not allowed: %s", e.User, e.Reason)
+}
+
+// Returned when a requested item does not exist.
+type NotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found: %s", e.Kind, e.Name)
+}
+
+// Returned when an operation conflicts with the current state of the data.
+type ConflictError struct {
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Reason
+}
+
+// Returned when an asset does not conform to the schema or enforced field rules of its type.
+type ValidationError struct {
+	AssetType  string             `json:"asset_type"`
+	AssetId    string             `json:"id"`
+	Violations []schema.Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("Validation failed (%s/%s): %s", e.AssetType, e.AssetId, strings.Join(msgs, "; "))
+}
+
+// Returned when a feature is not available with the configured datastore, or not configured
+// at all if the datastore is not set.
+type NotSupportedError struct {
+	Feature   string
+	Datastore string
+}
+
+func (e *NotSupportedError) Error() string {
+	if len(e.Datastore) == 0 {
+		return fmt.Sprintf("%s not configured", e.Feature)
+	}
+	return fmt.Sprintf("%s not supported by the '%s' datastore", e.Feature, e.Datastore)
+}
+
+// Returned when an operation is temporarily unavailable i.e. writes while reindexing.
+type UnavailableError struct {
+	Reason string
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Reason
+}
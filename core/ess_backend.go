@@ -3,6 +3,9 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 
 	elastigo "github.com/mattbaird/elastigo/lib"
 	"github.com/nats-io/gnatsd/server"
@@ -12,12 +15,81 @@ import (
 	"github.com/vindalu/vindalu/types"
 )
 
+// Mappings for document types in the meta index.  User supplied data such as saved query filters and
+// schemas are stored but not indexed as their values can be of any type.
+var META_MAPPINGS = map[string]string{
+	META_TYPE_SAVED_QUERY: `{
+		"properties": {
+			"name":       {"type": "string", "index": "not_analyzed"},
+			"asset_type": {"type": "string", "index": "not_analyzed"},
+			"owner":      {"type": "string", "index": "not_analyzed"},
+			"query":      {"type": "object", "enabled": false},
+			"options":    {"type": "object", "enabled": false}
+		}
+	}`,
+	META_TYPE_SCHEMA: `{
+		"properties": {
+			"asset_type": {"type": "string", "index": "not_analyzed"},
+			"updated_by": {"type": "string", "index": "not_analyzed"},
+			"schema":     {"type": "object", "enabled": false}
+		}
+	}`,
+	META_TYPE_SEQUENCE: `{
+		"_all": {"enabled": false},
+		"properties": {
+			"name": {"type": "string", "index": "no"}
+		}
+	}`,
+	META_TYPE_UNIQUE_KEY: `{
+		"_all": {"enabled": false},
+		"properties": {
+			"asset_type": {"type": "string", "index": "not_analyzed"},
+			"asset_id":   {"type": "string", "index": "not_analyzed"}
+		}
+	}`,
+}
+
 type EssDatastoreConfig struct {
 	Host         string `json:"host"`
 	Port         int    `json:"port"`
 	Index        string `json:"index"`
 	VersionIndex string
+	MetaIndex    string
 	MappingsDir  string `json:"mappings_dir"` // Holds mappings per type. One file per `type`
+	// Max value of from + size of a search.  Only used by the typeless datastore.
+	MaxResultWindow int64 `json:"max_result_window,omitempty"`
+
+	// host:port or url of each node.  Replaces host and port.
+	Hosts    []string      `json:"hosts,omitempty"`
+	Username string        `json:"username,omitempty"`
+	Password string        `json:"password,omitempty"`
+	TLS      *EssTLSConfig `json:"tls,omitempty"`
+	// Durations i.e. 30s
+	Timeout             string `json:"timeout,omitempty"`
+	ConnectTimeout      string `json:"connect_timeout,omitempty"`
+	RetryBackoff        string `json:"retry_backoff,omitempty"`
+	HealthCheckInterval string `json:"health_check_interval,omitempty"`
+	// Retries of idempotent requests.  Disabled if negative.
+	MaxRetries int `json:"max_retries,omitempty"`
+}
+
+// Parse the datastore config setting the version and meta index.  The parsed config is assigned
+// back to the global config.
+func parseEssDatastoreConfig(datastoreCfg *config.DatastoreConfig) (cfg EssDatastoreConfig, err error) {
+	var b []byte
+	if b, err = json.Marshal(datastoreCfg.Config); err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &cfg); err != nil {
+		return
+	}
+	// Set version and meta index in config
+	cfg.VersionIndex = cfg.Index + "_versions"
+	cfg.MetaIndex = cfg.Index + "_meta"
+
+	// Assign type config back to global config
+	datastoreCfg.Config = cfg
+	return
 }
 
 type ElasticsearchDatastore struct {
@@ -30,31 +102,33 @@ type ElasticsearchDatastore struct {
 	// Index + _version
 	VersionIndex string
 
+	// Index + _meta.  Holds vindalu specific data such as saved queries.
+	MetaIndex string
+
+	// Mapping files applied to the primary and version indices
+	MappingsDir string
+
 	log server.Logger
 }
 
 // Create the index if it does not exist. Optionally apply a mapping if mapping file is supplied.
 // Also initialize the version index.
 func NewElasticsearchDatastore(datastoreCfg *config.DatastoreConfig, log server.Logger) (*ElasticsearchDatastore, error) {
-	b, err := json.Marshal(datastoreCfg.Config)
+	cfg, err := parseEssDatastoreConfig(datastoreCfg)
 	if err != nil {
 		return nil, err
 	}
-
-	var cfg EssDatastoreConfig
-	if err = json.Unmarshal(b, &cfg); err != nil {
+	pool, err := newEssPool(cfg, log)
+	if err != nil {
 		return nil, err
 	}
-	// Set version index in config
-	cfg.VersionIndex = cfg.Index + "_versions"
-
-	// Assign type config back to global config
-	datastoreCfg.Config = cfg
 
 	ed := ElasticsearchDatastore{
-		Conn:         simpless.NewExtendedEssConn(cfg.Host, cfg.Port),
+		Conn:         simpless.NewExtendedEssConn(pool),
 		Index:        cfg.Index,
 		VersionIndex: cfg.VersionIndex,
+		MetaIndex:    cfg.MetaIndex,
+		MappingsDir:  cfg.MappingsDir,
 		log:          log,
 	}
 
@@ -63,9 +137,19 @@ func NewElasticsearchDatastore(datastoreCfg *config.DatastoreConfig, log server.
 			return nil, err
 		}
 	}
+	// Checked separately as it may not exist for indexes created by older versions.
+	if !ed.Conn.IndexExists(ed.MetaIndex) {
+		if err = ed.initializeMetaIndex(); err != nil {
+			return nil, err
+		}
+	}
+	// Apply meta mappings as types may have been added.
+	if err = ed.applyMetaMappings(); err != nil {
+		return nil, err
+	}
 
-	ed.log.Noticef("Elasticsearch (%s): %s:%d/%s\n", ed.Index, cfg.Host, cfg.Port, cfg.Index)
-	ed.log.Noticef("Elasticsearch (%s): %s:%d/%s\n", ed.VersionIndex, cfg.Host, cfg.Port, ed.VersionIndex)
+	ed.log.Noticef("Elasticsearch (%s): %s\n", ed.Index, strings.Join(pool.Hosts(), ","))
+	ed.log.Noticef("Elasticsearch (%s): %s\n", ed.VersionIndex, strings.Join(pool.Hosts(), ","))
 
 	// Apply mapping as they may have been updated.
 	if err = ed.Conn.ApplyMappingDir(ed.Index, cfg.MappingsDir, true); err != nil {
@@ -161,10 +245,18 @@ func (e *ElasticsearchDatastore) Query(rtype string, query map[string]interface{
 	// Lookup against versions table
 	if versionQuery {
 		index2use = e.VersionIndex
+		// Version ids are suffixed with the version number
+		translateVersionIdField(query)
 	} else {
 		index2use = e.Index
 	}
 
+	if err = translateIpRangeFields(query, func() (map[string]string, error) {
+		return e.TypeFieldTypes(rtype)
+	}); err != nil {
+		return nil, err
+	}
+
 	essQuery, err := buildElasticsearchQuery(index2use, query, opts)
 	if err != nil {
 		return nil, err
@@ -174,13 +266,30 @@ func (e *ElasticsearchDatastore) Query(rtype string, query map[string]interface{
 
 	// Aggregate queries
 	if _, ok := essQuery["aggs"]; ok {
-		rslt, err = e.execAggrQuery(index2use, rtype, opts.Aggregate, essQuery)
+		var items []AggregatedItem
+		if items, err = e.execAggrQuery(index2use, rtype, opts.Aggregate, essQuery); err != nil {
+			return nil, err
+		}
+		if opts.Subnet > 0 {
+			items, err = aggregateBySubnet(items, int(opts.Subnet), opts.Size)
+		}
+		rslt = items
 	} else {
 		var srchRslt elastigo.SearchResult
 		if srchRslt, err = e.Conn.Search(index2use, rtype, DEFAULT_FIELDS, essQuery); err != nil {
 			return nil, err
 		}
-		rslt, err = assembleAssetsFromHits(srchRslt.Hits.Hits)
+
+		var assets []BaseAsset
+		if assets, err = assembleAssetsFromHits(srchRslt.Hits.Hits); err != nil {
+			return nil, err
+		}
+		if versionQuery {
+			for i, a := range assets {
+				assets[i].Id = assetIdFromVersionId(a.Id)
+			}
+		}
+		rslt = assets
 	}
 	return
 }
@@ -218,6 +327,108 @@ func (e *ElasticsearchDatastore) GetVersions(assetType, assetId string, count in
 	return append([]BaseAsset{curr}, vAssets...), nil
 }
 
+// All versions of an asset in ascending order.  Versions are scrolled through so none are left out.
+func (e *ElasticsearchDatastore) ListAllVersions(assetType, assetId string) ([]BaseAsset, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"regexp": map[string]interface{}{"_id": escapeRegex(assetId) + `\.[0-9]+`},
+		},
+		"sort": map[string]interface{}{"version": "asc"},
+	}
+
+	versions := []BaseAsset{}
+	err := e.scroll(e.VersionIndex, assetType, DEFAULT_FIELDS, query, func(h elastigo.Hit) error {
+		v, err := assembleAssetFromHit(h)
+		if err == nil {
+			versions = append(versions, v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+/*
+	Call fn for every hit of a search of a type, or of all types if empty, using the scroll api.
+	Unlike a scan the order of the query is kept.
+*/
+func (e *ElasticsearchDatastore) scroll(index, assetType string, args, query map[string]interface{}, fn func(elastigo.Hit) error) error {
+	path := fmt.Sprintf("/%s/_search", index)
+	if len(assetType) > 0 {
+		path = fmt.Sprintf("/%s/%s/_search", index, assetType)
+	}
+	scrollArgs := map[string]interface{}{"scroll": ARCHIVE_SCROLL_TIMEOUT}
+	for k, v := range args {
+		scrollArgs[k] = v
+	}
+	body := map[string]interface{}{"size": ARCHIVE_BATCH_SIZE}
+	for k, v := range query {
+		body[k] = v
+	}
+
+	var rslt struct {
+		ScrollId string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []elastigo.Hit `json:"hits"`
+		} `json:"hits"`
+	}
+	b, err := e.Conn.DoCommand("POST", path, scrollArgs, body)
+
+	for err == nil {
+		rslt.Hits.Hits = nil
+		if err = json.Unmarshal(b, &rslt); err != nil || len(rslt.Hits.Hits) == 0 {
+			break
+		}
+		for _, h := range rslt.Hits.Hits {
+			if err = fn(h); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			b, err = e.Conn.DoCommand("POST", "/_search/scroll",
+				map[string]interface{}{"scroll": ARCHIVE_SCROLL_TIMEOUT}, rslt.ScrollId)
+		}
+	}
+
+	// Free the scroll context rather than waiting for it to expire
+	if len(rslt.ScrollId) > 0 {
+		e.Conn.DoCommand("DELETE", "/_search/scroll", nil, rslt.ScrollId)
+	}
+	return err
+}
+
+/*
+	Timestamp (ms) of the most recent version of any asset of the type, or of all types if the type
+	is empty.  Deleted assets also leave a version so this changes on every write.  0 if there are
+	no versions.
+*/
+func (e *ElasticsearchDatastore) LatestVersionTimestamp(assetType string) (float64, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"match_all": map[string]interface{}{}},
+		"sort":  map[string]interface{}{"_timestamp": "desc"},
+		"size":  1,
+	}
+
+	resp, err := e.Conn.Search(e.VersionIndex, assetType, DEFAULT_FIELDS, query)
+	if err != nil {
+		return 0, err
+	}
+	versions, err := assembleAssetsFromHits(resp.Hits.Hits)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	ts, _ := versions[0].Timestamp.(float64)
+	return ts, nil
+}
+
+// Remove a single version of an asset
+func (e *ElasticsearchDatastore) RemoveVersion(assetType, assetId string, version int64) error {
+	_, err := e.Conn.Delete(e.VersionIndex, assetType, fmt.Sprintf("%s.%d", assetId, version), nil)
+	return err
+}
+
 // Create a type with optional property definitions
 func (e *ElasticsearchDatastore) CreateType(assetType string, opts map[string]interface{}) (err error) {
 	var mapping []byte
@@ -248,12 +459,69 @@ func (e *ElasticsearchDatastore) CreateType(assetType string, opts map[string]in
 	return
 }
 
+// Update a type adding new property definitions and setting the metadata.  Existing property
+// definitions cannot be changed.
+func (e *ElasticsearchDatastore) UpdateType(assetType string, props map[string]interface{}, meta *AssetTypeMetadata) (err error) {
+	var mapping []byte
+	if mapping, err = json.Marshal(map[string]interface{}{
+		"properties": props,
+		"_meta":      meta.mappingMeta(),
+	}); err != nil {
+		return
+	}
+	if err = e.Conn.PutMappingFromJSON(e.Index, assetType, mapping); err != nil {
+		return
+	}
+
+	if len(props) > 0 {
+		if mapping, err = json.Marshal(map[string]interface{}{"properties": props}); err != nil {
+			return
+		}
+		err = e.Conn.PutMappingFromJSON(e.VersionIndex, assetType, mapping)
+	}
+	return
+}
+
+// Metadata for a type.  nil is returned if the type does not have any.
+func (e *ElasticsearchDatastore) GetTypeMetadata(assetType string) (*AssetTypeMetadata, error) {
+	typeMap, err := e.Conn.GetTypeMapping(e.Index, assetType)
+	if err != nil {
+		return nil, err
+	}
+	return metadataFromMapping(typeMap.Meta), nil
+}
+
+// Remove the type mapping.  The version index is left as is to retain the history.
+func (e *ElasticsearchDatastore) RemoveType(assetType string) (err error) {
+	_, err = e.Conn.DoCommand("DELETE", fmt.Sprintf("/%s/_mapping/%s", e.Index, assetType), nil, nil)
+	return
+}
+
+// Number of assets of the given type
+func (e *ElasticsearchDatastore) CountAssets(assetType string) (int64, error) {
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/%s/%s/_count", e.Index, assetType), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	err = json.Unmarshal(b, &resp)
+	return resp.Count, err
+}
+
 func (e *ElasticsearchDatastore) TypeExists(assetType string) error {
 	list, err := e.ListTypes()
 	if err != nil {
 		return err
 	}
 
+	return checkTypeListed(list, assetType)
+}
+
+// Error if the type is not in the list of types
+func checkTypeListed(list []ResourceType, assetType string) error {
 	for _, vt := range list {
 		if vt.Name == assetType {
 			return nil
@@ -267,6 +535,122 @@ func (e *ElasticsearchDatastore) ListTypeProperties(ptype string) ([]string, err
 	return e.Conn.GetPropertiesForType(e.Index, ptype)
 }
 
+/*
+	Mapping type of each field of a type by its dotted path.  With no type the fields of all
+	types are included, a field mapped as `ip` by any type being listed as such.
+*/
+func (e *ElasticsearchDatastore) TypeFieldTypes(assetType string) (map[string]string, error) {
+	assetTypes := []string{assetType}
+	if len(assetType) == 0 {
+		list, err := e.ListTypes()
+		if err != nil {
+			return nil, err
+		}
+		assetTypes = make([]string, len(list))
+		for i, rt := range list {
+			assetTypes[i] = rt.Name
+		}
+	}
+
+	fieldTypes := map[string]string{}
+	for _, t := range assetTypes {
+		propMap, err := e.Conn.GetPropertyMappingsForType(e.Index, t)
+		if err != nil {
+			return nil, err
+		}
+		types := map[string]string{}
+		flattenMappingTypes(propMap, "", types)
+		for field, ft := range types {
+			if fieldTypes[field] != "ip" {
+				fieldTypes[field] = ft
+			}
+		}
+	}
+	return fieldTypes, nil
+}
+
+// Detailed property information for a given type. Fill rate and cardinality are computed
+// with a single aggregation query across all assets of the type.
+func (e *ElasticsearchDatastore) ListTypePropertyDetails(ptype string) (details []PropertyDetail, err error) {
+	var propMap map[string]interface{}
+	if propMap, err = e.Conn.GetPropertyMappingsForType(e.Index, ptype); err != nil {
+		return
+	}
+
+	names := make([]string, 0, len(propMap))
+	for k, _ := range propMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	query := map[string]interface{}{"size": 0}
+	if aggs := propertyStatsAggs(names, propMap); len(aggs) > 0 {
+		query["aggs"] = aggs
+	}
+
+	var resp elastigo.SearchResult
+	if resp, err = e.Conn.Search(e.Index, ptype, nil, query); err != nil {
+		return
+	}
+	return propertyDetails(names, propMap, resp.Aggregations, int64(resp.Hits.Total))
+}
+
+// Fill rate and cardinality aggregations for each of the properties
+func propertyStatsAggs(names []string, propMap map[string]interface{}) map[string]interface{} {
+	// Aggregation names are index based as property names may contain reserved characters.
+	aggs := map[string]interface{}{}
+	for i, name := range names {
+		aggs[fmt.Sprintf("filled_%d", i)] = map[string]interface{}{
+			"filter": map[string]interface{}{"exists": map[string]string{"field": name}},
+		}
+		// Cardinality is not available on object or analyzed text fields
+		if pt := propertyMappingType(propMap[name]); pt != "object" && pt != "text" {
+			aggs[fmt.Sprintf("cardinality_%d", i)] = map[string]interface{}{
+				"cardinality": map[string]string{"field": name},
+			}
+		}
+	}
+	return aggs
+}
+
+// Property details from the response to the propertyStatsAggs aggregations
+func propertyDetails(names []string, propMap map[string]interface{}, aggregations []byte, total int64) (details []PropertyDetail, err error) {
+	var aggrs map[string]simpless.AggrMetric
+	if len(aggregations) > 0 {
+		if err = json.Unmarshal(aggregations, &aggrs); err != nil {
+			return
+		}
+	}
+
+	details = []PropertyDetail{
+		PropertyDetail{Name: "id", Type: "string", FillRate: fillRate(total, total), Cardinality: total},
+		PropertyDetail{Name: "timestamp", Type: "date", FillRate: fillRate(total, total)},
+	}
+	for i, name := range names {
+		details = append(details, PropertyDetail{
+			Name:        name,
+			Type:        propertyMappingType(propMap[name]),
+			FillRate:    fillRate(aggrs[fmt.Sprintf("filled_%d", i)].DocCount, total),
+			Cardinality: aggrs[fmt.Sprintf("cardinality_%d", i)].Value,
+		})
+	}
+	return
+}
+
+func (e *ElasticsearchDatastore) Pool() *simpless.Pool {
+	return e.Conn.Pool
+}
+
+func (e *ElasticsearchDatastore) ClusterStatus() (VindaluClusterStatus, error) {
+	return GetClusterStatus(e.Conn)
+}
+
+// Refresh the primary index making recent writes searchable.
+func (e *ElasticsearchDatastore) Refresh() error {
+	_, err := e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_refresh", e.Index), nil, nil)
+	return err
+}
+
 func (e *ElasticsearchDatastore) Close() error {
 	e.Conn.Close()
 	return nil
@@ -302,22 +686,409 @@ func (ds *ElasticsearchDatastore) getAssetRaw(index, assetType, assetId string,
 	return
 }
 
-// Initialize primary index and version index
+/*
+	Initialize primary index and version index.  Both are addressed through aliases of the
+	configured names so they can be reindexed.
+*/
 func (e *ElasticsearchDatastore) initializeIndex() error {
-	resp, err := e.Conn.CreateIndex(e.Index)
+	for _, alias := range []string{e.Index, e.VersionIndex} {
+		index := versionedIndexName(alias, 1)
+		if err := e.createIndex(index, nil, alias); err != nil {
+			return err
+		}
+		e.log.Noticef("Index created: %s (alias: %s)\n", index, alias)
+	}
+	return nil
+}
+
+// Initialize the meta index.
+func (e *ElasticsearchDatastore) initializeMetaIndex() error {
+	resp, err := e.Conn.CreateIndex(e.MetaIndex)
 	if err != nil {
 		return err
 	}
-	e.log.Noticef("Index created: %s %s\n", e.Index, resp)
+	e.log.Noticef("Meta index created: %s %s\n", e.MetaIndex, resp)
+	return nil
+}
+
+// Apply the mapping for each document type in the meta index.
+func (e *ElasticsearchDatastore) applyMetaMappings() error {
+	for metaType, mapping := range META_MAPPINGS {
+		if err := e.Conn.PutMappingFromJSON(e.MetaIndex, metaType,
+			[]byte(fmt.Sprintf(`{"%s":%s}`, metaType, mapping))); err != nil {
+			return fmt.Errorf("Failed to apply meta mapping (%s): %s", metaType, err)
+		}
+	}
+	return nil
+}
 
-	// Versioning index
-	resp, err = e.Conn.CreateIndex(e.VersionIndex)
+// Store a document in the meta index under the given meta type.
+func (e *ElasticsearchDatastore) putMetaDoc(metaType, id string, doc interface{}) (err error) {
+	_, err = e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, metaType, id), nil, doc)
+	return
+}
+
+// Get a document from the meta index unmarshalling the source into `doc`.  RecordNotFound is returned
+// if the document does not exist.
+func (e *ElasticsearchDatastore) getMetaDoc(metaType, id string, doc interface{}) (err error) {
+	var b []byte
+	if b, err = e.Conn.DoCommand("GET", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, metaType, id), nil, nil); err != nil {
+		return
+	}
+
+	var hit elastigo.Hit
+	if err = json.Unmarshal(b, &hit); err != nil {
+		return
+	}
+	if hit.Source == nil {
+		return elastigo.RecordNotFound
+	}
+	return json.Unmarshal(*hit.Source, doc)
+}
+
+func (e *ElasticsearchDatastore) removeMetaDoc(metaType, id string) (err error) {
+	_, err = e.Conn.DoCommand("DELETE", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, metaType, id), nil, nil)
+	return
+}
+
+// List the raw sources of documents of a given meta type optionally matching a query.
+func (e *ElasticsearchDatastore) listMetaDocs(metaType string, query map[string]interface{}, size int64) (docs []*json.RawMessage, err error) {
+	if query == nil {
+		query = map[string]interface{}{}
+	}
+	query["size"] = size
+
+	var resp elastigo.SearchResult
+	if resp, err = e.Conn.Search(e.MetaIndex, metaType, nil, query); err != nil {
+		return
+	}
+
+	docs = make([]*json.RawMessage, len(resp.Hits.Hits))
+	for i, h := range resp.Hits.Hits {
+		docs[i] = h.Source
+	}
+	return
+}
+
+/*
+	Next value of a named sequence starting at 1.  The sequence document is re-indexed and the
+	document version, which elasticsearch increments atomically, is used as the value.
+*/
+func (e *ElasticsearchDatastore) NextSequence(name string) (int64, error) {
+	// Not retried as a retry after a lost response would skip a value
+	b, err := e.Conn.DoCommandWithOptions("PUT", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, META_TYPE_SEQUENCE, name),
+		nil, map[string]string{"name": name}, simpless.RequestOptions{NoRetry: true})
 	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Version int64 `json:"_version"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+/*
+	Reserve a unique key for an asset.  The reservation is created only if it does not exist so
+	only one asset can hold it.  Returns the asset holding it if already reserved.
+*/
+func (e *ElasticsearchDatastore) ReserveUniqueKey(key string, holder AssetRef) (*AssetRef, error) {
+	_, err := e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, META_TYPE_UNIQUE_KEY, key),
+		map[string]interface{}{"op_type": "create"}, UniqueKeyReservation{AssetType: holder.Type, AssetId: holder.Id})
+	if esErr, ok := err.(elastigo.ESError); !ok || esErr.Code != 409 {
+		return nil, err
+	}
+
+	var r UniqueKeyReservation
+	if err = e.getMetaDoc(META_TYPE_UNIQUE_KEY, key, &r); err != nil {
+		return nil, err
+	}
+	current := r.Holder()
+	return &current, nil
+}
+
+// Remove the reservation of a unique key if it is still held by the given asset
+func (e *ElasticsearchDatastore) ReleaseUniqueKey(key string, holder AssetRef) error {
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, META_TYPE_UNIQUE_KEY, key), nil, nil)
+	if err == elastigo.RecordNotFound {
+		return nil
+	} else if err != nil {
 		return err
 	}
-	e.log.Noticef("Version index created: %s %s\n", e.Index, resp)
 
-	return nil
+	var hit struct {
+		Version int64                 `json:"_version"`
+		Source  *UniqueKeyReservation `json:"_source"`
+	}
+	if err = json.Unmarshal(b, &hit); err != nil {
+		return err
+	}
+	if hit.Source == nil || hit.Source.Holder() != holder {
+		return nil
+	}
+
+	// Only removed if not reserved again in the meantime
+	_, err = e.Conn.DoCommand("DELETE", fmt.Sprintf("/%s/%s/%s", e.MetaIndex, META_TYPE_UNIQUE_KEY, key),
+		map[string]interface{}{"version": hit.Version}, nil)
+	if esErr, ok := err.(elastigo.ESError); (ok && esErr.Code == 409) || err == elastigo.RecordNotFound {
+		return nil
+	}
+	return err
+}
+
+func (e *ElasticsearchDatastore) PutTypeSchema(ts AssetTypeSchema) error {
+	return e.putMetaDoc(META_TYPE_SCHEMA, ts.AssetType, ts)
+}
+
+func (e *ElasticsearchDatastore) GetTypeSchema(assetType string) (ts AssetTypeSchema, err error) {
+	if err = e.getMetaDoc(META_TYPE_SCHEMA, assetType, &ts); err == elastigo.RecordNotFound {
+		err = &NotFoundError{Kind: "Schema", Name: assetType}
+	}
+	return
+}
+
+func (e *ElasticsearchDatastore) RemoveTypeSchema(assetType string) (err error) {
+	if err = e.removeMetaDoc(META_TYPE_SCHEMA, assetType); err == elastigo.RecordNotFound {
+		err = &NotFoundError{Kind: "Schema", Name: assetType}
+	}
+	return
+}
+
+func (e *ElasticsearchDatastore) PutSavedQuery(sq SavedQuery) error {
+	return e.putMetaDoc(META_TYPE_SAVED_QUERY, sq.Name, sq)
+}
+
+func (e *ElasticsearchDatastore) GetSavedQuery(name string) (sq SavedQuery, err error) {
+	if err = e.getMetaDoc(META_TYPE_SAVED_QUERY, name, &sq); err == elastigo.RecordNotFound {
+		err = &NotFoundError{Kind: "Saved query", Name: name}
+	}
+	return
+}
+
+func (e *ElasticsearchDatastore) RemoveSavedQuery(name string) error {
+	return e.removeMetaDoc(META_TYPE_SAVED_QUERY, name)
+}
+
+// List saved queries optionally filtered by owner.  Results are sorted by name.
+func (e *ElasticsearchDatastore) ListSavedQueries(owner string) (list []SavedQuery, err error) {
+	query := map[string]interface{}{
+		"sort": []map[string]string{map[string]string{"name": "asc"}},
+	}
+	if len(owner) > 0 {
+		query["query"] = map[string]interface{}{
+			"term": map[string]string{"owner": owner},
+		}
+	}
+
+	var docs []*json.RawMessage
+	if docs, err = e.listMetaDocs(META_TYPE_SAVED_QUERY, query, MAX_SAVED_QUERIES); err != nil {
+		return
+	}
+
+	list = make([]SavedQuery, len(docs))
+	for i, d := range docs {
+		if err = json.Unmarshal(*d, &list[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Names of the saved queries selecting each asset.  Evaluated with a single multi search.
+func (e *ElasticsearchDatastore) MatchSavedQueries(queries []SavedQuery, assets []AssetRef) (map[AssetRef][]string, error) {
+	return matchSavedQueryPairs(e.Conn, e.log, savedQueryPairs(queries, assets), func(p savedQueryPair) (multiSearchItem, error) {
+		req := copyQuery(p.Query.Query)
+		translateIdField(req)
+		if err := translateIpRangeFields(req, func() (map[string]string, error) {
+			return e.TypeFieldTypes(p.Asset.Type)
+		}); err != nil {
+			return multiSearchItem{}, err
+		}
+
+		filters, err := translateLabelSelector(req)
+		if err != nil {
+			return multiSearchItem{}, err
+		}
+		filters = append(filters, map[string]interface{}{
+			"ids": map[string]interface{}{"values": []string{p.Asset.Id}},
+		})
+
+		essQuery, err := buildElasticsearchBaseQuery(e.Index, req, filters...)
+		if err != nil {
+			return multiSearchItem{}, err
+		}
+		return multiSearchItem{Header: map[string]interface{}{"index": e.Index, "type": p.Asset.Type}, Query: essQuery}, nil
+	})
+}
+
+// Ids of assets, other than the given one, with the same values for all the given fields.
+func (e *ElasticsearchDatastore) FindDuplicates(assetType, assetId string, values map[string]interface{}) (ids []string, err error) {
+	filters := make([]interface{}, 0, len(values))
+	for k, v := range values {
+		if arr, ok := v.([]interface{}); ok {
+			filters = append(filters, map[string]interface{}{"terms": map[string]interface{}{k: arr}})
+		} else {
+			filters = append(filters, map[string]interface{}{"term": map[string]interface{}{k: v}})
+		}
+	}
+
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"filtered": map[string]interface{}{
+				"filter": map[string]interface{}{
+					"bool": map[string]interface{}{
+						"must": filters,
+						"must_not": map[string]interface{}{
+							"ids": map[string]interface{}{"values": []string{assetId}},
+						},
+					},
+				},
+			},
+		},
+		"_source": false,
+		"size":    MAX_UNIQUE_VIOLATION_IDS,
+	}
+
+	var resp elastigo.SearchResult
+	if resp, err = e.Conn.Search(e.Index, assetType, nil, query); err != nil {
+		return
+	}
+
+	ids = make([]string, len(resp.Hits.Hits))
+	for i, h := range resp.Hits.Hits {
+		ids[i] = h.Id
+	}
+	return
+}
+
+// Links from other assets referencing the given asset.  All referrers are scrolled through.
+func (e *ElasticsearchDatastore) ListReferrers(target AssetRef) (links []AssetLink, err error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  target.String(),
+				"fields": []string{LINKS_FIELD + ".*"},
+			},
+		},
+		"_source": LINKS_FIELD,
+	}
+
+	links = []AssetLink{}
+	err = e.scroll(e.Index, "", nil, query, func(h elastigo.Hit) error {
+		var data map[string]interface{}
+		if h.Source == nil {
+			return nil
+		}
+		if err := json.Unmarshal(*h.Source, &data); err != nil {
+			return err
+		}
+
+		srcLinks, _ := parseAssetLinks(AssetRef{Type: h.Type, Id: h.Id}, data)
+		for _, l := range srcLinks {
+			if l.Target == target {
+				links = append(links, l)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Nested terms aggregation bucket used to find duplicate values
+type duplicatesAggrBucket struct {
+	Key         interface{} `json:"key"`
+	KeyAsString string      `json:"key_as_string"`
+	DocCount    int64       `json:"doc_count"`
+	// Buckets of the next field. nil for the last field.
+	Values *struct {
+		Buckets []duplicatesAggrBucket `json:"buckets"`
+	} `json:"values"`
+	Assets *struct {
+		Hits struct {
+			Hits []struct {
+				Id string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	} `json:"assets"`
+}
+
+// Values of the given fields shared by more than one asset of the type.
+func (e *ElasticsearchDatastore) ListDuplicates(assetType string, fields []string) (dups []UniqueViolation, err error) {
+	if len(fields) == 0 {
+		return []UniqueViolation{}, nil
+	}
+
+	query := map[string]interface{}{"size": 0, "aggs": duplicatesAggrQuery(fields)}
+
+	var resp elastigo.SearchResult
+	if resp, err = e.Conn.Search(e.Index, assetType, nil, query); err != nil {
+		return
+	}
+	return parseDuplicatesAggr(fields, resp.Aggregations)
+}
+
+/*
+	Nested terms aggregations for the given fields.  Each field is aggregated within the buckets
+	of the previous field only keeping buckets with at least 2 assets.
+*/
+func duplicatesAggrQuery(fields []string) map[string]interface{} {
+	aggs := map[string]interface{}{
+		"assets": map[string]interface{}{
+			"top_hits": map[string]interface{}{"size": MAX_UNIQUE_VIOLATION_IDS, "_source": false},
+		},
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		aggs = map[string]interface{}{
+			"values": map[string]interface{}{
+				"terms": map[string]interface{}{"field": fields[i], "size": 0, "min_doc_count": 2},
+				"aggs":  aggs,
+			},
+		}
+	}
+	return aggs
+}
+
+// Flatten the nested aggregation response into the duplicate values of the fields
+func parseDuplicatesAggr(fields []string, aggr []byte) (dups []UniqueViolation, err error) {
+	dups = []UniqueViolation{}
+	if len(aggr) == 0 {
+		return
+	}
+
+	var root duplicatesAggrBucket
+	if err = json.Unmarshal(aggr, &root); err != nil {
+		return
+	}
+
+	var walk func(bucket duplicatesAggrBucket, values []interface{})
+	walk = func(bucket duplicatesAggrBucket, values []interface{}) {
+		if bucket.Values == nil {
+			uv := UniqueViolation{Fields: fields, Values: values, Count: bucket.DocCount, Ids: []string{}}
+			if bucket.Assets != nil {
+				for _, h := range bucket.Assets.Hits.Hits {
+					uv.Ids = append(uv.Ids, h.Id)
+				}
+			}
+			dups = append(dups, uv)
+			return
+		}
+
+		for _, b := range bucket.Values.Buckets {
+			var val interface{} = b.Key
+			if len(b.KeyAsString) > 0 {
+				val = b.KeyAsString
+			}
+			walk(b, append(append([]interface{}{}, values...), val))
+		}
+	}
+	walk(root, []interface{}{})
+	return
 }
 
 func (e *ElasticsearchDatastore) ListTypes() (typeList []ResourceType, err error) {
@@ -327,7 +1098,7 @@ func (e *ElasticsearchDatastore) ListTypes() (typeList []ResourceType, err error
 			"aggs": buildElasticsearchAggregateQuery("_type", MAX_ASSET_TYPES),
 		}
 		mapBytes  []byte
-		mapping   map[string]map[string]map[string]interface{}
+		mapping   map[string]map[string]map[string]simpless.EssTypeMapping
 		aggrItems []AggregatedItem
 	)
 
@@ -342,22 +1113,31 @@ func (e *ElasticsearchDatastore) ListTypes() (typeList []ResourceType, err error
 	if err = json.Unmarshal(mapBytes, &mapping); err != nil {
 		return
 	}
-	// Remove types from mapping that are already in the output.
-	propMap := mapping[e.Index]["mappings"]
+	// Keyed by the concrete index behind the alias
+	indices := make([]string, 0, len(mapping))
+	for idx := range mapping {
+		indices = append(indices, idx)
+	}
+	propMap := mapping[simpless.CurrentIndex(e.Index, indices)]["mappings"]
 	delete(propMap, "_default_")
+
+	// Add missing types from map
+	inOutput := map[string]bool{}
 	for _, v := range aggrItems {
-		if _, ok := propMap[v.Name]; ok {
-			delete(propMap, v.Name)
-		}
+		inOutput[v.Name] = true
 	}
-	// Add missing types from map
 	for k, _ := range propMap {
-		aggrItems = append(aggrItems, AggregatedItem{Name: k, Count: 0})
+		if !inOutput[k] {
+			aggrItems = append(aggrItems, AggregatedItem{Name: k, Count: 0})
+		}
 	}
 
 	typeList = make([]ResourceType, len(aggrItems))
 	for i, v := range aggrItems {
-		typeList[i] = ResourceType{v}
+		typeList[i] = ResourceType{AggregatedItem: v}
+		if typeMap, ok := propMap[v.Name]; ok {
+			typeList[i].Metadata = metadataFromMapping(typeMap.Meta)
+		}
 	}
 
 	return
@@ -366,15 +1146,24 @@ func (e *ElasticsearchDatastore) ListTypes() (typeList []ResourceType, err error
 // Execute an aggregation query on a given property.
 func (ds *ElasticsearchDatastore) execAggrQuery(index, assetType, field string, aggsQuery interface{}) (items []AggregatedItem, err error) {
 	var resp elastigo.SearchResult
-	if resp, err = ds.Conn.Search(ds.Index, assetType, nil, aggsQuery); err != nil {
+	if resp, err = ds.Conn.Search(index, assetType, nil, aggsQuery); err != nil {
 		return
 	}
+	return parseAggregatedItems(resp.Aggregations, field)
+}
+
+// Buckets of the terms aggregation on the given field from the aggregations of a search response.
+func parseAggregatedItems(aggregations []byte, field string) (items []AggregatedItem, err error) {
 	// Parse elasticsearch response.
 	var aggr map[string]simpless.AggrField
-	if err = json.Unmarshal(resp.Aggregations, &aggr); err == nil {
+	if err = json.Unmarshal(aggregations, &aggr); err == nil {
 		items = make([]AggregatedItem, len(aggr[field].Buckets))
 		for i, bck := range aggr[field].Buckets {
 			items[i] = AggregatedItem{Count: bck.DocCount}
+			if len(bck.KeyAsString) > 0 {
+				items[i].Name = bck.KeyAsString
+				continue
+			}
 			switch bck.Key.(type) {
 			case string:
 				items[i].Name, _ = bck.Key.(string)
@@ -392,11 +1181,45 @@ func (ds *ElasticsearchDatastore) execAggrQuery(index, assetType, field string,
 				items[i].Name = fmt.Sprintf("%f", number)
 				break
 			default:
-				err = fmt.Errorf("Unknown type: ", bck.Key)
+				err = fmt.Errorf("Unknown type: %v", bck.Key)
 				break
 			}
 		}
-		//ds.log.Noticef("%s\n", resp.Aggregations)
 	}
 	return
 }
+
+// Datastore type of a property from its mapping definition.
+func propertyMappingType(propMapping interface{}) string {
+	m, ok := propMapping.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if t, ok := m["type"].(string); ok {
+		return t
+	}
+	if _, ok := m["properties"]; ok {
+		return "object"
+	}
+	return ""
+}
+
+// Add the mapping type of each property and of the properties of objects by their dotted path
+func flattenMappingTypes(propMap map[string]interface{}, prefix string, types map[string]string) {
+	for name, mapping := range propMap {
+		types[prefix+name] = propertyMappingType(mapping)
+		if m, ok := mapping.(map[string]interface{}); ok {
+			if props, ok := m["properties"].(map[string]interface{}); ok {
+				flattenMappingTypes(props, prefix+name+".", types)
+			}
+		}
+	}
+}
+
+// Percentage of `count` in `total` rounded to 2 decimal places
+func fillRate(count, total int64) float64 {
+	if total < 1 {
+		return 0
+	}
+	return math.Floor(float64(count)*10000/float64(total)+0.5) / 100
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vindalu/vindalu/simple-ess"
+)
+
+func Test_duplicatesAggrQuery(t *testing.T) {
+	b, _ := json.Marshal(duplicatesAggrQuery([]string{"rack", "slot"}))
+
+	var aggs map[string]map[string]interface{}
+	json.Unmarshal(b, &aggs)
+
+	terms, _ := aggs["values"]["terms"].(map[string]interface{})
+	if terms["field"] != "rack" || terms["min_doc_count"] != float64(2) {
+		t.Fatalf("Wrong aggregation: %s", b)
+	}
+	nested, _ := aggs["values"]["aggs"].(map[string]interface{})
+	if _, ok := nested["values"]; !ok {
+		t.Fatalf("Second field should be nested: %s", b)
+	}
+}
+
+func Test_parseDuplicatesAggr(t *testing.T) {
+	aggr := []byte(`{"values": {"buckets": [
+		{"key": "r01", "doc_count": 3, "values": {"buckets": [
+			{"key": 4, "doc_count": 2, "assets": {"hits": {"hits": [{"_id": "a"}, {"_id": "b"}]}}}
+		]}},
+		{"key": 167772161, "key_as_string": "10.0.0.1", "doc_count": 2, "values": {"buckets": []}}
+	]}}`)
+
+	dups, err := parseDuplicatesAggr([]string{"rack", "slot"}, aggr)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(dups) != 1 {
+		t.Fatalf("Wrong violations: %#v", dups)
+	}
+	if dups[0].Values[0] != "r01" || dups[0].Values[1] != float64(4) || dups[0].Count != 2 || len(dups[0].Ids) != 2 {
+		t.Fatalf("Wrong violation: %#v", dups[0])
+	}
+
+	if dups, err = parseDuplicatesAggr([]string{"rack"}, nil); err != nil || len(dups) != 0 {
+		t.Fatalf("Should be empty: %v %v", dups, err)
+	}
+}
+
+func Test_flattenMappingTypes(t *testing.T) {
+	var propMap map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"PrivateIpAddress": {"type": "ip"},
+		"cpu": {"properties": {"count": {"type": "long"}, "model": {"type": "string"}}}
+	}`), &propMap)
+
+	types := map[string]string{}
+	flattenMappingTypes(propMap, "", types)
+	expected := map[string]string{"PrivateIpAddress": "ip", "cpu": "object", "cpu.count": "long", "cpu.model": "string"}
+	if len(types) != len(expected) {
+		t.Fatalf("Wrong types: %v", types)
+	}
+	for k, v := range expected {
+		if types[k] != v {
+			t.Fatalf("Wrong type %s: %s", k, types[k])
+		}
+	}
+}
+
+func newTestEssDatastore(t *testing.T, fe *fakeEss) *ElasticsearchDatastore {
+	p, err := simpless.NewPool(simpless.PoolConfig{Hosts: []string{fe.URL}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return &ElasticsearchDatastore{
+		Conn:         simpless.NewExtendedEssConn(p),
+		Index:        "vindalu",
+		VersionIndex: "vindalu_versions",
+		MetaIndex:    "vindalu_meta",
+		log:          testLogger,
+	}
+}
+
+func Test_ElasticsearchDatastore_ListAllVersions(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestEssDatastore(t, fe)
+	defer ds.Conn.Close()
+
+	// More versions than returned by the first request
+	fe.Pages["POST /vindalu_versions/server/_search"] = []string{`{"_scroll_id": "s1", "hits": {"total": 3, "hits": [
+		{"_id": "web01.1", "_type": "server", "_source": {"version": 1}, "fields": {"_timestamp": 1}},
+		{"_id": "web01.2", "_type": "server", "_source": {"version": 2}, "fields": {"_timestamp": 2}}]}}`}
+	fe.Pages["POST /_search/scroll"] = []string{`{"_scroll_id": "s1", "hits": {"total": 3, "hits": [
+		{"_id": "web01.3", "_type": "server", "_source": {"version": 3}, "fields": {"_timestamp": 3}}]}}`}
+	fe.Responses["POST /_search/scroll"] = `{"_scroll_id": "s1", "hits": {"total": 3, "hits": []}}`
+
+	versions, err := ds.ListAllVersions("server", "web01")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(versions) != 3 || versions[2].GetVersion() != 3 {
+		t.Fatalf("All versions should be listed: %#v", versions)
+	}
+
+	req := fe.request("POST", "/vindalu_versions/server/_search")
+	if req == nil || req.Body["size"] != float64(ARCHIVE_BATCH_SIZE) {
+		t.Fatalf("Wrong search: %#v", req)
+	}
+	if fe.request("DELETE", "/_search/scroll") == nil {
+		t.Fatalf("Scroll not cleared")
+	}
+}
+
+func Test_ElasticsearchDatastore_ListReferrers(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestEssDatastore(t, fe)
+	defer ds.Conn.Close()
+
+	// More referrers than returned by the first request
+	fe.Pages["POST /vindalu/_search"] = []string{`{"_scroll_id": "s1", "hits": {"total": 2, "hits": [
+		{"_id": "web01", "_type": "server", "_source": {"_links": {"pool": "pool/web"}}}]}}`}
+	fe.Pages["POST /_search/scroll"] = []string{`{"_scroll_id": "s1", "hits": {"total": 2, "hits": [
+		{"_id": "web02", "_type": "server", "_source": {"_links": {"pool": "pool/web"}}}]}}`}
+	fe.Responses["POST /_search/scroll"] = `{"_scroll_id": "s1", "hits": {"total": 2, "hits": []}}`
+
+	links, err := ds.ListReferrers(AssetRef{Type: "pool", Id: "web"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(links) != 2 || links[1].Source != (AssetRef{Type: "server", Id: "web02"}) {
+		t.Fatalf("All referrers should be listed: %#v", links)
+	}
+}
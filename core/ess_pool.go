@@ -0,0 +1,106 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/nats-io/gnatsd/server"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/simple-ess"
+)
+
+// TLS settings for connections to elasticsearch
+type EssTLSConfig struct {
+	// PEM encoded CA certificates used to verify the nodes.  The system CAs are used if empty.
+	CAFile string `json:"ca_file,omitempty"`
+	// Client certificate and key
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+func (tc *EssTLSConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: tc.InsecureSkipVerify}
+
+	if len(tc.CAFile) > 0 {
+		b, err := ioutil.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("No certificates found in CA file: %s", tc.CAFile)
+		}
+	}
+
+	if len(tc.CertFile) > 0 || len(tc.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Parse an optional duration setting
+func parseEssDuration(name, value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid datastore %s '%s': %s", name, value, err)
+	}
+	return d, nil
+}
+
+/*
+	Connection pool settings from the datastore config.  `host` and `port` are used if no
+	`hosts` are given.  The password can be loaded from a file i.e. file:///path/to/password.
+*/
+func (cfg *EssDatastoreConfig) poolConfig(log server.Logger) (pc simpless.PoolConfig, err error) {
+	pc = simpless.PoolConfig{
+		Hosts:      cfg.Hosts,
+		Username:   cfg.Username,
+		MaxRetries: cfg.MaxRetries,
+		Log:        log,
+	}
+	if len(pc.Hosts) == 0 {
+		pc.Hosts = []string{fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)}
+	}
+
+	if pc.Password, err = config.GetExternalField(cfg.Password); err != nil {
+		return
+	}
+	if cfg.TLS != nil {
+		if pc.TLS, err = cfg.TLS.tlsConfig(); err != nil {
+			return
+		}
+	}
+
+	if pc.Timeout, err = parseEssDuration("timeout", cfg.Timeout); err != nil {
+		return
+	}
+	if pc.ConnectTimeout, err = parseEssDuration("connect_timeout", cfg.ConnectTimeout); err != nil {
+		return
+	}
+	if pc.RetryBackoff, err = parseEssDuration("retry_backoff", cfg.RetryBackoff); err != nil {
+		return
+	}
+	pc.HealthCheckInterval, err = parseEssDuration("health_check_interval", cfg.HealthCheckInterval)
+	return
+}
+
+func newEssPool(cfg EssDatastoreConfig, log server.Logger) (*simpless.Pool, error) {
+	pc, err := cfg.poolConfig(log)
+	if err != nil {
+		return nil, err
+	}
+	return simpless.NewPool(pc)
+}
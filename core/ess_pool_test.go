@@ -0,0 +1,53 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_EssDatastoreConfig_poolConfig(t *testing.T) {
+	cfg := EssDatastoreConfig{Host: "localhost", Port: 9200}
+	pc, err := cfg.poolConfig(nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(pc.Hosts) != 1 || pc.Hosts[0] != "localhost:9200" || pc.TLS != nil {
+		t.Fatalf("Should default to host and port: %#v", pc)
+	}
+
+	f, _ := ioutil.TempFile("", "vindalu-ess-password")
+	defer os.Remove(f.Name())
+	f.WriteString("secret\n")
+	f.Close()
+
+	cfg = EssDatastoreConfig{
+		Hosts:        []string{"es1:9200", "es2:9200"},
+		Username:     "vindalu",
+		Password:     "file://" + f.Name(),
+		TLS:          &EssTLSConfig{InsecureSkipVerify: true},
+		Timeout:      "10s",
+		MaxRetries:   -1,
+		RetryBackoff: "50ms",
+	}
+	if pc, err = cfg.poolConfig(nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(pc.Hosts) != 2 || pc.Password != "secret" || pc.TLS == nil || !pc.TLS.InsecureSkipVerify {
+		t.Fatalf("Wrong config: %#v", pc)
+	}
+	if pc.Timeout != 10*time.Second || pc.RetryBackoff != 50*time.Millisecond || pc.MaxRetries != -1 {
+		t.Fatalf("Wrong settings: %#v", pc)
+	}
+
+	cfg.Timeout = "10"
+	if _, err = cfg.poolConfig(nil); err == nil {
+		t.Fatalf("Should fail with invalid duration")
+	}
+	cfg.Timeout = ""
+	cfg.TLS = &EssTLSConfig{CAFile: "/nonexistent/ca.pem"}
+	if _, err = cfg.poolConfig(nil); err == nil {
+		t.Fatalf("Should fail with missing CA file")
+	}
+}
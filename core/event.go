@@ -10,6 +10,7 @@ const (
 	EVENT_BASE_TYPE_CREATED EventType = "created"
 	EVENT_BASE_TYPE_UPDATED EventType = "updated"
 	EVENT_BASE_TYPE_DELETED EventType = "deleted"
+	EVENT_BASE_TYPE_RENAMED EventType = "renamed"
 )
 
 type Event struct {
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	elastigo "github.com/mattbaird/elastigo/lib"
+)
+
+// Directions links are followed in when traversing the asset graph
+const (
+	GRAPH_DIRECTION_OUT  = "out"  // links from an asset to the assets it references
+	GRAPH_DIRECTION_IN   = "in"   // links from assets referencing an asset i.e. dependents
+	GRAPH_DIRECTION_BOTH = "both" // links in either direction
+)
+
+// Limits to keep traversals of large inventories cheap.  The graph is marked as truncated when
+// any limit is reached.
+const (
+	MAX_GRAPH_DEPTH = 10
+	// Max number of links followed from a single asset in each direction
+	MAX_GRAPH_FANOUT = 100
+	MAX_GRAPH_NODES  = 1000
+)
+
+// Options for traversing the asset graph
+type GraphOptions struct {
+	Depth     int
+	Direction string
+}
+
+func (opts *GraphOptions) Validate() error {
+	if opts.Depth < 1 || opts.Depth > MAX_GRAPH_DEPTH {
+		return fmt.Errorf("Graph depth must be between 1 and %d", MAX_GRAPH_DEPTH)
+	}
+	switch opts.Direction {
+	case GRAPH_DIRECTION_OUT, GRAPH_DIRECTION_IN, GRAPH_DIRECTION_BOTH:
+		return nil
+	}
+	return fmt.Errorf("Invalid graph direction '%s'. Must be one of: %s, %s, %s", opts.Direction,
+		GRAPH_DIRECTION_OUT, GRAPH_DIRECTION_IN, GRAPH_DIRECTION_BOTH)
+}
+
+type GraphNode struct {
+	Id    AssetRef `json:"id"`
+	Type  string   `json:"type"`
+	Depth int      `json:"depth"`
+	// Referenced asset does not exist
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Assets reachable from a root asset by following links
+type AssetGraph struct {
+	Root      AssetRef    `json:"root"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []AssetLink `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
+
+// Lookups needed to traverse links between assets
+type linkResolver interface {
+	Get(assetType, assetId string, version int64) (BaseAsset, error)
+	ListReferrers(target AssetRef) ([]AssetLink, error)
+}
+
+/*
+	Breadth first traversal of the links starting at the root asset.  Assets are read without any
+	filtering, as with a regular asset read, so every linked asset is included.  Linked assets that
+	do not exist are marked as missing.
+*/
+func traverseLinks(lr linkResolver, root AssetRef, opts GraphOptions) (*AssetGraph, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	rootAsset, err := lr.Get(root.Type, root.Id, 0)
+	if err == elastigo.RecordNotFound {
+		return nil, &NotFoundError{Kind: "Asset", Name: root.String()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	type graphItem struct {
+		node GraphNode
+		data map[string]interface{}
+	}
+
+	var (
+		graph = &AssetGraph{Root: root, Nodes: []GraphNode{}, Edges: []AssetLink{}}
+		seen  = map[AssetRef]bool{root: true}
+		edges = map[AssetLink]bool{}
+		queue = []graphItem{{node: GraphNode{Id: root, Type: root.Type}, data: rootAsset.Data}}
+	)
+
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		node := item.node
+		graph.Nodes = append(graph.Nodes, node)
+
+		if node.Missing || node.Depth >= opts.Depth {
+			continue
+		}
+
+		links, truncated, err := nodeLinks(lr, node.Id, item.data, opts.Direction)
+		if err != nil {
+			return nil, err
+		}
+		graph.Truncated = graph.Truncated || truncated
+
+		for _, l := range links {
+			next := l.Target
+			if next == node.Id {
+				next = l.Source
+			}
+
+			if !seen[next] {
+				if len(seen) >= MAX_GRAPH_NODES {
+					graph.Truncated = true
+					continue
+				}
+				seen[next] = true
+
+				gi := graphItem{node: GraphNode{Id: next, Type: next.Type, Depth: node.Depth + 1}}
+				asset, err := lr.Get(next.Type, next.Id, 0)
+				if err != nil {
+					if err != elastigo.RecordNotFound {
+						return nil, err
+					}
+					gi.node.Missing = true
+				}
+				gi.data = asset.Data
+				queue = append(queue, gi)
+			}
+
+			if !edges[l] {
+				edges[l] = true
+				graph.Edges = append(graph.Edges, l)
+			}
+		}
+	}
+
+	sort.Sort(graphNodesByDepth(graph.Nodes))
+	return graph, nil
+}
+
+// Links of an asset in the given direction limited to MAX_GRAPH_FANOUT per direction.
+func nodeLinks(lr linkResolver, ref AssetRef, data map[string]interface{}, direction string) (links []AssetLink, truncated bool, err error) {
+	if direction != GRAPH_DIRECTION_IN {
+		out, _ := parseAssetLinks(ref, data)
+		if len(out) > MAX_GRAPH_FANOUT {
+			out, truncated = out[:MAX_GRAPH_FANOUT], true
+		}
+		links = append(links, out...)
+	}
+
+	if direction != GRAPH_DIRECTION_OUT {
+		var in []AssetLink
+		if in, err = lr.ListReferrers(ref); err != nil {
+			return
+		}
+		if len(in) > MAX_GRAPH_FANOUT {
+			in, truncated = in[:MAX_GRAPH_FANOUT], true
+		}
+		links = append(links, in...)
+	}
+	return
+}
+
+// Graphviz DOT representation of the graph
+func (g *AssetGraph) DOT() string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "digraph %s {\n", dotQuote(g.Root.String()))
+	for _, n := range g.Nodes {
+		style := ""
+		if n.Missing {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&buf, "    %s [label=%s%s];\n", dotQuote(n.Id.String()), dotQuote(n.Id.Id+"\n"+n.Type), style)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&buf, "    %s -> %s [label=%s];\n", dotQuote(e.Source.String()), dotQuote(e.Target.String()),
+			dotQuote(e.Relation))
+	}
+	buf.WriteString("}\n")
+
+	return buf.String()
+}
+
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + strings.Replace(s, "\n", `\n`, -1) + `"`
+}
+
+// JSON Graph Format (http://jsongraphformat.info) representation of the graph
+func (g *AssetGraph) JGF() map[string]interface{} {
+	nodes := map[string]interface{}{}
+	for _, n := range g.Nodes {
+		nodes[n.Id.String()] = map[string]interface{}{
+			"label": n.Id.Id,
+			"metadata": map[string]interface{}{
+				"type":    n.Type,
+				"depth":   n.Depth,
+				"missing": n.Missing,
+			},
+		}
+	}
+
+	edges := make([]map[string]interface{}, len(g.Edges))
+	for i, e := range g.Edges {
+		edges[i] = map[string]interface{}{
+			"source":   e.Source.String(),
+			"target":   e.Target.String(),
+			"relation": e.Relation,
+		}
+	}
+
+	return map[string]interface{}{
+		"graph": map[string]interface{}{
+			"id":       g.Root.String(),
+			"directed": true,
+			"metadata": map[string]interface{}{"truncated": g.Truncated},
+			"nodes":    nodes,
+			"edges":    edges,
+		},
+	}
+}
+
+// Sorts nodes by depth then id for consistent output
+type graphNodesByDepth []GraphNode
+
+func (s graphNodesByDepth) Len() int      { return len(s) }
+func (s graphNodesByDepth) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s graphNodesByDepth) Less(i, j int) bool {
+	if s[i].Depth != s[j].Depth {
+		return s[i].Depth < s[j].Depth
+	}
+	return s[i].Id.String() < s[j].Id.String()
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	elastigo "github.com/mattbaird/elastigo/lib"
+)
+
+// In memory assets used to test traversals without a datastore
+type testLinkResolver map[AssetRef]map[string]interface{}
+
+func (lr testLinkResolver) Get(assetType, assetId string, version int64) (BaseAsset, error) {
+	data, ok := lr[AssetRef{Type: assetType, Id: assetId}]
+	if !ok {
+		return BaseAsset{}, elastigo.RecordNotFound
+	}
+	return BaseAsset{Type: assetType, Id: assetId, Data: data}, nil
+}
+
+func (lr testLinkResolver) ListReferrers(target AssetRef) ([]AssetLink, error) {
+	links := []AssetLink{}
+	for ref, data := range lr {
+		out, _ := parseAssetLinks(ref, data)
+		for _, l := range out {
+			if l.Target == target {
+				links = append(links, l)
+			}
+		}
+	}
+	return links, nil
+}
+
+var testGraphAssets = testLinkResolver{
+	AssetRef{"switch", "sw01"}: map[string]interface{}{},
+	AssetRef{"server", "web01"}: map[string]interface{}{
+		LINKS_FIELD: map[string]interface{}{"uplink": "switch/sw01"},
+	},
+	AssetRef{"server", "web02"}: map[string]interface{}{
+		LINKS_FIELD: map[string]interface{}{"uplink": "switch/sw01"},
+	},
+	AssetRef{"vip", "vip01"}: map[string]interface{}{
+		LINKS_FIELD: map[string]interface{}{"members": []interface{}{"server/web01", "server/web02", "server/gone"}},
+	},
+}
+
+func Test_traverseLinks_in(t *testing.T) {
+	graph, err := traverseLinks(testGraphAssets, AssetRef{"switch", "sw01"}, GraphOptions{Depth: 2, Direction: GRAPH_DIRECTION_IN})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(graph.Nodes) != 4 || len(graph.Edges) != 4 {
+		t.Fatalf("Wrong graph: %#v", graph)
+	}
+	if graph.Nodes[0].Id.Id != "sw01" || graph.Nodes[1].Id.Id != "web01" || graph.Nodes[3].Depth != 2 {
+		t.Fatalf("Wrong node order: %v", graph.Nodes)
+	}
+
+	// Depth limits traversal
+	if graph, _ = traverseLinks(testGraphAssets, AssetRef{"switch", "sw01"}, GraphOptions{Depth: 1, Direction: GRAPH_DIRECTION_IN}); len(graph.Nodes) != 3 {
+		t.Fatalf("Wrong graph: %#v", graph)
+	}
+}
+
+func Test_traverseLinks_out(t *testing.T) {
+	graph, err := traverseLinks(testGraphAssets, AssetRef{"vip", "vip01"}, GraphOptions{Depth: 1, Direction: GRAPH_DIRECTION_OUT})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(graph.Nodes) != 4 || len(graph.Edges) != 3 {
+		t.Fatalf("Wrong graph: %#v", graph)
+	}
+
+	missing := 0
+	for _, n := range graph.Nodes {
+		if n.Missing {
+			missing++
+		}
+	}
+	if missing != 1 {
+		t.Fatalf("Missing target should be marked: %v", graph.Nodes)
+	}
+}
+
+func Test_traverseLinks_error(t *testing.T) {
+	_, err := traverseLinks(testGraphAssets, AssetRef{"switch", "nope"}, GraphOptions{Depth: 1, Direction: GRAPH_DIRECTION_BOTH})
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("Should fail with not found on missing root: %v", err)
+	}
+	if _, err := traverseLinks(testGraphAssets, AssetRef{"switch", "sw01"}, GraphOptions{Depth: 1, Direction: "up"}); err == nil {
+		t.Fatalf("Should fail on invalid direction")
+	}
+	if _, err := traverseLinks(testGraphAssets, AssetRef{"switch", "sw01"}, GraphOptions{Depth: MAX_GRAPH_DEPTH + 1, Direction: GRAPH_DIRECTION_IN}); err == nil {
+		t.Fatalf("Should fail on invalid depth")
+	}
+}
+
+func Test_AssetGraph_DOT(t *testing.T) {
+	graph, _ := traverseLinks(testGraphAssets, AssetRef{"server", "web01"}, GraphOptions{Depth: 1, Direction: GRAPH_DIRECTION_BOTH})
+	dot := graph.DOT()
+
+	if !strings.HasPrefix(dot, `digraph "server/web01" {`) {
+		t.Fatalf("Wrong header: %s", dot)
+	}
+	if !strings.Contains(dot, `"vip/vip01" -> "server/web01" [label="members"];`) ||
+		!strings.Contains(dot, `"server/web01" -> "switch/sw01" [label="uplink"];`) {
+		t.Fatalf("Missing edges: %s", dot)
+	}
+
+	if dotQuote(`a "b"`) != `"a \"b\""` {
+		t.Fatalf("Wrong quoting: %s", dotQuote(`a "b"`))
+	}
+}
+
+func Test_AssetGraph_JGF(t *testing.T) {
+	graph, _ := traverseLinks(testGraphAssets, AssetRef{"server", "web01"}, GraphOptions{Depth: 1, Direction: GRAPH_DIRECTION_OUT})
+	jgf, _ := graph.JGF()["graph"].(map[string]interface{})
+
+	nodes, _ := jgf["nodes"].(map[string]interface{})
+	edges, _ := jgf["edges"].([]map[string]interface{})
+	if len(nodes) != 2 || len(edges) != 1 || edges[0]["target"] != "switch/sw01" {
+		t.Fatalf("Wrong graph: %#v", jgf)
+	}
+}
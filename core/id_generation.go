@@ -0,0 +1,90 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/vindalu/vindalu/config"
+)
+
+// Crockford's base32 alphabet used to encode ULIDs
+const ULID_ENCODING = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Random (version 4) UUID i.e. 1b4e28ba-2fa1-41d2-883f-0016d3cca427
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+/*
+	ULID for the given time i.e. 01ARYZ6S41TSV4RRFFQ69G5FAV.  The first 48 bits are the time in
+	ms and the remaining 80 bits are random, encoded as 26 base32 characters so ids sort by
+	creation time.
+*/
+func newULID(t time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+
+	// 128 bits are encoded as 130 with 2 leading zero bits
+	out := make([]byte, 26)
+	for i := range out {
+		var v byte
+		for pos := i*5 - 2; pos < i*5+3; pos++ {
+			v <<= 1
+			if pos >= 0 {
+				v |= (b[pos/8] >> uint(7-pos%8)) & 1
+			}
+		}
+		out[i] = ULID_ENCODING[v]
+	}
+	return string(out), nil
+}
+
+// Zero padded sequence id i.e. CHG-000123
+func sequenceId(prefix string, width int, n int64) string {
+	return fmt.Sprintf("%s%0*d", prefix, width, n)
+}
+
+/*
+	Allocate an id for a new asset as per the id generation config.  Sequences are kept per type
+	and are safe to use from multiple nodes.
+*/
+func (ds *InventoryDatastore) GenerateAssetId(assetCfg *config.AssetConfig, assetType string) (id string, err error) {
+	ic := assetCfg.IdGeneration
+	if ic == nil {
+		return "", fmt.Errorf("Id generation not configured for type: %s", assetType)
+	}
+
+	switch ic.Strategy {
+	case config.ID_STRATEGY_UUID:
+		id, err = newUUID()
+	case config.ID_STRATEGY_ULID:
+		id, err = newULID(time.Now())
+	case config.ID_STRATEGY_SEQUENCE:
+		var n int64
+		if n, err = ds.NextSequence(assetType); err == nil {
+			return sequenceId(ic.Prefix, ic.Width, n), nil
+		}
+	default:
+		err = fmt.Errorf("Invalid id generation strategy: %s", ic.Strategy)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return ic.Prefix + id, nil
+}
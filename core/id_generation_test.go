@@ -0,0 +1,52 @@
+package core
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func Test_newUUID(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	a, err := newUUID()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	b, _ := newUUID()
+	if !re.MatchString(a) || a == b {
+		t.Fatalf("Invalid UUIDs: %s %s", a, b)
+	}
+}
+
+func Test_newULID(t *testing.T) {
+	ts := time.Unix(0, 1469918176385*int64(time.Millisecond))
+
+	id, err := newULID(ts)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(id) != 26 || id[:10] != "01ARYZ6S41" {
+		t.Fatalf("Wrong ULID: %s", id)
+	}
+	if !regexp.MustCompile(`^[` + ULID_ENCODING + `]+$`).MatchString(id) {
+		t.Fatalf("Invalid characters: %s", id)
+	}
+
+	later, _ := newULID(ts.Add(time.Millisecond))
+	if later <= id {
+		t.Fatalf("ULIDs should sort by time: %s <= %s", later, id)
+	}
+}
+
+func Test_sequenceId(t *testing.T) {
+	if id := sequenceId("CHG-", 6, 123); id != "CHG-000123" {
+		t.Fatalf("Wrong id: %s", id)
+	}
+	if id := sequenceId("", 0, 42); id != "42" {
+		t.Fatalf("Wrong id: %s", id)
+	}
+	if id := sequenceId("L", 2, 1234); id != "L1234" {
+		t.Fatalf("Wrong id: %s", id)
+	}
+}
@@ -3,21 +3,25 @@ package core
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"time"
 
+	elastigo "github.com/mattbaird/elastigo/lib"
 	"github.com/nats-io/gnatsd/server"
 
 	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/schema"
 )
 
 type InventoryDatastore struct {
-	//IDatastore
-	*ElasticsearchDatastore
+	IDatastore
 
 	// Regex to validate type
 	typeRegex *regexp.Regexp
 	// Regex to validate id
 	idRegex *regexp.Regexp
+	// Regex to validate saved query names. Dots are not allowed as names are used in event topics.
+	queryNameRegex *regexp.Regexp
 
 	// Resource constraint configurations
 	resourceCfg config.AssetConfig
@@ -25,17 +29,19 @@ type InventoryDatastore struct {
 	log server.Logger
 }
 
-func NewInventoryDatastore(ds *ElasticsearchDatastore, resourceCfg config.AssetConfig, log server.Logger) *InventoryDatastore {
-	ids := &InventoryDatastore{ElasticsearchDatastore: ds, log: log, resourceCfg: resourceCfg}
+func NewInventoryDatastore(ds IDatastore, resourceCfg config.AssetConfig, log server.Logger) *InventoryDatastore {
+	ids := &InventoryDatastore{IDatastore: ds, log: log, resourceCfg: resourceCfg}
 
 	ids.typeRegex, _ = regexp.Compile(`^[a-z0-9\-_]+$`)
 	ids.idRegex, _ = regexp.Compile(`^[a-zA-Z0-9:_\(\)\{\}\|\-\.]+$`)
+	ids.queryNameRegex, _ = regexp.Compile(`^[a-zA-Z0-9_\-]+$`)
 
 	return ids
 }
 
 /*
-	Create new asset
+	Create new asset.  If the asset does not have an id one is allocated as per the id generation
+	config of the type.
 */
 func (ds *InventoryDatastore) CreateAsset(asset BaseAsset, createType bool) (string, error) {
 	err := ds.TypeExists(asset.Type)
@@ -43,41 +49,150 @@ func (ds *InventoryDatastore) CreateAsset(asset BaseAsset, createType bool) (str
 		return "", err
 	}
 
+	// The type metadata is only available if the type already exists
+	assetCfg := ds.resourceCfg.ForType(asset.Type)
+	if err == nil {
+		if assetCfg, err = ds.AssetConfigForType(asset.Type); err != nil {
+			return "", err
+		}
+	}
+
+	// Allocate an id if one was not supplied
+	if len(asset.Id) == 0 {
+		if asset.Id, err = ds.GenerateAssetId(assetCfg, asset.Type); err != nil {
+			return "", err
+		}
+	}
+
 	if !ds.idRegex.MatchString(asset.Id) {
 		return "", fmt.Errorf("Invalid characters in id: '%s'", asset.Id)
 	}
-	if err = validateEnforcedFields(&ds.resourceCfg, asset.Data); err != nil {
+
+	if err = validateEnforcedFields(assetCfg, asset.Type, asset.Id, asset.Data); err != nil {
+		return "", err
+	}
+	if err = validateAssetLabels(asset.Type, asset.Id, asset.Data); err != nil {
+		return "", err
+	}
+	if err = ValidateRequiredFields(assetCfg, asset.Data); err != nil {
+		return "", err
+	}
+	if err = ds.validateTypeSchema(asset.Type, asset.Id, asset.Data); err != nil {
+		return "", err
+	}
+	if err = ds.validateUniqueFields(assetCfg, asset.Type, asset.Id, asset.Data); err != nil {
 		return "", err
 	}
-	if err = ValidateRequiredFields(&ds.resourceCfg, asset.Data); err != nil {
+	if err = ds.validateAssetLinks(assetCfg, asset.Type, asset.Id, asset.Data); err != nil {
+		return "", err
+	}
+
+	ref := AssetRef{Type: asset.Type, Id: asset.Id}
+	reserved, err := ds.reserveUniqueKeys(assetCfg, ref, asset.Data, nil)
+	if err != nil {
 		return "", err
 	}
 
 	// in ms as es also stores _timestamp in ms
 	asset.Data["created_on"] = time.Now().Unix() * 1000
 
-	return ds.Create(asset, 0)
+	id, err := ds.Create(asset, 0)
+	if err != nil {
+		ds.releaseUniqueKeys(reserved, ref)
+	}
+	return id, err
 }
 
 func (ds *InventoryDatastore) CreateAssetType(assetType string, opts map[string]interface{}) error {
 	if !ds.typeRegex.MatchString(assetType) {
 		return fmt.Errorf("Invalid characters in type: '%s'", assetType)
 	}
+	if props, ok := opts["properties"]; ok {
+		if err := validateTypeProperties(props); err != nil {
+			return err
+		}
+	}
 
 	return ds.CreateType(assetType, opts)
 }
 
+// Add property definitions and update metadata of an existing type.  The current metadata is
+// retained if none is provided.
+func (ds *InventoryDatastore) UpdateAssetType(assetType string, props map[string]interface{}, meta *AssetTypeMetadata) (err error) {
+	if err = ds.TypeExists(assetType); err != nil {
+		return
+	}
+	if err = validateTypeProperties(props); err != nil {
+		return
+	}
+
+	if meta == nil {
+		if meta, err = ds.GetTypeMetadata(assetType); err != nil {
+			return
+		}
+	}
+	return ds.UpdateType(assetType, props, meta)
+}
+
+// Remove a type.  Only types without any assets can be removed.
+func (ds *InventoryDatastore) RemoveAssetType(assetType string) error {
+	if err := ds.TypeExists(assetType); err != nil {
+		return err
+	}
+
+	count, err := ds.CountAssets(assetType)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &ConflictError{Reason: fmt.Sprintf("Type '%s' has %d asset(s)", assetType, count)}
+	}
+
+	if err = ds.RemoveType(assetType); err != nil {
+		return err
+	}
+	// The schema is only applicable to the type
+	if err = ds.RemoveTypeSchema(assetType); err != nil {
+		if _, ok := err.(*NotFoundError); !ok {
+			ds.log.Errorf("Failed to remove schema (%s): %s\n", assetType, err)
+		}
+	}
+	return nil
+}
+
+// Asset config for a type with the rules from the type config and type metadata applied
+func (ds *InventoryDatastore) AssetConfigForType(assetType string) (*config.AssetConfig, error) {
+	assetCfg := ds.resourceCfg.ForType(assetType)
+
+	md, err := ds.GetTypeMetadata(assetType)
+	if err != nil {
+		return nil, err
+	}
+	if md != nil {
+		assetCfg = assetCfg.Apply(md.TypeAssetConfig)
+	}
+	return assetCfg, nil
+}
+
 func (ds *InventoryDatastore) EditAsset(updatedAsset *BaseAsset, delFields ...string) (id string, err error) {
+	var assetCfg *config.AssetConfig
+	if assetCfg, err = ds.AssetConfigForType(updatedAsset.Type); err != nil {
+		return
+	}
+
 	// Check required fields are not being deleted
 	for _, v := range delFields {
-		if ds.resourceCfg.IsRequiredField(v) {
+		if assetCfg.IsRequiredField(v) {
 			err = fmt.Errorf("Cannot delete required field '%s'", v)
 			return
 		}
 	}
 	//ds.log.Noticef("del :%v\n", ds.resourceCfg)
 
-	if err = validateEnforcedFields(&ds.resourceCfg, updatedAsset.Data); err != nil {
+	if err = validateEnforcedFields(assetCfg, updatedAsset.Type, updatedAsset.Id, updatedAsset.Data); err != nil {
+		return
+	}
+	if err = validateAssetLabels(updatedAsset.Type, updatedAsset.Id, updatedAsset.Data); err != nil {
 		return
 	}
 
@@ -89,6 +204,30 @@ func (ds *InventoryDatastore) EditAsset(updatedAsset *BaseAsset, delFields ...st
 		return
 	}
 
+	// Validate the document as it will be after the update
+	merged := mergeAssetData(asset.Data, updatedAsset.Data)
+	for _, v := range delFields {
+		delete(merged, v)
+	}
+	if err = ds.validateTypeSchema(updatedAsset.Type, updatedAsset.Id, merged); err != nil {
+		return
+	}
+	if err = ds.validateUniqueFields(assetCfg, updatedAsset.Type, updatedAsset.Id, merged); err != nil {
+		return
+	}
+	// Only links being updated are checked as existing ones have been validated
+	if err = ds.validateAssetLinks(assetCfg, updatedAsset.Type, updatedAsset.Id, updatedAsset.Data); err != nil {
+		return
+	}
+
+	ref := AssetRef{Type: updatedAsset.Type, Id: updatedAsset.Id}
+	var reserved []string
+	if reserved, err = ds.reserveUniqueKeys(assetCfg, ref, merged, nil); err != nil {
+		return
+	}
+	// Values the asset will no longer have
+	unreserved := ds.unreservedUniqueKeys(ref, asset.Data, merged)
+
 	if len(delFields) > 0 {
 		ds.log.Tracef("Fields to be deleted: %v\n", delFields)
 		// Add current asset data to updated asset
@@ -96,8 +235,10 @@ func (ds *InventoryDatastore) EditAsset(updatedAsset *BaseAsset, delFields ...st
 	}
 
 	if id, err = ds.Edit(updatedAsset, delFields...); err != nil {
+		ds.releaseUniqueKeys(reserved, ref)
 		return
 	}
+	ds.releaseUniqueKeys(unreserved, ref)
 
 	// Create version
 	var createdVersion int64
@@ -122,6 +263,8 @@ func (ds *InventoryDatastore) RemoveAsset(assetType, assetId string, versionMeta
 	if err = ds.Remove(assetType, assetId); err != nil {
 		return nil, err
 	}
+	ref := AssetRef{Type: assetType, Id: assetId}
+	ds.releaseUniqueKeys(ds.unreservedUniqueKeys(ref, asset.Data, nil), ref)
 	// Store deleted version
 	createdVersion, err := ds.CreateAssetVersion(asset)
 	if err != nil {
@@ -158,6 +301,439 @@ func (ds *InventoryDatastore) RemoveAsset(assetType, assetId string, versionMeta
 	return &asset, err
 }
 
+// Validate and store the JSON schema for a type
+func (ds *InventoryDatastore) PutTypeSchema(ts AssetTypeSchema) error {
+	if err := ds.TypeExists(ts.AssetType); err != nil {
+		return err
+	}
+	if _, err := schema.New(ts.Schema); err != nil {
+		return err
+	}
+	return ds.IDatastore.PutTypeSchema(ts)
+}
+
+// Validate asset data against the schema of the type if one has been set.  Managed fields are
+// not validated.
+func (ds *InventoryDatastore) validateTypeSchema(assetType, assetId string, data map[string]interface{}) error {
+	ts, err := ds.GetTypeSchema(assetType)
+	if err != nil {
+		if _, ok := err.(*NotFoundError); ok {
+			return nil
+		}
+		return err
+	}
+
+	sch, err := schema.New(ts.Schema)
+	if err != nil {
+		return fmt.Errorf("Invalid schema for type '%s': %s", assetType, err)
+	}
+
+	doc := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		doc[k] = v
+	}
+	for _, k := range INTERNAL_FIELDS {
+		delete(doc, k)
+	}
+
+	if violations := sch.Validate(doc); len(violations) > 0 {
+		return &ValidationError{AssetType: assetType, AssetId: assetId, Violations: violations}
+	}
+	return nil
+}
+
+/*
+	Check no other asset of the type has the same values for any of the unique keys.  A key is
+	only checked when the asset contains all of its fields.
+*/
+func (ds *InventoryDatastore) validateUniqueFields(assetCfg *config.AssetConfig, assetType, assetId string, data map[string]interface{}) error {
+	for _, key := range assetCfg.UniqueFields {
+		values := uniqueKeyValues(key, data)
+		if values == nil {
+			continue
+		}
+
+		ids, err := ds.FindDuplicates(assetType, assetId, values)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			return &ConflictError{Reason: fmt.Sprintf("Unique constraint (%s) violated. Values already used by: %s/%s",
+				key, assetType, ids[0])}
+		}
+	}
+	return nil
+}
+
+// Existing assets sharing the values of any of the unique keys of the type
+func (ds *InventoryDatastore) ListUniqueViolations(assetType string) ([]UniqueViolation, error) {
+	if err := ds.TypeExists(assetType); err != nil {
+		return nil, err
+	}
+
+	assetCfg, err := ds.AssetConfigForType(assetType)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := []UniqueViolation{}
+	for _, key := range assetCfg.UniqueFields {
+		dups, err := ds.ListDuplicates(assetType, key)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, dups...)
+	}
+	return violations, nil
+}
+
+// Check the links of an asset are well formed, of the configured type and that the targets exist.
+func (ds *InventoryDatastore) validateAssetLinks(assetCfg *config.AssetConfig, assetType, assetId string, data map[string]interface{}) error {
+	links, violations := parseAssetLinks(AssetRef{Type: assetType, Id: assetId}, data)
+
+	for _, l := range links {
+		path := "/" + LINKS_FIELD + "/" + l.Relation
+
+		if lc, ok := assetCfg.Links[l.Relation]; ok && len(lc.Type) > 0 && lc.Type != l.Target.Type {
+			violations = append(violations, schema.Violation{Path: path,
+				Message: fmt.Sprintf("must reference type '%s', got %s", lc.Type, l.Target)})
+			continue
+		}
+
+		if _, err := ds.Get(l.Target.Type, l.Target.Id, 0); err != nil {
+			if err != elastigo.RecordNotFound {
+				return err
+			}
+			violations = append(violations, schema.Violation{Path: path,
+				Message: fmt.Sprintf("referenced asset does not exist: %s", l.Target)})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{AssetType: assetType, AssetId: assetId, Violations: violations}
+	}
+	return nil
+}
+
+/*
+	Determine the assets to remove and links to remove from other assets in order to remove an
+	asset, as per the on_delete behavior of the relations referencing it.  A ConflictError is
+	returned if any remaining asset blocks the removal.
+*/
+func (ds *InventoryDatastore) PlanAssetRemoval(assetType, assetId string) (*RemovalPlan, error) {
+	var (
+		root     = AssetRef{Type: assetType, Id: assetId}
+		plan     = &RemovalPlan{Remove: []AssetRef{}, Unlink: []AssetLink{}}
+		removing = map[AssetRef]bool{root: true}
+		queue    = []AssetRef{root}
+		// Evaluated once all cascaded removals are known
+		blocking = []AssetLink{}
+		unlink   = []AssetLink{}
+
+		assetCfgs = map[string]*config.AssetConfig{}
+	)
+
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		plan.Remove = append(plan.Remove, target)
+
+		referrers, err := ds.ListReferrers(target)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range referrers {
+			if removing[l.Source] {
+				continue
+			}
+
+			assetCfg, ok := assetCfgs[l.Source.Type]
+			if !ok {
+				if assetCfg, err = ds.AssetConfigForType(l.Source.Type); err != nil {
+					return nil, err
+				}
+				assetCfgs[l.Source.Type] = assetCfg
+			}
+
+			switch assetCfg.Links[l.Relation].OnDeleteBehavior() {
+			case config.ON_DELETE_CASCADE:
+				removing[l.Source] = true
+				queue = append(queue, l.Source)
+			case config.ON_DELETE_NULLIFY:
+				unlink = append(unlink, l)
+			default:
+				blocking = append(blocking, l)
+			}
+		}
+	}
+
+	for _, l := range blocking {
+		if !removing[l.Source] {
+			return nil, &ConflictError{Reason: fmt.Sprintf("%s is referenced by %s (%s)", l.Target, l.Source, l.Relation)}
+		}
+	}
+	for _, l := range unlink {
+		if !removing[l.Source] {
+			plan.Unlink = append(plan.Unlink, l)
+		}
+	}
+	return plan, nil
+}
+
+// Update data removing the link from its source asset
+func (ds *InventoryDatastore) UnlinkData(link AssetLink) (map[string]interface{}, error) {
+	asset, err := ds.Get(link.Source.Type, link.Source.Id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rels, _ := asset.Data[LINKS_FIELD].(map[string]interface{})
+	return map[string]interface{}{
+		LINKS_FIELD: map[string]interface{}{
+			link.Relation: unlinkedRelationValue(rels[link.Relation], link.Target),
+		},
+	}, nil
+}
+
+// Update data pointing the link from its source asset to a renamed target
+func (ds *InventoryDatastore) RelinkData(link AssetLink, to AssetRef) (map[string]interface{}, error) {
+	asset, err := ds.Get(link.Source.Type, link.Source.Id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rels, _ := asset.Data[LINKS_FIELD].(map[string]interface{})
+	return map[string]interface{}{
+		LINKS_FIELD: map[string]interface{}{
+			link.Relation: relinkedRelationValue(rels[link.Relation], link.Target, to),
+		},
+	}, nil
+}
+
+/*
+	Rename an asset and/or move it to another type.  The versions of the asset are migrated and
+	the current asset is stored as a new version so the rename shows up in the history.  The
+	renamed asset is validated against the rules of the new type and has `renamed_from` set.
+	Links from other assets are not updated.  If a step fails before the original asset is removed,
+	the versions and asset created under the new name are removed again.
+*/
+func (ds *InventoryDatastore) RenameAsset(from, to AssetRef, user string) (_ *BaseAsset, err error) {
+	if from == to {
+		return nil, fmt.Errorf("Asset already named: %s", to)
+	}
+	if !ds.idRegex.MatchString(to.Id) {
+		return nil, fmt.Errorf("Invalid characters in id: '%s'", to.Id)
+	}
+	if err := ds.TypeExists(to.Type); err != nil {
+		return nil, err
+	}
+
+	asset, err := ds.Get(from.Type, from.Id, 0)
+	if err != nil {
+		if err == elastigo.RecordNotFound {
+			err = &NotFoundError{Kind: "Asset", Name: from.String()}
+		}
+		return nil, err
+	}
+	if _, err = ds.Get(to.Type, to.Id, 0); err == nil {
+		return nil, &ConflictError{Reason: fmt.Sprintf("Asset already exists: %s", to)}
+	} else if err != elastigo.RecordNotFound {
+		return nil, err
+	}
+
+	renamed := BaseAsset{Type: to.Type, Id: to.Id, Data: make(map[string]interface{}, len(asset.Data)+2)}
+	for k, v := range asset.Data {
+		renamed.Data[k] = v
+	}
+	renamed.Data["updated_by"] = user
+	renamed.Data[RENAMED_FROM_FIELD] = from.String()
+
+	if err = ds.validateRenamedAsset(from, renamed); err != nil {
+		return nil, err
+	}
+
+	assetCfg, err := ds.AssetConfigForType(to.Type)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := ds.reserveUniqueKeys(assetCfg, to, renamed.Data, &from)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		fromData = asset.Data
+		// Versions and whether the asset were created under the new name.  Removed on failure.
+		migrated []int64
+		created  bool
+	)
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rbErr := ds.rollbackRename(to, migrated, created); rbErr != nil {
+			ds.log.Errorf("Failed to roll back rename (%s -> %s): %s\n", from, to, rbErr)
+			err = fmt.Errorf("%s.  Rollback failed, %s remains with %d migrated versions (asset created: %t): %s",
+				err, to, len(migrated), created, rbErr)
+		}
+		ds.releaseUniqueKeys(reserved, to)
+		// The asset keeps its name so it gets back the reservations taken over
+		if fromCfg, err := ds.AssetConfigForType(from.Type); err == nil {
+			ds.reserveUniqueKeys(fromCfg, from, fromData, nil)
+		}
+	}()
+
+	versions, err := ds.ListAllVersions(from.Type, from.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrate versions followed by the current asset as the latest version
+	var latest int64
+	for _, v := range versions {
+		ver := v.GetVersion()
+		v.Type, v.Id = to.Type, to.Id
+		if _, err = ds.Create(v, ver); err != nil {
+			return nil, fmt.Errorf("Failed to migrate version %d: %s", ver, err)
+		}
+		migrated = append(migrated, ver)
+		if ver > latest {
+			latest = ver
+		}
+	}
+	asset.Type, asset.Id = to.Type, to.Id
+	if _, err = ds.Create(asset, latest+1); err != nil {
+		return nil, err
+	}
+	migrated = append(migrated, latest+1)
+
+	if _, err = ds.Create(renamed, 0); err != nil {
+		return nil, err
+	}
+	created = true
+
+	// Only removed once the renamed asset is in place
+	if err = ds.Remove(from.Type, from.Id); err != nil {
+		return nil, err
+	}
+	ds.releaseUniqueKeys(ds.unreservedUniqueKeys(from, fromData, nil), from)
+	for _, v := range versions {
+		if err = ds.RemoveVersion(from.Type, from.Id, v.GetVersion()); err != nil {
+			ds.log.Errorf("Failed to remove version (%s.%d): %s\n", from, v.GetVersion(), err)
+		}
+	}
+
+	ds.log.Noticef("Asset renamed: %s -> %s (versions=%d)\n", from, to, len(versions))
+	return &renamed, nil
+}
+
+// Remove the versions and asset a failed rename created under the new name.  Returns the first error.
+func (ds *InventoryDatastore) rollbackRename(to AssetRef, versions []int64, created bool) (err error) {
+	if created {
+		err = ds.Remove(to.Type, to.Id)
+	}
+	for _, ver := range versions {
+		if rerr := ds.RemoveVersion(to.Type, to.Id, ver); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	return
+}
+
+// Check a renamed asset against the rules of its new type
+func (ds *InventoryDatastore) validateRenamedAsset(from AssetRef, renamed BaseAsset) error {
+	assetCfg, err := ds.AssetConfigForType(renamed.Type)
+	if err != nil {
+		return err
+	}
+
+	if err = validateEnforcedFields(assetCfg, renamed.Type, renamed.Id, renamed.Data); err != nil {
+		return err
+	}
+	if err = ValidateRequiredFields(assetCfg, renamed.Data); err != nil {
+		return err
+	}
+	if err = ds.validateTypeSchema(renamed.Type, renamed.Id, renamed.Data); err != nil {
+		return err
+	}
+	// Within the same type the only asset with the same values could be the asset itself
+	if from.Type != renamed.Type {
+		if err = ds.validateUniqueFields(assetCfg, renamed.Type, renamed.Id, renamed.Data); err != nil {
+			return err
+		}
+	}
+	return ds.validateAssetLinks(assetCfg, renamed.Type, renamed.Id, renamed.Data)
+}
+
+// Validate and store a saved query
+func (ds *InventoryDatastore) PutSavedQuery(sq SavedQuery) error {
+	if !ds.queryNameRegex.MatchString(sq.Name) {
+		return fmt.Errorf("Invalid characters in query name: '%s'", sq.Name)
+	}
+	if len(sq.AssetType) > 0 {
+		if err := ds.TypeExists(sq.AssetType); err != nil {
+			return err
+		}
+	}
+	// Make sure the filter can be translated to a datastore query
+	if err := validateQuery(sq.Query); err != nil {
+		return err
+	}
+
+	return ds.IDatastore.PutSavedQuery(sq)
+}
+
+// Property details for a type including the required and enforced constraints.  Constrained
+// properties that are not yet part of the mapping are also listed.
+func (ds *InventoryDatastore) ListTypePropertyDetails(assetType string) ([]PropertyDetail, error) {
+	details, err := ds.IDatastore.ListTypePropertyDetails(assetType)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := ds.GetTypeMetadata(assetType)
+	if err != nil {
+		return nil, err
+	}
+	assetCfg := ds.resourceCfg.ForType(assetType)
+	if md != nil {
+		assetCfg = assetCfg.Apply(md.TypeAssetConfig)
+	}
+
+	listed := map[string]bool{}
+	for i, v := range details {
+		listed[v.Name] = true
+		applyPropertyConstraints(assetCfg, &details[i])
+	}
+
+	constrained := append([]string{}, assetCfg.RequiredFields...)
+	enforced := []string{}
+	for k, _ := range assetCfg.EnforcedFields {
+		enforced = append(enforced, k)
+	}
+	sort.Strings(enforced)
+
+	for _, name := range append(constrained, enforced...) {
+		if listed[name] {
+			continue
+		}
+		listed[name] = true
+
+		pd := PropertyDetail{Name: name}
+		applyPropertyConstraints(assetCfg, &pd)
+		details = append(details, pd)
+	}
+
+	// Add field documentation
+	if md != nil {
+		for i, v := range details {
+			details[i].Description = md.Fields[v.Name]
+		}
+	}
+
+	return details, nil
+}
+
 // Version up and store in version table
 func (e *InventoryDatastore) CreateAssetVersion(asset BaseAsset) (version int64, err error) {
 	// Get latest version
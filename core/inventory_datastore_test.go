@@ -1,6 +1,7 @@
 package core
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -44,7 +45,7 @@ var (
 
 	testAssetCfg = config.AssetConfig{
 		RequiredFields: []string{"status"},
-		EnforcedFields: map[string][]string{},
+		EnforcedFields: map[string]config.FieldRule{},
 	}
 
 	testEds, _ = NewElasticsearchDatastore(&testDsConfig, testLogger)
@@ -187,6 +188,183 @@ func Test_InventoryDatastore_ListTypeProperties(t *testing.T) {
 	t.Logf("%#v", props)
 }
 
+func Test_InventoryDatastore_ListTypePropertyDetails(t *testing.T) {
+	testEds.Conn.Refresh(testEds.Index)
+
+	details, err := testIds.ListTypePropertyDetails(testAssetType)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	found := false
+	for _, v := range details {
+		if v.Name == "status" {
+			found = true
+			if !v.Required || v.FillRate != 100 {
+				t.Fatalf("Wrong status details: %#v", v)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("status property not found: %#v", details)
+	}
+	t.Logf("%#v", details)
+}
+
+func Test_InventoryDatastore_UpdateAssetType(t *testing.T) {
+	props := map[string]interface{}{
+		"rack": map[string]interface{}{"type": "string", "index": "not_analyzed"},
+	}
+	meta := &AssetTypeMetadata{Description: "test", Fields: map[string]string{"rack": "Rack location"}}
+
+	if err := testIds.UpdateAssetType(testCreateType, props, meta); err != nil {
+		t.Fatalf("%s", err)
+	}
+	// Metadata should be retained
+	if err := testIds.UpdateAssetType(testCreateType, nil, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	md, err := testIds.GetTypeMetadata(testCreateType)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if md == nil || md.Description != "test" {
+		t.Fatalf("Metadata mismatch: %#v", md)
+	}
+}
+
+func Test_InventoryDatastore_RemoveAssetType(t *testing.T) {
+	if err := testIds.RemoveAssetType(testAssetType); err == nil {
+		t.Fatalf("Should fail as type has assets")
+	} else if _, ok := err.(*ConflictError); !ok {
+		t.Fatalf("Should be a conflict: %s", err)
+	}
+
+	if err := testIds.RemoveAssetType(testCreateType + "2"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := testIds.TypeExists(testCreateType + "2"); err == nil {
+		t.Fatalf("Type not removed")
+	}
+}
+
+func Test_InventoryDatastore_TypeSchema(t *testing.T) {
+	ts := AssetTypeSchema{
+		AssetType: testAssetType,
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"status": map[string]interface{}{"enum": []interface{}{"enabled", "disabled"}},
+			},
+		},
+	}
+	if err := testIds.PutTypeSchema(ts); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer testIds.RemoveTypeSchema(testAssetType)
+
+	update := BaseAsset{Id: testAssetId, Type: testAssetType, Data: map[string]interface{}{"status": "foo"}}
+	_, err := testIds.EditAsset(&update)
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("Should fail validation: %v", err)
+	}
+
+	if err = testIds.PutTypeSchema(AssetTypeSchema{AssetType: "does_not_exist", Schema: ts.Schema}); err == nil {
+		t.Fatalf("Should fail for non-existent type")
+	}
+}
+
+func Test_InventoryDatastore_UniqueFields(t *testing.T) {
+	testIds.resourceCfg.UniqueFields = []config.UniqueKey{config.UniqueKey{"name"}}
+	defer func() { testIds.resourceCfg.UniqueFields = nil }()
+	testIds.Refresh()
+
+	dup := BaseAsset{
+		Id:   "test_duplicate",
+		Type: testAssetType,
+		Data: map[string]interface{}{"name": testAssetId, "status": "enabled"},
+	}
+	if _, err := testIds.CreateAsset(dup, false); err == nil {
+		t.Fatalf("Should fail unique constraint")
+	} else if _, ok := err.(*ConflictError); !ok {
+		t.Fatalf("Should be a conflict: %s", err)
+	}
+
+	// Updating the asset itself should not conflict
+	update := BaseAsset{Id: testAssetId, Type: testAssetType, Data: map[string]interface{}{"name": testAssetId}}
+	if _, err := testIds.EditAsset(&update); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	violations, err := testIds.ListUniqueViolations(testAssetType)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(violations) != 0 {
+		t.Fatalf("Should not have violations: %v", violations)
+	}
+}
+
+func Test_InventoryDatastore_Links(t *testing.T) {
+	linked := BaseAsset{
+		Id:   "test_linked",
+		Type: testAssetType,
+		Data: map[string]interface{}{
+			"status":    "enabled",
+			LINKS_FIELD: map[string]interface{}{"parent": testAssetType + "/does_not_exist"},
+		},
+	}
+	if _, err := testIds.CreateAsset(linked, false); err == nil {
+		t.Fatalf("Should fail on missing target")
+	} else if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("Should be a validation error: %s", err)
+	}
+
+	linked.Data[LINKS_FIELD] = map[string]interface{}{"parent": testAssetType + "/" + testAssetId}
+	if _, err := testIds.CreateAsset(linked, false); err != nil {
+		t.Fatalf("%s", err)
+	}
+	testIds.Refresh()
+
+	target := AssetRef{Type: testAssetType, Id: testAssetId}
+	links, err := testIds.ListReferrers(target)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(links) != 1 || links[0].Source.Id != linked.Id || links[0].Relation != "parent" {
+		t.Fatalf("Wrong referrers: %v", links)
+	}
+
+	// Blocked by default
+	if _, err = testIds.PlanAssetRemoval(testAssetType, testAssetId); err == nil {
+		t.Fatalf("Removal should be blocked")
+	} else if _, ok := err.(*ConflictError); !ok {
+		t.Fatalf("Should be a conflict: %s", err)
+	}
+
+	testIds.resourceCfg.Links = map[string]config.LinkConfig{"parent": config.LinkConfig{OnDelete: config.ON_DELETE_NULLIFY}}
+	plan, err := testIds.PlanAssetRemoval(testAssetType, testAssetId)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(plan.Remove) != 1 || len(plan.Unlink) != 1 {
+		t.Fatalf("Wrong plan: %#v", plan)
+	}
+
+	testIds.resourceCfg.Links = map[string]config.LinkConfig{"parent": config.LinkConfig{OnDelete: config.ON_DELETE_CASCADE}}
+	if plan, err = testIds.PlanAssetRemoval(testAssetType, testAssetId); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(plan.Remove) != 2 || len(plan.Unlink) != 0 {
+		t.Fatalf("Wrong plan: %#v", plan)
+	}
+	testIds.resourceCfg.Links = nil
+
+	testIds.RemoveAsset(testAssetType, linked.Id, nil)
+	testIds.Refresh()
+}
+
 func Test_InventoryDatastore_RemoveAsset(t *testing.T) {
 	var err error
 	if _, err = testIds.RemoveAsset(testAssetType, testData.Id, nil); err != nil {
@@ -196,7 +374,7 @@ func Test_InventoryDatastore_RemoveAsset(t *testing.T) {
 		t.Fatalf("Did not remove asset")
 	}
 
-	testIds.Conn.Refresh()
+	testEds.Conn.Refresh()
 
 	var vers []BaseAsset
 	if vers, err = testIds.GetVersions(testAssetType, testAssetId, 10); err != nil {
@@ -209,7 +387,113 @@ func Test_InventoryDatastore_RemoveAsset(t *testing.T) {
 		t.Fatal("Failed to parse time")
 	}
 
-	testIds.Conn.DeleteIndex(testIds.Index)
-	testIds.Conn.DeleteIndex(testIds.VersionIndex)
+	testEds.Conn.DeleteIndex(testEds.Index)
+	testEds.Conn.DeleteIndex(testEds.VersionIndex)
+	testEds.Conn.DeleteIndex(testEds.MetaIndex)
 	testIds.Close()
 }
+
+func Test_InventoryDatastore_GenerateAssetId(t *testing.T) {
+	assetCfg := &config.AssetConfig{IdGeneration: &config.IdGenerationConfig{
+		Strategy: config.ID_STRATEGY_SEQUENCE, Prefix: "CHG-", Width: 6,
+	}}
+
+	first, err := testIds.GenerateAssetId(assetCfg, "test_sequence")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	second, err := testIds.GenerateAssetId(assetCfg, "test_sequence")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if first == second || len(second) != len("CHG-000000") || second <= first {
+		t.Fatalf("Sequence ids should increase: %s %s", first, second)
+	}
+
+	if _, err = testIds.GenerateAssetId(&config.AssetConfig{}, "test_sequence"); err == nil {
+		t.Fatalf("Should fail without id generation")
+	}
+}
+
+func Test_InventoryDatastore_RenameAsset(t *testing.T) {
+	from := AssetRef{Type: testAssetType, Id: "test_rename"}
+	to := AssetRef{Type: testAssetType, Id: "test_renamed"}
+
+	asset := BaseAsset{Id: from.Id, Type: from.Type, Data: map[string]interface{}{"status": "enabled"}}
+	if _, err := testIds.CreateAsset(asset, false); err != nil {
+		t.Fatalf("%s", err)
+	}
+	update := BaseAsset{Id: from.Id, Type: from.Type, Data: map[string]interface{}{"status": "disabled"}}
+	if _, err := testIds.EditAsset(&update); err != nil {
+		t.Fatalf("%s", err)
+	}
+	testIds.Refresh()
+
+	if _, err := testIds.RenameAsset(from, AssetRef{Type: testAssetType, Id: testAssetId}, "test"); err == nil {
+		t.Fatalf("Should fail renaming to an existing asset")
+	} else if _, ok := err.(*ConflictError); !ok {
+		t.Fatalf("Should be a conflict: %s", err)
+	}
+
+	renamed, err := testIds.RenameAsset(from, to, "test")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if renamed.Data[RENAMED_FROM_FIELD] != from.String() {
+		t.Fatalf("Rename not recorded: %v", renamed.Data)
+	}
+	testIds.Refresh()
+
+	if _, err = testIds.Get(from.Type, from.Id, 0); err == nil {
+		t.Fatalf("Old asset should be removed")
+	}
+	// Original version plus the asset as it was before the rename
+	versions, err := testIds.ListAllVersions(to.Type, to.Id)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(versions) != 2 || versions[1].Data["status"] != "disabled" {
+		t.Fatalf("Versions not migrated: %v", versions)
+	}
+	if versions, _ = testIds.ListAllVersions(from.Type, from.Id); len(versions) != 0 {
+		t.Fatalf("Old versions should be removed: %v", versions)
+	}
+
+	testIds.RemoveAsset(to.Type, to.Id, nil)
+	testIds.Refresh()
+}
+
+// Records removals of a rename rolled back
+type rollbackDatastore struct {
+	IDatastore
+	removed []string
+}
+
+func (rd *rollbackDatastore) Remove(assetType, assetId string) error {
+	rd.removed = append(rd.removed, assetType+"/"+assetId)
+	return nil
+}
+
+func (rd *rollbackDatastore) RemoveVersion(assetType, assetId string, version int64) error {
+	rd.removed = append(rd.removed, fmt.Sprintf("%s/%s.%d", assetType, assetId, version))
+	if version == 2 {
+		return fmt.Errorf("version remove failed")
+	}
+	return nil
+}
+
+func Test_InventoryDatastore_rollbackRename(t *testing.T) {
+	rd := &rollbackDatastore{}
+	ds := &InventoryDatastore{IDatastore: rd}
+	to := AssetRef{Type: "server", Id: "web02"}
+
+	if err := ds.rollbackRename(to, []int64{1}, false); err != nil || len(rd.removed) != 1 || rd.removed[0] != "server/web02.1" {
+		t.Fatalf("Wrong rollback: %v %v", rd.removed, err)
+	}
+
+	// All removals are attempted
+	rd.removed = nil
+	if err := ds.rollbackRename(to, []int64{1, 2, 3}, true); err == nil || len(rd.removed) != 4 || rd.removed[0] != "server/web02" {
+		t.Fatalf("Wrong rollback: %v %v", rd.removed, err)
+	}
+}
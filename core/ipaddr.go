@@ -0,0 +1,162 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Separator for ip ranges i.e. <start_ip>..<end_ip>
+const IP_RANGE_SEPARATOR = ".."
+
+// Parse a CIDR (10.20.0.0/16) or ip range (10.20.0.1..10.20.0.100) into the first and last address.
+// Only IPv4 is supported as that is what the elasticsearch `ip` type supports.
+func parseIPRange(val string) (start, end net.IP, ok bool) {
+	if strings.Contains(val, "/") {
+		_, ipnet, err := net.ParseCIDR(val)
+		if err != nil || ipnet.IP.To4() == nil {
+			return
+		}
+		start = ipnet.IP.To4()
+		end = make(net.IP, len(start))
+		for i := range start {
+			end[i] = start[i] | ^ipnet.Mask[i]
+		}
+		ok = true
+		return
+	}
+
+	parts := strings.Split(val, IP_RANGE_SEPARATOR)
+	if len(parts) != 2 {
+		return
+	}
+	if start = net.ParseIP(strings.TrimSpace(parts[0])).To4(); start == nil {
+		return
+	}
+	if end = net.ParseIP(strings.TrimSpace(parts[1])).To4(); end == nil {
+		return
+	}
+	ok = true
+	return
+}
+
+// Generate an ESS range filter if the value is a CIDR or ip range
+func ipRangeFilter(attr, val string) (map[string]interface{}, bool) {
+	start, end, ok := parseIPRange(val)
+	if !ok {
+		return nil, false
+	}
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			attr: map[string]string{
+				"gte": start.String(),
+				"lte": end.String(),
+			},
+		},
+	}, true
+}
+
+/*
+	Filter fields mapped as `ip` by the CIDR or ip range given as their value i.e.
+	PrivateIpAddress=10.20.0.0/16, the same as with the `iprange` operator.  Other fields are
+	matched as usual so values such as paths are not affected.  The field types are only read if
+	a value is a CIDR or ip range.
+*/
+func translateIpRangeFields(req map[string]interface{}, fieldTypes func() (map[string]string, error)) error {
+	var types map[string]string
+	for k, v := range req {
+		val, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if _, op := splitFieldOperator(k); len(op) > 0 {
+			continue
+		}
+		if _, _, ok = parseIPRange(strings.TrimSpace(val)); !ok {
+			continue
+		}
+
+		if types == nil {
+			var err error
+			if types, err = fieldTypes(); err != nil {
+				return err
+			}
+		}
+		if types[k] == "ip" {
+			delete(req, k)
+			req[k+MATCH_OPERATOR_SEPARATOR+MATCH_IP_RANGE] = v
+		}
+	}
+	return nil
+}
+
+// Network address in CIDR notation of the ip for the given prefix length
+func subnetOf(ipStr string, prefixLen int) (string, error) {
+	ip := net.ParseIP(ipStr).To4()
+	if ip == nil {
+		return "", fmt.Errorf("Invalid IPv4 address: %s", ipStr)
+	}
+	mask := net.CIDRMask(prefixLen, 32)
+	if mask == nil {
+		return "", fmt.Errorf("Invalid subnet prefix length: %d", prefixLen)
+	}
+	return fmt.Sprintf("%s/%d", ip.Mask(mask), prefixLen), nil
+}
+
+// Group aggregated ip counts by subnet.  Values that are not ip addresses are left as is.
+// The result is sorted by count (descending) and limited to `size` items if size > 0.
+func aggregateBySubnet(items []AggregatedItem, prefixLen int, size int64) ([]AggregatedItem, error) {
+	if prefixLen < 0 || prefixLen > 32 {
+		return nil, fmt.Errorf("Invalid subnet prefix length: %d", prefixLen)
+	}
+
+	var (
+		counts = map[string]int64{}
+		order  = []string{}
+	)
+
+	for _, item := range items {
+		name, err := subnetOf(item.Name, prefixLen)
+		if err != nil {
+			name = item.Name
+		}
+		if _, ok := counts[name]; !ok {
+			order = append(order, name)
+		}
+		counts[name] += item.Count
+	}
+
+	out := make([]AggregatedItem, len(order))
+	for i, name := range order {
+		out[i] = AggregatedItem{Name: name, Count: counts[name]}
+	}
+	sort.Sort(aggregatedItemsByCount(out))
+
+	if size > 0 && int64(len(out)) > size {
+		out = out[:size]
+	}
+	return out, nil
+}
+
+// Sort aggregated items by count descending then by address ascending
+type aggregatedItemsByCount []AggregatedItem
+
+func (a aggregatedItemsByCount) Len() int      { return len(a) }
+func (a aggregatedItemsByCount) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a aggregatedItemsByCount) Less(i, j int) bool {
+	if a[i].Count != a[j].Count {
+		return a[i].Count > a[j].Count
+	}
+	return ipSortKey(a[i].Name) < ipSortKey(a[j].Name)
+}
+
+// Numeric key for ordering subnets.  Non ip values sort after ip's.
+func ipSortKey(name string) uint64 {
+	ip := net.ParseIP(strings.Split(name, "/")[0]).To4()
+	if ip == nil {
+		return 1 << 32
+	}
+	return uint64(binary.BigEndian.Uint32(ip))
+}
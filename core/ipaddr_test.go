@@ -0,0 +1,121 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_parseIPRange(t *testing.T) {
+	tests := map[string][2]string{
+		"10.20.0.0/16":        {"10.20.0.0", "10.20.255.255"},
+		"10.20.30.40/24":      {"10.20.30.0", "10.20.30.255"},
+		"1.2.3.4..1.2.3.200":  {"1.2.3.4", "1.2.3.200"},
+		"1.2.3.4 .. 1.2.3.10": {"1.2.3.4", "1.2.3.10"},
+	}
+	for val, expected := range tests {
+		start, end, ok := parseIPRange(val)
+		if !ok {
+			t.Fatalf("Should be an ip range: %s", val)
+		}
+		if start.String() != expected[0] || end.String() != expected[1] {
+			t.Fatalf("%s: %s..%s != %s..%s", val, start, end, expected[0], expected[1])
+		}
+	}
+
+	for _, val := range []string{"10.20.0.0", "foo/bar", "1.2.3..4", "a..b", "fe80::/64", "10.20.0.0/33"} {
+		if _, _, ok := parseIPRange(val); ok {
+			t.Fatalf("Should not be an ip range: %s", val)
+		}
+	}
+}
+
+func Test_ipRangeFilter(t *testing.T) {
+	f, ok := ipRangeFilter("PrivateIpAddress", "10.20.0.0/16")
+	if !ok {
+		t.Fatalf("Filter not generated")
+	}
+	rng := f["range"].(map[string]interface{})["PrivateIpAddress"].(map[string]string)
+	if rng["gte"] != "10.20.0.0" || rng["lte"] != "10.20.255.255" {
+		t.Fatalf("Range mismatch: %#v", rng)
+	}
+
+	if _, ok = ipRangeFilter("os", "ubuntu"); ok {
+		t.Fatalf("Filter should not be generated")
+	}
+}
+
+func Test_translateIpRangeFields(t *testing.T) {
+	req := map[string]interface{}{
+		"PrivateIpAddress": "10.20.0.0/16",
+		"PublicIp":         "1.2.3.4..1.2.3.200",
+		"path":             "/var/lib/10.20.0.0/16",
+		"os":               "ubuntu",
+	}
+	fieldTypes := func() (map[string]string, error) {
+		return map[string]string{"PrivateIpAddress": "ip", "PublicIp": "ip", "path": "string", "os": "string"}, nil
+	}
+	if err := translateIpRangeFields(req, fieldTypes); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	query, err := buildElasticsearchBaseQuery("test_index", req)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	b, _ := json.Marshal(query)
+	for _, f := range []string{
+		`"range":{"PrivateIpAddress":{"gte":"10.20.0.0","lte":"10.20.255.255"}}`,
+		`"range":{"PublicIp":{"gte":"1.2.3.4","lte":"1.2.3.200"}}`,
+		`"term":{"path":"/var/lib/10.20.0.0/16"}`,
+		`"term":{"os":"ubuntu"}`,
+	} {
+		if !strings.Contains(string(b), f) {
+			t.Fatalf("Filter missing %s: %s", f, b)
+		}
+	}
+
+	// Field types are only read for ip ranges
+	err = translateIpRangeFields(map[string]interface{}{"os": "ubuntu"}, func() (map[string]string, error) {
+		t.Fatalf("Field types should not be read")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+}
+
+func Test_aggregateBySubnet(t *testing.T) {
+	items := []AggregatedItem{
+		{Name: "10.20.1.5", Count: 2},
+		{Name: "10.20.1.6", Count: 1},
+		{Name: "10.20.2.1", Count: 4},
+		{Name: "10.30.0.1", Count: 1},
+		{Name: "unknown", Count: 1},
+	}
+
+	out, err := aggregateBySubnet(items, 24, 0)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(out) != 4 {
+		t.Fatalf("Wrong count: %#v", out)
+	}
+	if out[0].Name != "10.20.2.0/24" || out[0].Count != 4 {
+		t.Fatalf("Wrong order: %#v", out)
+	}
+	if out[1].Name != "10.20.1.0/24" || out[1].Count != 3 {
+		t.Fatalf("Wrong grouping: %#v", out)
+	}
+	if out[3].Name != "unknown" {
+		t.Fatalf("Non ip values should sort last: %#v", out)
+	}
+
+	if out, _ = aggregateBySubnet(items, 16, 1); len(out) != 1 || out[0].Name != "10.20.0.0/16" || out[0].Count != 7 {
+		t.Fatalf("Wrong result: %#v", out)
+	}
+
+	if _, err = aggregateBySubnet(items, 33, 0); err == nil {
+		t.Fatalf("Should fail with invalid prefix")
+	}
+}
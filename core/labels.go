@@ -0,0 +1,265 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vindalu/vindalu/schema"
+)
+
+// Asset data field containing labels i.e. "labels": {"env": "prod", "example.org/team": "web"}.
+// Setting a label to null removes it.
+const LABELS_FIELD = "labels"
+
+// Query field containing a label selector i.e. selector=env=prod,tier in (web,cache)
+const LABEL_SELECTOR_FIELD = "selector"
+
+// Label selector operators
+const (
+	SELECTOR_EQUALS         = "="
+	SELECTOR_NOT_EQUALS     = "!="
+	SELECTOR_IN             = "in"
+	SELECTOR_NOT_IN         = "notin"
+	SELECTOR_EXISTS         = "exists"
+	SELECTOR_DOES_NOT_EXIST = "!"
+)
+
+const (
+	MAX_LABEL_NAME_LENGTH   = 63
+	MAX_LABEL_PREFIX_LENGTH = 253
+	MAX_LABEL_VALUE_LENGTH  = 63
+)
+
+var (
+	labelNameRegex   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelPrefixRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	// <key> in (<values>) / <key> notin (<values>)
+	setRequirementRegex = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// Labels of the asset.  Labels that are not strings are skipped.
+func (a *BaseAsset) Labels() map[string]string {
+	labels := map[string]string{}
+	raw, _ := a.Data[LABELS_FIELD].(map[string]interface{})
+	for k, v := range raw {
+		if str, ok := v.(string); ok {
+			labels[k] = str
+		}
+	}
+	return labels
+}
+
+/*
+	Check a label key is of the form [<prefix>/]<name> where the prefix is a DNS subdomain and the
+	name is at most 63 alphanumeric characters, '-', '_' or '.' starting and ending with an
+	alphanumeric character.
+*/
+func validateLabelKey(key string) error {
+	name := key
+	if i := strings.Index(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if len(prefix) == 0 || len(prefix) > MAX_LABEL_PREFIX_LENGTH || !labelPrefixRegex.MatchString(prefix) {
+			return fmt.Errorf("Invalid label key '%s'. Prefix must be a DNS subdomain", key)
+		}
+	}
+	if len(name) == 0 || len(name) > MAX_LABEL_NAME_LENGTH || !labelNameRegex.MatchString(name) {
+		return fmt.Errorf("Invalid label key '%s'. Name must be at most %d alphanumeric characters, '-', '_' or '.' starting and ending with an alphanumeric character",
+			key, MAX_LABEL_NAME_LENGTH)
+	}
+	return nil
+}
+
+// Label values are empty or follow the same rules as a key name.
+func validateLabelValue(val string) error {
+	if len(val) == 0 {
+		return nil
+	}
+	if len(val) > MAX_LABEL_VALUE_LENGTH || !labelNameRegex.MatchString(val) {
+		return fmt.Errorf("Invalid label value '%s'. Must be at most %d alphanumeric characters, '-', '_' or '.' starting and ending with an alphanumeric character",
+			val, MAX_LABEL_VALUE_LENGTH)
+	}
+	return nil
+}
+
+/* Check the labels of an asset are a map of valid keys to string values or null. */
+func validateLabels(data map[string]interface{}) (violations []schema.Violation) {
+	raw, ok := data[LABELS_FIELD]
+	if !ok || raw == nil {
+		return
+	}
+
+	labels, ok := raw.(map[string]interface{})
+	if !ok {
+		violations = append(violations, schema.Violation{Path: "/" + LABELS_FIELD, Message: "must be an object"})
+		return
+	}
+
+	// Sorted for consistent output
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := labels[k]
+		path := "/" + LABELS_FIELD + "/" + k
+		if err := validateLabelKey(k); err != nil {
+			violations = append(violations, schema.Violation{Path: path, Message: err.Error()})
+			continue
+		}
+
+		switch v.(type) {
+		case nil:
+		case string:
+			if err := validateLabelValue(v.(string)); err != nil {
+				violations = append(violations, schema.Violation{Path: path, Message: err.Error()})
+			}
+		default:
+			violations = append(violations, schema.Violation{Path: path, Message: "must be a string"})
+		}
+	}
+	return
+}
+
+// Check the labels of an asset returning a ValidationError for any invalid labels.
+func validateAssetLabels(assetType, assetId string, data map[string]interface{}) error {
+	if violations := validateLabels(data); len(violations) > 0 {
+		return &ValidationError{AssetType: assetType, AssetId: assetId, Violations: violations}
+	}
+	return nil
+}
+
+// Single requirement of a label selector
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+/*
+	Parse a comma separated label selector.  All requirements must be met.  Supported requirements:
+
+		<key>=<value>, <key>==<value>   label has the value
+		<key>!=<value>                  label does not have the value or is not set
+		<key> in (<v1>,<v2>)            label has one of the values
+		<key> notin (<v1>,<v2>)         label has none of the values or is not set
+		<key>                           label is set
+		!<key>                          label is not set
+*/
+func ParseLabelSelector(selector string) (reqs []LabelRequirement, err error) {
+	for _, part := range splitLabelSelector(selector) {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		var req LabelRequirement
+		if req, err = parseLabelRequirement(part); err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+
+	if len(reqs) == 0 {
+		err = fmt.Errorf("Empty label selector")
+	}
+	return
+}
+
+// Split the selector on commas outside of value sets
+func splitLabelSelector(selector string) (parts []string) {
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, selector[start:])
+}
+
+func parseLabelRequirement(str string) (req LabelRequirement, err error) {
+	if m := setRequirementRegex.FindStringSubmatch(str); m != nil {
+		req = LabelRequirement{Key: m[1], Operator: m[2]}
+		for _, v := range strings.Split(m[3], ",") {
+			req.Values = append(req.Values, strings.TrimSpace(v))
+		}
+	} else if strings.HasPrefix(str, "!") && !strings.Contains(str, "=") {
+		req = LabelRequirement{Key: strings.TrimSpace(str[1:]), Operator: SELECTOR_DOES_NOT_EXIST}
+	} else if i := strings.Index(str, "!="); i >= 0 {
+		req = LabelRequirement{Key: strings.TrimSpace(str[:i]), Operator: SELECTOR_NOT_EQUALS,
+			Values: []string{strings.TrimSpace(str[i+2:])}}
+	} else if i := strings.Index(str, "="); i >= 0 {
+		req = LabelRequirement{Key: strings.TrimSpace(str[:i]), Operator: SELECTOR_EQUALS,
+			Values: []string{strings.TrimSpace(strings.TrimPrefix(str[i+1:], "="))}}
+	} else {
+		req = LabelRequirement{Key: str, Operator: SELECTOR_EXISTS}
+	}
+
+	if err = validateLabelKey(req.Key); err != nil {
+		return req, fmt.Errorf("Invalid label selector '%s': %s", str, err)
+	}
+	for _, v := range req.Values {
+		if err = validateLabelValue(v); err != nil {
+			return req, fmt.Errorf("Invalid label selector '%s': %s", str, err)
+		}
+	}
+	return
+}
+
+// Elasticsearch filter for the requirement
+func (lr LabelRequirement) Filter() map[string]interface{} {
+	field := LABELS_FIELD + "." + lr.Key
+
+	switch lr.Operator {
+	case SELECTOR_EQUALS:
+		return map[string]interface{}{"term": map[string]string{field: lr.Values[0]}}
+	case SELECTOR_NOT_EQUALS:
+		return map[string]interface{}{"not": map[string]interface{}{
+			"term": map[string]string{field: lr.Values[0]},
+		}}
+	case SELECTOR_IN:
+		return map[string]interface{}{"terms": map[string]interface{}{field: lr.Values}}
+	case SELECTOR_NOT_IN:
+		return map[string]interface{}{"not": map[string]interface{}{
+			"terms": map[string]interface{}{field: lr.Values},
+		}}
+	case SELECTOR_DOES_NOT_EXIST:
+		return map[string]interface{}{"missing": map[string]string{"field": field}}
+	}
+	return map[string]interface{}{"exists": map[string]string{"field": field}}
+}
+
+// Remove the label selector from the query returning the elasticsearch filters for it.
+func translateLabelSelector(req map[string]interface{}) (filters []interface{}, err error) {
+	v, ok := req[LABEL_SELECTOR_FIELD]
+	if !ok {
+		return
+	}
+	delete(req, LABEL_SELECTOR_FIELD)
+
+	selector, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("Label selector must be a string")
+	}
+
+	var reqs []LabelRequirement
+	if reqs, err = ParseLabelSelector(selector); err != nil {
+		return
+	}
+	for _, r := range reqs {
+		filters = append(filters, r.Filter())
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"testing"
+)
+
+func Test_validateLabelKey(t *testing.T) {
+	for _, k := range []string{"env", "app.name", "example.org/team", "a-b_c.d"} {
+		if err := validateLabelKey(k); err != nil {
+			t.Errorf("%s", err)
+		}
+	}
+	for _, k := range []string{"", "-env", "env-", "a b", "/env", "Example.org/team", "example.org/", "a/b/c"} {
+		if err := validateLabelKey(k); err == nil {
+			t.Errorf("Should be invalid: '%s'", k)
+		}
+	}
+}
+
+func Test_validateLabels(t *testing.T) {
+	data := map[string]interface{}{
+		LABELS_FIELD: map[string]interface{}{
+			"env":     "prod",
+			"removed": nil,
+			"empty":   "",
+			"count":   1.0,
+			"bad key": "x",
+		},
+	}
+	violations := validateLabels(data)
+	if len(violations) != 2 || violations[0].Path != "/labels/bad key" || violations[1].Path != "/labels/count" {
+		t.Fatalf("Wrong violations: %v", violations)
+	}
+
+	if violations = validateLabels(map[string]interface{}{LABELS_FIELD: "env=prod"}); len(violations) != 1 {
+		t.Fatalf("Labels must be an object: %v", violations)
+	}
+	if violations = validateLabels(map[string]interface{}{"name": "web01"}); len(violations) != 0 {
+		t.Fatalf("Labels are optional: %v", violations)
+	}
+}
+
+func Test_BaseAsset_Labels(t *testing.T) {
+	asset := BaseAsset{Data: map[string]interface{}{
+		LABELS_FIELD: map[string]interface{}{"env": "prod", "removed": nil},
+	}}
+	labels := asset.Labels()
+	if len(labels) != 1 || labels["env"] != "prod" {
+		t.Fatalf("Wrong labels: %v", labels)
+	}
+}
+
+func Test_ParseLabelSelector(t *testing.T) {
+	reqs, err := ParseLabelSelector("env=prod, tier==web,zone != us-east,app in (web, cache),role notin (db),ready,!deprecated")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []LabelRequirement{
+		{"env", SELECTOR_EQUALS, []string{"prod"}},
+		{"tier", SELECTOR_EQUALS, []string{"web"}},
+		{"zone", SELECTOR_NOT_EQUALS, []string{"us-east"}},
+		{"app", SELECTOR_IN, []string{"web", "cache"}},
+		{"role", SELECTOR_NOT_IN, []string{"db"}},
+		{"ready", SELECTOR_EXISTS, nil},
+		{"deprecated", SELECTOR_DOES_NOT_EXIST, nil},
+	}
+	if len(reqs) != len(expected) {
+		t.Fatalf("Wrong requirements: %v", reqs)
+	}
+	for i, r := range reqs {
+		e := expected[i]
+		if r.Key != e.Key || r.Operator != e.Operator || len(r.Values) != len(e.Values) {
+			t.Fatalf("Wrong requirement: %v != %v", r, e)
+		}
+		for j := range r.Values {
+			if r.Values[j] != e.Values[j] {
+				t.Fatalf("Wrong requirement: %v != %v", r, e)
+			}
+		}
+	}
+
+	for _, s := range []string{"", " , ", "bad key=x", "env=bad value", "env in (a b)"} {
+		if _, err = ParseLabelSelector(s); err == nil {
+			t.Errorf("Should be invalid: '%s'", s)
+		}
+	}
+}
+
+func Test_translateLabelSelector(t *testing.T) {
+	req := map[string]interface{}{"name": "web01", LABEL_SELECTOR_FIELD: "env=prod,!deprecated"}
+	filters, err := translateLabelSelector(req)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, ok := req[LABEL_SELECTOR_FIELD]; ok || len(req) != 1 {
+		t.Fatalf("Selector should be removed from query: %v", req)
+	}
+	if len(filters) != 2 {
+		t.Fatalf("Wrong filters: %v", filters)
+	}
+	if term, _ := filters[0].(map[string]interface{})["term"].(map[string]string); term["labels.env"] != "prod" {
+		t.Fatalf("Wrong filter: %v", filters[0])
+	}
+	if missing, _ := filters[1].(map[string]interface{})["missing"].(map[string]string); missing["field"] != "labels.deprecated" {
+		t.Fatalf("Wrong filter: %v", filters[1])
+	}
+
+	if filters, err = translateLabelSelector(map[string]interface{}{"name": "web01"}); err != nil || len(filters) != 0 {
+		t.Fatalf("No selector should not add filters: %v %v", filters, err)
+	}
+	if _, err = translateLabelSelector(map[string]interface{}{LABEL_SELECTOR_FIELD: 1.0}); err == nil {
+		t.Fatalf("Should fail on non string selector")
+	}
+}
+
+func Test_buildElasticsearchQuery_selector(t *testing.T) {
+	q := map[string]interface{}{LABEL_SELECTOR_FIELD: "env in (prod,staging)"}
+	query, err := buildElasticsearchQuery("test_index", q, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, ok := query["query"]; !ok {
+		t.Fatalf("Selector filter missing: %v", query)
+	}
+
+	if _, err = buildElasticsearchQuery("test_index", map[string]interface{}{LABEL_SELECTOR_FIELD: "env in"}, nil); err == nil {
+		t.Fatalf("Should fail on invalid selector")
+	}
+}
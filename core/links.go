@@ -0,0 +1,156 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vindalu/vindalu/schema"
+)
+
+// Asset data field containing links to other assets keyed by relation name. Each relation is a
+// reference or a list of references i.e. "_links": {"pool": "pool/web", "members": ["server/web01"]}
+const LINKS_FIELD = "_links"
+
+// Reference to an asset in the form <type>/<id>
+type AssetRef struct {
+	Type string
+	Id   string
+}
+
+func ParseAssetRef(ref string) (AssetRef, error) {
+	parts := strings.SplitN(ref, "/", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return AssetRef{}, fmt.Errorf("Invalid asset reference '%s'. Must be <type>/<id>", ref)
+	}
+	return AssetRef{Type: parts[0], Id: parts[1]}, nil
+}
+
+func (ar AssetRef) String() string {
+	return ar.Type + "/" + ar.Id
+}
+
+func (ar AssetRef) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ar.String())
+}
+
+func (ar *AssetRef) UnmarshalJSON(b []byte) (err error) {
+	var ref string
+	if err = json.Unmarshal(b, &ref); err == nil {
+		*ar, err = ParseAssetRef(ref)
+	}
+	return
+}
+
+// Link from one asset to another
+type AssetLink struct {
+	Source   AssetRef `json:"source"`
+	Target   AssetRef `json:"target"`
+	Relation string   `json:"relation"`
+}
+
+// Assets to remove and links to remove from other assets in order to remove an asset.
+type RemovalPlan struct {
+	// The asset itself followed by cascaded removals
+	Remove []AssetRef
+	// Links to be removed from remaining assets
+	Unlink []AssetLink
+}
+
+/*
+	Parse the links of an asset.  Null relations are skipped.  Invalid relations are returned as
+	violations.
+*/
+func parseAssetLinks(source AssetRef, data map[string]interface{}) (links []AssetLink, violations []schema.Violation) {
+	raw, ok := data[LINKS_FIELD]
+	if !ok || raw == nil {
+		return
+	}
+
+	rels, ok := raw.(map[string]interface{})
+	if !ok {
+		violations = append(violations, schema.Violation{Path: "/" + LINKS_FIELD, Message: "must be an object"})
+		return
+	}
+
+	// Sorted for consistent output
+	names := make([]string, 0, len(rels))
+	for k := range rels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, rel := range names {
+		path := "/" + LINKS_FIELD + "/" + rel
+
+		var refs []interface{}
+		switch rels[rel].(type) {
+		case nil:
+			continue
+		case string:
+			refs = []interface{}{rels[rel]}
+		case []interface{}:
+			refs = rels[rel].([]interface{})
+		default:
+			violations = append(violations, schema.Violation{Path: path, Message: "must be a reference or list of references"})
+			continue
+		}
+
+		for i, r := range refs {
+			refPath := path
+			if _, isList := rels[rel].([]interface{}); isList {
+				refPath = fmt.Sprintf("%s/%d", path, i)
+			}
+
+			str, _ := r.(string)
+			target, err := ParseAssetRef(str)
+			if err != nil {
+				violations = append(violations, schema.Violation{Path: refPath, Message: "must be a reference in the form <type>/<id>"})
+				continue
+			}
+			links = append(links, AssetLink{Source: source, Target: target, Relation: rel})
+		}
+	}
+	return
+}
+
+/*
+	Value of a relation with the target removed.  Lists have the target removed while single
+	references are set to null.
+*/
+func unlinkedRelationValue(value interface{}, target AssetRef) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := []interface{}{}
+	for _, v := range list {
+		if str, _ := v.(string); str != target.String() {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+/*
+	Value of a relation with references to the target replaced by the new reference i.e. when the
+	target is renamed.
+*/
+func relinkedRelationValue(value interface{}, target, to AssetRef) interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		return to.String()
+	}
+
+	out := make([]interface{}, len(list))
+	for i, v := range list {
+		if str, _ := v.(string); str == target.String() {
+			out[i] = to.String()
+		} else {
+			out[i] = v
+		}
+	}
+	return out
+}
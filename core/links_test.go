@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_ParseAssetRef(t *testing.T) {
+	ref, err := ParseAssetRef("pool/web-pool")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if ref.Type != "pool" || ref.Id != "web-pool" || ref.String() != "pool/web-pool" {
+		t.Fatalf("Wrong ref: %#v", ref)
+	}
+
+	for _, v := range []string{"pool", "pool/", "/web-pool", ""} {
+		if _, err = ParseAssetRef(v); err == nil {
+			t.Fatalf("Should fail: %s", v)
+		}
+	}
+}
+
+func Test_AssetRef_json(t *testing.T) {
+	b, _ := json.Marshal(AssetLink{
+		Source:   AssetRef{Type: "server", Id: "web01"},
+		Target:   AssetRef{Type: "pool", Id: "web"},
+		Relation: "pool",
+	})
+	if string(b) != `{"source":"server/web01","target":"pool/web","relation":"pool"}` {
+		t.Fatalf("Wrong json: %s", b)
+	}
+
+	var l AssetLink
+	if err := json.Unmarshal(b, &l); err != nil || l.Target.Id != "web" {
+		t.Fatalf("Round trip failed: %#v %v", l, err)
+	}
+}
+
+func Test_parseAssetLinks(t *testing.T) {
+	source := AssetRef{Type: "vip", Id: "vip01"}
+	data := map[string]interface{}{
+		LINKS_FIELD: map[string]interface{}{
+			"pool":    "pool/web",
+			"members": []interface{}{"server/web01", "server/web02"},
+			"backup":  nil,
+		},
+	}
+
+	links, violations := parseAssetLinks(source, data)
+	if len(violations) != 0 {
+		t.Fatalf("Should not have violations: %v", violations)
+	}
+	if len(links) != 3 || links[0].Relation != "members" || links[2].Target.Id != "web" {
+		t.Fatalf("Wrong links: %v", links)
+	}
+
+	data[LINKS_FIELD] = map[string]interface{}{
+		"pool":    "web",
+		"members": []interface{}{"server/web01", 1},
+		"other":   true,
+	}
+	if links, violations = parseAssetLinks(source, data); len(violations) != 3 || len(links) != 1 {
+		t.Fatalf("Wrong violations: %v %v", links, violations)
+	}
+	if violations[0].Path != "/_links/members/1" {
+		t.Fatalf("Wrong path: %v", violations[0])
+	}
+
+	if _, violations = parseAssetLinks(source, map[string]interface{}{LINKS_FIELD: "pool/web"}); len(violations) != 1 {
+		t.Fatalf("Should be an object: %v", violations)
+	}
+}
+
+func Test_unlinkedRelationValue(t *testing.T) {
+	target := AssetRef{Type: "server", Id: "web01"}
+
+	if v := unlinkedRelationValue("server/web01", target); v != nil {
+		t.Fatalf("Should be null: %v", v)
+	}
+
+	v, _ := unlinkedRelationValue([]interface{}{"server/web01", "server/web02"}, target).([]interface{})
+	if len(v) != 1 || v[0] != "server/web02" {
+		t.Fatalf("Should be removed from list: %v", v)
+	}
+}
+
+func Test_relinkedRelationValue(t *testing.T) {
+	target := AssetRef{Type: "server", Id: "web01"}
+	to := AssetRef{Type: "server", Id: "web01.example.org"}
+
+	if v := relinkedRelationValue("server/web01", target, to); v != to.String() {
+		t.Fatalf("Should be replaced: %v", v)
+	}
+
+	v, _ := relinkedRelationValue([]interface{}{"server/web01", "server/web02"}, target, to).([]interface{})
+	if len(v) != 2 || v[0] != to.String() || v[1] != "server/web02" {
+		t.Fatalf("Should be replaced in list: %v", v)
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Separator between a field and its match operator in a query i.e. <field>:<operator>=<value>
+const MATCH_OPERATOR_SEPARATOR = ":"
+
+// Explicit match operators.  These override the default behavior of choosing a term, range or regex
+// search based on the value.
+const (
+	MATCH_LITERAL   = "literal"   // exact match.  Regex characters are not interpreted.
+	MATCH_IEQ       = "ieq"       // case-insensitive exact match
+	MATCH_PREFIX    = "prefix"    // value starts with
+	MATCH_IPREFIX   = "iprefix"   // value starts with (case-insensitive)
+	MATCH_SUFFIX    = "suffix"    // value ends with
+	MATCH_ISUFFIX   = "isuffix"   // value ends with (case-insensitive)
+	MATCH_CONTAINS  = "contains"  // value contains
+	MATCH_ICONTAINS = "icontains" // value contains (case-insensitive)
+	MATCH_IP_RANGE  = "iprange"   // address within a CIDR or <start_ip>..<end_ip> range
+)
+
+var matchOperators = map[string]bool{
+	MATCH_LITERAL: true, MATCH_IEQ: true, MATCH_PREFIX: true, MATCH_IPREFIX: true, MATCH_SUFFIX: true,
+	MATCH_ISUFFIX: true, MATCH_CONTAINS: true, MATCH_ICONTAINS: true, MATCH_IP_RANGE: true,
+}
+
+/*
+	Split a query key into the field and match operator.  Only a known operator after the last
+	separator is split off so fields containing the separator can be queried.  The operator is
+	empty if not specified.
+*/
+func splitFieldOperator(key string) (field, op string) {
+	i := strings.LastIndex(key, MATCH_OPERATOR_SEPARATOR)
+	if i < 1 || !matchOperators[key[i+1:]] {
+		return key, ""
+	}
+	return key[:i], key[i+1:]
+}
+
+// Generate an ESS filter for the field value using the given match operator.
+func matchOperatorFilter(field, op, val string) (map[string]interface{}, error) {
+	switch op {
+	case MATCH_LITERAL:
+		return map[string]interface{}{
+			"term": map[string]string{field: val},
+		}, nil
+	case MATCH_PREFIX:
+		return map[string]interface{}{
+			"prefix": map[string]string{field: val},
+		}, nil
+	case MATCH_IEQ:
+		return regexFilter(field, caseInsensitiveRegex(val)), nil
+	case MATCH_IPREFIX:
+		return regexFilter(field, caseInsensitiveRegex(val)+".*"), nil
+	case MATCH_SUFFIX:
+		return regexFilter(field, ".*"+escapeRegex(val)), nil
+	case MATCH_ISUFFIX:
+		return regexFilter(field, ".*"+caseInsensitiveRegex(val)), nil
+	case MATCH_CONTAINS:
+		return regexFilter(field, ".*"+escapeRegex(val)+".*"), nil
+	case MATCH_ICONTAINS:
+		return regexFilter(field, ".*"+caseInsensitiveRegex(val)+".*"), nil
+	case MATCH_IP_RANGE:
+		if f, ok := ipRangeFilter(field, strings.TrimSpace(val)); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("Invalid ip range for field %s: %s", field, val)
+	}
+	return nil, fmt.Errorf("Invalid match operator '%s' for field: %s", op, field)
+}
+
+/*
+	Regex of a match operator matching whole values i.e. prefix web -> web.*  false for operators
+	that cannot be expressed as a regex.
+*/
+func matchOperatorRegex(op, val string) (string, bool) {
+	switch op {
+	case MATCH_LITERAL:
+		return escapeRegex(val), true
+	case MATCH_IEQ:
+		return caseInsensitiveRegex(val), true
+	case MATCH_PREFIX:
+		return escapeRegex(val) + ".*", true
+	case MATCH_IPREFIX:
+		return caseInsensitiveRegex(val) + ".*", true
+	case MATCH_SUFFIX:
+		return ".*" + escapeRegex(val), true
+	case MATCH_ISUFFIX:
+		return ".*" + caseInsensitiveRegex(val), true
+	case MATCH_CONTAINS:
+		return ".*" + escapeRegex(val) + ".*", true
+	case MATCH_ICONTAINS:
+		return ".*" + caseInsensitiveRegex(val) + ".*", true
+	}
+	return "", false
+}
+
+// Regex literally matching the string regardless of case i.e. Web01 -> [wW][eE][bB]01
+func caseInsensitiveRegex(str string) string {
+	var out []string
+	for _, r := range str {
+		lower, upper := unicode.ToLower(r), unicode.ToUpper(r)
+		if lower != upper {
+			out = append(out, "["+string(lower)+string(upper)+"]")
+		} else {
+			out = append(out, escapeRegex(string(r)))
+		}
+	}
+	return strings.Join(out, "")
+}
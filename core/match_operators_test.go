@@ -0,0 +1,56 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_splitFieldOperator(t *testing.T) {
+	tests := map[string][2]string{
+		"hostname":        {"hostname", ""},
+		"hostname:ieq":    {"hostname", "ieq"},
+		"hostname:prefix": {"hostname", "prefix"},
+		":ieq":            {":ieq", ""},
+		"labels:env":      {"labels:env", ""},
+		"a:b:prefix":      {"a:b", "prefix"},
+		"hostname:ieq:x":  {"hostname:ieq:x", ""},
+	}
+	for key, expected := range tests {
+		if f, op := splitFieldOperator(key); f != expected[0] || op != expected[1] {
+			t.Fatalf("%s: %s %s", key, f, op)
+		}
+	}
+}
+
+func Test_caseInsensitiveRegex(t *testing.T) {
+	if re := caseInsensitiveRegex("Web-01.a"); re != `[wW][eE][bB]-01\.[aA]` {
+		t.Fatalf("Mismatch: %s", re)
+	}
+}
+
+func Test_matchOperatorFilter(t *testing.T) {
+	tests := map[string]string{
+		MATCH_LITERAL:   `{"term":{"hostname":"web*|$"}}`,
+		MATCH_PREFIX:    `{"prefix":{"hostname":"web*|$"}}`,
+		MATCH_IEQ:       `{"regexp":{"hostname":"[wW][eE][bB]\\*\\|$"}}`,
+		MATCH_IPREFIX:   `{"regexp":{"hostname":"[wW][eE][bB]\\*\\|$.*"}}`,
+		MATCH_SUFFIX:    `{"regexp":{"hostname":".*web\\*\\|$"}}`,
+		MATCH_ISUFFIX:   `{"regexp":{"hostname":".*[wW][eE][bB]\\*\\|$"}}`,
+		MATCH_CONTAINS:  `{"regexp":{"hostname":".*web\\*\\|$.*"}}`,
+		MATCH_ICONTAINS: `{"regexp":{"hostname":".*[wW][eE][bB]\\*\\|$.*"}}`,
+	}
+	for op, expected := range tests {
+		f, err := matchOperatorFilter("hostname", op, "web*|$")
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		b, _ := json.Marshal(f)
+		if string(b) != expected {
+			t.Fatalf("%s: %s != %s", op, b, expected)
+		}
+	}
+
+	if _, err := matchOperatorFilter("hostname", "foo", "web"); err == nil {
+		t.Fatalf("Should fail with invalid operator")
+	}
+}
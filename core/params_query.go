@@ -0,0 +1,76 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/vindalu/vindalu/types"
+)
+
+/*
+	Filter of a search given as url params i.e. status=enabled&os=ubuntu.  Search options are
+	excluded.  Repeated params match any of their values except label selectors which must all be
+	met.
+*/
+func ParamsFilter(params url.Values) map[string]interface{} {
+	filter := map[string]interface{}{}
+	for k, v := range params {
+		if k == LABEL_SELECTOR_FIELD {
+			filter[k] = strings.Join(v, ",")
+		} else if !IsSearchParamOption(k) {
+			filter[k] = strings.Join(v, "|")
+		}
+	}
+	return filter
+}
+
+// Overlay a filter and the options in params on top of a saved query.
+func ApplySavedQuery(sq SavedQuery, assetType string, filter map[string]interface{}, params url.Values) (string, map[string]interface{}, types.QueryOptions, error) {
+	qo := sq.Options
+
+	if len(assetType) == 0 {
+		assetType = sq.AssetType
+	} else if len(sq.AssetType) > 0 && assetType != sq.AssetType {
+		return "", nil, qo, fmt.Errorf("Saved query '%s' is for type: %s", sq.Name, sq.AssetType)
+	}
+
+	query := map[string]interface{}{}
+	for k, v := range sq.Query {
+		query[k] = v
+	}
+	for k, v := range filter {
+		query[k] = v
+	}
+
+	err := qo.Update(params)
+	return assetType, query, qo, err
+}
+
+/*
+	Asset type, filter and options of a search given as url params.  `filter` is applied over the
+	filter in the params i.e. one from a request body.  When the `saved_query` param is supplied
+	the saved query is used as the base.
+*/
+func (vc *VindaluCore) QueryFromParams(assetType string, params url.Values, filter map[string]interface{}) (string, map[string]interface{}, types.QueryOptions, error) {
+	query := ParamsFilter(params)
+	for k, v := range filter {
+		query[k] = v
+	}
+
+	if name := params.Get("saved_query"); len(name) > 0 {
+		sq, err := vc.GetSavedQuery(name)
+		if err != nil {
+			return "", nil, types.QueryOptions{}, err
+		}
+		return ApplySavedQuery(sq, assetType, query, params)
+	}
+
+	qo, err := types.NewQueryOptions(params)
+	return assetType, query, qo, err
+}
+
+// Make all writes searchable
+func (vc *VindaluCore) Refresh() error {
+	return vc.datastore.Refresh()
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/vindalu/vindalu/types"
+)
+
+func Test_ParamsFilter(t *testing.T) {
+	params, _ := url.ParseQuery("os=xenserver&os=ubuntu&selector=env=prod&selector=team=web&size=5")
+	filter := ParamsFilter(params)
+	if len(filter) != 2 || filter["os"] != "xenserver|ubuntu" || filter[LABEL_SELECTOR_FIELD] != "env=prod,team=web" {
+		t.Fatalf("Wrong filter: %v", filter)
+	}
+}
+
+func Test_ApplySavedQuery(t *testing.T) {
+	sq := SavedQuery{
+		Name:      "ubuntu_pool",
+		AssetType: "pool",
+		Query:     map[string]interface{}{"os": "ubuntu", "status": "enabled"},
+		Options:   types.QueryOptions{From: 0, Size: 10, Aggregate: "status"},
+	}
+
+	params, _ := url.ParseQuery("size=5&status=disabled")
+	assetType, query, qo, err := ApplySavedQuery(sq, "", map[string]interface{}{"status": "disabled"}, params)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if assetType != "pool" {
+		t.Fatalf("Asset type mismatch: %s", assetType)
+	}
+	if query["os"] != "ubuntu" || query["status"] != "disabled" {
+		t.Fatalf("User filter not applied: %#v", query)
+	}
+	if qo.Size != 5 || qo.Aggregate != "status" {
+		t.Fatalf("Options mismatch: %#v", qo)
+	}
+	// Saved query should not be modified
+	if sq.Query["status"] != "enabled" || sq.Options.Size != 10 {
+		t.Fatalf("Saved query modified: %#v", sq)
+	}
+
+	if _, _, _, err = ApplySavedQuery(sq, "virtualserver", map[string]interface{}{}, url.Values{}); err == nil {
+		t.Fatalf("Should fail with mismatched type")
+	}
+}
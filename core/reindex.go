@@ -0,0 +1,455 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/gnatsd/server"
+)
+
+const (
+	REINDEX_IDLE      = "idle"
+	REINDEX_RUNNING   = "running"
+	REINDEX_COMPLETED = "completed"
+	REINDEX_FAILED    = "failed"
+)
+
+// Copy of an aliased index into a new index
+type ReindexProgress struct {
+	Alias  string `json:"alias"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Total  int64  `json:"total"`
+	Copied int64  `json:"copied"`
+}
+
+type ReindexStatus struct {
+	State    string            `json:"state"`
+	User     string            `json:"user,omitempty"`
+	KeepOld  bool              `json:"keep_old,omitempty"`
+	Started  int64             `json:"started,omitempty"`
+	Finished int64             `json:"finished,omitempty"`
+	Indices  []ReindexProgress `json:"indices,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+/*
+	Runs reindexes of the primary and version indices in the background and tracks their progress.
+	Only one runs at a time.
+*/
+type Reindexer struct {
+	ds *ElasticsearchDatastore
+
+	mu     sync.Mutex
+	status ReindexStatus
+
+	log server.Logger
+}
+
+func NewReindexer(ds *ElasticsearchDatastore, log server.Logger) *Reindexer {
+	return &Reindexer{ds: ds, status: ReindexStatus{State: REINDEX_IDLE}, log: log}
+}
+
+// Whether a reindex is in progress.  Writes are blocked on the indices being copied.
+func (rx *Reindexer) Running() bool {
+	rx.mu.Lock()
+	defer rx.mu.Unlock()
+	return rx.status.State == REINDEX_RUNNING
+}
+
+func (rx *Reindexer) Status() ReindexStatus {
+	rx.mu.Lock()
+	defer rx.mu.Unlock()
+
+	status := rx.status
+	status.Indices = make([]ReindexProgress, len(rx.status.Indices))
+	copy(status.Indices, rx.status.Indices)
+	return status
+}
+
+/*
+	Start a reindex in the background.  The new indices are resolved before returning so
+	configuration errors are returned immediately.  `done` is called once the aliases have been
+	swapped.
+*/
+func (rx *Reindexer) Start(user string, keepOld bool, done func()) (ReindexStatus, error) {
+	rx.mu.Lock()
+	if rx.status.State == REINDEX_RUNNING {
+		rx.mu.Unlock()
+		return rx.Status(), &ConflictError{Reason: "Reindex already in progress"}
+	}
+	rx.status = ReindexStatus{State: REINDEX_RUNNING, User: user, KeepOld: keepOld, Started: nowMillis()}
+	rx.mu.Unlock()
+
+	indices, err := rx.ds.reindexPlan()
+	if err != nil {
+		rx.finish(err)
+		return rx.Status(), err
+	}
+
+	rx.mu.Lock()
+	rx.status.Indices = indices
+	rx.mu.Unlock()
+
+	go func() {
+		err := rx.ds.Reindex(indices, keepOld, rx.setProgress)
+		rx.finish(err)
+		if err == nil && done != nil {
+			done()
+		}
+	}()
+	return rx.Status(), nil
+}
+
+func (rx *Reindexer) setProgress(i int, total, copied int64) {
+	rx.mu.Lock()
+	rx.status.Indices[i].Total = total
+	rx.status.Indices[i].Copied = copied
+	rx.mu.Unlock()
+}
+
+func (rx *Reindexer) finish(err error) {
+	rx.mu.Lock()
+	defer rx.mu.Unlock()
+
+	rx.status.Finished = nowMillis()
+	if err != nil {
+		rx.status.State = REINDEX_FAILED
+		rx.status.Error = err.Error()
+		rx.log.Errorf("Reindex failed: %s\n", err)
+	} else {
+		rx.status.State = REINDEX_COMPLETED
+		rx.log.Noticef("Reindex completed in %dms\n", rx.status.Finished-rx.status.Started)
+	}
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / 1000000
+}
+
+// Name of the n'th index behind an alias i.e. vindalu_v2
+func versionedIndexName(alias string, n int) string {
+	return fmt.Sprintf("%s_v%d", alias, n)
+}
+
+/*
+	Index to copy an aliased index into.  Indices created by older versions are named after the
+	alias and are followed by the first versioned index.
+*/
+func nextIndexName(alias, current string) string {
+	n, err := strconv.Atoi(strings.TrimPrefix(current, alias+"_v"))
+	if !strings.HasPrefix(current, alias+"_v") || err != nil {
+		n = 0
+	}
+	return versionedIndexName(alias, n+1)
+}
+
+// Current and new index of the primary and version aliases
+func (e *ElasticsearchDatastore) reindexPlan() ([]ReindexProgress, error) {
+	plan := []ReindexProgress{}
+	for _, alias := range []string{e.Index, e.VersionIndex} {
+		indices, err := e.aliasIndices(alias)
+		if err != nil {
+			return nil, err
+		}
+
+		p := ReindexProgress{Alias: alias}
+		switch len(indices) {
+		case 0:
+			if !e.Conn.IndexExists(alias) {
+				return nil, &NotFoundError{Kind: "Index", Name: alias}
+			}
+			p.From = alias
+		case 1:
+			p.From = indices[0]
+		default:
+			return nil, &ConflictError{Reason: fmt.Sprintf("Alias %s points to multiple indices: %s",
+				alias, strings.Join(indices, ", "))}
+		}
+		p.To = nextIndexName(alias, p.From)
+
+		if p.Total, err = e.countDocs(p.From); err != nil {
+			return nil, err
+		}
+		plan = append(plan, p)
+	}
+	return plan, nil
+}
+
+/*
+	Copy each index into a new one with the current mappings and swap the aliases over to the new
+	indices in a single request.  Writes to the old indices are blocked while copying and reads
+	are served from them until the swap.  The old indices are removed unless `keepOld` is set.
+	Indices created by older versions are always removed as the alias takes their name.  They are
+	removed in the same request as the swap so the name is never left without an index, or just
+	before it with elasticsearch versions lacking the `remove_index` action.
+*/
+func (e *ElasticsearchDatastore) Reindex(indices []ReindexProgress, keepOld bool, progress func(i int, total, copied int64)) (err error) {
+	var created, blocked []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, index := range blocked {
+			if uerr := e.setWriteBlock(index, false); uerr != nil {
+				e.log.Errorf("Failed to unblock writes (%s): %s\n", index, uerr)
+			}
+		}
+		for _, index := range created {
+			if _, derr := e.Conn.DoCommand("DELETE", "/"+index, nil, nil); derr != nil {
+				e.log.Errorf("Failed to remove index %s: %s\n", index, derr)
+			}
+		}
+	}()
+
+	for _, p := range indices {
+		if err = e.prepareIndex(p.From, p.To); err != nil {
+			return
+		}
+		created = append(created, p.To)
+		e.log.Noticef("Reindex: %s created for %s\n", p.To, p.Alias)
+	}
+
+	for _, p := range indices {
+		if err = e.setWriteBlock(p.From, true); err != nil {
+			return
+		}
+		blocked = append(blocked, p.From)
+	}
+
+	for i, p := range indices {
+		var total, copied int64
+		// Counted again now that writes are blocked
+		if total, err = e.countDocs(p.From); err != nil {
+			return
+		}
+		progress(i, total, 0)
+
+		if copied, err = e.copyIndex(p.From, p.To, func(n int64) { progress(i, total, n) }); err != nil {
+			return fmt.Errorf("Failed to copy %s to %s: %s", p.From, p.To, err)
+		}
+		if _, err = e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_refresh", p.To), nil, nil); err != nil {
+			return
+		}
+		if copied != total {
+			return fmt.Errorf("Copied %d of %d documents from %s", copied, total, p.From)
+		}
+		e.log.Noticef("Reindex: %d documents copied from %s to %s\n", copied, p.From, p.To)
+	}
+
+	var removeIndex bool
+	if removeIndex, err = e.supportsRemoveIndex(); err != nil {
+		return
+	}
+	if !removeIndex {
+		// Indices created by older versions have to be removed before the alias can take their
+		// name.  The copies are kept from here on.
+		for _, p := range indices {
+			if p.From != p.Alias {
+				continue
+			}
+			created, blocked = nil, nil
+			if _, err = e.Conn.DoCommand("DELETE", "/"+p.From, nil, nil); err != nil {
+				return fmt.Errorf("Failed to remove index %s: %s", p.From, err)
+			}
+		}
+	}
+
+	if _, err = e.Conn.DoCommand("POST", "/_aliases", nil,
+		map[string]interface{}{"actions": aliasSwapActions(indices, removeIndex)}); err != nil {
+		return fmt.Errorf("Failed to swap aliases: %s", err)
+	}
+
+	for _, p := range indices {
+		if keepOld || p.From == p.Alias {
+			continue
+		}
+		if _, derr := e.Conn.DoCommand("DELETE", "/"+p.From, nil, nil); derr != nil {
+			e.log.Errorf("Failed to remove index %s: %s\n", p.From, derr)
+		}
+	}
+	return nil
+}
+
+// Create the new index with the settings of the current one, the mapping files and then the
+// current mappings.  Conflicting fields keep the mapping from the files.
+func (e *ElasticsearchDatastore) prepareIndex(from, to string) error {
+	if e.Conn.IndexExists(to) {
+		return &ConflictError{Reason: fmt.Sprintf("Index already exists: %s", to)}
+	}
+
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/%s/_settings", from), nil, nil)
+	if err != nil {
+		return err
+	}
+	var resp map[string]struct {
+		Settings struct {
+			Index struct {
+				Shards   string `json:"number_of_shards"`
+				Replicas string `json:"number_of_replicas"`
+			} `json:"index"`
+		} `json:"settings"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+	settings := map[string]interface{}{}
+	for _, s := range resp {
+		settings["number_of_shards"] = s.Settings.Index.Shards
+		settings["number_of_replicas"] = s.Settings.Index.Replicas
+	}
+
+	if err = e.createIndex(to, settings, ""); err != nil {
+		return err
+	}
+
+	if len(e.MappingsDir) > 0 {
+		if err = e.Conn.ApplyMappingDir(to, e.MappingsDir, true); err != nil {
+			return err
+		}
+	}
+
+	mappings, err := e.indexMappings(from)
+	if err != nil {
+		return err
+	}
+	return e.putMappings(to, mappings)
+}
+
+// Copy all documents keeping their timestamps.  `progress` is called after each batch.
+func (e *ElasticsearchDatastore) copyIndex(from, to string, progress func(copied int64)) (copied int64, err error) {
+	var (
+		buf     bytes.Buffer
+		pending int64
+	)
+	flush := func() error {
+		if err := execBulk(e.Conn, buf.String()); err != nil {
+			return err
+		}
+		copied += pending
+		pending = 0
+		buf.Reset()
+		progress(copied)
+		return nil
+	}
+
+	if err = e.scanIndex(from, func(h archiveHit) error {
+		asset := BaseAsset{Id: h.Id, Type: h.Type, Timestamp: h.Fields["_timestamp"]}
+		if err := writeBulkItem(&buf, archiveAssetAction(to, asset, h.Id), h.Source); err != nil {
+			return err
+		}
+		if pending++; pending >= ARCHIVE_BATCH_SIZE {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+
+	if pending > 0 {
+		err = flush()
+	}
+	return
+}
+
+// Alias actions moving each alias to its new index.  Indices named after the alias have no alias
+// to remove.  With `removeIndex` they are removed so the alias can take their name.
+func aliasSwapActions(indices []ReindexProgress, removeIndex bool) []interface{} {
+	actions := []interface{}{}
+	for _, p := range indices {
+		if p.From != p.Alias {
+			actions = append(actions, map[string]interface{}{
+				"remove": map[string]string{"index": p.From, "alias": p.Alias}})
+		} else if removeIndex {
+			actions = append(actions, map[string]interface{}{
+				"remove_index": map[string]string{"index": p.From}})
+		}
+		actions = append(actions, map[string]interface{}{
+			"add": map[string]string{"index": p.To, "alias": p.Alias}})
+	}
+	return actions
+}
+
+// Indices an alias points to.  Empty if it is not an alias.
+func (e *ElasticsearchDatastore) aliasIndices(alias string) ([]string, error) {
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/_alias/%s", alias), nil, nil)
+	if err != nil {
+		if isNotFoundResponse(b) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	var resp map[string]interface{}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	indices := []string{}
+	for index := range resp {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// Create an index optionally with settings and an alias
+func (e *ElasticsearchDatastore) createIndex(index string, settings map[string]interface{}, alias string) error {
+	body := map[string]interface{}{}
+	if len(settings) > 0 {
+		body["settings"] = settings
+	}
+	if len(alias) > 0 {
+		body["aliases"] = map[string]interface{}{alias: map[string]interface{}{}}
+	}
+	_, err := e.Conn.DoCommand("PUT", "/"+index, nil, body)
+	return err
+}
+
+// Whether the `remove_index` alias action is available i.e. elasticsearch 6.4 and later
+func (e *ElasticsearchDatastore) supportsRemoveIndex() (bool, error) {
+	info, err := e.Conn.Info()
+	if err != nil {
+		return false, err
+	}
+	return versionAtLeast(info.Version.Number, 6, 4), nil
+}
+
+// Whether a version number i.e. 1.4.5 is at least major.minor
+func versionAtLeast(number string, major, minor int) bool {
+	parts := strings.SplitN(number, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	maj, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	min, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return maj > major || (maj == major && min >= minor)
+}
+
+func (e *ElasticsearchDatastore) setWriteBlock(index string, block bool) error {
+	_, err := e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/_settings", index), nil,
+		map[string]interface{}{"index.blocks.write": block})
+	return err
+}
+
+func (e *ElasticsearchDatastore) countDocs(index string) (int64, error) {
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/%s/_count", index), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	err = json.Unmarshal(b, &resp)
+	return resp.Count, err
+}
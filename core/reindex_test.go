@@ -0,0 +1,76 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_nextIndexName(t *testing.T) {
+	for current, expected := range map[string]string{
+		"vindalu":             "vindalu_v1",
+		"vindalu_v1":          "vindalu_v2",
+		"vindalu_v12":         "vindalu_v13",
+		"vindalu_versions_v3": "vindalu_v1",
+	} {
+		if next := nextIndexName("vindalu", current); next != expected {
+			t.Fatalf("Wrong next index for %s: %s", current, next)
+		}
+	}
+	if next := nextIndexName("vindalu_versions", "vindalu_versions_v3"); next != "vindalu_versions_v4" {
+		t.Fatalf("Wrong next version index: %s", next)
+	}
+}
+
+func Test_aliasSwapActions(t *testing.T) {
+	indices := []ReindexProgress{
+		{Alias: "vindalu", From: "vindalu_v1", To: "vindalu_v2"},
+		{Alias: "vindalu_versions", From: "vindalu_versions", To: "vindalu_versions_v1"},
+	}
+
+	b, _ := json.Marshal(aliasSwapActions(indices, false))
+	expected := `[{"remove":{"alias":"vindalu","index":"vindalu_v1"}},` +
+		`{"add":{"alias":"vindalu","index":"vindalu_v2"}},` +
+		`{"add":{"alias":"vindalu_versions","index":"vindalu_versions_v1"}}]`
+	if string(b) != expected {
+		t.Fatalf("Wrong actions: %s", b)
+	}
+
+	b, _ = json.Marshal(aliasSwapActions(indices, true))
+	expected = `[{"remove":{"alias":"vindalu","index":"vindalu_v1"}},` +
+		`{"add":{"alias":"vindalu","index":"vindalu_v2"}},` +
+		`{"remove_index":{"index":"vindalu_versions"}},` +
+		`{"add":{"alias":"vindalu_versions","index":"vindalu_versions_v1"}}]`
+	if string(b) != expected {
+		t.Fatalf("Wrong actions: %s", b)
+	}
+}
+
+func Test_versionAtLeast(t *testing.T) {
+	for number, expected := range map[string]bool{
+		"1.4.5": false,
+		"6.3.2": false,
+		"6.4.0": true,
+		"7.10":  true,
+		"":      false,
+	} {
+		if versionAtLeast(number, 6, 4) != expected {
+			t.Fatalf("Wrong result for %s", number)
+		}
+	}
+}
+
+func Test_Reindexer_Status(t *testing.T) {
+	rx := NewReindexer(nil, nil)
+	if rx.Status().State != REINDEX_IDLE {
+		t.Fatalf("Wrong initial state: %s", rx.Status().State)
+	}
+
+	rx.status.Indices = []ReindexProgress{{Alias: "vindalu", Total: 10}}
+	rx.setProgress(0, 10, 5)
+
+	status := rx.Status()
+	status.Indices[0].Copied = 0
+	if rx.Status().Indices[0].Copied != 5 {
+		t.Fatal("Status should be a copy")
+	}
+}
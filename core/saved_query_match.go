@@ -0,0 +1,241 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/gnatsd/server"
+)
+
+const (
+	// Max number of written assets matched against the saved queries together
+	SAVED_QUERY_MATCH_BATCH = 100
+	// Max number of searches per multi search request
+	MAX_MULTI_SEARCH_SIZE = 1000
+)
+
+// Asset written and the type of the event published for it
+type savedQueryMatch struct {
+	EvtType EventType
+	Asset   BaseAsset
+}
+
+// Saved query to evaluate against an asset
+type savedQueryPair struct {
+	Query SavedQuery
+	Asset AssetRef
+}
+
+// Pair each asset with the saved queries applicable to its type.  Assets are only paired once.
+func savedQueryPairs(queries []SavedQuery, assets []AssetRef) []savedQueryPair {
+	pairs := []savedQueryPair{}
+	paired := map[AssetRef]bool{}
+	for _, ref := range assets {
+		if paired[ref] {
+			continue
+		}
+		paired[ref] = true
+		for _, sq := range queries {
+			if len(sq.AssetType) == 0 || sq.AssetType == ref.Type {
+				pairs = append(pairs, savedQueryPair{Query: sq, Asset: ref})
+			}
+		}
+	}
+	return pairs
+}
+
+// Search of a multi search request
+type multiSearchItem struct {
+	Header map[string]interface{}
+	Query  map[string]interface{}
+}
+
+/*
+	Evaluate the saved queries of each pair with multi searches of up to MAX_MULTI_SEARCH_SIZE
+	searches.  `search` builds the search selecting the asset of a pair if the query matches.
+	Pairs that fail are logged and treated as not matching.  Returns the names of the matching
+	queries by asset.
+*/
+func matchSavedQueryPairs(conn essCommander, log server.Logger, pairs []savedQueryPair,
+	search func(p savedQueryPair) (multiSearchItem, error)) (map[AssetRef][]string, error) {
+
+	var (
+		buf     bytes.Buffer
+		matched = map[AssetRef][]string{}
+		// Pairs in the order of the pending searches
+		searched = make([]savedQueryPair, 0, MAX_MULTI_SEARCH_SIZE)
+	)
+	flush := func() error {
+		if len(searched) == 0 {
+			return nil
+		}
+		totals, err := multiSearch(conn, buf.String(), len(searched))
+		if err != nil {
+			return err
+		}
+		for i, p := range searched {
+			if totals[i] > 0 {
+				matched[p.Asset] = append(matched[p.Asset], p.Query.Name)
+			} else if totals[i] < 0 {
+				log.Errorf("Saved query match failed: %s\n", p.Query.Name)
+			}
+		}
+		buf.Reset()
+		searched = searched[:0]
+		return nil
+	}
+
+	for _, p := range pairs {
+		item, err := search(p)
+		if err != nil {
+			log.Errorf("Saved query match failed (%s): %s\n", p.Query.Name, err)
+			continue
+		}
+		item.Query["size"] = 0
+		for _, line := range []interface{}{item.Header, item.Query} {
+			b, err := json.Marshal(line)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+
+		if searched = append(searched, p); len(searched) >= MAX_MULTI_SEARCH_SIZE {
+			if err = flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// Total hits of each search of a multi search.  -1 for searches that failed.
+func multiSearch(conn essCommander, body string, count int) ([]int64, error) {
+	b, err := conn.DoCommand("POST", "/_msearch", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Responses []struct {
+			Hits struct {
+				// A number with elasticsearch 1.x, an object with later versions
+				Total json.RawMessage `json:"total"`
+			} `json:"hits"`
+			Error interface{} `json:"error"`
+		} `json:"responses"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) != count {
+		return nil, fmt.Errorf("Multi search returned %d of %d responses", len(resp.Responses), count)
+	}
+
+	totals := make([]int64, count)
+	for i, r := range resp.Responses {
+		if r.Error != nil {
+			totals[i] = -1
+		} else {
+			totals[i] = searchTotal(r.Hits.Total)
+		}
+	}
+	return totals, nil
+}
+
+// Total hits of a search response of any elasticsearch version
+func searchTotal(raw json.RawMessage) int64 {
+	var total int64
+	if json.Unmarshal(raw, &total) == nil {
+		return total
+	}
+	var obj struct {
+		Value int64 `json:"value"`
+	}
+	json.Unmarshal(raw, &obj)
+	return obj.Value
+}
+
+/*
+	Queue a written asset so the events of the saved queries selecting it are published.  Does
+	nothing unless events are enabled.
+*/
+func (ir *VindaluCore) queueSavedQueryMatch(evtType EventType, ba BaseAsset) {
+	if ir.savedQueryQ != nil {
+		ir.savedQueryQ <- savedQueryMatch{EvtType: evtType, Asset: ba}
+	}
+}
+
+/*
+	Publish the events of the saved queries selecting written assets in the background.  Queued
+	writes are batched so the index is refreshed once and the queries are evaluated with a single
+	request per batch.
+*/
+func (ir *VindaluCore) savedQueryMatcher() {
+	for m := range ir.savedQueryQ {
+		batch := []savedQueryMatch{m}
+	drain:
+		for len(batch) < SAVED_QUERY_MATCH_BATCH {
+			select {
+			case next := <-ir.savedQueryQ:
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		queries, err := ir.datastore.ListSavedQueries("")
+		if err != nil {
+			ir.log.Errorf("Failed to list saved queries: %s\n", err)
+			continue
+		}
+		if len(queries) < 1 {
+			continue
+		}
+		// Make the writes searchable
+		if err = ir.datastore.Refresh(); err != nil {
+			ir.log.Errorf("Refresh failed: %s\n", err)
+		}
+
+		refs := make([]AssetRef, len(batch))
+		for i, m := range batch {
+			refs[i] = AssetRef{Type: m.Asset.Type, Id: m.Asset.Id}
+		}
+		matched, err := ir.datastore.MatchSavedQueries(queries, refs)
+		if err != nil {
+			ir.log.Errorf("Saved query match failed: %s\n", err)
+			continue
+		}
+		for i, m := range batch {
+			ir.publishSavedQueryEvents(m.EvtType, m.Asset, matched[refs[i]])
+		}
+	}
+}
+
+/*
+	Names of the saved queries selecting the given asset.  Only used for removals as the asset
+	is no longer searchable afterwards.  This is only evaluated when events are enabled as it is
+	solely used for publishing.
+*/
+func (ir *VindaluCore) matchingSavedQueries(assetType, assetId string) []string {
+	if !ir.cfg.Events.Enabled {
+		return nil
+	}
+
+	queries, err := ir.datastore.ListSavedQueries("")
+	if err != nil || len(queries) < 1 {
+		return nil
+	}
+
+	ref := AssetRef{Type: assetType, Id: assetId}
+	matched, err := ir.datastore.MatchSavedQueries(queries, []AssetRef{ref})
+	if err != nil {
+		ir.log.Errorf("Saved query match failed: %s\n", err)
+		return nil
+	}
+	return matched[ref]
+}
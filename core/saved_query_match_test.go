@@ -0,0 +1,28 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_savedQueryPairs(t *testing.T) {
+	queries := []SavedQuery{{Name: "all"}, {Name: "servers", AssetType: "server"}}
+	web01 := AssetRef{Type: "server", Id: "web01"}
+	sw01 := AssetRef{Type: "switch", Id: "sw01"}
+
+	pairs := savedQueryPairs(queries, []AssetRef{web01, sw01, web01})
+	if len(pairs) != 3 {
+		t.Fatalf("Wrong pairs: %#v", pairs)
+	}
+	if pairs[0].Asset != web01 || pairs[1].Query.Name != "servers" || pairs[2].Asset != sw01 || pairs[2].Query.Name != "all" {
+		t.Fatalf("Wrong pairs: %#v", pairs)
+	}
+}
+
+func Test_searchTotal(t *testing.T) {
+	for raw, expected := range map[string]int64{`3`: 3, `{"value": 2, "relation": "eq"}`: 2, `null`: 0} {
+		if total := searchTotal(json.RawMessage(raw)); total != expected {
+			t.Fatalf("Wrong total for %s: %d", raw, total)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/gnatsd/server"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/simple-ess"
+)
+
+const (
+	SNAPSHOT_DEFAULT_REPOSITORY = "vindalu_backup"
+	// Snapshot names are the index name followed by the creation time in this format
+	SNAPSHOT_TIME_FORMAT = "20060102-150405"
+	// Delay before retrying a failed scheduled snapshot
+	SNAPSHOT_RETRY_DELAY = 5 * time.Minute
+)
+
+type SnapshotShards struct {
+	Total      int `json:"total"`
+	Failed     int `json:"failed"`
+	Successful int `json:"successful"`
+}
+
+// Snapshot as reported by elasticsearch
+type Snapshot struct {
+	Name            string         `json:"snapshot"`
+	Indices         []string       `json:"indices"`
+	State           string         `json:"state,omitempty"`
+	StartTime       string         `json:"start_time,omitempty"`
+	StartTimeMillis int64          `json:"start_time_in_millis,omitempty"`
+	EndTime         string         `json:"end_time,omitempty"`
+	EndTimeMillis   int64          `json:"end_time_in_millis,omitempty"`
+	DurationMillis  int64          `json:"duration_in_millis,omitempty"`
+	Failures        []interface{}  `json:"failures,omitempty"`
+	Shards          SnapshotShards `json:"shards"`
+}
+
+// Snapshot state reported on /status
+type SnapshotStatus struct {
+	Repository string `json:"repository"`
+	Location   string `json:"location"`
+	Interval   string `json:"interval,omitempty"`
+	Retention  int    `json:"retention"`
+	// Operation currently running i.e. snapshot, restore
+	Running string `json:"running,omitempty"`
+	// Latest successful snapshot
+	LastSnapshot *Snapshot `json:"last_snapshot,omitempty"`
+	// Error of the last operation if it failed
+	LastError string `json:"last_error,omitempty"`
+	// Time the next scheduled snapshot is due
+	NextSnapshot string `json:"next_snapshot,omitempty"`
+}
+
+/*
+	Takes, restores and removes snapshots of the asset, versions and meta indices.  Only one
+	operation runs at a time.
+*/
+type SnapshotManager struct {
+	ds  *ElasticsearchDatastore
+	cfg config.SnapshotConfig
+
+	mu     sync.Mutex
+	status SnapshotStatus
+
+	log server.Logger
+}
+
+/*
+	Register the filesystem repository and load the latest snapshot.  The repository location must
+	be listed in `path.repo` on all elasticsearch nodes.
+*/
+func NewSnapshotManager(ds *ElasticsearchDatastore, cfg config.SnapshotConfig, log server.Logger) (*SnapshotManager, error) {
+	if len(cfg.Repository) == 0 {
+		cfg.Repository = SNAPSHOT_DEFAULT_REPOSITORY
+	}
+
+	if err := ds.Conn.CreateFSBackupRepo(cfg.Repository, cfg.Location, cfg.Compress); err != nil {
+		return nil, fmt.Errorf("Failed to register snapshot repository %s (%s): %s", cfg.Repository, cfg.Location, err)
+	}
+
+	sm := &SnapshotManager{
+		ds:  ds,
+		cfg: cfg,
+		status: SnapshotStatus{
+			Repository: cfg.Repository,
+			Location:   cfg.Location,
+			Interval:   cfg.Interval,
+			Retention:  cfg.Retention,
+		},
+		log: log,
+	}
+
+	snapshots, err := ds.ListSnapshots(cfg.Repository)
+	if err != nil {
+		return nil, err
+	}
+	sm.status.LastSnapshot = latestSnapshot(snapshots, ds.Index)
+	return sm, nil
+}
+
+func (sm *SnapshotManager) Status() SnapshotStatus {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.status
+}
+
+func (sm *SnapshotManager) List() ([]Snapshot, error) {
+	return sm.ds.ListSnapshots(sm.cfg.Repository)
+}
+
+func (sm *SnapshotManager) Get(name string) (Snapshot, error) {
+	return sm.ds.GetSnapshot(sm.cfg.Repository, name)
+}
+
+// Take a snapshot then remove those beyond the retention count
+func (sm *SnapshotManager) Create() (snap Snapshot, err error) {
+	if err = sm.begin("snapshot"); err != nil {
+		return
+	}
+	defer sm.end()
+
+	name := snapshotName(sm.ds.Index, time.Now())
+	if snap, err = sm.ds.CreateSnapshot(sm.cfg.Repository, name); err != nil {
+		sm.setError(err)
+		return
+	}
+	sm.log.Noticef("Snapshot created: %s/%s (%dms)\n", sm.cfg.Repository, name, snap.DurationMillis)
+
+	sm.mu.Lock()
+	sm.status.LastSnapshot = &snap
+	sm.status.LastError = ""
+	sm.mu.Unlock()
+
+	if sm.cfg.Retention > 0 {
+		if err := sm.prune(); err != nil {
+			sm.log.Errorf("Failed to remove expired snapshots: %s\n", err)
+			sm.setError(err)
+		}
+	}
+	return
+}
+
+// Restore the indices of a snapshot.  They are unavailable until the restore completes.
+func (sm *SnapshotManager) Restore(name string) (snap Snapshot, err error) {
+	if err = sm.begin("restore"); err != nil {
+		return
+	}
+	defer sm.end()
+
+	if snap, err = sm.ds.RestoreSnapshot(sm.cfg.Repository, name); err != nil {
+		sm.setError(err)
+		return
+	}
+	sm.setError(nil)
+	return
+}
+
+func (sm *SnapshotManager) Delete(name string) (err error) {
+	if err = sm.begin("delete"); err != nil {
+		return
+	}
+	defer sm.end()
+
+	if err = sm.ds.DeleteSnapshot(sm.cfg.Repository, name); err != nil {
+		return
+	}
+	sm.log.Noticef("Snapshot removed: %s/%s\n", sm.cfg.Repository, name)
+
+	sm.mu.Lock()
+	if sm.status.LastSnapshot != nil && sm.status.LastSnapshot.Name == name {
+		sm.status.LastSnapshot = nil
+	}
+	sm.mu.Unlock()
+	return
+}
+
+/*
+	Take snapshots at the configured interval.  The latest snapshot is checked before each one so
+	multiple nodes sharing the repository do not all take one.
+*/
+func (sm *SnapshotManager) Schedule() {
+	interval := sm.cfg.IntervalDuration()
+	for {
+		next, err := sm.nextScheduled(interval)
+		if err != nil {
+			sm.log.Errorf("Failed to list snapshots: %s\n", err)
+			next = time.Now().Add(SNAPSHOT_RETRY_DELAY)
+		} else if now := time.Now(); !next.After(now) {
+			if _, err = sm.Create(); err == nil {
+				continue
+			}
+			sm.log.Errorf("Scheduled snapshot failed: %s\n", err)
+			next = now.Add(SNAPSHOT_RETRY_DELAY)
+		}
+
+		sm.mu.Lock()
+		sm.status.NextSnapshot = next.UTC().Format(time.RFC3339)
+		sm.mu.Unlock()
+
+		time.Sleep(next.Sub(time.Now()))
+	}
+}
+
+func (sm *SnapshotManager) nextScheduled(interval time.Duration) (time.Time, error) {
+	snapshots, err := sm.ds.ListSnapshots(sm.cfg.Repository)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return nextSnapshotTime(latestSnapshot(snapshots, sm.ds.Index), interval, time.Now()), nil
+}
+
+func (sm *SnapshotManager) prune() error {
+	snapshots, err := sm.ds.ListSnapshots(sm.cfg.Repository)
+	if err != nil {
+		return err
+	}
+	for _, s := range expiredSnapshots(snapshots, sm.ds.Index, sm.cfg.Retention) {
+		if err = sm.ds.DeleteSnapshot(sm.cfg.Repository, s.Name); err != nil {
+			return err
+		}
+		sm.log.Noticef("Expired snapshot removed: %s/%s\n", sm.cfg.Repository, s.Name)
+	}
+	return nil
+}
+
+func (sm *SnapshotManager) begin(op string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if len(sm.status.Running) > 0 {
+		return &ConflictError{Reason: fmt.Sprintf("Snapshot %s in progress", sm.status.Running)}
+	}
+	sm.status.Running = op
+	return nil
+}
+
+func (sm *SnapshotManager) end() {
+	sm.mu.Lock()
+	sm.status.Running = ""
+	sm.mu.Unlock()
+}
+
+func (sm *SnapshotManager) setError(err error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err != nil {
+		sm.status.LastError = err.Error()
+	} else {
+		sm.status.LastError = ""
+	}
+}
+
+func snapshotName(index string, t time.Time) string {
+	return index + "-" + t.UTC().Format(SNAPSHOT_TIME_FORMAT)
+}
+
+// Whether the snapshot was taken by vindalu for the index.  Only these are subject to retention.
+func isIndexSnapshot(name, index string) bool {
+	if !strings.HasPrefix(name, index+"-") {
+		return false
+	}
+	_, err := time.Parse(SNAPSHOT_TIME_FORMAT, strings.TrimPrefix(name, index+"-"))
+	return err == nil
+}
+
+// Snapshots of the index sorted oldest first
+func indexSnapshots(snapshots []Snapshot, index string) []Snapshot {
+	names := []string{}
+	byName := map[string]Snapshot{}
+	for _, s := range snapshots {
+		if isIndexSnapshot(s.Name, index) {
+			names = append(names, s.Name)
+			byName[s.Name] = s
+		}
+	}
+	// Names sort in time order
+	sort.Strings(names)
+
+	out := make([]Snapshot, len(names))
+	for i, n := range names {
+		out[i] = byName[n]
+	}
+	return out
+}
+
+// Latest successful snapshot of the index or nil if there is none
+func latestSnapshot(snapshots []Snapshot, index string) *Snapshot {
+	sorted := indexSnapshots(snapshots, index)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].State == "SUCCESS" {
+			return &sorted[i]
+		}
+	}
+	return nil
+}
+
+// Completed snapshots of the index beyond the newest `retention`
+func expiredSnapshots(snapshots []Snapshot, index string, retention int) []Snapshot {
+	done := []Snapshot{}
+	for _, s := range indexSnapshots(snapshots, index) {
+		if s.State != "IN_PROGRESS" {
+			done = append(done, s)
+		}
+	}
+	if retention <= 0 || len(done) <= retention {
+		return []Snapshot{}
+	}
+	return done[:len(done)-retention]
+}
+
+// A snapshot is due an interval after the latest one or immediately if there is none
+func nextSnapshotTime(latest *Snapshot, interval time.Duration, now time.Time) time.Time {
+	if latest == nil {
+		return now
+	}
+	return time.Unix(0, latest.StartTimeMillis*int64(time.Millisecond)).Add(interval)
+}
+
+func (e *ElasticsearchDatastore) snapshotIndices() string {
+	return strings.Join([]string{e.Index, e.VersionIndex, e.MetaIndex}, ",")
+}
+
+// Snapshot and restore requests wait for completion so are sent once without a time limit
+var snapshotRequestOptions = simpless.RequestOptions{Timeout: -1, NoRetry: true}
+
+// Snapshot the asset, versions and meta indices waiting for it to complete
+func (e *ElasticsearchDatastore) CreateSnapshot(repo, name string) (snap Snapshot, err error) {
+	var b []byte
+	if b, err = e.Conn.DoCommandWithOptions("PUT", fmt.Sprintf("/_snapshot/%s/%s", repo, name),
+		map[string]interface{}{"wait_for_completion": true},
+		map[string]interface{}{"indices": e.snapshotIndices(), "include_global_state": false},
+		snapshotRequestOptions); err != nil {
+		return snap, snapshotError(name, b, err)
+	}
+
+	var resp struct {
+		Snapshot Snapshot `json:"snapshot"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return
+	}
+	snap = resp.Snapshot
+
+	if snap.State != "SUCCESS" {
+		err = fmt.Errorf("Snapshot %s %s: %v", name, snap.State, snap.Failures)
+	}
+	return
+}
+
+func (e *ElasticsearchDatastore) ListSnapshots(repo string) ([]Snapshot, error) {
+	return e.getSnapshots(repo, "_all")
+}
+
+func (e *ElasticsearchDatastore) GetSnapshot(repo, name string) (Snapshot, error) {
+	snapshots, err := e.getSnapshots(repo, name)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return Snapshot{}, &NotFoundError{Kind: "Snapshot", Name: name}
+	}
+	return snapshots[0], nil
+}
+
+func (e *ElasticsearchDatastore) getSnapshots(repo, name string) ([]Snapshot, error) {
+	b, err := e.Conn.DoCommand("GET", fmt.Sprintf("/_snapshot/%s/%s", repo, name), nil, nil)
+	if err != nil {
+		return nil, snapshotError(name, b, err)
+	}
+
+	var resp struct {
+		Snapshots []Snapshot `json:"snapshots"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Snapshots == nil {
+		resp.Snapshots = []Snapshot{}
+	}
+	return resp.Snapshots, nil
+}
+
+func (e *ElasticsearchDatastore) DeleteSnapshot(repo, name string) error {
+	b, err := e.Conn.DoCommand("DELETE", fmt.Sprintf("/_snapshot/%s/%s", repo, name), nil, nil)
+	if err != nil {
+		return snapshotError(name, b, err)
+	}
+	return nil
+}
+
+/*
+	Restore the indices of a snapshot.  Open indices cannot be restored over so they are closed
+	first and reopened if the restore fails.
+*/
+func (e *ElasticsearchDatastore) RestoreSnapshot(repo, name string) (snap Snapshot, err error) {
+	if snap, err = e.GetSnapshot(repo, name); err != nil {
+		return
+	}
+
+	closed := []string{}
+	for _, index := range snap.Indices {
+		if !e.Conn.IndexExists(index) {
+			continue
+		}
+		if _, err = e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_close", index), nil, nil); err != nil {
+			e.reopenIndices(closed)
+			return snap, fmt.Errorf("Failed to close index %s: %s", index, err)
+		}
+		closed = append(closed, index)
+	}
+
+	var b []byte
+	if b, err = e.Conn.DoCommandWithOptions("POST", fmt.Sprintf("/_snapshot/%s/%s/_restore", repo, name),
+		map[string]interface{}{"wait_for_completion": true},
+		map[string]interface{}{"indices": strings.Join(snap.Indices, ","), "include_global_state": false},
+		snapshotRequestOptions); err != nil {
+		e.reopenIndices(closed)
+		return snap, snapshotError(name, b, err)
+	}
+
+	var resp struct {
+		Snapshot struct {
+			Shards SnapshotShards `json:"shards"`
+		} `json:"snapshot"`
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return
+	}
+	if resp.Snapshot.Shards.Failed > 0 {
+		return snap, fmt.Errorf("Snapshot %s restore failed for %d of %d shards", name,
+			resp.Snapshot.Shards.Failed, resp.Snapshot.Shards.Total)
+	}
+	err = e.detachAliases(snap.Indices)
+	return
+}
+
+/*
+	Restored indices bring their aliases with them.  Indices created by a reindex after the
+	snapshot was taken are removed from the aliases so each alias only points to the restored
+	index.  They are left in place.
+*/
+func (e *ElasticsearchDatastore) detachAliases(restored []string) error {
+	isRestored := map[string]bool{}
+	for _, index := range restored {
+		isRestored[index] = true
+	}
+
+	actions := []interface{}{}
+	for _, alias := range []string{e.Index, e.VersionIndex} {
+		indices, err := e.aliasIndices(alias)
+		if err != nil {
+			return err
+		}
+		if len(indices) < 2 {
+			continue
+		}
+		for _, index := range indices {
+			if !isRestored[index] {
+				actions = append(actions, map[string]interface{}{
+					"remove": map[string]string{"index": index, "alias": alias}})
+				e.log.Noticef("Index %s removed from alias %s after restore\n", index, alias)
+			}
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	_, err := e.Conn.DoCommand("POST", "/_aliases", nil, map[string]interface{}{"actions": actions})
+	return err
+}
+
+func (e *ElasticsearchDatastore) reopenIndices(indices []string) {
+	for _, index := range indices {
+		if _, err := e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_open", index), nil, nil); err != nil {
+			e.log.Errorf("Failed to reopen index %s: %s\n", index, err)
+		}
+	}
+}
+
+// Missing snapshots are returned as a NotFoundError
+func snapshotError(name string, body []byte, err error) error {
+	if isNotFoundResponse(body) {
+		return &NotFoundError{Kind: "Snapshot", Name: name}
+	}
+	return err
+}
+
+// Whether an elasticsearch error response has a 404 status
+func isNotFoundResponse(body []byte) bool {
+	var resp struct {
+		Status int `json:"status"`
+	}
+	return json.Unmarshal(body, &resp) == nil && resp.Status == 404
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vindalu/vindalu/config"
+)
+
+var testSnapshots = []Snapshot{
+	{Name: "vindalu-20151014-020000", State: "SUCCESS", StartTimeMillis: 1444788000000},
+	{Name: "manual", State: "SUCCESS", StartTimeMillis: 1444960800000},
+	{Name: "vindalu-20151016-020000", State: "IN_PROGRESS", StartTimeMillis: 1444960800000},
+	{Name: "vindalu-20151012-020000", State: "SUCCESS", StartTimeMillis: 1444615200000},
+	{Name: "vindalu-20151015-020000", State: "FAILED", StartTimeMillis: 1444874400000},
+	{Name: "inventory-20151015-020000", State: "SUCCESS", StartTimeMillis: 1444874400000},
+}
+
+func Test_snapshotName(t *testing.T) {
+	name := snapshotName("vindalu", time.Date(2015, 10, 14, 2, 0, 0, 0, time.UTC))
+	if name != "vindalu-20151014-020000" {
+		t.Fatalf("Wrong name: %s", name)
+	}
+	if !isIndexSnapshot(name, "vindalu") || isIndexSnapshot(name, "inventory") || isIndexSnapshot("vindalu-manual", "vindalu") {
+		t.Fatal("Wrong index snapshot match")
+	}
+}
+
+func Test_latestSnapshot(t *testing.T) {
+	latest := latestSnapshot(testSnapshots, "vindalu")
+	if latest == nil || latest.Name != "vindalu-20151014-020000" {
+		t.Fatalf("Wrong latest snapshot: %#v", latest)
+	}
+	if latestSnapshot(testSnapshots, "other") != nil {
+		t.Fatal("Should not have a latest snapshot")
+	}
+
+	next := nextSnapshotTime(latest, 24*time.Hour, time.Now())
+	if !next.Equal(time.Date(2015, 10, 15, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Wrong next snapshot: %s", next)
+	}
+	now := time.Now()
+	if !nextSnapshotTime(nil, 24*time.Hour, now).Equal(now) {
+		t.Fatal("Snapshot should be due without a previous one")
+	}
+}
+
+func Test_expiredSnapshots(t *testing.T) {
+	expired := expiredSnapshots(testSnapshots, "vindalu", 2)
+	if len(expired) != 1 || expired[0].Name != "vindalu-20151012-020000" {
+		t.Fatalf("Wrong expired snapshots: %#v", expired)
+	}
+	if len(expiredSnapshots(testSnapshots, "vindalu", 0)) != 0 {
+		t.Fatal("All snapshots should be kept without retention")
+	}
+	if len(expiredSnapshots(testSnapshots, "vindalu", 3)) != 0 {
+		t.Fatal("No snapshots should expire")
+	}
+}
+
+func Test_snapshotManager_NotConfigured(t *testing.T) {
+	for dsType, ds := range map[string]IDatastore{
+		"elasticsearch": &ElasticsearchDatastore{},
+		"opensearch":    &TypelessDatastore{},
+	} {
+		cfg := &config.InventoryConfig{}
+		cfg.Datastore.Type = dsType
+		ir := &VindaluCore{cfg: cfg, datastore: &InventoryDatastore{IDatastore: ds}}
+
+		_, err := ir.snapshotManager("admin", true)
+		nse, ok := err.(*NotSupportedError)
+		if !ok {
+			t.Fatalf("Should be not supported (%s): %#v", dsType, err)
+		}
+		if dsType == "elasticsearch" && nse.Error() != "Snapshots not configured" {
+			t.Fatalf("Wrong error: %s", nse)
+		} else if dsType != "elasticsearch" && nse.Datastore != dsType {
+			t.Fatalf("Wrong datastore: %s", nse)
+		}
+	}
+}
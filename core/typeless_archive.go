@@ -0,0 +1,293 @@
+package core
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/vindalu/vindalu/config"
+)
+
+var typelessScanQuery = map[string]interface{}{
+	"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+	"sort":    []string{"_doc"},
+	"version": true,
+}
+
+/*
+	Write an archive in the same format as the elasticsearch 1.x datastore so archives can be
+	moved between the two.  The mapping of each type is built from its type document i.e. the
+	properties it was created with and its metadata.
+*/
+func (e *TypelessDatastore) ExportArchive(w io.Writer) (*ArchiveManifest, error) {
+	manifest := &ArchiveManifest{
+		Format:         ARCHIVE_FORMAT_VERSION,
+		VindaluVersion: config.VERSION,
+		Index:          e.Index,
+		Created:        time.Now().UnixNano() / 1000000,
+		Types:          []string{},
+		Typeless:       true,
+	}
+	mappings := archiveMappings{Index: map[string]*json.RawMessage{}, Versions: map[string]*json.RawMessage{}}
+
+	parts := []*archivePart{{name: ARCHIVE_META}, {name: ARCHIVE_VERSIONS}, {name: ARCHIVE_ASSETS}}
+	defer func() {
+		for _, p := range parts {
+			if p.file != nil {
+				p.file.Close()
+				os.Remove(p.file.Name())
+			}
+		}
+	}()
+
+	var err error
+	if parts[0].file, err = spoolNDJSON(func(enc *json.Encoder) error {
+		return e.scroll(e.MetaIndex, typelessScanQuery, func(h typelessHit) error {
+			metaType, id := splitTypelessDocId(h.Id)
+			if metaType == META_TYPE_ASSET_TYPE {
+				return e.addArchiveType(manifest, mappings, h)
+			}
+
+			doc, err := typelessMetaDoc(h.Source)
+			if err != nil {
+				return err
+			}
+			manifest.MetaDocs++
+			return enc.Encode(archiveMetaDoc{Type: metaType, Id: id, Version: h.Version, Doc: doc})
+		})
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(manifest.Types)
+
+	for i, index := range []string{e.VersionIndex, e.Index} {
+		count := &manifest.Versions
+		if index == e.Index {
+			count = &manifest.Assets
+		}
+		if parts[i+1].file, err = spoolNDJSON(func(enc *json.Encoder) error {
+			return e.scroll(index, typelessScanQuery, func(h typelessHit) error {
+				asset, err := assetFromTypelessSource(h.Source)
+				if err != nil {
+					return err
+				}
+				*count++
+				return enc.Encode(asset)
+			})
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return manifest, writeArchive(w, manifest, mappings, parts)
+}
+
+// Add a type and its mapping from a type document
+func (e *TypelessDatastore) addArchiveType(manifest *ArchiveManifest, mappings archiveMappings, h typelessHit) error {
+	if h.Source == nil {
+		return nil
+	}
+	var doc typelessTypeDoc
+	if err := json.Unmarshal(*h.Source, &doc); err != nil {
+		return err
+	}
+
+	mapping := map[string]interface{}{}
+	if len(doc.Properties) > 0 {
+		mapping["properties"] = doc.Properties
+	}
+	if len(doc.Metadata) > 0 {
+		mapping["_meta"] = doc.Metadata
+	}
+	b, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+
+	raw := json.RawMessage(b)
+	mappings.Index[doc.Name] = &raw
+	mappings.Versions[doc.Name] = &raw
+	manifest.Types = append(manifest.Types, doc.Name)
+	return nil
+}
+
+// Source of a meta document without the meta type field
+func typelessMetaDoc(source *json.RawMessage) (*json.RawMessage, error) {
+	if source == nil {
+		return nil, nil
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(*source, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, TYPELESS_META_TYPE_FIELD)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(b)
+	return &raw, nil
+}
+
+/*
+	Restore an archive written by either elasticsearch datastore into an empty datastore.  Types
+	are created from the archived mappings, adding their properties to the shared mapping only
+	if the archive was written by a typeless datastore.  Version numbers, timestamps and all
+	asset data are preserved.  If the import fails the datastore has to be emptied before
+	retrying.
+*/
+func (e *TypelessDatastore) ImportArchive(r io.Reader) (*ArchiveManifest, error) {
+	if err := checkIndicesEmpty(e.Conn, e.Index, e.VersionIndex); err != nil {
+		return nil, err
+	}
+
+	r, err := archiveReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		manifest *ArchiveManifest
+		imported = &ArchiveManifest{}
+		tr       = tar.NewReader(r)
+		// Types of the archived assets.  Added in case the archive lacks their mapping.
+		added = map[string]bool{}
+	)
+	addType := func(assetType string) error {
+		if added[assetType] {
+			return nil
+		}
+		added[assetType] = true
+		return e.addType(assetType)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Invalid archive: %s", err)
+		}
+
+		name := path.Base(hdr.Name)
+		if manifest == nil && name != ARCHIVE_MANIFEST {
+			return nil, fmt.Errorf("Invalid archive: %s must be the first entry", ARCHIVE_MANIFEST)
+		}
+
+		switch name {
+		case ARCHIVE_MANIFEST:
+			if manifest, err = readArchiveManifest(tr); err != nil {
+				return nil, err
+			}
+			*imported = *manifest
+			imported.Assets, imported.Versions, imported.MetaDocs = 0, 0, 0
+		case ARCHIVE_MAPPINGS:
+			var mappings archiveMappings
+			if err = json.NewDecoder(tr).Decode(&mappings); err != nil {
+				return nil, fmt.Errorf("Invalid archive mappings: %s", err)
+			}
+			if !manifest.Typeless {
+				if mappings.Index, err = archiveMappingsMeta(mappings.Index); err != nil {
+					return nil, err
+				}
+			}
+			if err = e.importTypes(mappings.Index); err != nil {
+				return nil, err
+			}
+			for assetType := range mappings.Index {
+				added[assetType] = true
+			}
+		case ARCHIVE_META:
+			imported.MetaDocs, err = bulkImport(e.Conn, tr, func(line []byte) (map[string]interface{}, interface{}, error) {
+				var doc archiveMetaDoc
+				if err := json.Unmarshal(line, &doc); err != nil {
+					return nil, nil, err
+				}
+				var src interface{} = map[string]interface{}{}
+				if doc.Doc != nil {
+					src = doc.Doc
+				}
+				source, err := typelessMetaSource(doc.Type, src)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				action := map[string]interface{}{"_index": e.MetaIndex, "_id": typelessDocId(doc.Type, doc.Id)}
+				if doc.Version > 0 {
+					action["version"] = doc.Version
+					action["version_type"] = "external"
+				}
+				return action, source, nil
+			})
+		case ARCHIVE_VERSIONS:
+			imported.Versions, err = bulkImport(e.Conn, tr, func(line []byte) (map[string]interface{}, interface{}, error) {
+				asset, err := decodeArchiveAsset(line)
+				if err != nil {
+					return nil, nil, err
+				}
+				ver := asset.GetVersion()
+				if ver < 1 {
+					return nil, nil, fmt.Errorf("Version missing: %s/%s", asset.Type, asset.Id)
+				}
+				action := map[string]interface{}{
+					"_index": e.VersionIndex,
+					"_id":    typelessVersionDocId(asset.Type, asset.Id, ver),
+				}
+				return action, typelessSource(asset, asset.Timestamp), nil
+			})
+		case ARCHIVE_ASSETS:
+			imported.Assets, err = bulkImport(e.Conn, tr, func(line []byte) (map[string]interface{}, interface{}, error) {
+				asset, err := decodeArchiveAsset(line)
+				if err != nil {
+					return nil, nil, err
+				}
+				if err = addType(asset.Type); err != nil {
+					return nil, nil, err
+				}
+				action := map[string]interface{}{"_index": e.Index, "_id": typelessDocId(asset.Type, asset.Id)}
+				return action, typelessSource(asset, asset.Timestamp), nil
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to import %s: %s", name, err)
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("Invalid archive: %s missing", ARCHIVE_MANIFEST)
+	}
+	if _, err = e.Conn.DoCommand("POST",
+		fmt.Sprintf("/%s,%s,%s/_refresh", e.Index, e.VersionIndex, e.MetaIndex), nil, nil); err != nil {
+		return nil, err
+	}
+	if imported.Assets != manifest.Assets || imported.Versions != manifest.Versions || imported.MetaDocs != manifest.MetaDocs {
+		return imported, fmt.Errorf("Incomplete archive: imported %d/%d assets, %d/%d versions, %d/%d meta documents",
+			imported.Assets, manifest.Assets, imported.Versions, manifest.Versions, imported.MetaDocs, manifest.MetaDocs)
+	}
+	return imported, nil
+}
+
+// Create the types of the archived mappings with their properties and metadata
+func (e *TypelessDatastore) importTypes(mappings map[string]*json.RawMessage) error {
+	for name, raw := range mappings {
+		var mapping struct {
+			Properties map[string]interface{} `json:"properties"`
+			Meta       map[string]interface{} `json:"_meta"`
+		}
+		if raw != nil {
+			if err := json.Unmarshal(*raw, &mapping); err != nil {
+				return fmt.Errorf("Invalid mapping (%s): %s", name, err)
+			}
+		}
+		if err := e.putType(name, mapping.Properties, mapping.Meta); err != nil {
+			return fmt.Errorf("Failed to create type %s: %s", name, err)
+		}
+	}
+	return nil
+}
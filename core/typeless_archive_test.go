@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_TypelessDatastore_Archive(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	fe.Responses["POST /vindalu_meta/_search"] = `{"_scroll_id": "s1", "hits": {"hits": [
+		{"_id": "type:server", "_version": 1, "_source": {"name": "server", "vindalu_meta_type": "type",
+			"properties": {"ip": {"type": "ip"}}, "metadata": {"description": "Servers"}}},
+		{"_id": "sequence:server", "_version": 7, "_source": {"name": "server", "vindalu_meta_type": "sequence"}}]}}`
+	fe.Responses["POST /vindalu_versions/_search"] = `{"_scroll_id": "s2", "hits": {"hits": [
+		{"_id": "server:web01.1", "_source": {"vindalu_type": "server", "vindalu_id": "web01",
+			"vindalu_timestamp": 1000, "version": 1, "os": "ubuntu"}}]}}`
+	fe.Responses["POST /vindalu/_search"] = `{"_scroll_id": "s3", "hits": {"hits": [
+		{"_id": "server:web01", "_source": {"vindalu_type": "server", "vindalu_id": "web01",
+			"vindalu_timestamp": 1000, "version": 1, "os": "ubuntu"}}]}}`
+
+	var buf bytes.Buffer
+	manifest, err := ds.ExportArchive(&buf)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !manifest.Typeless || manifest.Assets != 1 || manifest.Versions != 1 || manifest.MetaDocs != 1 ||
+		len(manifest.Types) != 1 || manifest.Types[0] != "server" {
+		t.Fatalf("Wrong manifest: %#v", manifest)
+	}
+	if fe.request("DELETE", "/_search/scroll") == nil {
+		t.Fatalf("Scroll not cleared")
+	}
+
+	// Import into an empty datastore
+	fe2 := newFakeEss()
+	defer fe2.Close()
+	ds2 := newTestTypelessDatastore(t, fe2)
+	fe2.Responses["GET /vindalu/_count"] = `{"count": 0}`
+	fe2.Responses["GET /vindalu_versions/_count"] = `{"count": 0}`
+
+	if manifest, err = ds2.ImportArchive(&buf); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if manifest.Assets != 1 || manifest.Versions != 1 || manifest.MetaDocs != 1 {
+		t.Fatalf("Wrong import: %#v", manifest)
+	}
+
+	req := fe2.request("PUT", "/vindalu_meta/_doc/type:server")
+	if req == nil || req.Body["metadata"] == nil || req.Body["properties"] == nil {
+		t.Fatalf("Type not created: %#v", req)
+	}
+	if fe2.request("PUT", "/vindalu/_mapping") == nil {
+		t.Fatalf("Properties not added")
+	}
+
+	var bulk []string
+	for _, r := range fe2.Requests {
+		if r.Method == "POST" && r.Path == "/_bulk" {
+			bulk = append(bulk, r.Raw)
+		}
+	}
+	if len(bulk) != 3 {
+		t.Fatalf("Wrong bulk requests: %v", bulk)
+	}
+	for i, expected := range []string{
+		`"_id":"sequence:server","_index":"vindalu_meta","version":7,"version_type":"external"`,
+		`"_id":"server:web01.1","_index":"vindalu_versions"`,
+		`"_id":"server:web01","_index":"vindalu"`,
+	} {
+		if !strings.Contains(bulk[i], expected) {
+			t.Fatalf("Wrong bulk request: %s", bulk[i])
+		}
+	}
+	if !strings.Contains(bulk[2], `"vindalu_timestamp":1000`) {
+		t.Fatalf("Timestamp not kept: %s", bulk[2])
+	}
+}
@@ -0,0 +1,1194 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	elastigo "github.com/mattbaird/elastigo/lib"
+	"github.com/nats-io/gnatsd/server"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/simple-ess"
+	"github.com/vindalu/vindalu/types"
+)
+
+/*
+	Mapping of the meta index.  Only the fields used to look up documents are indexed, user
+	supplied data such as saved query filters and schemas is only stored.
+*/
+var TYPELESS_META_MAPPING = map[string]interface{}{
+	"dynamic": false,
+	"properties": map[string]interface{}{
+		TYPELESS_META_TYPE_FIELD: map[string]string{"type": "keyword"},
+		"name":                   map[string]string{"type": "keyword"},
+		"asset_type":             map[string]string{"type": "keyword"},
+		"owner":                  map[string]string{"type": "keyword"},
+		"updated_by":             map[string]string{"type": "keyword"},
+	},
+}
+
+// Search response
+type typelessSearchResult struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []typelessHit `json:"hits"`
+	} `json:"hits"`
+	Aggregations json.RawMessage `json:"aggregations"`
+}
+
+// Search hit or get response
+type typelessHit struct {
+	Id      string           `json:"_id"`
+	Version int64            `json:"_version"`
+	Found   bool             `json:"found"`
+	Source  *json.RawMessage `json:"_source"`
+	// Used for optimistic concurrency control
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+// Type document in the meta index as types are not part of the mappings
+type typelessTypeDoc struct {
+	Name string `json:"name"`
+	// Property definitions supplied when creating or updating the type
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+/*
+	Datastore for elasticsearch 7 and later as well as OpenSearch which no longer support mapping
+	types.  All asset types share the primary and version index.  The type, id and timestamp of
+	an asset are stored in reserved fields and types are tracked in the meta index.
+*/
+type TypelessDatastore struct {
+	Conn *simpless.Client
+
+	// User specified index
+	Index string
+	// Index + _versions
+	VersionIndex string
+	// Index + _meta.  Holds types, saved queries, schemas and sequences.
+	MetaIndex string
+
+	// Typeless mapping files merged into the mapping of the primary and version indices
+	MappingsDir string
+	// Max value of from + size of a search
+	MaxResultWindow int64
+
+	log server.Logger
+}
+
+// Create the indices if they do not exist otherwise apply the mappings as they may have been updated.
+func NewTypelessDatastore(datastoreCfg *config.DatastoreConfig, log server.Logger) (*TypelessDatastore, error) {
+	cfg, err := parseEssDatastoreConfig(datastoreCfg)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := newEssPool(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	ed := TypelessDatastore{
+		Conn:            simpless.NewClient(pool),
+		Index:           cfg.Index,
+		VersionIndex:    cfg.VersionIndex,
+		MetaIndex:       cfg.MetaIndex,
+		MappingsDir:     cfg.MappingsDir,
+		MaxResultWindow: cfg.MaxResultWindow,
+		log:             log,
+	}
+	if ed.MaxResultWindow <= 0 {
+		ed.MaxResultWindow = TYPELESS_DEFAULT_RESULT_WINDOW
+	}
+
+	mapping, err := typelessMapping(ed.MappingsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range []string{ed.Index, ed.VersionIndex} {
+		if err = ed.initializeIndex(index, mapping); err != nil {
+			return nil, err
+		}
+	}
+	if err = ed.initializeIndex(ed.MetaIndex, TYPELESS_META_MAPPING); err != nil {
+		return nil, err
+	}
+
+	ed.log.Noticef("Elasticsearch (typeless): %s/%s\n", strings.Join(pool.Hosts(), ","), ed.Index)
+	return &ed, nil
+}
+
+/*
+	Mapping of the primary and version indices.  The dynamic templates and properties of the json
+	files in the mappings directory are merged in file name order.  The built-in templates are
+	applied last mapping all other strings as keywords i.e. not analyzed.
+*/
+func typelessMapping(mappingsDir string) (map[string]interface{}, error) {
+	var (
+		templates = []interface{}{}
+		props     = map[string]interface{}{}
+	)
+
+	if len(mappingsDir) > 0 {
+		files, err := filepath.Glob(filepath.Join(mappingsDir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+
+		for _, f := range files {
+			b, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			var mapping map[string]*json.RawMessage
+			if err = json.Unmarshal(b, &mapping); err != nil {
+				return nil, fmt.Errorf("Mapping file: %s; Reason=%s", f, err)
+			}
+
+			for k, v := range mapping {
+				switch k {
+				case "dynamic_templates":
+					var t []interface{}
+					err = json.Unmarshal(*v, &t)
+					templates = append(templates, t...)
+				case "properties":
+					err = json.Unmarshal(*v, &props)
+				default:
+					err = fmt.Errorf("only 'properties' and 'dynamic_templates' are supported without mapping types: %s", k)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("Mapping file: %s; Reason=%s", f, err)
+				}
+			}
+		}
+	}
+
+	templates = append(templates, map[string]interface{}{
+		"strings": map[string]interface{}{
+			"match_mapping_type": "string",
+			"mapping":            map[string]string{"type": "keyword"},
+		},
+	})
+	props[TYPELESS_TYPE_FIELD] = map[string]string{"type": "keyword"}
+	props[TYPELESS_ID_FIELD] = map[string]string{"type": "keyword"}
+	props[TYPELESS_TIMESTAMP_FIELD] = map[string]string{"type": "date", "format": "epoch_millis"}
+
+	return map[string]interface{}{"dynamic_templates": templates, "properties": props}, nil
+}
+
+// Create the index with the mapping or update the mapping and settings of an existing one.
+func (e *TypelessDatastore) initializeIndex(index string, mapping map[string]interface{}) (err error) {
+	settings := map[string]interface{}{
+		"index": map[string]interface{}{"max_result_window": e.MaxResultWindow},
+	}
+
+	if _, err = e.Conn.DoCommand("HEAD", "/"+index, nil, nil); err == elastigo.RecordNotFound {
+		if _, err = e.Conn.DoCommand("PUT", "/"+index, nil,
+			map[string]interface{}{"settings": settings, "mappings": mapping}); err == nil {
+			e.log.Noticef("Index created: %s\n", index)
+		}
+		return
+	} else if err != nil {
+		return
+	}
+
+	if _, err = e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/_mapping", index), nil, mapping); err != nil {
+		return fmt.Errorf("Failed to apply mapping (%s): %s", index, err)
+	}
+	_, err = e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/_settings", index), nil, settings)
+	return
+}
+
+func docPath(index, docId string) string {
+	return fmt.Sprintf("/%s/_doc/%s", index, docId)
+}
+
+// Document source of an asset.  The data is copied so the asset is left as is.
+func typelessSource(asset BaseAsset, timestamp interface{}) map[string]interface{} {
+	src := make(map[string]interface{}, len(asset.Data)+len(TYPELESS_RESERVED_FIELDS))
+	for k, v := range asset.Data {
+		src[k] = v
+	}
+	src[TYPELESS_TYPE_FIELD] = asset.Type
+	src[TYPELESS_ID_FIELD] = asset.Id
+
+	switch ts := timestamp.(type) {
+	case float64:
+		src[TYPELESS_TIMESTAMP_FIELD] = int64(ts)
+	case int64:
+		src[TYPELESS_TIMESTAMP_FIELD] = ts
+	default:
+		src[TYPELESS_TIMESTAMP_FIELD] = nowMillis()
+	}
+	return src
+}
+
+// Asset from a document source removing the reserved fields from the data
+func assetFromTypelessSource(source *json.RawMessage) (asset BaseAsset, err error) {
+	if source == nil {
+		return asset, elastigo.RecordNotFound
+	}
+	if err = json.Unmarshal(*source, &asset.Data); err != nil {
+		return
+	}
+
+	asset.Type, _ = asset.Data[TYPELESS_TYPE_FIELD].(string)
+	asset.Id, _ = asset.Data[TYPELESS_ID_FIELD].(string)
+	asset.Timestamp = asset.Data[TYPELESS_TIMESTAMP_FIELD]
+	for _, f := range TYPELESS_RESERVED_FIELDS {
+		delete(asset.Data, f)
+	}
+	return
+}
+
+func assetsFromTypelessHits(hits []typelessHit) (assets []BaseAsset, err error) {
+	assets = make([]BaseAsset, len(hits))
+	for i, h := range hits {
+		if assets[i], err = assetFromTypelessSource(h.Source); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Filters selecting an asset or its versions
+func assetFilters(assetType, assetId string) []interface{} {
+	return []interface{}{
+		typeFilter(assetType),
+		map[string]interface{}{"term": map[string]string{TYPELESS_ID_FIELD: assetId}},
+	}
+}
+
+func (e *TypelessDatastore) search(index string, query interface{}) (resp typelessSearchResult, err error) {
+	var b []byte
+	if b, err = e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_search", index), nil, query); err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &resp)
+	return
+}
+
+// Returned by a scroll callback to stop without an error
+var errScrollDone = fmt.Errorf("Scroll done")
+
+/*
+	Call fn for every hit of a search using the scroll api.  The query is sent as is apart from
+	the batch size so it should sort by `_doc` unless the order matters.
+*/
+func (e *TypelessDatastore) scroll(index string, query map[string]interface{}, fn func(typelessHit) error) error {
+	body := map[string]interface{}{"size": ARCHIVE_BATCH_SIZE}
+	for k, v := range query {
+		body[k] = v
+	}
+
+	var rslt struct {
+		typelessSearchResult
+		ScrollId string `json:"_scroll_id"`
+	}
+	b, err := e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_search", index),
+		map[string]interface{}{"scroll": ARCHIVE_SCROLL_TIMEOUT}, body)
+
+	for err == nil {
+		rslt.Hits.Hits = nil
+		if err = json.Unmarshal(b, &rslt); err != nil || len(rslt.Hits.Hits) == 0 {
+			break
+		}
+		for _, h := range rslt.Hits.Hits {
+			if err = fn(h); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			b, err = e.Conn.DoCommand("POST", "/_search/scroll", nil,
+				map[string]string{"scroll": ARCHIVE_SCROLL_TIMEOUT, "scroll_id": rslt.ScrollId})
+		}
+	}
+
+	// Free the scroll context rather than waiting for it to expire
+	if len(rslt.ScrollId) > 0 {
+		e.Conn.DoCommand("DELETE", "/_search/scroll", nil, map[string]string{"scroll_id": rslt.ScrollId})
+	}
+	if err == errScrollDone {
+		return nil
+	}
+	return err
+}
+
+/*
+	Results of a search whose from + size is beyond the max result window.  The results are
+	scrolled through in the requested order skipping the first `from` hits.
+*/
+func (e *TypelessDatastore) scrollAssets(index string, query map[string]interface{}) (assets []BaseAsset, err error) {
+	from, _ := query["from"].(int64)
+	size, _ := query["size"].(int64)
+
+	scrollQuery := map[string]interface{}{"sort": []string{"_doc"}}
+	for k, v := range query {
+		if k != "from" && k != "size" {
+			scrollQuery[k] = v
+		}
+	}
+
+	assets = []BaseAsset{}
+	if size <= 0 {
+		return
+	}
+	err = e.scroll(index, scrollQuery, func(h typelessHit) error {
+		if from > 0 {
+			from--
+			return nil
+		}
+		asset, err := assetFromTypelessSource(h.Source)
+		if err != nil {
+			return err
+		}
+		if assets = append(assets, asset); int64(len(assets)) >= size {
+			return errScrollDone
+		}
+		return nil
+	})
+	return
+}
+
+// Create new asset or the given version of it
+func (e *TypelessDatastore) Create(asset BaseAsset, version int64) (id string, err error) {
+	if version <= 0 {
+		if err = e.addType(asset.Type); err != nil {
+			return
+		}
+
+		_, err = e.Conn.DoCommand("PUT", docPath(e.Index, typelessDocId(asset.Type, asset.Id)),
+			map[string]interface{}{"op_type": "create"}, typelessSource(asset, nowMillis()))
+		if simpless.IsResponseStatus(err, 409) {
+			return "", fmt.Errorf("Asset already exists: %s", asset.Id)
+		} else if err != nil {
+			return
+		}
+		return asset.Id, nil
+	}
+
+	asset.Data["version"] = version
+	// The timestamp of the asset is kept
+	if _, err = e.Conn.DoCommand("PUT", docPath(e.VersionIndex, typelessVersionDocId(asset.Type, asset.Id, version)),
+		nil, typelessSource(asset, asset.Timestamp)); err != nil {
+		return
+	}
+	e.log.Debugf("Version created: %s/%s.%d", asset.Type, asset.Id, version)
+	return fmt.Sprintf("%s.%d", asset.Id, version), nil
+}
+
+// Get a resource with optional version.  If the version is <= 0 the latest version is fetched
+func (e *TypelessDatastore) Get(assetType, assetId string, version int64) (BaseAsset, error) {
+	path := docPath(e.Index, typelessDocId(assetType, assetId))
+	if version > 0 {
+		path = docPath(e.VersionIndex, typelessVersionDocId(assetType, assetId, version))
+	}
+
+	b, err := e.Conn.DoCommand("GET", path, nil, nil)
+	if err != nil {
+		return BaseAsset{}, err
+	}
+	var hit typelessHit
+	if err = json.Unmarshal(b, &hit); err != nil {
+		return BaseAsset{}, err
+	}
+	return assetFromTypelessSource(hit.Source)
+}
+
+func (e *TypelessDatastore) Edit(updatedAsset *BaseAsset, delFields ...string) (id string, err error) {
+	docId := typelessDocId(updatedAsset.Type, updatedAsset.Id)
+
+	if len(delFields) > 0 {
+		for _, v := range delFields {
+			delete(updatedAsset.Data, v)
+		}
+		// Fresh index because we are deleting fields
+		_, err = e.Conn.DoCommand("PUT", docPath(e.Index, docId), nil, typelessSource(*updatedAsset, nowMillis()))
+	} else {
+		_, err = e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_update/%s", e.Index, docId), nil,
+			map[string]interface{}{"doc": typelessSource(*updatedAsset, nowMillis())})
+	}
+	if err != nil {
+		return
+	}
+	return updatedAsset.Id, nil
+}
+
+func (e *TypelessDatastore) Remove(assetType, assetId string) error {
+	_, err := e.Conn.DoCommand("DELETE", docPath(e.Index, typelessDocId(assetType, assetId)), nil, nil)
+	return err
+}
+
+// Query resource index or resource version index.
+func (e *TypelessDatastore) Query(assetType string, query map[string]interface{}, opts *types.QueryOptions, versionQuery bool) (rslt interface{}, err error) {
+	index := e.Index
+	if versionQuery {
+		// Versions hold the id of the asset so no translation is needed
+		index = e.VersionIndex
+	}
+
+	if err = translateIpRangeFields(query, func() (map[string]string, error) {
+		return e.TypeFieldTypes(assetType)
+	}); err != nil {
+		return nil, err
+	}
+
+	essQuery, err := buildTypelessQuery(assetType, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	e.log.Tracef("%#v\n", essQuery)
+
+	if _, ok := essQuery["aggs"]; !ok && exceedsResultWindow(essQuery, e.MaxResultWindow) {
+		return e.scrollAssets(index, essQuery)
+	}
+
+	resp, err := e.search(index, essQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aggregate queries
+	if _, ok := essQuery["aggs"]; ok {
+		var items []AggregatedItem
+		if items, err = parseAggregatedItems(resp.Aggregations, opts.Aggregate); err != nil {
+			return nil, err
+		}
+		if opts.Subnet > 0 {
+			items, err = aggregateBySubnet(items, int(opts.Subnet), opts.Size)
+		}
+		rslt = items
+	} else {
+		var assets []BaseAsset
+		if assets, err = assetsFromTypelessHits(resp.Hits.Hits); err != nil {
+			return nil, err
+		}
+		rslt = assets
+	}
+	return
+}
+
+// Get the last `count` asset versions
+func (e *TypelessDatastore) GetVersions(assetType, assetId string, count int64) ([]BaseAsset, error) {
+	query := map[string]interface{}{
+		"query": typelessBoolQuery(assetFilters(assetType, assetId)),
+		"sort": map[string]interface{}{
+			"version": map[string]string{"order": "desc", "unmapped_type": "long"},
+		},
+		"size": count,
+	}
+
+	resp, err := e.search(e.VersionIndex, query)
+	if err != nil {
+		e.log.Noticef("WARNING (GetVersions): id=%s %s\n", assetId, err)
+		return []BaseAsset{}, nil
+	}
+
+	vAssets, err := assetsFromTypelessHits(resp.Hits.Hits)
+	if err != nil {
+		return []BaseAsset{}, err
+	}
+
+	// Get current version
+	curr, err := e.Get(assetType, assetId, 0)
+	if err != nil {
+		e.log.Noticef("WARNING No current version: id=%s %s\n", assetId, err)
+		return vAssets, nil
+	}
+
+	if len(vAssets) > 0 {
+		curr.Data["version"] = vAssets[0].GetVersion() + 1
+	} else {
+		curr.Data["version"] = 1
+	}
+
+	return append([]BaseAsset{curr}, vAssets...), nil
+}
+
+// All versions of an asset in ascending order.  Versions are scrolled through so none are left out.
+func (e *TypelessDatastore) ListAllVersions(assetType, assetId string) ([]BaseAsset, error) {
+	query := map[string]interface{}{
+		"query": typelessBoolQuery(assetFilters(assetType, assetId)),
+		"sort": map[string]interface{}{
+			"version": map[string]string{"order": "asc", "unmapped_type": "long"},
+		},
+	}
+
+	versions := []BaseAsset{}
+	err := e.scroll(e.VersionIndex, query, func(h typelessHit) error {
+		v, err := assetFromTypelessSource(h.Source)
+		if err == nil {
+			versions = append(versions, v)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Timestamp (ms) of the most recent version of any asset of the type, or of all types if the type
+// is empty.  0 if there are no versions.
+func (e *TypelessDatastore) LatestVersionTimestamp(assetType string) (float64, error) {
+	query := map[string]interface{}{
+		"sort": map[string]interface{}{TYPELESS_TIMESTAMP_FIELD: "desc"},
+		"size": 1,
+	}
+	if len(assetType) > 0 {
+		query["query"] = typelessBoolQuery([]interface{}{typeFilter(assetType)})
+	}
+
+	resp, err := e.search(e.VersionIndex, query)
+	if err != nil {
+		return 0, err
+	}
+	versions, err := assetsFromTypelessHits(resp.Hits.Hits)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	ts, _ := versions[0].Timestamp.(float64)
+	return ts, nil
+}
+
+// Remove a single version of an asset
+func (e *TypelessDatastore) RemoveVersion(assetType, assetId string, version int64) error {
+	_, err := e.Conn.DoCommand("DELETE", docPath(e.VersionIndex, typelessVersionDocId(assetType, assetId, version)), nil, nil)
+	return err
+}
+
+// Add a type document unless one exists.  Types are added when the first asset is created.
+func (e *TypelessDatastore) addType(assetType string) error {
+	src, err := typelessMetaSource(META_TYPE_ASSET_TYPE, typelessTypeDoc{Name: assetType})
+	if err != nil {
+		return err
+	}
+	_, err = e.Conn.DoCommand("PUT", docPath(e.MetaIndex, typelessDocId(META_TYPE_ASSET_TYPE, assetType)),
+		map[string]interface{}{"op_type": "create"}, src)
+	if simpless.IsResponseStatus(err, 409) {
+		return nil
+	}
+	return err
+}
+
+// Type document.  An empty one is returned if the type does not have one.
+func (e *TypelessDatastore) getTypeDoc(assetType string) (doc typelessTypeDoc, err error) {
+	if err = e.getMetaDoc(META_TYPE_ASSET_TYPE, assetType, &doc); err == elastigo.RecordNotFound {
+		return typelessTypeDoc{Name: assetType}, nil
+	}
+	return
+}
+
+/*
+	Add property definitions to the mapping of the primary and version index.  The mapping is
+	shared by all types so a property cannot be defined differently for different types.
+*/
+func (e *TypelessDatastore) putProperties(props map[string]interface{}) (err error) {
+	mapping := map[string]interface{}{"properties": props}
+	for _, index := range []string{e.Index, e.VersionIndex} {
+		if _, err = e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/_mapping", index), nil, mapping); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Store the type document merging in the property definitions
+func (e *TypelessDatastore) putType(assetType string, props, meta map[string]interface{}) error {
+	doc, err := e.getTypeDoc(assetType)
+	if err != nil {
+		return err
+	}
+
+	if len(props) > 0 {
+		if err = e.putProperties(props); err != nil {
+			return err
+		}
+		if doc.Properties == nil {
+			doc.Properties = map[string]interface{}{}
+		}
+		for k, v := range props {
+			doc.Properties[k] = v
+		}
+	}
+	if meta != nil {
+		doc.Metadata = meta
+	}
+	return e.putMetaDoc(META_TYPE_ASSET_TYPE, assetType, doc)
+}
+
+// Create a type with optional property definitions and metadata i.e. `properties` and `_meta`
+func (e *TypelessDatastore) CreateType(assetType string, opts map[string]interface{}) error {
+	props, _ := opts["properties"].(map[string]interface{})
+	meta, _ := opts["_meta"].(map[string]interface{})
+
+	e.log.Noticef("%s %s '%v'\n", e.Index, assetType, opts)
+	return e.putType(assetType, props, meta)
+}
+
+// Update a type adding new property definitions and setting the metadata.  Existing property
+// definitions cannot be changed.
+func (e *TypelessDatastore) UpdateType(assetType string, props map[string]interface{}, meta *AssetTypeMetadata) error {
+	return e.putType(assetType, props, meta.mappingMeta())
+}
+
+// Metadata for a type.  nil is returned if the type does not have any.
+func (e *TypelessDatastore) GetTypeMetadata(assetType string) (*AssetTypeMetadata, error) {
+	doc, err := e.getTypeDoc(assetType)
+	if err != nil {
+		return nil, err
+	}
+	return metadataFromMapping(doc.Metadata), nil
+}
+
+// Remove the type document.  Property definitions remain part of the shared mapping.
+func (e *TypelessDatastore) RemoveType(assetType string) error {
+	return e.removeMetaDoc(META_TYPE_ASSET_TYPE, assetType)
+}
+
+// Number of assets of the given type
+func (e *TypelessDatastore) CountAssets(assetType string) (int64, error) {
+	b, err := e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_count", e.Index), nil,
+		map[string]interface{}{"query": typelessBoolQuery([]interface{}{typeFilter(assetType)})})
+	if err != nil {
+		return 0, err
+	}
+
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	err = json.Unmarshal(b, &resp)
+	return resp.Count, err
+}
+
+func (e *TypelessDatastore) TypeExists(assetType string) error {
+	list, err := e.ListTypes()
+	if err != nil {
+		return err
+	}
+	return checkTypeListed(list, assetType)
+}
+
+// Types with assets along with the ones that only have a type document
+func (e *TypelessDatastore) ListTypes() (typeList []ResourceType, err error) {
+	aggrQuery := map[string]interface{}{
+		"size": 0,
+		"aggs": buildElasticsearchAggregateQuery(TYPELESS_TYPE_FIELD, TYPELESS_MAX_TERMS),
+	}
+
+	var resp typelessSearchResult
+	if resp, err = e.search(e.Index, aggrQuery); err != nil {
+		return
+	}
+	var aggrItems []AggregatedItem
+	if aggrItems, err = parseAggregatedItems(resp.Aggregations, TYPELESS_TYPE_FIELD); err != nil {
+		return
+	}
+
+	var docs []*json.RawMessage
+	if docs, err = e.listMetaDocs(META_TYPE_ASSET_TYPE, nil, nil, TYPELESS_MAX_TERMS); err != nil {
+		return
+	}
+	typeDocs := map[string]typelessTypeDoc{}
+	names := make([]string, 0, len(docs))
+	for _, d := range docs {
+		var doc typelessTypeDoc
+		if err = json.Unmarshal(*d, &doc); err != nil {
+			return
+		}
+		typeDocs[doc.Name] = doc
+		names = append(names, doc.Name)
+	}
+	sort.Strings(names)
+
+	// Add types without any assets
+	inOutput := map[string]bool{}
+	for _, v := range aggrItems {
+		inOutput[v.Name] = true
+	}
+	for _, name := range names {
+		if !inOutput[name] {
+			aggrItems = append(aggrItems, AggregatedItem{Name: name, Count: 0})
+		}
+	}
+
+	typeList = make([]ResourceType, len(aggrItems))
+	for i, v := range aggrItems {
+		typeList[i] = ResourceType{AggregatedItem: v, Metadata: metadataFromMapping(typeDocs[v.Name].Metadata)}
+	}
+	return
+}
+
+// Top level property mappings of an index excluding the reserved fields
+func (e *TypelessDatastore) indexProperties(index string) (props map[string]interface{}, err error) {
+	var b []byte
+	if b, err = e.Conn.DoCommand("GET", fmt.Sprintf("/%s/_mapping", index), nil, nil); err != nil {
+		return
+	}
+
+	var mapping map[string]struct {
+		Mappings struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err = json.Unmarshal(b, &mapping); err != nil {
+		return
+	}
+	// Keyed by the concrete index
+	for _, m := range mapping {
+		props = m.Mappings.Properties
+		break
+	}
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	for _, f := range TYPELESS_RESERVED_FIELDS {
+		delete(props, f)
+	}
+	return
+}
+
+// List all properties for a given type
+func (e *TypelessDatastore) ListTypeProperties(assetType string) ([]string, error) {
+	details, err := e.ListTypePropertyDetails(assetType)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make([]string, len(details))
+	for i, d := range details {
+		props[i] = d.Name
+	}
+	return props, nil
+}
+
+// Mapping type of each field of a type by its dotted path.  The mapping is shared by all types.
+func (e *TypelessDatastore) TypeFieldTypes(assetType string) (map[string]string, error) {
+	propMap, err := e.indexProperties(e.Index)
+	if err != nil {
+		return nil, err
+	}
+	types := map[string]string{}
+	flattenMappingTypes(propMap, "", types)
+	return types, nil
+}
+
+/*
+	Detailed property information for a given type.  As the mapping is shared by all types only
+	the properties used by assets of the type or defined for it are listed.
+*/
+func (e *TypelessDatastore) ListTypePropertyDetails(assetType string) (details []PropertyDetail, err error) {
+	var propMap map[string]interface{}
+	if propMap, err = e.indexProperties(e.Index); err != nil {
+		return
+	}
+	var doc typelessTypeDoc
+	if doc, err = e.getTypeDoc(assetType); err != nil {
+		return
+	}
+
+	names := make([]string, 0, len(propMap))
+	for k, _ := range propMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	query := map[string]interface{}{
+		"size":             0,
+		"query":            typelessBoolQuery([]interface{}{typeFilter(assetType)}),
+		"track_total_hits": true,
+	}
+	if aggs := propertyStatsAggs(names, propMap); len(aggs) > 0 {
+		query["aggs"] = aggs
+	}
+
+	var resp typelessSearchResult
+	if resp, err = e.search(e.Index, query); err != nil {
+		return
+	}
+	var all []PropertyDetail
+	if all, err = propertyDetails(names, propMap, resp.Aggregations, resp.Hits.Total.Value); err != nil {
+		return
+	}
+
+	var aggrs map[string]simpless.AggrMetric
+	if len(resp.Aggregations) > 0 {
+		if err = json.Unmarshal(resp.Aggregations, &aggrs); err != nil {
+			return
+		}
+	}
+
+	// id and timestamp are listed first
+	details = all[:2]
+	for i, name := range names {
+		if _, defined := doc.Properties[name]; defined || aggrs[fmt.Sprintf("filled_%d", i)].DocCount > 0 {
+			details = append(details, all[i+2])
+		}
+	}
+	return
+}
+
+// Names of the saved queries selecting each asset.  Evaluated with a single multi search.
+func (e *TypelessDatastore) MatchSavedQueries(queries []SavedQuery, assets []AssetRef) (map[AssetRef][]string, error) {
+	return matchSavedQueryPairs(e.Conn, e.log, savedQueryPairs(queries, assets), func(p savedQueryPair) (multiSearchItem, error) {
+		req := copyQuery(p.Query.Query)
+		if err := translateIpRangeFields(req, func() (map[string]string, error) {
+			return e.TypeFieldTypes(p.Asset.Type)
+		}); err != nil {
+			return multiSearchItem{}, err
+		}
+		essQuery, err := buildTypelessQuery(p.Asset.Type, req, nil, map[string]interface{}{
+			"ids": map[string]interface{}{"values": []string{typelessDocId(p.Asset.Type, p.Asset.Id)}},
+		})
+		if err != nil {
+			return multiSearchItem{}, err
+		}
+		return multiSearchItem{Header: map[string]interface{}{"index": e.Index}, Query: essQuery}, nil
+	})
+}
+
+// Ids of assets, other than the given one, with the same values for all the given fields.
+func (e *TypelessDatastore) FindDuplicates(assetType, assetId string, values map[string]interface{}) (ids []string, err error) {
+	filters := []interface{}{typeFilter(assetType)}
+	for k, v := range values {
+		if arr, ok := v.([]interface{}); ok {
+			filters = append(filters, map[string]interface{}{"terms": map[string]interface{}{k: arr}})
+		} else {
+			filters = append(filters, map[string]interface{}{"term": map[string]interface{}{k: v}})
+		}
+	}
+
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filters,
+				"must_not": []interface{}{
+					map[string]interface{}{"ids": map[string]interface{}{"values": []string{typelessDocId(assetType, assetId)}}},
+				},
+			},
+		},
+		"_source": false,
+		"size":    MAX_UNIQUE_VIOLATION_IDS,
+	}
+
+	var resp typelessSearchResult
+	if resp, err = e.search(e.Index, query); err != nil {
+		return
+	}
+
+	ids = make([]string, len(resp.Hits.Hits))
+	for i, h := range resp.Hits.Hits {
+		_, ids[i] = splitTypelessDocId(h.Id)
+	}
+	return
+}
+
+// Values of the given fields shared by more than one asset of the type.
+func (e *TypelessDatastore) ListDuplicates(assetType string, fields []string) (dups []UniqueViolation, err error) {
+	if len(fields) == 0 {
+		return []UniqueViolation{}, nil
+	}
+
+	aggs := duplicatesAggrQuery(fields)
+	limitTermsSize(aggs)
+	query := map[string]interface{}{
+		"size":  0,
+		"query": typelessBoolQuery([]interface{}{typeFilter(assetType)}),
+		"aggs":  aggs,
+	}
+
+	var resp typelessSearchResult
+	if resp, err = e.search(e.Index, query); err != nil {
+		return
+	}
+	if dups, err = parseDuplicatesAggr(fields, resp.Aggregations); err != nil {
+		return
+	}
+	for _, uv := range dups {
+		for j, id := range uv.Ids {
+			_, uv.Ids[j] = splitTypelessDocId(id)
+		}
+	}
+	return
+}
+
+// Links from other assets referencing the given asset.  All referrers are scrolled through.
+func (e *TypelessDatastore) ListReferrers(target AssetRef) (links []AssetLink, err error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  target.String(),
+				"fields": []string{LINKS_FIELD + ".*"},
+			},
+		},
+		"_source": LINKS_FIELD,
+		"sort":    []string{"_doc"},
+	}
+
+	links = []AssetLink{}
+	err = e.scroll(e.Index, query, func(h typelessHit) error {
+		var data map[string]interface{}
+		if h.Source == nil {
+			return nil
+		}
+		if err := json.Unmarshal(*h.Source, &data); err != nil {
+			return err
+		}
+
+		assetType, assetId := splitTypelessDocId(h.Id)
+		srcLinks, _ := parseAssetLinks(AssetRef{Type: assetType, Id: assetId}, data)
+		for _, l := range srcLinks {
+			if l.Target == target {
+				links = append(links, l)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Source of a meta document with the meta type added so documents can be listed by type
+func typelessMetaSource(metaType string, doc interface{}) (src map[string]interface{}, err error) {
+	var b []byte
+	if b, err = json.Marshal(doc); err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &src); err != nil {
+		return
+	}
+	src[TYPELESS_META_TYPE_FIELD] = metaType
+	return
+}
+
+// Store a document in the meta index under the given meta type.
+func (e *TypelessDatastore) putMetaDoc(metaType, id string, doc interface{}) error {
+	src, err := typelessMetaSource(metaType, doc)
+	if err != nil {
+		return err
+	}
+	_, err = e.Conn.DoCommand("PUT", docPath(e.MetaIndex, typelessDocId(metaType, id)), nil, src)
+	return err
+}
+
+// Get a document from the meta index unmarshalling the source into `doc`.  RecordNotFound is returned
+// if the document does not exist.
+func (e *TypelessDatastore) getMetaDoc(metaType, id string, doc interface{}) (err error) {
+	var b []byte
+	if b, err = e.Conn.DoCommand("GET", docPath(e.MetaIndex, typelessDocId(metaType, id)), nil, nil); err != nil {
+		return
+	}
+
+	var hit typelessHit
+	if err = json.Unmarshal(b, &hit); err != nil {
+		return
+	}
+	if hit.Source == nil {
+		return elastigo.RecordNotFound
+	}
+	return json.Unmarshal(*hit.Source, doc)
+}
+
+func (e *TypelessDatastore) removeMetaDoc(metaType, id string) (err error) {
+	_, err = e.Conn.DoCommand("DELETE", docPath(e.MetaIndex, typelessDocId(metaType, id)), nil, nil)
+	return
+}
+
+// List the raw sources of documents of a given meta type optionally matching the filters.
+func (e *TypelessDatastore) listMetaDocs(metaType string, filters []interface{}, sort interface{}, size int64) (docs []*json.RawMessage, err error) {
+	filters = append([]interface{}{
+		map[string]interface{}{"term": map[string]string{TYPELESS_META_TYPE_FIELD: metaType}},
+	}, filters...)
+
+	query := map[string]interface{}{"query": typelessBoolQuery(filters), "size": size}
+	if sort != nil {
+		query["sort"] = sort
+	}
+
+	var resp typelessSearchResult
+	if resp, err = e.search(e.MetaIndex, query); err != nil {
+		return
+	}
+
+	docs = make([]*json.RawMessage, len(resp.Hits.Hits))
+	for i, h := range resp.Hits.Hits {
+		docs[i] = h.Source
+	}
+	return
+}
+
+/*
+	Next value of a named sequence starting at 1.  The sequence document is re-indexed and the
+	document version, which elasticsearch increments atomically, is used as the value.
+*/
+func (e *TypelessDatastore) NextSequence(name string) (int64, error) {
+	src, err := typelessMetaSource(META_TYPE_SEQUENCE, map[string]string{"name": name})
+	if err != nil {
+		return 0, err
+	}
+	// Not retried as a retry after a lost response would skip a value
+	b, err := e.Conn.DoCommandWithOptions("PUT", docPath(e.MetaIndex, typelessDocId(META_TYPE_SEQUENCE, name)),
+		nil, src, simpless.RequestOptions{NoRetry: true})
+	if err != nil {
+		return 0, err
+	}
+
+	var hit typelessHit
+	if err = json.Unmarshal(b, &hit); err != nil {
+		return 0, err
+	}
+	return hit.Version, nil
+}
+
+/*
+	Reserve a unique key for an asset.  The reservation is created only if it does not exist so
+	only one asset can hold it.  Returns the asset holding it if already reserved.
+*/
+func (e *TypelessDatastore) ReserveUniqueKey(key string, holder AssetRef) (*AssetRef, error) {
+	src, err := typelessMetaSource(META_TYPE_UNIQUE_KEY, UniqueKeyReservation{AssetType: holder.Type, AssetId: holder.Id})
+	if err != nil {
+		return nil, err
+	}
+	_, err = e.Conn.DoCommand("PUT", docPath(e.MetaIndex, typelessDocId(META_TYPE_UNIQUE_KEY, key)),
+		map[string]interface{}{"op_type": "create"}, src)
+	if !simpless.IsResponseStatus(err, 409) {
+		return nil, err
+	}
+
+	var r UniqueKeyReservation
+	if err = e.getMetaDoc(META_TYPE_UNIQUE_KEY, key, &r); err != nil {
+		return nil, err
+	}
+	current := r.Holder()
+	return &current, nil
+}
+
+// Remove the reservation of a unique key if it is still held by the given asset
+func (e *TypelessDatastore) ReleaseUniqueKey(key string, holder AssetRef) error {
+	id := typelessDocId(META_TYPE_UNIQUE_KEY, key)
+	b, err := e.Conn.DoCommand("GET", docPath(e.MetaIndex, id), nil, nil)
+	if err == elastigo.RecordNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var hit typelessHit
+	if err = json.Unmarshal(b, &hit); err != nil {
+		return err
+	}
+	if hit.Source == nil {
+		return nil
+	}
+	var r UniqueKeyReservation
+	if err = json.Unmarshal(*hit.Source, &r); err != nil {
+		return err
+	}
+	if r.Holder() != holder {
+		return nil
+	}
+
+	// Only removed if not reserved again in the meantime
+	_, err = e.Conn.DoCommand("DELETE", docPath(e.MetaIndex, id),
+		map[string]interface{}{"if_seq_no": hit.SeqNo, "if_primary_term": hit.PrimaryTerm}, nil)
+	if simpless.IsResponseStatus(err, 409) || err == elastigo.RecordNotFound {
+		return nil
+	}
+	return err
+}
+
+func (e *TypelessDatastore) PutTypeSchema(ts AssetTypeSchema) error {
+	return e.putMetaDoc(META_TYPE_SCHEMA, ts.AssetType, ts)
+}
+
+func (e *TypelessDatastore) GetTypeSchema(assetType string) (ts AssetTypeSchema, err error) {
+	if err = e.getMetaDoc(META_TYPE_SCHEMA, assetType, &ts); err == elastigo.RecordNotFound {
+		err = &NotFoundError{Kind: "Schema", Name: assetType}
+	}
+	return
+}
+
+func (e *TypelessDatastore) RemoveTypeSchema(assetType string) (err error) {
+	if err = e.removeMetaDoc(META_TYPE_SCHEMA, assetType); err == elastigo.RecordNotFound {
+		err = &NotFoundError{Kind: "Schema", Name: assetType}
+	}
+	return
+}
+
+func (e *TypelessDatastore) PutSavedQuery(sq SavedQuery) error {
+	return e.putMetaDoc(META_TYPE_SAVED_QUERY, sq.Name, sq)
+}
+
+func (e *TypelessDatastore) GetSavedQuery(name string) (sq SavedQuery, err error) {
+	if err = e.getMetaDoc(META_TYPE_SAVED_QUERY, name, &sq); err == elastigo.RecordNotFound {
+		err = &NotFoundError{Kind: "Saved query", Name: name}
+	}
+	return
+}
+
+func (e *TypelessDatastore) RemoveSavedQuery(name string) error {
+	return e.removeMetaDoc(META_TYPE_SAVED_QUERY, name)
+}
+
+// List saved queries optionally filtered by owner.  Results are sorted by name.
+func (e *TypelessDatastore) ListSavedQueries(owner string) (list []SavedQuery, err error) {
+	var filters []interface{}
+	if len(owner) > 0 {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"owner": owner}})
+	}
+
+	var docs []*json.RawMessage
+	if docs, err = e.listMetaDocs(META_TYPE_SAVED_QUERY, filters,
+		[]map[string]string{map[string]string{"name": "asc"}}, MAX_SAVED_QUERIES); err != nil {
+		return
+	}
+
+	list = make([]SavedQuery, len(docs))
+	for i, d := range docs {
+		if err = json.Unmarshal(*d, &list[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (e *TypelessDatastore) Pool() *simpless.Pool {
+	return e.Conn.Pool
+}
+
+func (e *TypelessDatastore) ClusterStatus() (cs VindaluClusterStatus, err error) {
+	var b []byte
+	if b, err = e.Conn.DoCommand("GET", "/_cluster/state", nil, nil); err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &cs); err != nil {
+		return
+	}
+
+	if b, err = e.Conn.DoCommand("GET", "/_cluster/health", nil, nil); err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &cs.Health)
+	return
+}
+
+// Refresh the primary index making recent writes searchable.
+func (e *TypelessDatastore) Refresh() error {
+	_, err := e.Conn.DoCommand("POST", fmt.Sprintf("/%s/_refresh", e.Index), nil, nil)
+	return err
+}
+
+func (e *TypelessDatastore) Close() error {
+	e.Conn.Close()
+	return nil
+}
@@ -0,0 +1,376 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/types"
+)
+
+type fakeEssRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   map[string]interface{}
+	Raw    string
+}
+
+/*
+	Fake elasticsearch recording the requests.  Responses are keyed by "<method> <path>".  Pages
+	are returned in order before the response, i.e. for scrolls.  Paths without a response get a
+	404 on HEAD and GET and an empty object otherwise.
+*/
+type fakeEss struct {
+	*httptest.Server
+	Responses map[string]string
+	Pages     map[string][]string
+	Requests  []fakeEssRequest
+}
+
+func newFakeEss() *fakeEss {
+	fe := &fakeEss{Responses: map[string]string{}, Pages: map[string][]string{}}
+	fe.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := fakeEssRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery}
+		if b, _ := ioutil.ReadAll(r.Body); len(b) > 0 {
+			req.Raw = string(b)
+			json.Unmarshal(b, &req.Body)
+		}
+		fe.Requests = append(fe.Requests, req)
+
+		key := r.Method + " " + r.URL.Path
+		if pages := fe.Pages[key]; len(pages) > 0 {
+			fe.Pages[key] = pages[1:]
+			w.Write([]byte(pages[0]))
+		} else if rsp, ok := fe.Responses[key]; ok {
+			w.Write([]byte(rsp))
+		} else if r.Method == "HEAD" || r.Method == "GET" {
+			w.WriteHeader(404)
+		} else {
+			w.Write([]byte(`{}`))
+		}
+	}))
+	return fe
+}
+
+// Last request for the method and path
+func (fe *fakeEss) request(method, path string) *fakeEssRequest {
+	for i := len(fe.Requests) - 1; i >= 0; i-- {
+		if fe.Requests[i].Method == method && fe.Requests[i].Path == path {
+			return &fe.Requests[i]
+		}
+	}
+	return nil
+}
+
+func newTestTypelessDatastore(t *testing.T, fe *fakeEss) *TypelessDatastore {
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(fe.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+
+	ds, err := NewTypelessDatastore(&config.DatastoreConfig{
+		Type:   "elasticsearch7",
+		Config: map[string]interface{}{"host": host, "port": p, "index": "vindalu"},
+	}, testLogger)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return ds
+}
+
+func Test_NewTypelessDatastore(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	fe.Responses["HEAD /vindalu_meta"] = ``
+
+	newTestTypelessDatastore(t, fe)
+
+	req := fe.request("PUT", "/vindalu")
+	if req == nil {
+		t.Fatalf("Index not created: %#v", fe.Requests)
+	}
+	b, _ := json.Marshal(req.Body)
+	if !strings.Contains(string(b), `"max_result_window":10000`) ||
+		!strings.Contains(string(b), `"vindalu_type":{"type":"keyword"}`) {
+		t.Fatalf("Wrong index body: %s", b)
+	}
+	if fe.request("PUT", "/vindalu_versions") == nil {
+		t.Fatalf("Version index not created")
+	}
+	// Existing index
+	if fe.request("PUT", "/vindalu_meta") != nil || fe.request("PUT", "/vindalu_meta/_mapping") == nil {
+		t.Fatalf("Meta mapping should be updated")
+	}
+}
+
+func Test_TypelessDatastore_Create(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	asset := BaseAsset{Type: "server", Id: "web01", Data: map[string]interface{}{"os": "ubuntu"}}
+	if _, err := ds.Create(asset, 0); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	req := fe.request("PUT", "/vindalu/_doc/server:web01")
+	if req == nil || req.Query != "op_type=create" {
+		t.Fatalf("Wrong create request: %#v", fe.Requests)
+	}
+	if req.Body["vindalu_type"] != "server" || req.Body["vindalu_id"] != "web01" || req.Body["os"] != "ubuntu" {
+		t.Fatalf("Wrong source: %#v", req.Body)
+	}
+	if _, ok := req.Body["vindalu_timestamp"].(float64); !ok {
+		t.Fatalf("Timestamp missing: %#v", req.Body)
+	}
+	if req = fe.request("PUT", "/vindalu_meta/_doc/type:server"); req == nil || req.Body["vindalu_meta_type"] != "type" {
+		t.Fatalf("Type not added: %#v", fe.Requests)
+	}
+}
+
+func Test_TypelessDatastore_Get(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	fe.Responses["GET /vindalu/_doc/server:web01"] = `{"_id": "server:web01", "found": true, "_source": {
+		"os": "ubuntu", "vindalu_type": "server", "vindalu_id": "web01", "vindalu_timestamp": 1450000000000}}`
+
+	asset, err := ds.Get("server", "web01", 0)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if asset.Type != "server" || asset.Id != "web01" || asset.Timestamp != float64(1450000000000) {
+		t.Fatalf("Wrong asset: %#v", asset)
+	}
+	if _, ok := asset.Data["vindalu_id"]; ok || asset.Data["os"] != "ubuntu" {
+		t.Fatalf("Reserved fields should be removed: %#v", asset.Data)
+	}
+
+	if _, err = ds.Get("server", "web02", 0); err == nil {
+		t.Fatalf("Should not be found")
+	}
+	if _, err = ds.Get("server", "web01", 2); err == nil || fe.request("GET", "/vindalu_versions/_doc/server:web01.2") == nil {
+		t.Fatalf("Version should be fetched from version index: %v", err)
+	}
+}
+
+func Test_TypelessDatastore_Query(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	fe.Responses["POST /vindalu/_search"] = `{"hits": {"total": {"value": 1}, "hits": [
+		{"_id": "server:web01", "_source": {"os": "ubuntu", "vindalu_type": "server", "vindalu_id": "web01"}}]}}`
+
+	rslt, err := ds.Query("server", map[string]interface{}{"os": "ubuntu"}, &types.QueryOptions{Size: 10}, false)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if assets := rslt.([]BaseAsset); len(assets) != 1 || assets[0].Id != "web01" {
+		t.Fatalf("Wrong result: %#v", rslt)
+	}
+
+	b, _ := json.Marshal(fe.request("POST", "/vindalu/_search").Body)
+	if !strings.Contains(string(b), `"query":{"bool":{"filter":[{"term":{"vindalu_type":"server"}},{"term":{"os":"ubuntu"}}]}}`) {
+		t.Fatalf("Wrong query: %s", b)
+	}
+}
+
+func Test_TypelessDatastore_Query_Scroll(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+	ds.MaxResultWindow = 2
+
+	fe.Responses["POST /vindalu/_search"] = `{"_scroll_id": "s1", "hits": {"hits": [
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web01"}},
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web02"}},
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web03"}}]}}`
+	fe.Responses["POST /_search/scroll"] = `{"_scroll_id": "s1", "hits": {"hits": [
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web04"}}]}}`
+
+	rslt, err := ds.Query("server", map[string]interface{}{}, &types.QueryOptions{From: 2, Size: 2}, false)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	assets := rslt.([]BaseAsset)
+	if len(assets) != 2 || assets[0].Id != "web03" || assets[1].Id != "web04" {
+		t.Fatalf("Wrong page: %#v", assets)
+	}
+
+	req := fe.request("POST", "/vindalu/_search")
+	if req.Query != "scroll=5m" || req.Body["from"] != nil || req.Body["size"] != float64(ARCHIVE_BATCH_SIZE) {
+		t.Fatalf("Wrong scroll request: %#v", req)
+	}
+	if fe.request("DELETE", "/_search/scroll") == nil {
+		t.Fatalf("Scroll not cleared")
+	}
+}
+
+func Test_TypelessDatastore_ListAllVersions(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	// More versions than returned by the first request
+	fe.Pages["POST /vindalu_versions/_search"] = []string{`{"_scroll_id": "s1", "hits": {"total": {"value": 3}, "hits": [
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web01", "version": 1}},
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web01", "version": 2}}]}}`}
+	fe.Pages["POST /_search/scroll"] = []string{`{"_scroll_id": "s1", "hits": {"hits": [
+		{"_source": {"vindalu_type": "server", "vindalu_id": "web01", "version": 3}}]}}`}
+	fe.Responses["POST /_search/scroll"] = `{"_scroll_id": "s1", "hits": {"hits": []}}`
+
+	versions, err := ds.ListAllVersions("server", "web01")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(versions) != 3 || versions[2].GetVersion() != 3 {
+		t.Fatalf("All versions should be listed: %#v", versions)
+	}
+	if req := fe.request("POST", "/vindalu_versions/_search"); req.Query != "scroll=5m" {
+		t.Fatalf("Versions should be scrolled: %#v", req)
+	}
+}
+
+func Test_TypelessDatastore_ListReferrers(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	// More referrers than returned by the first request
+	fe.Pages["POST /vindalu/_search"] = []string{`{"_scroll_id": "s1", "hits": {"total": {"value": 2}, "hits": [
+		{"_id": "server:web01", "_source": {"_links": {"pool": "pool/web"}}}]}}`}
+	fe.Pages["POST /_search/scroll"] = []string{`{"_scroll_id": "s1", "hits": {"hits": [
+		{"_id": "server:web02", "_source": {"_links": {"pool": "pool/web"}}}]}}`}
+	fe.Responses["POST /_search/scroll"] = `{"_scroll_id": "s1", "hits": {"hits": []}}`
+
+	links, err := ds.ListReferrers(AssetRef{Type: "pool", Id: "web"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(links) != 2 || links[1].Source != (AssetRef{Type: "server", Id: "web02"}) {
+		t.Fatalf("All referrers should be listed: %#v", links)
+	}
+}
+
+func Test_TypelessDatastore_Edit(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	asset := BaseAsset{Type: "server", Id: "web01", Data: map[string]interface{}{"os": "centos"}}
+	if _, err := ds.Edit(&asset); err != nil {
+		t.Fatalf("%s", err)
+	}
+	req := fe.request("POST", "/vindalu/_update/server:web01")
+	if req == nil {
+		t.Fatalf("Wrong update request: %#v", fe.Requests)
+	}
+	if doc, _ := req.Body["doc"].(map[string]interface{}); doc["os"] != "centos" || doc["vindalu_type"] != "server" {
+		t.Fatalf("Wrong update: %#v", req.Body)
+	}
+
+	if _, err := ds.Edit(&asset, "os"); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if req = fe.request("PUT", "/vindalu/_doc/server:web01"); req == nil || req.Body["os"] != nil {
+		t.Fatalf("Field should be removed: %#v", req)
+	}
+}
+
+func Test_TypelessDatastore_ListTypes(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	fe.Responses["POST /vindalu/_search"] = `{"hits": {"total": {"value": 3}, "hits": []}, "aggregations": {
+		"vindalu_type": {"buckets": [{"key": "server", "doc_count": 3}]}}}`
+	fe.Responses["POST /vindalu_meta/_search"] = `{"hits": {"total": {"value": 2}, "hits": [
+		{"_id": "type:pool", "_source": {"name": "pool", "metadata": {"description": "Pools"}}},
+		{"_id": "type:server", "_source": {"name": "server"}}]}}`
+
+	list, err := ds.ListTypes()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(list) != 2 || list[0].Name != "server" || list[0].Count != 3 || list[1].Name != "pool" || list[1].Count != 0 {
+		t.Fatalf("Wrong types: %#v", list)
+	}
+	if list[1].Metadata == nil || list[1].Metadata.Description != "Pools" {
+		t.Fatalf("Metadata missing: %#v", list[1].Metadata)
+	}
+	if err = checkTypeListed(list, "rack"); err == nil {
+		t.Fatalf("Type should not exist")
+	}
+}
+
+func Test_TypelessDatastore_UniqueKeys(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	web01 := AssetRef{Type: "server", Id: "web01"}
+	holder, err := ds.ReserveUniqueKey("abc", web01)
+	if err != nil || holder != nil {
+		t.Fatalf("Should be reserved: %v %v", holder, err)
+	}
+	req := fe.request("PUT", "/vindalu_meta/_doc/unique:abc")
+	if req == nil || req.Query != "op_type=create" || req.Body["asset_id"] != "web01" || req.Body["vindalu_meta_type"] != "unique" {
+		t.Fatalf("Wrong reserve request: %#v", req)
+	}
+
+	fe.Responses["GET /vindalu_meta/_doc/unique:abc"] = `{"_id": "unique:abc", "_seq_no": 7, "_primary_term": 2,
+		"_source": {"asset_type": "server", "asset_id": "web01", "vindalu_meta_type": "unique"}}`
+	if err = ds.ReleaseUniqueKey("abc", AssetRef{Type: "server", Id: "web02"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if fe.request("DELETE", "/vindalu_meta/_doc/unique:abc") != nil {
+		t.Fatalf("Reservation of another asset should be kept")
+	}
+
+	if err = ds.ReleaseUniqueKey("abc", web01); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if req = fe.request("DELETE", "/vindalu_meta/_doc/unique:abc"); req == nil || req.Query != "if_primary_term=2&if_seq_no=7" {
+		t.Fatalf("Wrong release request: %#v", req)
+	}
+}
+
+func Test_TypelessDatastore_MatchSavedQueries(t *testing.T) {
+	fe := newFakeEss()
+	defer fe.Close()
+	ds := newTestTypelessDatastore(t, fe)
+
+	fe.Responses["POST /_msearch"] = `{"responses": [
+		{"hits": {"total": {"value": 1}}},
+		{"hits": {"total": {"value": 0}}},
+		{"error": {"type": "query_shard_exception"}}]}`
+
+	queries := []SavedQuery{
+		{Name: "ubuntu", Query: map[string]interface{}{"os": "ubuntu"}},
+		{Name: "stopped", AssetType: "server", Query: map[string]interface{}{"status": "stopped"}},
+		{Name: "broken", Query: map[string]interface{}{"os": "centos"}},
+	}
+	web01 := AssetRef{Type: "server", Id: "web01"}
+	matched, err := ds.MatchSavedQueries(queries, []AssetRef{web01})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(matched[web01]) != 1 || matched[web01][0] != "ubuntu" {
+		t.Fatalf("Wrong matches: %#v", matched)
+	}
+
+	req := fe.request("POST", "/_msearch")
+	if req == nil || strings.Count(req.Raw, "\n") != 6 || !strings.Contains(req.Raw, `"server:web01"`) {
+		t.Fatalf("Wrong multi search: %#v", req)
+	}
+	if fe.request("POST", "/vindalu/_refresh") != nil {
+		t.Fatalf("Should not refresh")
+	}
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vindalu/vindalu/types"
+)
+
+// Fields added to the documents of the typeless datastore as there is no `_type` or `_timestamp`.
+// They are removed from the asset data when read.
+const (
+	TYPELESS_TYPE_FIELD      = "vindalu_type"
+	TYPELESS_ID_FIELD        = "vindalu_id"
+	TYPELESS_TIMESTAMP_FIELD = "vindalu_timestamp"
+	// Document type in the meta index
+	TYPELESS_META_TYPE_FIELD = "vindalu_meta_type"
+
+	// Bucket limit for terms aggregations which elasticsearch 1.x did not need with a size of 0
+	TYPELESS_MAX_TERMS = 10000
+	// Default index.max_result_window i.e. max value of from + size
+	TYPELESS_DEFAULT_RESULT_WINDOW = 10000
+)
+
+var TYPELESS_RESERVED_FIELDS = []string{TYPELESS_TYPE_FIELD, TYPELESS_ID_FIELD, TYPELESS_TIMESTAMP_FIELD}
+
+// Document id of an asset i.e. <type>:<id>.  Types cannot contain a colon.
+func typelessDocId(assetType, assetId string) string {
+	return assetType + ":" + assetId
+}
+
+// Document id of an asset version i.e. <type>:<id>.<version>
+func typelessVersionDocId(assetType, assetId string, version int64) string {
+	return fmt.Sprintf("%s:%s.%d", assetType, assetId, version)
+}
+
+// Asset type and id from a document id
+func splitTypelessDocId(docId string) (assetType, assetId string) {
+	i := strings.Index(docId, ":")
+	if i < 0 {
+		return "", docId
+	}
+	return docId[:i], docId[i+1:]
+}
+
+// Translate the vindalu `id` field, including with a match operator, to the field holding the
+// asset id.  Regex and prefix queries are not supported on `_id`.
+func translateTypelessIdField(req map[string]interface{}) {
+	for k, v := range req {
+		if field, op := splitFieldOperator(k); field == "id" {
+			delete(req, k)
+			if len(op) > 0 {
+				req[TYPELESS_ID_FIELD+MATCH_OPERATOR_SEPARATOR+op] = v
+			} else {
+				req[TYPELESS_ID_FIELD] = v
+			}
+		}
+	}
+}
+
+func typeFilter(assetType string) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]string{TYPELESS_TYPE_FIELD: assetType}}
+}
+
+func mustNotFilter(filter interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"bool": map[string]interface{}{"must_not": []interface{}{filter}},
+	}
+}
+
+/*
+	Rewrite a filter generated for elasticsearch 1.x as a query supported by later versions.  The
+	`not` and `missing` filters have been replaced by bool queries.  All others are used as is.
+*/
+func typelessFilter(filter interface{}) interface{} {
+	m, ok := filter.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return filter
+	}
+	if inner, ok := m["not"]; ok {
+		return mustNotFilter(typelessFilter(inner))
+	}
+	if inner, ok := m["missing"]; ok {
+		return mustNotFilter(map[string]interface{}{"exists": inner})
+	}
+	return filter
+}
+
+// Bool query and'ing the filters.  They do not contribute to scoring as with the `filtered` query.
+func typelessBoolQuery(filters []interface{}) map[string]interface{} {
+	clauses := make([]interface{}, len(filters))
+	for i, f := range filters {
+		clauses[i] = typelessFilter(f)
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{"filter": clauses},
+	}
+}
+
+/*
+	Build a search for the typeless datastore from a vindalu query and options.  Assets are
+	restricted to the type if one is given.  Additional filters can be supplied which are and'ed
+	with the ones generated from the query.
+*/
+func buildTypelessQuery(assetType string, req map[string]interface{}, queryOpts *types.QueryOptions, extraFilters ...interface{}) (query map[string]interface{}, err error) {
+	translateTypelessIdField(req)
+
+	var selectorFilters []interface{}
+	if selectorFilters, err = translateLabelSelector(req); err != nil {
+		return
+	}
+
+	filters := []interface{}{}
+	if len(assetType) > 0 {
+		filters = append(filters, typeFilter(assetType))
+	}
+	filters = append(append(filters, extraFilters...), selectorFilters...)
+
+	if filters, err = buildQueryFilters(req, filters...); err != nil {
+		return
+	}
+
+	query = map[string]interface{}{}
+	if len(filters) > 0 {
+		query["query"] = typelessBoolQuery(filters)
+	}
+
+	if queryOpts != nil {
+		for k, v := range buildElasticsearchQueryOptions(*queryOpts) {
+			query[k] = v
+		}
+		limitTermsSize(query["aggs"])
+	}
+	return
+}
+
+/*
+	Set the size of terms aggregations, including nested ones, that are unbounded i.e. 0 or above
+	TYPELESS_MAX_TERMS.  Elasticsearch 1.x returned all buckets with a size of 0.
+*/
+func limitTermsSize(aggs interface{}) {
+	m, ok := aggs.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for k, v := range m {
+		terms, ok := v.(map[string]interface{})
+		if !ok || k != "terms" {
+			limitTermsSize(v)
+			continue
+		}
+
+		var size int64
+		switch n := terms["size"].(type) {
+		case int:
+			size = int64(n)
+		case int64:
+			size = n
+		}
+		if size <= 0 || size > TYPELESS_MAX_TERMS {
+			terms["size"] = TYPELESS_MAX_TERMS
+		}
+	}
+}
+
+// Check if from + size of a search is beyond the max result window.  Such searches fail so the
+// results have to be scrolled through instead.
+func exceedsResultWindow(query map[string]interface{}, maxResults int64) bool {
+	size, _ := query["size"].(int64)
+	from, _ := query["from"].(int64)
+	return from+size > maxResults
+}
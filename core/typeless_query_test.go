@@ -0,0 +1,71 @@
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vindalu/vindalu/types"
+)
+
+func Test_splitTypelessDocId(t *testing.T) {
+	if assetType, assetId := splitTypelessDocId(typelessDocId("server", "web:01")); assetType != "server" || assetId != "web:01" {
+		t.Fatalf("Wrong split: %s %s", assetType, assetId)
+	}
+	if typelessVersionDocId("server", "web01", 3) != "server:web01.3" {
+		t.Fatalf("Wrong version id")
+	}
+}
+
+func Test_typelessFilter(t *testing.T) {
+	f := typelessFilter(map[string]interface{}{"not": map[string]interface{}{
+		"missing": map[string]interface{}{"field": "os"},
+	}})
+	b, _ := json.Marshal(f)
+	if string(b) != `{"bool":{"must_not":[{"bool":{"must_not":[{"exists":{"field":"os"}}]}}]}}` {
+		t.Fatalf("Wrong filter: %s", b)
+	}
+
+	term := map[string]interface{}{"term": map[string]interface{}{"os": "ubuntu"}}
+	if b, _ = json.Marshal(typelessFilter(term)); string(b) != `{"term":{"os":"ubuntu"}}` {
+		t.Fatalf("Filter should be unchanged: %s", b)
+	}
+}
+
+func Test_buildTypelessQuery(t *testing.T) {
+	opts := types.QueryOptions{From: 9990, Size: 100}
+	query, err := buildTypelessQuery("server", map[string]interface{}{"id": "web01", "os": "ubuntu"}, &opts)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b, _ := json.Marshal(query)
+	s := string(b)
+	if strings.Contains(s, "filtered") {
+		t.Fatalf("Should not use filtered query: %s", s)
+	}
+	if !strings.Contains(s, `{"term":{"vindalu_type":"server"}}`) {
+		t.Fatalf("Type filter missing: %s", s)
+	}
+	if !strings.Contains(s, `"vindalu_id"`) || strings.Contains(s, `"id"`) {
+		t.Fatalf("Id not translated: %s", s)
+	}
+	if query["size"] != int64(100) || !exceedsResultWindow(query, 10000) {
+		t.Fatalf("Size should be kept: %v", query["size"])
+	}
+	if exceedsResultWindow(query, 10090) {
+		t.Fatalf("Should be within result window")
+	}
+
+	opts = types.QueryOptions{Aggregate: "address", Subnet: 24}
+	if query, err = buildTypelessQuery("server", map[string]interface{}{}, &opts); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if b, _ = json.Marshal(query["aggs"]); !strings.Contains(string(b), `"size":10000`) {
+		t.Fatalf("Terms size should be bounded: %s", b)
+	}
+
+	if query, err = buildTypelessQuery("", map[string]interface{}{}, nil); err != nil || len(query) != 0 {
+		t.Fatalf("Should be empty: %v %v", query, err)
+	}
+}
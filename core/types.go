@@ -1,7 +1,28 @@
 package core
 
+import (
+	"encoding/json"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/types"
+)
+
 const (
 	MAX_ASSET_TYPES = 100000
+	// Max number of saved queries returned when listing
+	MAX_SAVED_QUERIES = 10000
+	// Max number of asset ids listed per unique constraint violation
+	MAX_UNIQUE_VIOLATION_IDS = 100
+	// Max number of reservations per unique key of an asset i.e. combinations of array values
+	MAX_UNIQUE_KEY_RESERVATIONS = 100
+
+	// Document types in the meta index
+	META_TYPE_SAVED_QUERY = "query"
+	META_TYPE_SCHEMA      = "schema"
+	META_TYPE_SEQUENCE    = "sequence"
+	META_TYPE_UNIQUE_KEY  = "unique"
+	// Only used by the typeless datastore as types are not part of the mappings
+	META_TYPE_ASSET_TYPE = "type"
 )
 
 var (
@@ -11,10 +32,10 @@ var (
 	}
 	// Managed fields in data field
 	INTERNAL_FIELDS = []string{
-		"created_by", "updated_by", "created_on",
+		"created_by", "updated_by", "created_on", RENAMED_FROM_FIELD,
 	}
 	// Search parameter options
-	SEARCH_PARAM_OPTIONS = []string{"sort", "from", "size", "aggregate"}
+	SEARCH_PARAM_OPTIONS = []string{"sort", "from", "size", "aggregate", "subnet", "saved_query", "format", "fields", "pretty"}
 )
 
 // Aggregated count of a particular field value across the dataset
@@ -26,6 +47,127 @@ type AggregatedItem struct {
 // Data specific to a resource type.  name and count are defaults.
 type ResourceType struct {
 	AggregatedItem
+	Metadata *AssetTypeMetadata `json:"metadata,omitempty"`
+}
+
+// Descriptive information about an asset type.  This is stored in the `_meta` section of the type
+// mapping.
+type AssetTypeMetadata struct {
+	Description string `json:"description,omitempty"`
+	OwnerTeam   string `json:"owner_team,omitempty"`
+	Contact     string `json:"contact,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	// Documentation for each property keyed by property name
+	Fields map[string]string `json:"fields,omitempty"`
+	// Required and enforced fields for the type, applied after the configured ones
+	config.TypeAssetConfig
+}
+
+// Type metadata from the `_meta` section of a mapping.  nil if there is none.
+func metadataFromMapping(meta map[string]interface{}) *AssetTypeMetadata {
+	if len(meta) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return nil
+	}
+	var md AssetTypeMetadata
+	if err = json.Unmarshal(b, &md); err != nil {
+		return nil
+	}
+	return &md
+}
+
+// Type metadata for the `_meta` section of a mapping.
+func (md *AssetTypeMetadata) mappingMeta() map[string]interface{} {
+	m := map[string]interface{}{}
+	if md == nil {
+		return m
+	}
+
+	b, _ := json.Marshal(md)
+	json.Unmarshal(b, &m)
+	return m
+}
+
+// Detailed information about a single property of a resource type
+type PropertyDetail struct {
+	Name string `json:"name"`
+	// Mapped datastore type e.g. string, long, ip, object
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Enforced bool   `json:"enforced"`
+	// Allowed values when the property is enforced
+	EnforcedValues []string `json:"enforced_values,omitempty"`
+	// Complete rule when the property is enforced by more than a list of values
+	EnforcedRule *config.FieldRule `json:"enforced_rule,omitempty"`
+	// Percentage of assets that contain the property
+	FillRate float64 `json:"fill_rate"`
+	// Approximate number of distinct values
+	Cardinality int64 `json:"cardinality"`
+	// Documentation from the type metadata
+	Description string `json:"description,omitempty"`
+}
+
+// Values of a unique key shared by more than one asset
+type UniqueViolation struct {
+	Fields []string      `json:"fields"`
+	Values []interface{} `json:"values"`
+	Count  int64         `json:"count"`
+	// Assets sharing the values.  Limited to MAX_UNIQUE_VIOLATION_IDS
+	Ids []string `json:"ids"`
+}
+
+// Reservation of the values of a unique key by the asset holding them
+type UniqueKeyReservation struct {
+	AssetType string `json:"asset_type"`
+	AssetId   string `json:"asset_id"`
+}
+
+func (r UniqueKeyReservation) Holder() AssetRef {
+	return AssetRef{Type: r.AssetType, Id: r.AssetId}
+}
+
+// Managed field set to the previous <type>/<id> of a renamed asset
+const RENAMED_FROM_FIELD = "renamed_from"
+
+// Payload of the event published when an asset is renamed or moved to another type
+type AssetRename struct {
+	From  AssetRef  `json:"from"`
+	To    AssetRef  `json:"to"`
+	Asset BaseAsset `json:"asset"`
+}
+
+// Outcome of importing a set of assets
+type ImportResult struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Failed  int           `json:"failed"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// Asset that failed to import
+type ImportError struct {
+	// Position of the asset in the import starting at 1
+	Row   int    `json:"row"`
+	Type  string `json:"type"`
+	Id    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// Named filter and query options that can be executed on demand.
+type SavedQuery struct {
+	Name string `json:"name"`
+	// Asset type the query applies to.  Empty for all types.
+	AssetType string                 `json:"asset_type"`
+	Query     map[string]interface{} `json:"query"`
+	Options   types.QueryOptions     `json:"options"`
+	// User that created the query.  Only the owner or an admin can modify it.
+	Owner     string `json:"owner"`
+	CreatedOn int64  `json:"created_on"`
+	UpdatedOn int64  `json:"updated_on"`
 }
 
 type BaseAsset struct {
@@ -56,3 +198,11 @@ func (ba *BaseAsset) GetVersion() int64 {
 	}
 	return int64(-1)
 }
+
+// JSON schema assets of a type are validated against
+type AssetTypeSchema struct {
+	AssetType string                 `json:"asset_type"`
+	Schema    map[string]interface{} `json:"schema"`
+	UpdatedBy string                 `json:"updated_by"`
+	UpdatedOn int64                  `json:"updated_on"`
+}
@@ -36,3 +36,42 @@ func Test_BaseAsset_GetVersion(t *testing.T) {
 		t.Fatalf("Version should be -1(bad version)")
 	}
 }
+
+func Test_AssetTypeMetadata_mappingMeta(t *testing.T) {
+	md := &AssetTypeMetadata{
+		Description: "Physical servers",
+		OwnerTeam:   "syseng",
+		Fields:      map[string]string{"host": "Fully qualified hostname"},
+	}
+	md.RequiredFields = []string{"rack"}
+
+	m := md.mappingMeta()
+	if m["owner_team"] != "syseng" {
+		t.Fatalf("Wrong meta: %#v", m)
+	}
+	if _, ok := m["contact"]; ok {
+		t.Fatalf("Empty fields should be omitted: %#v", m)
+	}
+	if _, ok := m["required_fields"]; !ok {
+		t.Fatalf("Type rules should be inline: %#v", m)
+	}
+	if _, ok := m["override"]; ok {
+		t.Fatalf("Empty fields should be omitted: %#v", m)
+	}
+
+	rt := metadataFromMapping(m)
+	if rt == nil || rt.Description != md.Description || rt.Fields["host"] != md.Fields["host"] {
+		t.Fatalf("Round trip failed: %#v", rt)
+	}
+	if len(rt.RequiredFields) != 1 || rt.RequiredFields[0] != "rack" {
+		t.Fatalf("Round trip failed: %#v", rt)
+	}
+
+	if metadataFromMapping(map[string]interface{}{}) != nil {
+		t.Fatalf("Should be nil")
+	}
+	var empty *AssetTypeMetadata
+	if len(empty.mappingMeta()) != 0 {
+		t.Fatalf("Should be empty")
+	}
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	elastigo "github.com/mattbaird/elastigo/lib"
+
+	"github.com/vindalu/vindalu/config"
+)
+
+/*
+	Unique keys are enforced by reserving their values in the meta index before an asset is
+	written.  A reservation is created only if it does not exist, so of two assets written at the
+	same time with the same values only one gets it.  Reservations are released when the asset is
+	removed or its values change.  Duplicates are still searched for as assets written by older
+	versions have no reservations.
+*/
+
+/*
+	Reservation ids of a unique key of an asset.  Array values are reserved per element so
+	assets sharing any element conflict, as with the duplicates query.  nil if the key is not
+	checked for the data.
+*/
+func uniqueKeyReservationIds(assetType string, key config.UniqueKey, data map[string]interface{}) ([]string, error) {
+	values := uniqueKeyValues(key, data)
+	if values == nil {
+		return nil, nil
+	}
+
+	combos := []map[string]interface{}{{}}
+	for _, f := range key {
+		elems, ok := values[f].([]interface{})
+		if !ok {
+			elems = []interface{}{values[f]}
+		}
+
+		next := make([]map[string]interface{}, 0, len(combos)*len(elems))
+		for _, c := range combos {
+			for _, v := range elems {
+				nc := make(map[string]interface{}, len(c)+1)
+				for k, cv := range c {
+					nc[k] = cv
+				}
+				nc[f] = v
+				next = append(next, nc)
+			}
+		}
+		if len(next) > MAX_UNIQUE_KEY_RESERVATIONS {
+			return nil, fmt.Errorf("Unique constraint (%s) has too many values: %d > %d",
+				key, len(next), MAX_UNIQUE_KEY_RESERVATIONS)
+		}
+		combos = next
+	}
+
+	ids := make([]string, len(combos))
+	for i, c := range combos {
+		// Keys are sorted when marshalled
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha1.Sum(append([]byte(assetType+"\n"), b...))
+		ids[i] = hex.EncodeToString(sum[:])
+	}
+	return ids, nil
+}
+
+// Reservation ids of all unique keys of an asset mapped to their key
+func uniqueReservations(assetCfg *config.AssetConfig, assetType string, data map[string]interface{}) (map[string]config.UniqueKey, error) {
+	reservations := map[string]config.UniqueKey{}
+	for _, key := range assetCfg.UniqueFields {
+		ids, err := uniqueKeyReservationIds(assetType, key, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			reservations[id] = key
+		}
+	}
+	return reservations, nil
+}
+
+/*
+	Reserve the unique keys of an asset.  Reservations held by `replaces`, or by assets that no
+	longer have the values, are taken over.  Returns the newly made reservations.  None are kept
+	on error.
+*/
+func (ds *InventoryDatastore) reserveUniqueKeys(assetCfg *config.AssetConfig, ref AssetRef, data map[string]interface{},
+	replaces *AssetRef) (reserved []string, err error) {
+
+	reservations, err := uniqueReservations(assetCfg, ref.Type, data)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(reservations))
+	for id := range reservations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	defer func() {
+		if err != nil {
+			ds.releaseUniqueKeys(reserved, ref)
+			reserved = nil
+		}
+	}()
+
+	for _, id := range ids {
+		for retried := false; ; retried = true {
+			var holder *AssetRef
+			if holder, err = ds.ReserveUniqueKey(id, ref); err != nil {
+				return
+			}
+			if holder == nil {
+				reserved = append(reserved, id)
+				break
+			}
+			if *holder == ref {
+				break
+			}
+
+			takeOver := replaces != nil && *holder == *replaces
+			if !takeOver && !retried {
+				if takeOver, err = ds.isStaleReservation(*holder, id, reservations[id]); err != nil {
+					return
+				}
+			}
+			if !takeOver || retried {
+				err = &ConflictError{Reason: fmt.Sprintf("Unique constraint (%s) violated. Values already used by: %s",
+					reservations[id], holder)}
+				return
+			}
+			if err = ds.ReleaseUniqueKey(id, *holder); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Whether the asset holding a reservation no longer has the reserved values or does not exist
+func (ds *InventoryDatastore) isStaleReservation(holder AssetRef, id string, key config.UniqueKey) (bool, error) {
+	asset, err := ds.Get(holder.Type, holder.Id, 0)
+	if err == elastigo.RecordNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	ids, err := uniqueKeyReservationIds(holder.Type, key, asset.Data)
+	if err != nil {
+		return true, nil
+	}
+	for _, held := range ids {
+		if held == id {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Release reservations held by an asset.  Failures are only logged as stale reservations are
+// taken over.
+func (ds *InventoryDatastore) releaseUniqueKeys(ids []string, holder AssetRef) {
+	for _, id := range ids {
+		if err := ds.ReleaseUniqueKey(id, holder); err != nil {
+			ds.log.Errorf("Failed to release unique key (%s %s): %s\n", holder, id, err)
+		}
+	}
+}
+
+// Reservation ids of the unique keys of an asset that are not reserved by `data`
+func (ds *InventoryDatastore) unreservedUniqueKeys(ref AssetRef, held, data map[string]interface{}) []string {
+	assetCfg, err := ds.AssetConfigForType(ref.Type)
+	if err != nil {
+		ds.log.Errorf("Failed to get unique keys (%s): %s\n", ref, err)
+		return nil
+	}
+	// Values beyond the limit were never reserved
+	heldIds, _ := uniqueReservations(assetCfg, ref.Type, held)
+	keep, _ := uniqueReservations(assetCfg, ref.Type, data)
+
+	ids := []string{}
+	for id := range heldIds {
+		if _, ok := keep[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
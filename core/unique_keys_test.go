@@ -0,0 +1,47 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/vindalu/vindalu/config"
+)
+
+func Test_uniqueKeyReservationIds(t *testing.T) {
+	key := config.UniqueKey{"rack", "slot"}
+
+	ids, err := uniqueKeyReservationIds("server", key, map[string]interface{}{"rack": "r01", "slot": float64(4)})
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("Wrong ids: %v %v", ids, err)
+	}
+	// Numbers are reserved the same whether parsed or not
+	same, _ := uniqueKeyReservationIds("server", key, map[string]interface{}{"slot": 4, "rack": "r01"})
+	if len(same) != 1 || same[0] != ids[0] {
+		t.Fatalf("Ids should match: %v %v", ids, same)
+	}
+	other, _ := uniqueKeyReservationIds("switch", key, map[string]interface{}{"rack": "r01", "slot": float64(4)})
+	if other[0] == ids[0] {
+		t.Fatalf("Ids should be per type")
+	}
+
+	// One per combination of array elements
+	arr, err := uniqueKeyReservationIds("server", key, map[string]interface{}{
+		"rack": []interface{}{"r01", "r02"}, "slot": []interface{}{float64(4), float64(5)}})
+	if err != nil || len(arr) != 4 {
+		t.Fatalf("Wrong ids: %v %v", arr, err)
+	}
+	if !(arr[0] == ids[0] || arr[1] == ids[0] || arr[2] == ids[0] || arr[3] == ids[0]) {
+		t.Fatalf("Should share an id: %v %v", arr, ids)
+	}
+
+	if ids, _ = uniqueKeyReservationIds("server", key, map[string]interface{}{"rack": "r01"}); ids != nil {
+		t.Fatalf("Should not be reserved: %v", ids)
+	}
+
+	many := make([]interface{}, MAX_UNIQUE_KEY_RESERVATIONS+1)
+	for i := range many {
+		many[i] = i
+	}
+	if _, err = uniqueKeyReservationIds("server", config.UniqueKey{"ip"}, map[string]interface{}{"ip": many}); err == nil {
+		t.Fatalf("Should fail with too many values")
+	}
+}
@@ -5,18 +5,22 @@ import (
 	"fmt"
 	//"io/ioutil"
 	//"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
 	elastigo "github.com/mattbaird/elastigo/lib"
 
 	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/schema"
 	"github.com/vindalu/vindalu/types"
 )
 
 var (
 	// Special chars to trigger a regex search
 	RE_TRIGGER_CHARS = []string{"*", "+", "^", "$", "|"}
+	// Characters that need to be escaped to be matched literally in a regex
+	RE_RESERVED_CHARS = `.?+*|{}[]()"\#@&<>~`
 )
 
 /* Generate an ESS regex filter */
@@ -66,25 +70,160 @@ func ValidateRequiredFields(cfg *config.AssetConfig, req map[string]interface{})
 	return nil
 }
 
-func validateEnforcedFields(cfg *config.AssetConfig, req map[string]interface{}) error {
+/*
+	Validate field values against the enforced field rules.  All violations are returned as a
+	single ValidationError.
+*/
+func validateEnforcedFields(cfg *config.AssetConfig, assetType, assetId string, req map[string]interface{}) error {
+	// Sorted for consistent output
+	fields := make([]string, 0, len(cfg.EnforcedFields))
+	for k := range cfg.EnforcedFields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
 
-	for k, enforcedVals := range cfg.EnforcedFields {
-		if _, ok := req[k]; ok {
-			found := false
-			for _, ef := range enforcedVals {
-				if req[k] == ef {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return fmt.Errorf("'%s' field must be: %v\n", k, enforcedVals)
-			}
+	violations := []schema.Violation{}
+	for _, k := range fields {
+		if val, ok := req[k]; ok {
+			rule := cfg.EnforcedFields[k]
+			violations = append(violations, checkFieldRule(&rule, val, "/"+k)...)
 		}
 	}
+
+	if len(violations) > 0 {
+		return &ValidationError{AssetType: assetType, AssetId: assetId, Violations: violations}
+	}
 	return nil
 }
 
+// Check a value against an enforced field rule.  Each element of an array value is checked
+// against the rule.
+func checkFieldRule(rule *config.FieldRule, val interface{}, path string) (violations []schema.Violation) {
+	arr, isArray := val.([]interface{})
+	if rule.Type == "array" && !isArray {
+		return []schema.Violation{{Path: path, Message: fmt.Sprintf("expected array, got %s", valueType(val))}}
+	}
+
+	if isArray {
+		for i, v := range arr {
+			violations = append(violations, checkFieldRuleValue(rule, v, fmt.Sprintf("%s/%d", path, i))...)
+		}
+		return
+	}
+	return checkFieldRuleValue(rule, val, path)
+}
+
+func checkFieldRuleValue(rule *config.FieldRule, val interface{}, path string) (violations []schema.Violation) {
+	addViolation := func(format string, args ...interface{}) {
+		violations = append(violations, schema.Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	actual := valueType(val)
+	if len(rule.Type) > 0 && rule.Type != "array" && rule.Type != actual &&
+		!(rule.Type == "number" && actual == "integer") {
+		addViolation("expected %s, got %s", rule.Type, actual)
+		// Remaining checks would only repeat the type mismatch
+		return
+	}
+
+	if len(rule.Values) > 0 {
+		str, ok := scalarString(val)
+		found := false
+		for _, v := range rule.Values {
+			if ok && v == str {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addViolation("value must be one of %v", rule.Values)
+		}
+	}
+
+	if re := rule.Regexp(); re != nil {
+		if str, ok := val.(string); !ok {
+			addViolation("expected string to match pattern '%s', got %s", rule.Pattern, actual)
+		} else if !re.MatchString(str) {
+			addViolation("must match pattern '%s'", rule.Pattern)
+		}
+	}
+
+	if rule.Min != nil || rule.Max != nil {
+		num, ok := val.(float64)
+		if !ok {
+			addViolation("expected number, got %s", actual)
+		} else if rule.Min != nil && num < *rule.Min {
+			addViolation("must be greater than or equal to %v", *rule.Min)
+		} else if rule.Max != nil && num > *rule.Max {
+			addViolation("must be less than or equal to %v", *rule.Max)
+		}
+	}
+	return
+}
+
+// JSON type name of a decoded value.  Integral numbers are reported as integer.
+func valueType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if f := val.(float64); f == float64(int64(f)) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+// String form of a scalar value used to compare against enforced values
+func scalarString(val interface{}) (string, bool) {
+	switch val.(type) {
+	case string:
+		return val.(string), true
+	case bool:
+		return strconv.FormatBool(val.(bool)), true
+	case float64:
+		return strconv.FormatFloat(val.(float64), 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// Values of the unique key fields in the data.  nil if any of the fields is missing, null or an object.
+func uniqueKeyValues(key config.UniqueKey, data map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{}, len(key))
+	for _, f := range key {
+		v, ok := data[f]
+		if !ok || v == nil {
+			return nil
+		}
+		if _, isObj := v.(map[string]interface{}); isObj {
+			return nil
+		}
+		values[f] = v
+	}
+	return values
+}
+
+// Set the required and enforced flags on the property based on the asset config
+func applyPropertyConstraints(cfg *config.AssetConfig, prop *PropertyDetail) {
+	prop.Required = cfg.IsRequiredField(prop.Name)
+	if rule, ok := cfg.EnforcedFields[prop.Name]; ok {
+		prop.Enforced = true
+		prop.EnforcedValues = rule.Values
+		if !rule.IsValuesOnly() {
+			prop.EnforcedRule = &rule
+		}
+	}
+}
+
 // Convert a given number to an int64
 func parseVersion(ver interface{}) (verInt int64, err error) {
 	switch ver.(type) {
@@ -115,7 +254,12 @@ func buildElasticsearchQueryOptions(qo types.QueryOptions) map[string]interface{
 	m := qo.Map()
 	if len(qo.Aggregate) > 0 {
 		delete(m, "aggregate")
-		m["aggs"] = buildElasticsearchAggregateQuery(qo.Aggregate, qo.Size)
+		if qo.Subnet > 0 {
+			// All addresses are needed to group by subnet. Size is applied after grouping.
+			m["aggs"] = buildElasticsearchAggregateQuery(qo.Aggregate, 0)
+		} else {
+			m["aggs"] = buildElasticsearchAggregateQuery(qo.Aggregate, qo.Size)
+		}
 		// size is set in aggregate query so remove from top level
 		m["size"] = 0
 		delete(m, "from")
@@ -135,12 +279,49 @@ func buildElasticsearchAggregateQuery(field string, resultSize interface{}) map[
 	}
 }
 
-// Build elasticsearch query from vindalu query
-func buildElasticsearchBaseQuery(index string, req map[string]interface{}) (query map[string]interface{}, err error) {
+// Build elasticsearch query from vindalu query.  Additional elasticsearch filters can be supplied
+// which are and'ed with the ones generated from the query.
+func buildElasticsearchBaseQuery(index string, req map[string]interface{}, extraFilters ...interface{}) (query map[string]interface{}, err error) {
+	var filterOps []interface{}
+	if filterOps, err = buildQueryFilters(req, extraFilters...); err != nil {
+		return
+	}
+
+	if len(filterOps) > 0 {
+		query = map[string]interface{}{
+			"query": map[string]interface{}{
+				"filtered": elastigo.Search(index).Filter(filterOps...),
+			},
+		}
+	} else {
+		// Empty query i.e. return everything
+		query = map[string]interface{}{}
+	}
+
+	return
+}
+
+// Filters generated from each field of a vindalu query followed by the extra filters.
+func buildQueryFilters(req map[string]interface{}, extraFilters ...interface{}) (filterOps []interface{}, err error) {
 
-	filterOps := []interface{}{}
+	filterOps = append([]interface{}{}, extraFilters...)
 
 	for k, v := range req {
+		// Explicit match operator i.e. <field>:<operator>
+		if field, op := splitFieldOperator(k); len(op) > 0 {
+			val, ok := v.(string)
+			if !ok {
+				err = fmt.Errorf("Match operator '%s' requires a string value: %s", op, field)
+				return
+			}
+			var opFilter map[string]interface{}
+			if opFilter, err = matchOperatorFilter(field, op, val); err != nil {
+				return
+			}
+			filterOps = append(filterOps, opFilter)
+			continue
+		}
+
 		switch v.(type) {
 		case string:
 			val, _ := v.(string)
@@ -165,9 +346,9 @@ func buildElasticsearchBaseQuery(index string, req map[string]interface{}) (quer
 				}
 				// Add range filterop
 				if strings.HasPrefix(val, ">") {
-					filterOps = append(filterOps, elastigo.Range().Field(k).Gt(nVal))
+					filterOps = append(filterOps, rangeFilter(k, "gt", nVal))
 				} else {
-					filterOps = append(filterOps, elastigo.Range().Field(k).Lt(nVal))
+					filterOps = append(filterOps, rangeFilter(k, "lt", nVal))
 				}
 
 			} else {
@@ -195,31 +376,116 @@ func buildElasticsearchBaseQuery(index string, req map[string]interface{}) (quer
 			return
 		}
 	}
+	return
+}
 
-	if len(filterOps) > 0 {
-		query = map[string]interface{}{
-			"query": map[string]interface{}{
-				"filtered": elastigo.Search(index).Filter(filterOps...),
-			},
+/* Generate an ESS range filter comparing the field with the operator e.g. gt */
+func rangeFilter(attr, op string, val interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			attr: map[string]interface{}{op: val},
+		},
+	}
+}
+
+// Check a vindalu query, including the label selector, can be translated to datastore filters.
+func validateQuery(query map[string]interface{}) error {
+	req := copyQuery(query)
+	selectorFilters, err := translateLabelSelector(req)
+	if err != nil {
+		return err
+	}
+	_, err = buildQueryFilters(req, selectorFilters...)
+	return err
+}
+
+// Shallow copy of a user query as building the datastore query modifies it.
+func copyQuery(query map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(query))
+	for k, v := range query {
+		c[k] = v
+	}
+	return c
+}
+
+// Translate the vindalu `id` field to the elasticsearch `_id` field.  This includes the field with
+// a match operator i.e. id:<operator>
+func translateIdField(req map[string]interface{}) {
+	for k, v := range req {
+		if field, op := splitFieldOperator(k); field == "id" {
+			delete(req, k)
+			if len(op) > 0 {
+				req["_id"+MATCH_OPERATOR_SEPARATOR+op] = v
+			} else {
+				req["_id"] = v
+			}
+		}
+	}
+}
+
+// Translate the vindalu `id` field to a regex on the elasticsearch `_id` field matching all versions
+// of the asset(s) i.e. <id>.<version>.  This includes the field with a match operator i.e. id:<operator>
+func translateVersionIdField(req map[string]interface{}) {
+	for k, v := range req {
+		field, op := splitFieldOperator(k)
+		if field != "id" {
+			continue
+		}
+		id, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		delete(req, k)
+		if len(op) > 0 {
+			if re, ok := matchOperatorRegex(op, id); ok {
+				req["_id"] = "(" + re + `)\.[0-9]+`
+			} else {
+				req["_id"+MATCH_OPERATOR_SEPARATOR+op] = v
+			}
+		} else if id = strings.TrimSpace(id); isRegexSearch(id) {
+			req["_id"] = "(" + id + `)\.[0-9]+`
+		} else {
+			req["_id"] = escapeRegex(id) + `\.[0-9]+`
 		}
-	} else {
-		// Empty query i.e. return everything
-		query = map[string]interface{}{}
 	}
+}
 
-	return
+// Strip the version suffix from a version document id
+func assetIdFromVersionId(versionId string) string {
+	i := strings.LastIndex(versionId, ".")
+	if i < 1 {
+		return versionId
+	}
+	if _, err := strconv.ParseInt(versionId[i+1:], 10, 64); err != nil {
+		return versionId
+	}
+	return versionId[:i]
+}
+
+// Escape characters reserved by the elasticsearch (lucene) regex syntax
+func escapeRegex(str string) string {
+	var out []byte
+	for i := 0; i < len(str); i++ {
+		if strings.IndexByte(RE_RESERVED_CHARS, str[i]) >= 0 {
+			out = append(out, '\\')
+		}
+		out = append(out, str[i])
+	}
+	return string(out)
 }
 
 // Build elasticsearch query from user query and options. It wraps 2 other helper functions.
 //func buildElasticsearchQuery(index string, resultSize int64, paramReq map[string]interface{}, opts map[string][]string) (query map[string]interface{}, err error) {
 func buildElasticsearchQuery(index string, paramReq map[string]interface{}, queryOpts *types.QueryOptions) (query map[string]interface{}, err error) {
-	if _, ok := paramReq["id"]; ok {
-		// Elasticsearch translation
-		paramReq["_id"] = paramReq["id"]
-		delete(paramReq, "id")
+	translateIdField(paramReq)
+
+	var selectorFilters []interface{}
+	if selectorFilters, err = translateLabelSelector(paramReq); err != nil {
+		return
 	}
 
-	if query, err = buildElasticsearchBaseQuery(index, paramReq); err != nil {
+	if query, err = buildElasticsearchBaseQuery(index, paramReq, selectorFilters...); err != nil {
 		return
 	}
 
@@ -249,6 +515,42 @@ func assembleAssetFromHit(hit elastigo.Hit) (asset BaseAsset, err error) {
 	return
 }
 
+// Check user supplied property definitions are of the form {"<name>": {"type": "..."}, ...}
+func validateTypeProperties(props interface{}) error {
+	if props == nil {
+		return nil
+	}
+	pm, ok := props.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("properties must be an object")
+	}
+	for name, def := range pm {
+		if _, ok := def.(map[string]interface{}); !ok {
+			return fmt.Errorf("Invalid property definition: %s", name)
+		}
+	}
+	return nil
+}
+
+// Merge updated data on to the current data the way a partial update does i.e. objects are merged
+// recursively and all other values are replaced.  Neither input is modified.
+func mergeAssetData(curr, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(curr)+len(update))
+	for k, v := range curr {
+		merged[k] = v
+	}
+	for k, v := range update {
+		currObj, currIsObj := merged[k].(map[string]interface{})
+		updObj, updIsObj := v.(map[string]interface{})
+		if currIsObj && updIsObj {
+			merged[k] = mergeAssetData(currObj, updObj)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
 // Copy current asset data to the new one as removing fields requires a full index.
 // Skip over fields that are already in updated asset.
 func assembleAssetUpdate(curr, update *BaseAsset) {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	//"net/http"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/vindalu/vindalu/config"
@@ -58,9 +59,9 @@ func Test_IsSearchParamOption(t *testing.T) {
 func Test_ValidateRequiredFields(t *testing.T) {
 	var cfg *config.AssetConfig = &config.AssetConfig{
 		RequiredFields: []string{"status", "environment"},
-		EnforcedFields: map[string][]string{
-			"status":      []string{"enabled", "disabled"},
-			"environment": []string{"production", "development", "testing", "lab"},
+		EnforcedFields: map[string]config.FieldRule{
+			"status":      config.FieldRule{Values: []string{"enabled", "disabled"}},
+			"environment": config.FieldRule{Values: []string{"production", "development", "testing", "lab"}},
 		},
 	}
 	req1 := map[string]interface{}{"status": true}
@@ -78,9 +79,9 @@ func Test_ValidateRequiredFields(t *testing.T) {
 func Test_validateEnforcedFields(t *testing.T) {
 	var cfg *config.AssetConfig = &config.AssetConfig{
 		RequiredFields: []string{"status", "environment"},
-		EnforcedFields: map[string][]string{
-			"status":      []string{"enabled", "disabled"},
-			"environment": []string{"production", "development", "testing", "lab"},
+		EnforcedFields: map[string]config.FieldRule{
+			"status":      config.FieldRule{Values: []string{"enabled", "disabled"}},
+			"environment": config.FieldRule{Values: []string{"production", "development", "testing", "lab"}},
 		},
 	}
 	req1 := map[string]interface{}{
@@ -90,16 +91,84 @@ func Test_validateEnforcedFields(t *testing.T) {
 	req2 := map[string]interface{}{
 		"status": nil,
 	}
-	err1 := validateEnforcedFields(cfg, req1)
+	err1 := validateEnforcedFields(cfg, "server", "test", req1)
 	if err1 != nil {
 		t.Fatalf("Field value is enforced, should not return error")
 	}
-	err2 := validateEnforcedFields(cfg, req2)
+	err2 := validateEnforcedFields(cfg, "server", "test", req2)
 	if err2 == nil {
 		t.Fatalf("Field value is not enforced, should return error")
 	}
 }
 
+func Test_validateEnforcedFields_rules(t *testing.T) {
+	min, max := float64(1), float64(65535)
+	cfg := &config.AssetConfig{
+		EnforcedFields: map[string]config.FieldRule{
+			"hostname": config.FieldRule{Pattern: `^[a-z0-9\-]+$`},
+			"port":     config.FieldRule{Type: "integer", Min: &min, Max: &max},
+			"virtual":  config.FieldRule{Type: "boolean"},
+			"roles":    config.FieldRule{Type: "array", Values: []string{"web", "db"}},
+			"tier":     config.FieldRule{Values: []string{"1", "2", "3"}},
+		},
+	}
+
+	valid := map[string]interface{}{
+		"hostname": "web-01",
+		"port":     float64(8080),
+		"virtual":  true,
+		"roles":    []interface{}{"web", "db"},
+		"tier":     float64(2),
+	}
+	if err := validateEnforcedFields(cfg, "server", "web01", valid); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	invalid := map[string]interface{}{
+		"hostname": "Web_01",
+		"port":     float64(70000),
+		"virtual":  "yes",
+		"roles":    []interface{}{"web", "cache"},
+		"tier":     "4",
+	}
+	err := validateEnforcedFields(cfg, "server", "web01", invalid)
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Should be a validation error: %v", err)
+	}
+	if len(verr.Violations) != 5 {
+		t.Fatalf("All violations should be returned: %v", verr.Violations)
+	}
+	// Sorted by field
+	if verr.Violations[0].Path != "/hostname" || verr.Violations[2].Path != "/roles/1" {
+		t.Fatalf("Wrong violations: %v", verr.Violations)
+	}
+	if verr.AssetType != "server" || verr.AssetId != "web01" {
+		t.Fatalf("Wrong asset: %#v", verr)
+	}
+}
+
+func Test_checkFieldRule(t *testing.T) {
+	min := float64(0)
+	rule := &config.FieldRule{Min: &min}
+	if v := checkFieldRule(rule, "10", "/count"); len(v) != 1 || v[0].Message != "expected number, got string" {
+		t.Fatalf("Wrong violations: %v", v)
+	}
+
+	rule = &config.FieldRule{Type: "number"}
+	if v := checkFieldRule(rule, float64(10), "/count"); len(v) != 0 {
+		t.Fatalf("Integers are numbers: %v", v)
+	}
+	if v := checkFieldRule(rule, []interface{}{float64(1), "2"}, "/count"); len(v) != 1 || v[0].Path != "/count/1" {
+		t.Fatalf("Array elements should be checked: %v", v)
+	}
+
+	rule = &config.FieldRule{Type: "array"}
+	if v := checkFieldRule(rule, "web", "/roles"); len(v) != 1 {
+		t.Fatalf("Should require an array: %v", v)
+	}
+}
+
 func Test_buildElasticsearchQueryOptions_case1(t *testing.T) {
 	qOpts1, _ := types.NewQueryOptions(map[string][]string{
 		"sort": []string{"name:asc", "age:desc", "title"},
@@ -235,3 +304,269 @@ func Test_buildElasticsearchBaseQuery_Range(t *testing.T) {
 	b, _ := json.MarshalIndent(query, "", " ")
 	t.Logf("%s\n", b)
 }
+
+func Test_buildElasticsearchBaseQuery_ExtraFilters(t *testing.T) {
+	req := map[string]interface{}{
+		"OS": "oracle",
+	}
+	ids := map[string]interface{}{
+		"ids": map[string]interface{}{"values": []string{"test1"}},
+	}
+
+	query, err := buildElasticsearchBaseQuery("test_index", req, ids)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b, _ := json.Marshal(query)
+	if !strings.Contains(string(b), `"ids":{"values":["test1"]}`) {
+		t.Fatalf("Extra filter missing: %s", b)
+	}
+
+	// Extra filters only
+	if query, err = buildElasticsearchBaseQuery("test_index", map[string]interface{}{}, ids); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if _, ok := query["query"]; !ok {
+		t.Fatalf("Query should not be empty: %#v", query)
+	}
+}
+
+func Test_buildElasticsearchBaseQuery_IPRange(t *testing.T) {
+	req := map[string]interface{}{
+		"PrivateIpAddress:iprange": "10.20.0.0/16",
+		"path":                     "/var/lib/10.20.0.0/16",
+		"version":                  "1.2.3.4..1.2.3.5",
+	}
+
+	query, err := buildElasticsearchBaseQuery("test_index", req)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b, _ := json.Marshal(query)
+	if !strings.Contains(string(b), `"range":{"PrivateIpAddress":{"gte":"10.20.0.0","lte":"10.20.255.255"}}`) {
+		t.Fatalf("Range filter missing: %s", b)
+	}
+	// Only with the operator
+	if !strings.Contains(string(b), `"term":{"version":"1.2.3.4..1.2.3.5"}`) || strings.Count(string(b), `"range"`) != 1 {
+		t.Fatalf("Range filter should only be used with the operator: %s", b)
+	}
+
+	if _, err = buildElasticsearchBaseQuery("test_index", map[string]interface{}{"PrivateIpAddress:iprange": "10.20.0.0"}); err == nil {
+		t.Fatalf("Should fail with invalid range")
+	}
+}
+
+func Test_buildElasticsearchQueryOptions_Subnet(t *testing.T) {
+	qo := types.QueryOptions{Size: 10, Aggregate: "PrivateIpAddress", Subnet: 24}
+	m := buildElasticsearchQueryOptions(qo)
+
+	aggs := m["aggs"].(map[string]interface{})["PrivateIpAddress"].(map[string]interface{})
+	if aggs["terms"].(map[string]interface{})["size"] != 0 {
+		t.Fatalf("Aggregation size should be 0: %#v", aggs)
+	}
+	if _, ok := m["subnet"]; ok {
+		t.Fatalf("Subnet should not be in query: %#v", m)
+	}
+}
+
+func Test_buildElasticsearchBaseQuery_Operator(t *testing.T) {
+	req := map[string]interface{}{
+		"hostname:ieq": "Web01",
+		"os":           "ubuntu",
+	}
+
+	query, err := buildElasticsearchBaseQuery("test_index", req)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b, _ := json.Marshal(query)
+	if !strings.Contains(string(b), `"regexp":{"hostname":"[wW][eE][bB]01"}`) {
+		t.Fatalf("Operator filter missing: %s", b)
+	}
+
+	// Unknown operators are part of the field name
+	if query, err = buildElasticsearchBaseQuery("test_index", map[string]interface{}{"hostname:foo": "web"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if b, _ = json.Marshal(query); !strings.Contains(string(b), `"hostname:foo":"web"`) {
+		t.Fatalf("Field filter missing: %s", b)
+	}
+	if _, err = buildElasticsearchBaseQuery("test_index", map[string]interface{}{"count:prefix": 1.0}); err == nil {
+		t.Fatalf("Should fail with non-string value")
+	}
+}
+
+func Test_validateTypeProperties(t *testing.T) {
+	valid := map[string]interface{}{
+		"host": map[string]interface{}{"type": "string"},
+	}
+	if err := validateTypeProperties(valid); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := validateTypeProperties(nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := validateTypeProperties(map[string]interface{}{"host": "string"}); err == nil {
+		t.Fatalf("Should fail with invalid definition")
+	}
+	if err := validateTypeProperties([]interface{}{}); err == nil {
+		t.Fatalf("Should fail with non-object")
+	}
+}
+
+func Test_mergeAssetData(t *testing.T) {
+	curr := map[string]interface{}{
+		"os":       "ubuntu",
+		"location": map[string]interface{}{"dc": "us1", "rack": "r1"},
+	}
+	update := map[string]interface{}{
+		"status":   "enabled",
+		"location": map[string]interface{}{"rack": "r2"},
+	}
+
+	merged := mergeAssetData(curr, update)
+	loc := merged["location"].(map[string]interface{})
+	if merged["os"] != "ubuntu" || merged["status"] != "enabled" || loc["dc"] != "us1" || loc["rack"] != "r2" {
+		t.Fatalf("Merge failed: %#v", merged)
+	}
+	if curr["location"].(map[string]interface{})["rack"] != "r1" {
+		t.Fatalf("Current data modified: %#v", curr)
+	}
+}
+
+func Test_copyQuery(t *testing.T) {
+	orig := map[string]interface{}{"id": "test1", "os": "ubuntu"}
+	c := copyQuery(orig)
+	translateIdField(c)
+
+	if _, ok := orig["id"]; !ok {
+		t.Fatalf("Original query modified: %#v", orig)
+	}
+	if len(c) != 2 || c["_id"] != "test1" {
+		t.Fatalf("Copy mismatch: %#v", c)
+	}
+}
+
+func Test_translateIdField(t *testing.T) {
+	req := map[string]interface{}{"id": "test1"}
+	translateIdField(req)
+	if _, ok := req["id"]; ok || req["_id"] != "test1" {
+		t.Fatalf("id not translated: %#v", req)
+	}
+
+	req = map[string]interface{}{"id:prefix": "test"}
+	translateIdField(req)
+	if _, ok := req["id:prefix"]; ok || req["_id:prefix"] != "test" {
+		t.Fatalf("id operator not translated: %#v", req)
+	}
+
+	req = map[string]interface{}{"os": "ubuntu"}
+	translateIdField(req)
+	if _, ok := req["_id"]; ok {
+		t.Fatalf("_id should not be set: %#v", req)
+	}
+}
+
+func Test_translateVersionIdField(t *testing.T) {
+	tests := map[string]string{
+		"foo.bar.org": `foo\.bar\.org\.[0-9]+`,
+		"foo.*":       `(foo.*)\.[0-9]+`,
+	}
+	for id, expected := range tests {
+		req := map[string]interface{}{"id": id}
+		translateVersionIdField(req)
+		if _, ok := req["id"]; ok || req["_id"] != expected {
+			t.Fatalf("Mismatch %s: %#v", expected, req)
+		}
+		if !isRegexSearch(req["_id"].(string)) {
+			t.Fatalf("Should be a regex search: %s", req["_id"])
+		}
+	}
+
+	for key, expected := range map[string]string{
+		"id:literal": `(web01\.a\|b)\.[0-9]+`,
+		"id:prefix":  `(web01\.a\|b.*)\.[0-9]+`,
+		"id:ieq":     `([wW][eE][bB]01\.[aA]\|[bB])\.[0-9]+`,
+	} {
+		req := map[string]interface{}{key: "web01.a|b"}
+		translateVersionIdField(req)
+		if _, ok := req[key]; ok || req["_id"] != expected {
+			t.Fatalf("Mismatch %s: %#v", expected, req)
+		}
+	}
+}
+
+func Test_assetIdFromVersionId(t *testing.T) {
+	tests := map[string]string{
+		"foo.bar.org.3": "foo.bar.org",
+		"test1.10":      "test1",
+		"foo.bar.org":   "foo.bar.org",
+		"test1":         "test1",
+	}
+	for vid, expected := range tests {
+		if id := assetIdFromVersionId(vid); id != expected {
+			t.Fatalf("%s: %s != %s", vid, id, expected)
+		}
+	}
+}
+
+func Test_escapeRegex(t *testing.T) {
+	if escapeRegex(`a.b(c)`) != `a\.b\(c\)` {
+		t.Fatalf("Escape failed: %s", escapeRegex(`a.b(c)`))
+	}
+}
+
+func Test_applyPropertyConstraints(t *testing.T) {
+	cfg := &config.AssetConfig{
+		RequiredFields: []string{"status"},
+		EnforcedFields: map[string]config.FieldRule{
+			"status": config.FieldRule{Values: []string{"enabled", "disabled"}},
+		},
+	}
+
+	prop := PropertyDetail{Name: "status"}
+	applyPropertyConstraints(cfg, &prop)
+	if !prop.Required || !prop.Enforced || len(prop.EnforcedValues) != 2 {
+		t.Fatalf("Constraints not applied: %#v", prop)
+	}
+	if prop.EnforcedRule != nil {
+		t.Fatalf("Value only rules should not be included: %#v", prop)
+	}
+
+	prop = PropertyDetail{Name: "host"}
+	applyPropertyConstraints(cfg, &prop)
+	if prop.Required || prop.Enforced {
+		t.Fatalf("Should not be constrained: %#v", prop)
+	}
+}
+
+func Test_fillRate(t *testing.T) {
+	if fillRate(1, 3) != 33.33 {
+		t.Fatalf("Wrong fill rate: %f", fillRate(1, 3))
+	}
+	if fillRate(5, 0) != 0 {
+		t.Fatalf("Wrong fill rate: %f", fillRate(5, 0))
+	}
+}
+
+func Test_uniqueKeyValues(t *testing.T) {
+	data := map[string]interface{}{
+		"rack":  "r01",
+		"slot":  float64(4),
+		"owner": nil,
+		"attrs": map[string]interface{}{"a": 1},
+	}
+
+	vals := uniqueKeyValues(config.UniqueKey{"rack", "slot"}, data)
+	if len(vals) != 2 || vals["rack"] != "r01" {
+		t.Fatalf("Wrong values: %v", vals)
+	}
+	for _, key := range []config.UniqueKey{{"rack", "serial"}, {"owner"}, {"attrs"}} {
+		if uniqueKeyValues(key, data) != nil {
+			t.Fatalf("Should not be checked: %v", key)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package core
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/nats-io/gnatsd/server"
 
@@ -17,8 +20,16 @@ type VindaluCore struct {
 	// Global config
 	cfg *config.InventoryConfig
 
+	// Snapshots of the datastore.  nil if not configured.
+	snapshots *SnapshotManager
+
+	// Background reindexing of the primary and version indices
+	reindexer *Reindexer
+
 	// Channel used to publish events to the main event system.
 	EventQ chan Event
+	// Written assets to match against the saved queries.  nil if events are disabled.
+	savedQueryQ chan savedQueryMatch
 
 	// Multi-destination logger
 	log server.Logger
@@ -40,24 +51,80 @@ func NewVindaluCore(cfg *config.InventoryConfig, log server.Logger) (ir *Vindalu
 			break
 		}
 		ir.datastore = NewInventoryDatastore(ds, cfg.AssetCfg, log)
+		ir.reindexer = NewReindexer(ds, log)
+
+		if cfg.Snapshots.Enabled() {
+			ir.snapshots, err = NewSnapshotManager(ds, cfg.Snapshots, log)
+		}
+	case "elasticsearch7", "elasticsearch8", "opensearch":
+		if cfg.Snapshots.Enabled() {
+			log.Errorf("Snapshots not supported by the %s datastore.  Ignoring snapshot config!\n", cfg.Datastore.Type)
+		}
+		var ds *TypelessDatastore
+		if ds, err = NewTypelessDatastore(&cfg.Datastore, log); err != nil {
+			break
+		}
+		ir.datastore = NewInventoryDatastore(ds, cfg.AssetCfg, log)
 	default:
 		err = fmt.Errorf("Datastore not supported: %s!", cfg.Datastore.Type)
 	}
 
+	if err == nil && cfg.Events.Enabled {
+		ir.savedQueryQ = make(chan savedQueryMatch, SAVED_QUERY_MATCH_BATCH)
+		go ir.savedQueryMatcher()
+	}
 	return
 }
 
-// Create asset type and publish event
-func (ir *VindaluCore) CreateAssetType(assetType string, properties map[string]interface{}) (err error) {
-	if err = ir.datastore.CreateAssetType(assetType, properties); err != nil {
+// Create asset type with optional property definitions and metadata and publish event
+func (ir *VindaluCore) CreateAssetType(assetType string, properties map[string]interface{}, meta *AssetTypeMetadata) (err error) {
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
+	opts := map[string]interface{}{}
+	if len(properties) > 0 {
+		opts["properties"] = properties
+	}
+	if meta != nil {
+		opts["_meta"] = meta.mappingMeta()
+	}
+
+	if err = ir.datastore.CreateAssetType(assetType, opts); err != nil {
 		return
 	}
 	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_CREATED, assetType, map[string]string{"id": assetType})
 	return
 }
 
-/* Create asset and publish event */
+// Update asset type property definitions and metadata and publish event
+func (ir *VindaluCore) UpdateAssetType(assetType string, properties map[string]interface{}, meta *AssetTypeMetadata) (err error) {
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
+	if err = ir.datastore.UpdateAssetType(assetType, properties, meta); err != nil {
+		return
+	}
+	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_UPDATED, assetType, map[string]string{"id": assetType})
+	return
+}
+
+// Remove an empty asset type and publish event
+func (ir *VindaluCore) RemoveAssetType(assetType string) (err error) {
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
+	if err = ir.datastore.RemoveAssetType(assetType); err != nil {
+		return
+	}
+	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_DELETED, assetType, map[string]string{"id": assetType})
+	return
+}
+
+/* Create asset and publish event.  An id is allocated if the asset does not have one. */
 func (ir *VindaluCore) CreateAsset(ba BaseAsset, user string, isAdmin, isImport bool) (id string, err error) {
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
 	// Do not add `created_by` and `updated_by` fields when importing an asset as it
 	// should be part of the data, hence the import.
 	if isImport {
@@ -84,17 +151,56 @@ func (ir *VindaluCore) CreateAsset(ba BaseAsset, user string, isAdmin, isImport
 	if id, err = ir.datastore.CreateAsset(ba, isAdmin); err != nil {
 		return
 	}
+	// Id may have been allocated
+	ba.Id = id
 	// New type dynamically created.  Write out an event.
 	if assetTypeExists != nil {
 		ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_CREATED, ba.Type, map[string]string{"id": ba.Type})
 	}
 
-	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_CREATED, ba.Type+"."+ba.Id, ba)
+	ir.publishAssetEvent(EVENT_BASE_TYPE_CREATED, ba)
 	return
 }
 
+/*
+	Create or update each asset through the regular create and edit paths.  Existing assets are
+	updated with the supplied fields.  Assets without an id are created with a generated one.
+	Failures are recorded and the remaining assets are still imported.
+*/
+func (ir *VindaluCore) ImportAssets(assets []BaseAsset, user string, isAdmin bool) ImportResult {
+	result := ImportResult{Errors: []ImportError{}}
+
+	for i, ba := range assets {
+		exists := false
+		if len(ba.Id) > 0 {
+			_, err := ir.datastore.Get(ba.Type, ba.Id, 0)
+			exists = err == nil
+		}
+
+		var err error
+		if exists {
+			if _, err = ir.EditAsset(ba, user); err == nil {
+				result.Updated++
+			}
+		} else {
+			if _, err = ir.CreateAsset(ba, user, isAdmin, false); err == nil {
+				result.Created++
+			}
+		}
+
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, ImportError{Row: i + 1, Type: ba.Type, Id: ba.Id, Error: err.Error()})
+		}
+	}
+	return result
+}
+
 /* Edit asset and publish event */
 func (ir *VindaluCore) EditAsset(ba BaseAsset, user string, delFields ...string) (id string, err error) {
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
 
 	// Simply remove in case provided as these cannot be edited.
 	// This happens here as this is where the layer of request user abstraction happens.
@@ -106,27 +212,384 @@ func (ir *VindaluCore) EditAsset(ba BaseAsset, user string, delFields ...string)
 		return
 	}
 
-	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_UPDATED, ba.Type+"."+ba.Id, ba)
+	ir.publishAssetEvent(EVENT_BASE_TYPE_UPDATED, ba)
 
 	return
 }
 
-/* Remove asset and publish event */
+/*
+	Remove asset and publish event.  Links referencing the asset are handled as per their
+	on_delete behavior i.e. referencing assets are removed or have the link removed.
+*/
 func (ir *VindaluCore) RemoveAsset(assetType, assetId string, versionMeta map[string]interface{}) (err error) {
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
+	if _, err = ir.datastore.Get(assetType, assetId, 0); err != nil {
+		return
+	}
+
+	var plan *RemovalPlan
+	if plan, err = ir.datastore.PlanAssetRemoval(assetType, assetId); err != nil {
+		return
+	}
+
+	user, _ := versionMeta["updated_by"].(string)
+	for _, l := range plan.Unlink {
+		var update map[string]interface{}
+		if update, err = ir.datastore.UnlinkData(l); err != nil {
+			return
+		}
+		if _, err = ir.EditAsset(BaseAsset{Type: l.Source.Type, Id: l.Source.Id, Data: update}, user); err != nil {
+			return fmt.Errorf("Failed to remove link from %s: %s", l.Source, err)
+		}
+	}
+
+	for _, ref := range plan.Remove {
+		if err = ir.removeAsset(ref.Type, ref.Id, versionMeta); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/*
+	Rename an asset and/or move it to another type and publish event.  Only admins can rename
+	assets.  Links from other assets are updated to the new name.  If a link cannot be updated the
+	rename is kept, the remaining links are still updated and the error lists the assets whose
+	links were not.
+*/
+func (ir *VindaluCore) RenameAsset(from, to AssetRef, user string, isAdmin bool) (ba *BaseAsset, err error) {
+	if !isAdmin {
+		return nil, &AccessDeniedError{User: user, Reason: "only admins can rename assets"}
+	}
+	if err = ir.checkWritable(); err != nil {
+		return
+	}
+
+	var referrers []AssetLink
+	if referrers, err = ir.datastore.ListReferrers(from); err != nil {
+		return
+	}
+
+	if ba, err = ir.datastore.RenameAsset(from, to, user); err != nil {
+		return
+	}
+
+	// The rename is complete at this point so all links are attempted and failures reported
+	failed := []string{}
+	for _, l := range referrers {
+		// Links from the asset to itself
+		if l.Source == from {
+			l.Source = to
+		}
+
+		update, lerr := ir.datastore.RelinkData(l, to)
+		if lerr == nil {
+			_, lerr = ir.EditAsset(BaseAsset{Type: l.Source.Type, Id: l.Source.Id, Data: update}, user)
+		}
+		if lerr != nil {
+			ir.log.Errorf("Failed to update link from %s: %s\n", l.Source, lerr)
+			failed = append(failed, fmt.Sprintf("%s (%s)", l.Source, lerr))
+		}
+	}
+
+	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_RENAMED, from.Type+"."+from.Id, AssetRename{From: from, To: to, Asset: *ba})
+	if len(failed) > 0 {
+		err = fmt.Errorf("Asset renamed to %s but failed to update links from: %s", to, strings.Join(failed, ", "))
+	}
+	return
+}
+
+// Write an archive of the complete inventory.  Only admins can export the inventory.
+func (ir *VindaluCore) ExportArchive(w io.Writer, user string, isAdmin bool) (*ArchiveManifest, error) {
+	if !isAdmin {
+		return nil, &AccessDeniedError{User: user, Reason: "only admins can export the inventory"}
+	}
+	ar, err := ir.archiver()
+	if err != nil {
+		return nil, err
+	}
+	return ar.ExportArchive(w)
+}
+
+/*
+	Restore an archive into an empty datastore and publish a created event for each type.  Only
+	admins can import archives.
+*/
+func (ir *VindaluCore) ImportArchive(r io.Reader, user string, isAdmin bool) (*ArchiveManifest, error) {
+	if !isAdmin {
+		return nil, &AccessDeniedError{User: user, Reason: "only admins can import the inventory"}
+	}
+	if err := ir.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	ar, err := ir.archiver()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ar.ImportArchive(r)
+	if err != nil {
+		return manifest, err
+	}
+	ir.log.Noticef("Archive imported by %s: types=%d assets=%d versions=%d\n",
+		user, len(manifest.Types), manifest.Assets, manifest.Versions)
+
+	for _, assetType := range manifest.Types {
+		ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_CREATED, assetType, map[string]string{"id": assetType})
+	}
+	return manifest, nil
+}
+
+func (ir *VindaluCore) archiver() (Archiver, error) {
+	if ar, ok := ir.datastore.IDatastore.(Archiver); ok {
+		return ar, nil
+	}
+	return nil, &NotSupportedError{Feature: "Archives", Datastore: ir.cfg.Datastore.Type}
+}
+
+func (ir *VindaluCore) snapshotManager(user string, isAdmin bool) (*SnapshotManager, error) {
+	if !isAdmin {
+		return nil, &AccessDeniedError{User: user, Reason: "only admins can manage snapshots"}
+	}
+	if ir.snapshots == nil {
+		if _, ok := ir.datastore.IDatastore.(*ElasticsearchDatastore); ok {
+			return nil, &NotSupportedError{Feature: "Snapshots"}
+		}
+		return nil, &NotSupportedError{Feature: "Snapshots", Datastore: ir.cfg.Datastore.Type}
+	}
+	return ir.snapshots, nil
+}
+
+// Take a snapshot of the datastore.  Only admins can manage snapshots.
+func (ir *VindaluCore) CreateSnapshot(user string, isAdmin bool) (Snapshot, error) {
+	sm, err := ir.snapshotManager(user, isAdmin)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return sm.Create()
+}
+
+func (ir *VindaluCore) ListSnapshots(user string, isAdmin bool) ([]Snapshot, error) {
+	sm, err := ir.snapshotManager(user, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	return sm.List()
+}
+
+func (ir *VindaluCore) GetSnapshot(name, user string, isAdmin bool) (Snapshot, error) {
+	sm, err := ir.snapshotManager(user, isAdmin)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return sm.Get(name)
+}
+
+func (ir *VindaluCore) RemoveSnapshot(name, user string, isAdmin bool) error {
+	sm, err := ir.snapshotManager(user, isAdmin)
+	if err != nil {
+		return err
+	}
+	return sm.Delete(name)
+}
+
+/*
+	Restore the datastore from a snapshot and publish a created event for each type.  Requests fail
+	while the indices are being restored.
+*/
+func (ir *VindaluCore) RestoreSnapshot(name, user string, isAdmin bool) (Snapshot, error) {
+	sm, err := ir.snapshotManager(user, isAdmin)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err = ir.checkWritable(); err != nil {
+		return Snapshot{}, err
+	}
+
+	snap, err := sm.Restore(name)
+	if err != nil {
+		return snap, err
+	}
+	ir.log.Noticef("Snapshot %s restored by %s\n", name, user)
+
+	types, err := ir.datastore.ListTypes()
+	if err != nil {
+		return snap, err
+	}
+	for _, t := range types {
+		ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_CREATED, t.Name, map[string]string{"id": t.Name})
+	}
+	return snap, nil
+}
+
+// Snapshot state or nil if snapshots are not configured
+func (ir *VindaluCore) SnapshotStatus() *SnapshotStatus {
+	if ir.snapshots == nil {
+		return nil
+	}
+	status := ir.snapshots.Status()
+	return &status
+}
+
+// Take scheduled snapshots.  Returns immediately if none are configured.
+func (ir *VindaluCore) ScheduleSnapshots() {
+	if ir.snapshots == nil || ir.cfg.Snapshots.IntervalDuration() == 0 {
+		return
+	}
+	ir.snapshots.Schedule()
+}
+
+/*
+	Start copying the primary and version indices into new indices with the current mappings.  The
+	aliases are swapped to the new indices once complete and an updated event is published for each
+	type.  Only admins can reindex.
+*/
+func (ir *VindaluCore) Reindex(user string, isAdmin, keepOld bool) (ReindexStatus, error) {
+	if !isAdmin {
+		return ReindexStatus{}, &AccessDeniedError{User: user, Reason: "only admins can reindex"}
+	}
+	if ir.reindexer == nil {
+		return ReindexStatus{}, &NotSupportedError{Feature: "Reindexing", Datastore: ir.cfg.Datastore.Type}
+	}
+	status, err := ir.reindexer.Start(user, keepOld, func() {
+		types, err := ir.datastore.ListTypes()
+		if err != nil {
+			ir.log.Errorf("Failed to list types after reindex: %s\n", err)
+			return
+		}
+		for _, t := range types {
+			ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_UPDATED, t.Name, map[string]string{"id": t.Name})
+		}
+	})
+	if err == nil {
+		ir.log.Noticef("Reindex started by %s\n", user)
+	}
+	return status, err
+}
+
+// Writes fail while reindexing as the indices being copied are write blocked
+func (ir *VindaluCore) checkWritable() error {
+	if ir.reindexer != nil && ir.reindexer.Running() {
+		return &UnavailableError{Reason: "Reindex in progress.  Writes are blocked until it completes."}
+	}
+	return nil
+}
+
+func (ir *VindaluCore) ReindexStatus(user string, isAdmin bool) (ReindexStatus, error) {
+	if !isAdmin {
+		return ReindexStatus{}, &AccessDeniedError{User: user, Reason: "only admins can reindex"}
+	}
+	if ir.reindexer == nil {
+		return ReindexStatus{}, &NotSupportedError{Feature: "Reindexing", Datastore: ir.cfg.Datastore.Type}
+	}
+	return ir.reindexer.Status(), nil
+}
+
+func (ir *VindaluCore) removeAsset(assetType, assetId string, versionMeta map[string]interface{}) (err error) {
+	// Evaluated before removal as the asset will no longer be searchable
+	savedQueries := ir.matchingSavedQueries(assetType, assetId)
+
 	var ba *BaseAsset
 	if ba, err = ir.datastore.RemoveAsset(assetType, assetId, versionMeta); err != nil {
 		return
 	}
-	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_DELETED, assetType+"."+assetId, *ba)
+	ir.EventQ <- *NewEvent(EVENT_BASE_TYPE_DELETED, ba.Type+"."+ba.Id, *ba)
+	ir.publishSavedQueryEvents(EVENT_BASE_TYPE_DELETED, *ba, savedQueries)
 	return
 }
 
+// Publish an asset event.  The events of the saved queries selecting the asset follow once they
+// have been matched in the background.
+func (ir *VindaluCore) publishAssetEvent(evtType EventType, ba BaseAsset) {
+	ir.EventQ <- *NewEvent(evtType, ba.Type+"."+ba.Id, ba)
+	ir.queueSavedQueryMatch(evtType, ba)
+}
+
+// Publish an event for each of the given saved queries i.e. `<event>._queries.<name>`.  This allows
+// subscribing to changes of the assets selected by a saved query.
+func (ir *VindaluCore) publishSavedQueryEvents(evtType EventType, ba BaseAsset, savedQueries []string) {
+	for _, name := range savedQueries {
+		ir.EventQ <- *NewEvent(evtType, "_queries."+name, ba)
+	}
+}
+
+// Create or update a saved query.  Existing queries can only be modified by the owner or an admin.
+func (ir *VindaluCore) SaveQuery(sq SavedQuery, user string, isAdmin bool) error {
+	now := time.Now().Unix() * 1000
+
+	sq.Owner = user
+	sq.CreatedOn = now
+	existing, err := ir.datastore.GetSavedQuery(sq.Name)
+	if err == nil {
+		if existing.Owner != user && !isAdmin {
+			return &AccessDeniedError{User: user,
+				Reason: fmt.Sprintf("saved query '%s' is owned by '%s'", sq.Name, existing.Owner)}
+		}
+		// Retain original owner and creation time
+		sq.Owner = existing.Owner
+		sq.CreatedOn = existing.CreatedOn
+	} else if _, ok := err.(*NotFoundError); !ok {
+		return err
+	}
+	sq.UpdatedOn = now
+
+	return ir.datastore.PutSavedQuery(sq)
+}
+
+// Remove a saved query.  Only the owner or an admin can remove it.
+func (ir *VindaluCore) RemoveSavedQuery(name, user string, isAdmin bool) error {
+	sq, err := ir.datastore.GetSavedQuery(name)
+	if err != nil {
+		return err
+	}
+	if sq.Owner != user && !isAdmin {
+		return &AccessDeniedError{User: user,
+			Reason: fmt.Sprintf("saved query '%s' is owned by '%s'", name, sq.Owner)}
+	}
+	return ir.datastore.RemoveSavedQuery(name)
+}
+
+// Set the JSON schema for a type.  Only admins can manage schemas.
+func (ir *VindaluCore) SetTypeSchema(assetType string, def map[string]interface{}, user string, isAdmin bool) error {
+	if !isAdmin {
+		return &AccessDeniedError{User: user, Reason: "only admins can manage schemas"}
+	}
+	return ir.datastore.PutTypeSchema(AssetTypeSchema{
+		AssetType: assetType,
+		Schema:    def,
+		UpdatedBy: user,
+		UpdatedOn: time.Now().Unix() * 1000,
+	})
+}
+
+// Remove the JSON schema for a type.  Only admins can manage schemas.
+func (ir *VindaluCore) RemoveTypeSchema(assetType, user string, isAdmin bool) error {
+	if !isAdmin {
+		return &AccessDeniedError{User: user, Reason: "only admins can manage schemas"}
+	}
+	if _, err := ir.datastore.GetTypeSchema(assetType); err != nil {
+		return err
+	}
+	return ir.datastore.RemoveTypeSchema(assetType)
+}
+
 // Executes the query against the datastore
 func (ir *VindaluCore) ExecuteQuery(assetType string, userQuery map[string]interface{}, queryOpts *types.QueryOptions) (rslt interface{}, err error) {
+	return ir.executeQuery(assetType, userQuery, queryOpts, false)
+}
+
+// Executes the query against all historical versions in the datastore
+func (ir *VindaluCore) ExecuteVersionQuery(assetType string, userQuery map[string]interface{}, queryOpts *types.QueryOptions) (rslt interface{}, err error) {
+	return ir.executeQuery(assetType, userQuery, queryOpts, true)
+}
+
+func (ir *VindaluCore) executeQuery(assetType string, userQuery map[string]interface{}, queryOpts *types.QueryOptions, versionQuery bool) (rslt interface{}, err error) {
 	if queryOpts != nil && queryOpts.Size < 1 {
 		queryOpts.Size = ir.cfg.DefaultResultSize
 	}
-	return ir.datastore.Query(assetType, userQuery, queryOpts, false)
+	return ir.datastore.Query(assetType, userQuery, queryOpts, versionQuery)
 }
 
 /* Exposed datastore methods */
@@ -143,17 +606,98 @@ func (vc *VindaluCore) GetResourceVersions(rtype, rid string, versionCount int64
 	return vc.datastore.GetVersions(rtype, rid, versionCount)
 }
 
+func (vc *VindaluCore) LatestVersionTimestamp(assetType string) (float64, error) {
+	return vc.datastore.LatestVersionTimestamp(assetType)
+}
+
+func (vc *VindaluCore) GetTypeSchema(assetType string) (AssetTypeSchema, error) {
+	return vc.datastore.GetTypeSchema(assetType)
+}
+
+func (vc *VindaluCore) AssetConfigForType(assetType string) (*config.AssetConfig, error) {
+	return vc.datastore.AssetConfigForType(assetType)
+}
+
+func (vc *VindaluCore) ListUniqueViolations(assetType string) ([]UniqueViolation, error) {
+	return vc.datastore.ListUniqueViolations(assetType)
+}
+
+func (vc *VindaluCore) ListReferrers(assetType, assetId string) ([]AssetLink, error) {
+	if _, err := vc.datastore.Get(assetType, assetId, 0); err != nil {
+		return nil, err
+	}
+	return vc.datastore.ListReferrers(AssetRef{Type: assetType, Id: assetId})
+}
+
+func (vc *VindaluCore) AssetGraph(assetType, assetId string, opts GraphOptions) (*AssetGraph, error) {
+	return traverseLinks(vc.datastore, AssetRef{Type: assetType, Id: assetId}, opts)
+}
+
+func (vc *VindaluCore) GetSavedQuery(name string) (SavedQuery, error) {
+	return vc.datastore.GetSavedQuery(name)
+}
+
+func (vc *VindaluCore) ListSavedQueries(owner string) ([]SavedQuery, error) {
+	return vc.datastore.ListSavedQueries(owner)
+}
+
 func (vc *VindaluCore) ListTypeProperties(ptype string) ([]string, error) {
 	return vc.datastore.ListTypeProperties(ptype)
 }
 
+func (vc *VindaluCore) ListTypePropertyDetails(ptype string) ([]PropertyDetail, error) {
+	return vc.datastore.ListTypePropertyDetails(ptype)
+}
+
+// Mapping type of each field of a type by its dotted path
+func (vc *VindaluCore) TypeFieldTypes(assetType string) (map[string]string, error) {
+	return vc.datastore.TypeFieldTypes(assetType)
+}
+
 func (vc *VindaluCore) ListResourceTypes() ([]ResourceType, error) {
 	return vc.datastore.ListTypes()
 }
 
 func (vc *VindaluCore) ClusterStatus() (VindaluClusterStatus, error) {
-	// TODO: de-couple from datastore
-	return GetClusterStatus(vc.datastore.Conn.Conn)
+	cs, err := vc.datastore.ClusterStatus()
+	if err == nil {
+		cs.Snapshots = vc.SnapshotStatus()
+		if pd, ok := vc.datastore.IDatastore.(PooledDatastore); ok {
+			cs.Hosts = pd.Pool().Status()
+		}
+		// Reindexing is only available with elasticsearch 1.x
+		if vc.reindexer != nil {
+			if status := vc.reindexer.Status(); status.State != REINDEX_IDLE {
+				cs.Reindex = &status
+			}
+		}
+	}
+	return cs, err
+}
+
+/*
+	Pass a request through to the primary or version index using the datastore connection pool.
+	Only the Accept and Content-Type headers are sent.  The caller must close the response body.
+*/
+func (vc *VindaluCore) RawRequest(method, path, rawQuery string, header http.Header, body []byte, versions bool) (*http.Response, error) {
+	pd, ok := vc.datastore.IDatastore.(PooledDatastore)
+	if !ok {
+		return nil, &NotSupportedError{Feature: "Raw requests", Datastore: vc.cfg.Datastore.Type}
+	}
+
+	dscfg, _ := vc.cfg.Datastore.Config.(EssDatastoreConfig)
+	index := dscfg.Index
+	if versions {
+		index = dscfg.VersionIndex
+	}
+
+	fwd := http.Header{}
+	for _, k := range []string{"Accept", "Content-Type"} {
+		if v := header.Get(k); len(v) > 0 {
+			fwd.Set(k, v)
+		}
+	}
+	return pd.Pool().Do(method, "/"+index+"/"+strings.TrimPrefix(path, "/"), rawQuery, fwd, body)
 }
 
 func (vc *VindaluCore) Config() *config.InventoryConfig {
@@ -55,8 +55,8 @@ func TestMain(m *testing.M) {
 	retval := m.Run()
 
 	// Cleanup
-	testInv.datastore.Conn.DeleteIndex("test_core")
-	testInv.datastore.Conn.DeleteIndex("test_core_versions")
+	testInv.datastore.IDatastore.(*ElasticsearchDatastore).Conn.DeleteIndex("test_core")
+	testInv.datastore.IDatastore.(*ElasticsearchDatastore).Conn.DeleteIndex("test_core_versions")
 
 	os.Exit(retval)
 }
@@ -93,7 +93,7 @@ func Test_VindaluCore_ClusterMemberAddrs(t *testing.T) {
 
 func Test_VindaluCore_CreateAssetType(t *testing.T) {
 
-	err := testInv.CreateAssetType("testtype", nil)
+	err := testInv.CreateAssetType("testtype", nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -174,7 +174,7 @@ func Test_VindaluCore_CreateAsset_new_type_admin(t *testing.T) {
 
 func Test_VindaluCore_ExecuteQuery(t *testing.T) {
 	// Needed to force index.
-	testInv.datastore.Conn.Refresh("test_core")
+	testInv.datastore.IDatastore.(*ElasticsearchDatastore).Conn.Refresh("test_core")
 
 	q := map[string]interface{}{"status": "enabled"}
 
@@ -0,0 +1,173 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+// Host variables added to the asset data
+const (
+	ANSIBLE_VAR_ID   = "vindalu_id"
+	ANSIBLE_VAR_TYPE = "vindalu_type"
+)
+
+// Group of hosts not in any other group
+const ANSIBLE_UNGROUPED = "ungrouped"
+
+var ansibleGroupInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Group of an ansible inventory
+type AnsibleGroup struct {
+	Hosts    []string `json:"hosts,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+/*
+	Build an ansible dynamic inventory from assets i.e.
+
+		{
+			"_meta": {"hostvars": {"web01": {"status": "enabled", "role": "web", ...}}},
+			"all": {"children": ["role_web", "ungrouped"]},
+			"role_web": {"hosts": ["web01"]},
+			"ungrouped": {}
+		}
+
+	The host name is the value of `HostField`, or the asset id if not set or the asset does not
+	have the field.  Assets sharing a host name use their id instead so none of them are lost.  A
+	ConflictError is returned if host names are still not unique i.e. for assets of different
+	types with the same id.  Each value of the `GroupBy` fields adds the host to a group named
+	<field>_<value> with characters other than letters, digits and '_' replaced by '_'.  Array
+	fields add the host to a group per element.  Host variables are the asset data along with the
+	asset id and type.
+*/
+func AnsibleInventory(assets []core.BaseAsset, cfg config.AnsibleConfig) (map[string]interface{}, error) {
+	var (
+		hostvars = map[string]interface{}{}
+		groups   = map[string][]string{}
+		grouped  = map[string]bool{}
+	)
+
+	hosts, err := ansibleHostNames(assets, cfg.HostField)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, a := range assets {
+		host := hosts[i]
+
+		vars := map[string]interface{}{}
+		for k, v := range a.Data {
+			vars[k] = v
+		}
+		vars[ANSIBLE_VAR_ID] = a.Id
+		vars[ANSIBLE_VAR_TYPE] = a.Type
+		hostvars[host] = vars
+
+		for _, field := range cfg.GroupBy {
+			v, _ := fieldValue(a.Data, field)
+			for _, name := range ansibleGroupNames(field, v) {
+				groups[name] = append(groups[name], host)
+				grouped[host] = true
+			}
+		}
+	}
+
+	inventory := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostvars},
+	}
+
+	children := []string{}
+	for name, hosts := range groups {
+		inventory[name] = AnsibleGroup{Hosts: uniqueSorted(hosts)}
+		children = append(children, name)
+	}
+	sort.Strings(children)
+
+	ungrouped := []string{}
+	for host := range hostvars {
+		if !grouped[host] {
+			ungrouped = append(ungrouped, host)
+		}
+	}
+	sort.Strings(ungrouped)
+	inventory[ANSIBLE_UNGROUPED] = AnsibleGroup{Hosts: ungrouped}
+
+	inventory["all"] = AnsibleGroup{Children: append(children, ANSIBLE_UNGROUPED)}
+	return inventory, nil
+}
+
+// Unique host name of each asset.  Assets sharing a host name fall back to their id.
+func ansibleHostNames(assets []core.BaseAsset, hostField string) ([]string, error) {
+	hosts := make([]string, len(assets))
+	counts := map[string]int{}
+	for i, a := range assets {
+		hosts[i] = ansibleHostName(a, hostField)
+		counts[hosts[i]]++
+	}
+
+	owners := map[string]core.BaseAsset{}
+	for i, a := range assets {
+		if counts[hosts[i]] > 1 {
+			hosts[i] = a.Id
+		}
+		if owner, ok := owners[hosts[i]]; ok {
+			return nil, &core.ConflictError{Reason: fmt.Sprintf("Duplicate ansible host name '%s': %s/%s and %s/%s",
+				hosts[i], owner.Type, owner.Id, a.Type, a.Id)}
+		}
+		owners[hosts[i]] = a
+	}
+	return hosts, nil
+}
+
+func ansibleHostName(a core.BaseAsset, hostField string) string {
+	if len(hostField) == 0 {
+		return a.Id
+	}
+
+	v, _ := fieldValue(a.Data, hostField)
+	switch v.(type) {
+	case string, float64, bool:
+		if name := csvValue(v); len(name) > 0 {
+			return name
+		}
+	}
+	return a.Id
+}
+
+// Group names for the value of a field.  Objects and nulls are not grouped.
+func ansibleGroupNames(field string, v interface{}) (names []string) {
+	switch v.(type) {
+	case nil, map[string]interface{}:
+		return
+	case []interface{}:
+		for _, item := range v.([]interface{}) {
+			names = append(names, ansibleGroupNames(field, item)...)
+		}
+		return
+	}
+
+	if val := csvValue(v); len(val) > 0 {
+		names = append(names, AnsibleGroupName(field+"_"+val))
+	}
+	return
+}
+
+// Replace characters not allowed in ansible group names with '_'
+func AnsibleGroupName(name string) string {
+	return ansibleGroupInvalidChars.ReplaceAllString(name, "_")
+}
+
+func uniqueSorted(vals []string) []string {
+	sort.Strings(vals)
+	out := vals[:0]
+	for _, v := range vals {
+		if len(out) == 0 || v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+var testHosts = []core.BaseAsset{
+	{Id: "a1", Type: "server", Data: map[string]interface{}{
+		"hostname":    "web01.example.org",
+		"environment": "prod",
+		"roles":       []interface{}{"web", "cache"},
+	}},
+	{Id: "a2", Type: "server", Data: map[string]interface{}{
+		"hostname":    "db01.example.org",
+		"environment": "prod",
+		"roles":       []interface{}{"db"},
+	}},
+	{Id: "a3", Type: "server", Data: map[string]interface{}{
+		"labels": map[string]interface{}{"env": "dev"},
+	}},
+}
+
+func Test_AnsibleInventory(t *testing.T) {
+	inv, err := AnsibleInventory(testHosts, config.AnsibleConfig{
+		HostField: "hostname",
+		GroupBy:   []string{"environment", "roles", "missing"},
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b, _ := json.Marshal(inv)
+	var rsp map[string]interface{}
+	json.Unmarshal(b, &rsp)
+
+	expected := map[string]interface{}{
+		"environment_prod": map[string]interface{}{"hosts": []interface{}{"db01.example.org", "web01.example.org"}},
+		"roles_web":        map[string]interface{}{"hosts": []interface{}{"web01.example.org"}},
+		"roles_cache":      map[string]interface{}{"hosts": []interface{}{"web01.example.org"}},
+		"roles_db":         map[string]interface{}{"hosts": []interface{}{"db01.example.org"}},
+		"ungrouped":        map[string]interface{}{"hosts": []interface{}{"a3"}},
+		"all": map[string]interface{}{"children": []interface{}{
+			"environment_prod", "roles_cache", "roles_db", "roles_web", "ungrouped",
+		}},
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(rsp[k], v) {
+			t.Fatalf("Wrong group %s: %v", k, rsp[k])
+		}
+	}
+
+	hostvars := rsp["_meta"].(map[string]interface{})["hostvars"].(map[string]interface{})
+	if len(hostvars) != 3 {
+		t.Fatalf("Wrong hostvars: %v", hostvars)
+	}
+	vars := hostvars["web01.example.org"].(map[string]interface{})
+	if vars[ANSIBLE_VAR_ID] != "a1" || vars[ANSIBLE_VAR_TYPE] != "server" || vars["environment"] != "prod" {
+		t.Fatalf("Wrong host vars: %v", vars)
+	}
+}
+
+func Test_AnsibleInventory_Defaults(t *testing.T) {
+	inv, err := AnsibleInventory(testHosts, config.AnsibleConfig{GroupBy: []string{"labels.env"}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if g := inv["labels_env_dev"].(AnsibleGroup); !reflect.DeepEqual(g.Hosts, []string{"a3"}) {
+		t.Fatalf("Should group by nested field: %v", g)
+	}
+	if g := inv[ANSIBLE_UNGROUPED].(AnsibleGroup); !reflect.DeepEqual(g.Hosts, []string{"a1", "a2"}) {
+		t.Fatalf("Host names should default to the id: %v", g)
+	}
+}
+
+func Test_AnsibleInventory_DuplicateHosts(t *testing.T) {
+	assets := append([]core.BaseAsset{
+		{Id: "a4", Type: "server", Data: map[string]interface{}{"hostname": "web01.example.org"}},
+	}, testHosts...)
+
+	inv, err := AnsibleInventory(assets, config.AnsibleConfig{HostField: "hostname"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	hostvars := inv["_meta"].(map[string]interface{})["hostvars"].(map[string]interface{})
+	if len(hostvars) != 4 {
+		t.Fatalf("Hosts should not be overwritten: %v", hostvars)
+	}
+	for _, host := range []string{"a1", "a4", "db01.example.org"} {
+		if _, ok := hostvars[host]; !ok {
+			t.Fatalf("Host missing: %s %v", host, hostvars)
+		}
+	}
+
+	assets = append(assets, core.BaseAsset{Id: "a1", Type: "switch", Data: map[string]interface{}{}})
+	if _, err = AnsibleInventory(assets, config.AnsibleConfig{HostField: "hostname"}); err == nil {
+		t.Fatalf("Should fail with ids of different types")
+	} else if _, ok := err.(*core.ConflictError); !ok {
+		t.Fatalf("Wrong error type: %#v", err)
+	}
+}
+
+func Test_AnsibleGroupName(t *testing.T) {
+	if name := AnsibleGroupName("labels.example.org/team_web-1"); name != "labels_example_org_team_web_1" {
+		t.Fatalf("Wrong group name: %s", name)
+	}
+}
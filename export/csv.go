@@ -0,0 +1,261 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vindalu/vindalu/core"
+)
+
+// Separator between the names of nested fields in column names i.e. labels.env
+const FIELD_SEPARATOR = "."
+
+// Columns that are not part of the asset data.  Prefixed so they do not clash with data fields.
+const (
+	COLUMN_ID   = "_id"
+	COLUMN_TYPE = "_type"
+)
+
+/*
+	Mapped types of the (dotted) fields of an asset type i.e. {"cpus": "long"}.  Used to convert
+	CSV cells.  Fields missing from the mapping are read as strings.
+*/
+type FieldTypes func(assetType string) map[string]string
+
+/*
+	Flatten nested objects in the asset data into dotted field names i.e.
+	{"labels": {"env": "prod"}} -> {"labels.env": "prod"}.  Arrays are left as is.
+*/
+func FlattenData(data map[string]interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	flattenInto(flat, "", data)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, data map[string]interface{}) {
+	for k, v := range data {
+		if obj, ok := v.(map[string]interface{}); ok && len(obj) > 0 {
+			flattenInto(flat, prefix+k+FIELD_SEPARATOR, obj)
+		} else {
+			flat[prefix+k] = v
+		}
+	}
+}
+
+// Reverse of FlattenData
+func UnflattenData(flat map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range flat {
+		parts := strings.Split(k, FIELD_SEPARATOR)
+
+		curr := data
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := curr[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				curr[p] = next
+			}
+			curr = next
+		}
+		curr[parts[len(parts)-1]] = v
+	}
+	return data
+}
+
+// Value of a (dotted) field.  Selecting an object returns the object.
+func fieldValue(data map[string]interface{}, field string) (interface{}, bool) {
+	if v, ok := data[field]; ok {
+		return v, true
+	}
+
+	var curr interface{} = data
+	for _, p := range strings.Split(field, FIELD_SEPARATOR) {
+		obj, ok := curr.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if curr, ok = obj[p]; !ok {
+			return nil, false
+		}
+	}
+	return curr, true
+}
+
+/*
+	Write assets as CSV with a header row.  If no fields are given the columns are the id, type and
+	all flattened data fields in alphabetical order.  Arrays and objects are written as JSON.
+*/
+func WriteCSV(w io.Writer, assets []core.BaseAsset, fields []string) error {
+	if len(fields) == 0 {
+		fields = csvColumns(assets)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+
+	row := make([]string, len(fields))
+	for _, a := range assets {
+		for i, f := range fields {
+			switch f {
+			case COLUMN_ID:
+				row[i] = a.Id
+			case COLUMN_TYPE:
+				row[i] = a.Type
+			default:
+				v, _ := fieldValue(a.Data, f)
+				row[i] = csvValue(v)
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Write aggregated counts as CSV with name and count columns
+func WriteAggregatedCSV(w io.Writer, items []core.AggregatedItem) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"name", "count"})
+	for _, item := range items {
+		cw.Write([]string{item.Name, strconv.FormatInt(item.Count, 10)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// _id, _type and the sorted union of the flattened data fields of all assets
+func csvColumns(assets []core.BaseAsset) []string {
+	seen := map[string]bool{}
+	fields := []string{}
+	for _, a := range assets {
+		for k := range FlattenData(a.Data) {
+			if !seen[k] && k != COLUMN_ID && k != COLUMN_TYPE {
+				seen[k] = true
+				fields = append(fields, k)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	return append([]string{COLUMN_ID, COLUMN_TYPE}, fields...)
+}
+
+func csvValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v.(string)
+	case float64:
+		return strconv.FormatFloat(v.(float64), 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v.(bool))
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+/*
+	Parse a CSV cell of a field with the given mapped type.  Numbers and booleans are only
+	converted for numeric and boolean fields so values such as serials or zip codes keep their
+	form.  Arrays and objects in JSON form are decoded.  All other values are kept as strings.
+*/
+func parseCSVValue(s, fieldType string) interface{} {
+	if len(s) == 0 {
+		return s
+	}
+	switch fieldType {
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			return b
+		}
+	}
+	if s[0] == '[' || s[0] == '{' {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			return v
+		}
+	}
+	return s
+}
+
+/*
+	Read assets of `assetType` from CSV with a header row of (dotted) field names.  The `_id` column
+	is required though values can be empty for types generating ids.  The `_type` column is
+	optional so exported CSV can be read back, though rows of other types are rejected.  Cells are converted per the mapped type of their field
+	from `fieldTypes`, which may be nil, looked up by the column name i.e. the dotted path of
+	nested fields.  Empty cells are skipped.
+*/
+func ReadCSV(r io.Reader, assetType string, fieldTypes FieldTypes) ([]core.BaseAsset, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("CSV header required")
+		}
+		return nil, err
+	}
+
+	idCol, typeCol := -1, -1
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		switch header[i] {
+		case COLUMN_ID:
+			idCol = i
+		case COLUMN_TYPE:
+			typeCol = i
+		}
+	}
+	if idCol < 0 {
+		return nil, fmt.Errorf("CSV column required: %s", COLUMN_ID)
+	}
+
+	assets := []core.BaseAsset{}
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		asset := core.BaseAsset{Id: strings.TrimSpace(row[idCol]), Type: assetType}
+		if typeCol >= 0 {
+			if t := strings.TrimSpace(row[typeCol]); len(t) > 0 && !strings.EqualFold(t, assetType) {
+				return nil, fmt.Errorf("Row %d: %s '%s' does not match the asset type: %s", len(assets)+1, COLUMN_TYPE, t, assetType)
+			}
+		}
+
+		var types map[string]string
+		if fieldTypes != nil {
+			types = fieldTypes(asset.Type)
+		}
+
+		flat := map[string]interface{}{}
+		for i, cell := range row {
+			if i == idCol || i == typeCol || len(cell) == 0 {
+				continue
+			}
+			flat[header[i]] = parseCSVValue(cell, types[header[i]])
+		}
+		asset.Data = UnflattenData(flat)
+
+		assets = append(assets, asset)
+	}
+	return assets, nil
+}
@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/vindalu/vindalu/core"
+)
+
+var testAssets = []core.BaseAsset{
+	{Id: "web01", Type: "server", Data: map[string]interface{}{
+		"status": "enabled",
+		"cpus":   4.0,
+		"roles":  []interface{}{"web", "cache"},
+		"labels": map[string]interface{}{"env": "prod"},
+	}},
+	{Id: "web02", Type: "server", Data: map[string]interface{}{
+		"status":  "disabled",
+		"comment": "has, comma",
+	}},
+}
+
+func Test_FlattenData(t *testing.T) {
+	data := map[string]interface{}{
+		"name":   "web01",
+		"labels": map[string]interface{}{"env": "prod", "team": map[string]interface{}{"name": "web"}},
+	}
+
+	flat := FlattenData(data)
+	if len(flat) != 3 || flat["labels.env"] != "prod" || flat["labels.team.name"] != "web" {
+		t.Fatalf("Wrong flattened data: %v", flat)
+	}
+
+	unflat := UnflattenData(flat)
+	labels, _ := unflat["labels"].(map[string]interface{})
+	if unflat["name"] != "web01" || labels["env"] != "prod" {
+		t.Fatalf("Wrong unflattened data: %v", unflat)
+	}
+}
+
+func Test_WriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testAssets, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := `_id,_type,comment,cpus,labels.env,roles,status
+web01,server,,4,prod,"[""web"",""cache""]",enabled
+web02,server,"has, comma",,,,disabled
+`
+	if buf.String() != expected {
+		t.Fatalf("Wrong csv:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, testAssets, []string{"_id", "labels", "status"}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !strings.HasPrefix(buf.String(), "_id,labels,status\nweb01,\"{\"\"env\"\":\"\"prod\"\"}\",enabled\n") {
+		t.Fatalf("Wrong csv:\n%s", buf.String())
+	}
+}
+
+func Test_WriteAggregatedCSV(t *testing.T) {
+	var buf bytes.Buffer
+	WriteAggregatedCSV(&buf, []core.AggregatedItem{{Name: "enabled", Count: 3}})
+	if buf.String() != "name,count\nenabled,3\n" {
+		t.Fatalf("Wrong csv:\n%s", buf.String())
+	}
+}
+
+func Test_ReadCSV(t *testing.T) {
+	var buf bytes.Buffer
+	WriteCSV(&buf, testAssets, nil)
+
+	fieldTypes := func(assetType string) map[string]string {
+		if assetType != "server" {
+			t.Fatalf("Wrong type: %s", assetType)
+		}
+		return map[string]string{"cpus": "long", "status": "string"}
+	}
+	assets, err := ReadCSV(&buf, "server", fieldTypes)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(assets) != 2 || assets[0].Id != "web01" || assets[0].Type != "server" {
+		t.Fatalf("Wrong assets: %v", assets)
+	}
+
+	data := assets[0].Data
+	labels, _ := data["labels"].(map[string]interface{})
+	roles, _ := data["roles"].([]interface{})
+	if data["cpus"] != 4.0 || labels["env"] != "prod" || len(roles) != 2 || data["status"] != "enabled" {
+		t.Fatalf("Wrong data: %v", data)
+	}
+	if _, ok := data["comment"]; ok {
+		t.Fatalf("Empty cells should be skipped: %v", data)
+	}
+	if assets[1].Data["comment"] != "has, comma" {
+		t.Fatalf("Wrong data: %v", assets[1].Data)
+	}
+}
+
+func Test_ReadCSV_defaults(t *testing.T) {
+	csv := "_id,serial,enabled,port,cpu.count\n,007,true,8080,4\n"
+	assets, err := ReadCSV(strings.NewReader(csv), "server", nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(assets) != 1 || assets[0].Id != "" || assets[0].Type != "server" {
+		t.Fatalf("Wrong assets: %v", assets)
+	}
+	// Unmapped fields are kept as strings
+	data := assets[0].Data
+	cpu, _ := data["cpu"].(map[string]interface{})
+	if data["serial"] != "007" || data["enabled"] != "true" || data["port"] != "8080" || cpu["count"] != "4" {
+		t.Fatalf("Wrong values: %v", data)
+	}
+
+	fieldTypes := func(string) map[string]string {
+		return map[string]string{"serial": "string", "enabled": "boolean", "port": "long", "cpu": "object", "cpu.count": "long"}
+	}
+	if assets, err = ReadCSV(strings.NewReader(csv), "server", fieldTypes); err != nil {
+		t.Fatalf("%s", err)
+	}
+	data = assets[0].Data
+	// Nested fields are typed by their dotted path
+	cpu, _ = data["cpu"].(map[string]interface{})
+	if data["serial"] != "007" || data["enabled"] != true || data["port"] != 8080.0 || cpu["count"] != 4.0 {
+		t.Fatalf("Wrong values: %v", data)
+	}
+
+	if _, err = ReadCSV(strings.NewReader("name\nweb01\n"), "server", nil); err == nil {
+		t.Fatalf("Should fail without id column")
+	}
+	if _, err = ReadCSV(strings.NewReader(""), "server", nil); err == nil {
+		t.Fatalf("Should fail without header")
+	}
+}
+
+func Test_CSV_idTypeFields(t *testing.T) {
+	assets := []core.BaseAsset{{Id: "web01", Type: "server", Data: map[string]interface{}{"id": "i-123", "type": "m4.large"}}}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, assets, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if buf.String() != "_id,_type,id,type\nweb01,server,i-123,m4.large\n" {
+		t.Fatalf("Wrong csv:\n%s", buf.String())
+	}
+
+	read, err := ReadCSV(bytes.NewReader(buf.Bytes()), "server", nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if read[0].Id != "web01" || read[0].Type != "server" || read[0].Data["id"] != "i-123" || read[0].Data["type"] != "m4.large" {
+		t.Fatalf("Wrong assets: %v", read)
+	}
+
+	// Rows can not be imported into another type
+	if _, err = ReadCSV(bytes.NewReader(buf.Bytes()), "pool", nil); err == nil {
+		t.Fatalf("Should fail with another type")
+	}
+}
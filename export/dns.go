@@ -0,0 +1,273 @@
+package export
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+// Asset fields used for records when not configured
+const (
+	DNS_DEFAULT_NAME_FIELD  = "name"
+	DNS_DEFAULT_TYPE_FIELD  = "record_type"
+	DNS_DEFAULT_VALUE_FIELD = "value"
+	DNS_DEFAULT_TTL_FIELD   = "ttl"
+)
+
+// Record type of assets without one
+const DNS_DEFAULT_RECORD_TYPE = "A"
+
+// Zone defaults in seconds
+const (
+	DNS_DEFAULT_TTL     = 3600
+	DNS_DEFAULT_REFRESH = 3600
+	DNS_DEFAULT_RETRY   = 600
+	DNS_DEFAULT_EXPIRE  = 604800
+	DNS_DEFAULT_MINIMUM = 300
+)
+
+// Zone contact used when not configured.  Relative to the origin.
+const DNS_DEFAULT_HOSTMASTER = "hostmaster"
+
+// Record types whose data is a single domain name
+var dnsNameRecordTypes = map[string]bool{"CNAME": true, "NS": true, "PTR": true}
+
+// Resource record of a zone
+type DNSRecord struct {
+	// Name relative to the origin, '@' or fully qualified with a trailing dot
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Record ttl.  0 uses the zone default.
+	TTL int `json:"ttl,omitempty"`
+	// Record data in zone file syntax
+	Value   string `json:"value"`
+	AssetId string `json:"asset_id"`
+}
+
+// Line of a hosts file
+type HostsEntry struct {
+	Address string   `json:"address"`
+	Names   []string `json:"names"`
+}
+
+/*
+	Zone config with defaults applied.  The origin, name servers and hostmaster are made fully
+	qualified.  The hostmaster is converted to the mailbox form i.e. hostmaster@example.org ->
+	hostmaster.example.org.
+*/
+func ZoneConfig(cfg config.DNSConfig) config.DNSConfig {
+	if len(cfg.NameField) == 0 {
+		cfg.NameField = DNS_DEFAULT_NAME_FIELD
+	}
+	if len(cfg.TypeField) == 0 {
+		cfg.TypeField = DNS_DEFAULT_TYPE_FIELD
+	}
+	if len(cfg.ValueField) == 0 {
+		cfg.ValueField = DNS_DEFAULT_VALUE_FIELD
+	}
+	if len(cfg.TTLField) == 0 {
+		cfg.TTLField = DNS_DEFAULT_TTL_FIELD
+	}
+
+	if cfg.TTL < 1 {
+		cfg.TTL = DNS_DEFAULT_TTL
+	}
+	if cfg.Refresh < 1 {
+		cfg.Refresh = DNS_DEFAULT_REFRESH
+	}
+	if cfg.Retry < 1 {
+		cfg.Retry = DNS_DEFAULT_RETRY
+	}
+	if cfg.Expire < 1 {
+		cfg.Expire = DNS_DEFAULT_EXPIRE
+	}
+	if cfg.Minimum < 1 {
+		cfg.Minimum = DNS_DEFAULT_MINIMUM
+	}
+
+	if len(cfg.Origin) > 0 {
+		cfg.Origin = FQDN("@", cfg.Origin)
+	}
+
+	nameservers := make([]string, len(cfg.Nameservers))
+	for i, ns := range cfg.Nameservers {
+		nameservers[i] = FQDN(ns, cfg.Origin)
+	}
+	cfg.Nameservers = nameservers
+
+	if len(cfg.Hostmaster) == 0 {
+		cfg.Hostmaster = DNS_DEFAULT_HOSTMASTER
+	}
+	if i := strings.Index(cfg.Hostmaster, "@"); i >= 0 {
+		local := strings.Replace(cfg.Hostmaster[:i], ".", `\.`, -1)
+		cfg.Hostmaster = local + "." + FQDN("@", cfg.Hostmaster[i+1:])
+	} else {
+		cfg.Hostmaster = FQDN(cfg.Hostmaster, cfg.Origin)
+	}
+	return cfg
+}
+
+/*
+	Build resource records from assets.  The name, type, data and ttl are read from the configured
+	fields.  Assets without a name use their id and those without a type are A records.  Array
+	data fields give a record per element.  Assets without data are skipped.  Names within the
+	origin are made relative to it, TXT data is quoted.
+*/
+func DNSRecords(assets []core.BaseAsset, cfg config.DNSConfig) []DNSRecord {
+	records := []DNSRecord{}
+
+	for _, a := range assets {
+		name := a.Id
+		if v, _ := fieldValue(a.Data, cfg.NameField); len(scalarValues(v)) == 1 {
+			name = scalarValues(v)[0]
+		}
+		name = ZoneName(name, cfg.Origin)
+
+		rtype := DNS_DEFAULT_RECORD_TYPE
+		if v, _ := fieldValue(a.Data, cfg.TypeField); len(scalarValues(v)) == 1 {
+			rtype = strings.ToUpper(scalarValues(v)[0])
+		}
+
+		var ttl int
+		if v, _ := fieldValue(a.Data, cfg.TTLField); len(scalarValues(v)) == 1 {
+			ttl, _ = strconv.Atoi(scalarValues(v)[0])
+		}
+
+		v, _ := fieldValue(a.Data, cfg.ValueField)
+		for _, val := range scalarValues(v) {
+			switch {
+			case rtype == "TXT" && !strings.HasPrefix(val, `"`):
+				val = strconv.Quote(val)
+			case dnsNameRecordTypes[rtype]:
+				val = ZoneName(val, cfg.Origin)
+			}
+			records = append(records, DNSRecord{Name: name, Type: rtype, TTL: ttl, Value: val, AssetId: a.Id})
+		}
+	}
+	return records
+}
+
+/*
+	Hosts file entries from the A and AAAA records, one per address in the order first seen.
+	CNAME records add their name to the entry of the host they point to.
+*/
+func HostsEntries(records []DNSRecord, origin string) []HostsEntry {
+	var (
+		entries = []HostsEntry{}
+		byAddr  = map[string]int{}
+		byName  = map[string][]int{}
+	)
+
+	for _, rec := range records {
+		if rec.Type != "A" && rec.Type != "AAAA" {
+			continue
+		}
+		host := HostName(rec.Name, origin)
+		if len(host) == 0 {
+			continue
+		}
+
+		i, ok := byAddr[rec.Value]
+		if !ok {
+			i = len(entries)
+			byAddr[rec.Value] = i
+			entries = append(entries, HostsEntry{Address: rec.Value})
+		}
+		if !containsString(entries[i].Names, host) {
+			entries[i].Names = append(entries[i].Names, host)
+			byName[host] = append(byName[host], i)
+		}
+	}
+
+	for _, rec := range records {
+		if rec.Type != "CNAME" {
+			continue
+		}
+		alias := HostName(rec.Name, origin)
+		for _, i := range byName[HostName(rec.Value, origin)] {
+			if len(alias) > 0 && !containsString(entries[i].Names, alias) {
+				entries[i].Names = append(entries[i].Names, alias)
+			}
+		}
+	}
+	return entries
+}
+
+/*
+	Serial of a zone from the latest change to its records i.e. the most recent of the asset
+	timestamps and the given version timestamp (ms), as seconds since the epoch.
+*/
+func ZoneSerial(assets []core.BaseAsset, latestVersion float64) int64 {
+	latest := latestVersion
+	for _, a := range assets {
+		if ts := timestampMillis(a.Timestamp); ts > latest {
+			latest = ts
+		}
+	}
+	return int64(latest / 1000)
+}
+
+func timestampMillis(ts interface{}) float64 {
+	switch ts.(type) {
+	case float64:
+		return ts.(float64)
+	case int64:
+		return float64(ts.(int64))
+	case json.Number:
+		f, _ := ts.(json.Number).Float64()
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(ts.(string), 64)
+		return f
+	}
+	return 0
+}
+
+// Fully qualified form of a name with a trailing dot.  '@' is the origin itself.
+func FQDN(name, origin string) string {
+	origin = strings.TrimSuffix(origin, ".")
+	switch {
+	case len(name) == 0 || name == "@":
+		return origin + "."
+	case strings.HasSuffix(name, "."):
+		return name
+	case len(origin) == 0:
+		return name + "."
+	}
+	return name + "." + origin + "."
+}
+
+// Fully qualified form of a name without the trailing dot as used in hosts files
+func HostName(name, origin string) string {
+	return strings.TrimSuffix(FQDN(name, origin), ".")
+}
+
+/*
+	Name as written in a zone file with the given origin.  Names within the origin that are not
+	fully qualified i.e. www.example.org are given a trailing dot so they are not made relative
+	again.
+*/
+func ZoneName(name, origin string) string {
+	origin = strings.TrimSuffix(origin, ".")
+	switch {
+	case len(name) == 0 || name == "@" || (len(origin) > 0 && name == origin):
+		return "@"
+	case strings.HasSuffix(name, "."):
+		return name
+	case len(origin) > 0 && strings.HasSuffix(name, "."+origin):
+		return name + "."
+	}
+	return name
+}
+
+func containsString(vals []string, s string) bool {
+	for _, v := range vals {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
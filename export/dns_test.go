@@ -0,0 +1,116 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+var testDNSAssets = []core.BaseAsset{
+	{Id: "www", Type: "dnsrecord", Timestamp: float64(1400000000000), Data: map[string]interface{}{
+		"value": []interface{}{"10.0.0.1", "10.0.0.2"},
+		"ttl":   float64(300),
+	}},
+	{Id: "mail", Type: "dnsrecord", Timestamp: float64(1400000300000), Data: map[string]interface{}{
+		"name":        "mail.example.org",
+		"record_type": "aaaa",
+		"value":       "fe80::1",
+	}},
+	{Id: "web", Type: "dnsrecord", Timestamp: float64(1400000100000), Data: map[string]interface{}{
+		"record_type": "CNAME",
+		"value":       "www.example.org",
+	}},
+	{Id: "spf", Type: "dnsrecord", Data: map[string]interface{}{
+		"name":        "@",
+		"record_type": "TXT",
+		"value":       `v=spf1 mx -all`,
+	}},
+	{Id: "empty", Type: "dnsrecord", Data: map[string]interface{}{}},
+}
+
+func Test_DNSRecords(t *testing.T) {
+	records := DNSRecords(testDNSAssets, ZoneConfig(config.DNSConfig{Origin: "example.org"}))
+	expected := []DNSRecord{
+		{Name: "www", Type: "A", TTL: 300, Value: "10.0.0.1", AssetId: "www"},
+		{Name: "www", Type: "A", TTL: 300, Value: "10.0.0.2", AssetId: "www"},
+		{Name: "mail.example.org.", Type: "AAAA", Value: "fe80::1", AssetId: "mail"},
+		{Name: "web", Type: "CNAME", Value: "www.example.org.", AssetId: "web"},
+		{Name: "@", Type: "TXT", Value: `"v=spf1 mx -all"`, AssetId: "spf"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Fatalf("Wrong records: %#v", records)
+	}
+
+	records = DNSRecords(testDNSAssets[:1], ZoneConfig(config.DNSConfig{ValueField: "missing"}))
+	if len(records) != 0 {
+		t.Fatalf("Assets without data should be skipped: %#v", records)
+	}
+}
+
+func Test_HostsEntries(t *testing.T) {
+	records := DNSRecords(testDNSAssets, ZoneConfig(config.DNSConfig{Origin: "example.org"}))
+	entries := HostsEntries(records, "example.org.")
+	expected := []HostsEntry{
+		{Address: "10.0.0.1", Names: []string{"www.example.org", "web.example.org"}},
+		{Address: "10.0.0.2", Names: []string{"www.example.org", "web.example.org"}},
+		{Address: "fe80::1", Names: []string{"mail.example.org"}},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Wrong entries: %#v", entries)
+	}
+}
+
+func Test_ZoneConfig(t *testing.T) {
+	cfg := ZoneConfig(config.DNSConfig{
+		Origin:      "example.org",
+		Nameservers: []string{"ns1", "ns2.example.net."},
+		Hostmaster:  "dns.admin@example.org",
+	})
+	if cfg.Origin != "example.org." || cfg.TTL != DNS_DEFAULT_TTL || cfg.NameField != DNS_DEFAULT_NAME_FIELD {
+		t.Fatalf("Defaults not applied: %#v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Nameservers, []string{"ns1.example.org.", "ns2.example.net."}) {
+		t.Fatalf("Wrong nameservers: %#v", cfg.Nameservers)
+	}
+	if cfg.Hostmaster != `dns\.admin.example.org.` {
+		t.Fatalf("Wrong hostmaster: %s", cfg.Hostmaster)
+	}
+
+	if cfg = ZoneConfig(config.DNSConfig{Origin: "example.org."}); cfg.Hostmaster != "hostmaster.example.org." {
+		t.Fatalf("Wrong default hostmaster: %s", cfg.Hostmaster)
+	}
+}
+
+func Test_ZoneSerial(t *testing.T) {
+	if serial := ZoneSerial(testDNSAssets, 0); serial != 1400000300 {
+		t.Fatalf("Wrong serial: %d", serial)
+	}
+	if serial := ZoneSerial(testDNSAssets, 1400000900000); serial != 1400000900 {
+		t.Fatalf("Version timestamp not used: %d", serial)
+	}
+}
+
+func Test_ZoneName(t *testing.T) {
+	cases := map[string]string{
+		"":                 "@",
+		"@":                "@",
+		"example.org":      "@",
+		"www":              "www",
+		"www.example.org":  "www.example.org.",
+		"www.example.net.": "www.example.net.",
+	}
+	for name, expected := range cases {
+		if zn := ZoneName(name, "example.org."); zn != expected {
+			t.Fatalf("Wrong zone name for '%s': %s", name, zn)
+		}
+	}
+
+	if fqdn := FQDN("www", "example.org"); fqdn != "www.example.org." {
+		t.Fatalf("Wrong fqdn: %s", fqdn)
+	}
+	if host := HostName("@", "example.org."); host != "example.org" {
+		t.Fatalf("Wrong host name: %s", host)
+	}
+}
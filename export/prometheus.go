@@ -0,0 +1,178 @@
+package export
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+// Labels added to every target group.  Meta labels are available for relabeling only.
+const (
+	PROMETHEUS_LABEL_ID   = "__meta_vindalu_id"
+	PROMETHEUS_LABEL_TYPE = "__meta_vindalu_type"
+)
+
+var prometheusLabelInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Params of the target options that are not search filters
+var prometheusParams = []string{"target_field", "target_port", "label_fields"}
+
+// Target group of prometheus file_sd and http_sd
+type PrometheusTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+/*
+	Build prometheus target groups from assets, one per asset i.e.
+
+		[{"targets": ["web01.example.org:9100"], "labels": {"environment": "prod", "__meta_vindalu_id": "web01", ...}}]
+
+	Targets are the values of `TargetField`, or the asset id if not set.  Array fields give a target
+	per element.  `TargetPort` is appended to targets without a port.  Assets without targets are
+	skipped.  `LabelFields` are (dotted) fields added as labels named after the field, or the name
+	after a colon i.e. labels.env:env.  Arrays are joined with commas.
+*/
+func PrometheusTargets(assets []core.BaseAsset, cfg config.PrometheusConfig) []PrometheusTargetGroup {
+	groups := []PrometheusTargetGroup{}
+
+	for _, a := range assets {
+		var targets []string
+		if len(cfg.TargetField) == 0 {
+			targets = []string{a.Id}
+		} else {
+			v, _ := fieldValue(a.Data, cfg.TargetField)
+			targets = scalarValues(v)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		if cfg.TargetPort > 0 {
+			for i, t := range targets {
+				targets[i] = withPort(t, cfg.TargetPort)
+			}
+		}
+
+		labels := map[string]string{PROMETHEUS_LABEL_ID: a.Id, PROMETHEUS_LABEL_TYPE: a.Type}
+		for _, lf := range cfg.LabelFields {
+			field, name := lf, PrometheusLabelName(lf)
+			if i := strings.Index(lf, ":"); i >= 0 {
+				field, name = lf[:i], PrometheusLabelName(lf[i+1:])
+			}
+
+			v, _ := fieldValue(a.Data, field)
+			if vals := scalarValues(v); len(vals) > 0 {
+				labels[name] = strings.Join(vals, ",")
+			}
+		}
+
+		groups = append(groups, PrometheusTargetGroup{Targets: targets, Labels: labels})
+	}
+	return groups
+}
+
+/*
+	Prometheus targets of the assets matched by a search given as url params i.e.
+	environment=production&target_port=9100.  `filter` is applied over the filter in the params.
+	The `target_field`, `target_port` and `label_fields` params override the options in `cfg` and
+	are not used as filters.
+*/
+func QueryPrometheusTargets(vc *core.VindaluCore, cfg config.PrometheusConfig, assetType string, params url.Values,
+	filter map[string]interface{}) ([]PrometheusTargetGroup, error) {
+
+	cfg, err := PrometheusParamsConfig(cfg, params)
+	if err != nil {
+		return nil, err
+	}
+
+	assetType, query, qo, err := vc.QueryFromParams(assetType, PrometheusSearchParams(params), filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(qo.Aggregate) > 0 {
+		return nil, fmt.Errorf("Aggregation not supported for targets")
+	}
+
+	rsp, err := vc.ExecuteQuery(assetType, query, &qo)
+	if err != nil {
+		return nil, err
+	}
+	assets, _ := rsp.([]core.BaseAsset)
+	return PrometheusTargets(assets, cfg), nil
+}
+
+// Target options overridden by the `target_field`, `target_port` and `label_fields` params
+func PrometheusParamsConfig(cfg config.PrometheusConfig, params url.Values) (config.PrometheusConfig, error) {
+	if targetField := params.Get("target_field"); len(targetField) > 0 {
+		cfg.TargetField = targetField
+	}
+	if port := params.Get("target_port"); len(port) > 0 {
+		var err error
+		if cfg.TargetPort, err = strconv.Atoi(port); err != nil || cfg.TargetPort < 1 || cfg.TargetPort > 65535 {
+			return cfg, fmt.Errorf("Invalid target port: %s", port)
+		}
+	}
+
+	var labelFields []string
+	for _, v := range params["label_fields"] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); len(f) > 0 {
+				labelFields = append(labelFields, f)
+			}
+		}
+	}
+	if len(labelFields) > 0 {
+		cfg.LabelFields = labelFields
+	}
+	return cfg, nil
+}
+
+// Search params without the target options
+func PrometheusSearchParams(params url.Values) url.Values {
+	search := url.Values{}
+	for k, v := range params {
+		search[k] = v
+	}
+	for _, k := range prometheusParams {
+		search.Del(k)
+	}
+	return search
+}
+
+// String forms of a scalar or the scalar elements of an array.  Empty strings are skipped.
+func scalarValues(v interface{}) (vals []string) {
+	switch v.(type) {
+	case string, float64, bool:
+		if s := csvValue(v); len(s) > 0 {
+			vals = append(vals, s)
+		}
+	case []interface{}:
+		for _, item := range v.([]interface{}) {
+			vals = append(vals, scalarValues(item)...)
+		}
+	}
+	return
+}
+
+// Append the port to the target if it does not have one
+func withPort(target string, port int) string {
+	if _, _, err := net.SplitHostPort(target); err == nil {
+		return target
+	}
+	return net.JoinHostPort(strings.Trim(target, "[]"), strconv.Itoa(port))
+}
+
+// Replace characters not allowed in prometheus label names with '_'
+func PrometheusLabelName(name string) string {
+	name = prometheusLabelInvalidChars.ReplaceAllString(name, "_")
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+func Test_PrometheusTargets(t *testing.T) {
+	assets := []core.BaseAsset{
+		{Id: "web01", Type: "server", Data: map[string]interface{}{
+			"hostname":    "web01.example.org",
+			"environment": "prod",
+			"roles":       []interface{}{"web", "cache"},
+			"labels":      map[string]interface{}{"tier": "frontend"},
+		}},
+		{Id: "db01", Type: "server", Data: map[string]interface{}{
+			"ips": []interface{}{"10.0.0.1", "fe80::1", "10.0.0.2:9200"},
+		}},
+		{Id: "old01", Type: "server", Data: map[string]interface{}{}},
+	}
+
+	groups := PrometheusTargets(assets, config.PrometheusConfig{
+		TargetField: "hostname",
+		TargetPort:  9100,
+		LabelFields: []string{"environment", "roles", "labels.tier:tier"},
+	})
+	expected := []PrometheusTargetGroup{{
+		Targets: []string{"web01.example.org:9100"},
+		Labels: map[string]string{
+			PROMETHEUS_LABEL_ID: "web01", PROMETHEUS_LABEL_TYPE: "server",
+			"environment": "prod", "roles": "web,cache", "tier": "frontend",
+		},
+	}}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("Wrong targets: %#v", groups)
+	}
+
+	groups = PrometheusTargets(assets, config.PrometheusConfig{TargetField: "ips", TargetPort: 9100})
+	if len(groups) != 1 || !reflect.DeepEqual(groups[0].Targets, []string{"10.0.0.1:9100", "[fe80::1]:9100", "10.0.0.2:9200"}) {
+		t.Fatalf("Wrong array targets: %#v", groups)
+	}
+
+	if groups = PrometheusTargets(assets, config.PrometheusConfig{}); len(groups) != 3 || groups[2].Targets[0] != "old01" {
+		t.Fatalf("Targets should default to the id: %#v", groups)
+	}
+}
+
+func Test_PrometheusLabelName(t *testing.T) {
+	if name := PrometheusLabelName("labels.example.org/team"); name != "labels_example_org_team" {
+		t.Fatalf("Wrong label name: %s", name)
+	}
+	if name := PrometheusLabelName("1st"); name != "_1st" {
+		t.Fatalf("Label names cannot start with a digit: %s", name)
+	}
+}
+
+func Test_PrometheusParamsConfig(t *testing.T) {
+	base := config.PrometheusConfig{TargetField: "hostname", TargetPort: 9100, LabelFields: []string{"status"}}
+
+	params, _ := url.ParseQuery("target_port=9200&label_fields=labels.env:env,role&label_fields=dc")
+	cfg, err := PrometheusParamsConfig(base, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.TargetField != "hostname" || cfg.TargetPort != 9200 || len(cfg.LabelFields) != 3 || cfg.LabelFields[2] != "dc" {
+		t.Fatalf("Wrong config: %#v", cfg)
+	}
+	if base.TargetPort != 9100 || len(base.LabelFields) != 1 {
+		t.Fatalf("Base config modified: %#v", base)
+	}
+
+	params, _ = url.ParseQuery("target_port=99999")
+	if _, err = PrometheusParamsConfig(base, params); err == nil {
+		t.Fatalf("Should fail on invalid port")
+	}
+}
+
+func Test_PrometheusSearchParams(t *testing.T) {
+	params, _ := url.ParseQuery("environment=production&target_field=hostname&target_port=9100&label_fields=role")
+	search := PrometheusSearchParams(params)
+	if len(search) != 1 || search.Get("environment") != "production" {
+		t.Fatalf("Target options should be excluded: %v", search)
+	}
+	if params.Get("target_port") != "9100" {
+		t.Fatalf("Params should not be modified: %v", params)
+	}
+}
@@ -0,0 +1,186 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+// Names of the built-in templates
+const (
+	TEMPLATE_ZONE  = "zone"
+	TEMPLATE_HOSTS = "hosts"
+)
+
+// BIND zone file.  Requires the origin and at least one name server.
+const BIND_ZONE_TEMPLATE = `{{with .DNS}}$ORIGIN {{.Origin}}
+$TTL {{.TTL}}
+@	IN	SOA	{{index .Nameservers 0}} {{.Hostmaster}} (
+		{{$.Serial}}	; serial
+		{{.Refresh}}	; refresh
+		{{.Retry}}	; retry
+		{{.Expire}}	; expire
+		{{.Minimum}} )	; minimum
+{{range .Nameservers}}@	IN	NS	{{.}}
+{{end}}{{end}}
+{{range .Records}}{{.Name}}	{{if .TTL}}{{.TTL}}{{end}}	IN	{{.Type}}	{{.Value}}
+{{end}}`
+
+// /etc/hosts file
+const HOSTS_TEMPLATE = `# Generated by vindalu (serial {{.Serial}})
+{{range .Hosts}}{{.Address}}	{{join .Names " "}}
+{{end}}`
+
+// Built-in templates by name.  Configured templates with the same name take precedence.
+var BUILTIN_TEMPLATES = map[string]string{
+	TEMPLATE_ZONE:  BIND_ZONE_TEMPLATE,
+	TEMPLATE_HOSTS: HOSTS_TEMPLATE,
+}
+
+/*
+	Functions available to templates in addition to the text/template builtins:
+
+		field     (dotted) field of an asset i.e. {{field . "labels.env"}}
+		values    string forms of a scalar or array i.e. {{join (values (field . "roles")) ","}}
+		join      strings.Join
+		lower     strings.ToLower
+		upper     strings.ToUpper
+		fqdn      fully qualified name with a trailing dot i.e. {{fqdn "www" "example.org"}}
+		hostname  fully qualified name without the trailing dot
+		default   the first argument if the second is empty i.e. {{default "-" (field . "owner")}}
+		json      value as json
+*/
+var TemplateFuncs = template.FuncMap{
+	"field":    templateField,
+	"values":   scalarValues,
+	"join":     strings.Join,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"fqdn":     FQDN,
+	"hostname": HostName,
+	"default":  templateDefault,
+	"json":     templateJSON,
+}
+
+// Data templates are executed with
+type TemplateData struct {
+	Assets []core.BaseAsset
+	// Resource records built from the assets
+	Records []DNSRecord
+	// Hosts file entries built from the records
+	Hosts []HostsEntry
+	// Zone serial derived from the latest change
+	Serial int64
+	// Zone config with defaults applied
+	DNS config.DNSConfig
+	// Request params (first value of each)
+	Params map[string]string
+}
+
+// Template data for query results.  `latestVersion` is the most recent version timestamp (ms).
+func NewTemplateData(assets []core.BaseAsset, cfg config.DNSConfig, latestVersion float64, params map[string]string) TemplateData {
+	cfg = ZoneConfig(cfg)
+	records := DNSRecords(assets, cfg)
+
+	return TemplateData{
+		Assets:  assets,
+		Records: records,
+		Hosts:   HostsEntries(records, cfg.Origin),
+		Serial:  ZoneSerial(assets, latestVersion),
+		DNS:     cfg,
+		Params:  params,
+	}
+}
+
+// Maximum nesting of {{template}} invocations.  Recursive templates are rejected.
+const MAX_TEMPLATE_DEPTH = 10
+
+/*
+	Parse a text/template with the template functions and execute it with the given data.
+	Templates invoking themselves, directly or through others, or nesting invocations deeper
+	than MAX_TEMPLATE_DEPTH are rejected before being executed.
+*/
+func ExecuteTemplate(w io.Writer, name, text string, data interface{}) error {
+	t, err := template.New(name).Funcs(TemplateFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+	if err = checkTemplateDepth(t, name, map[string]bool{}, 0); err != nil {
+		return err
+	}
+	return t.Execute(w, data)
+}
+
+// Follow the {{template}} invocations of the named template checking for cycles and depth
+func checkTemplateDepth(t *template.Template, name string, active map[string]bool, depth int) error {
+	if depth > MAX_TEMPLATE_DEPTH {
+		return fmt.Errorf("Template nesting exceeds %d", MAX_TEMPLATE_DEPTH)
+	}
+	if active[name] {
+		return fmt.Errorf("Recursive template: %s", name)
+	}
+
+	tmpl := t.Lookup(name)
+	if tmpl == nil || tmpl.Tree == nil {
+		// Undefined templates fail on execution
+		return nil
+	}
+
+	active[name] = true
+	defer delete(active, name)
+	for _, called := range templateCalls(tmpl.Tree.Root, nil) {
+		if err := checkTemplateDepth(t, called, active, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Names of the templates invoked by a node and its children
+func templateCalls(node parse.Node, names []string) []string {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, c := range n.Nodes {
+				names = templateCalls(c, names)
+			}
+		}
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	case *parse.IfNode:
+		names = templateCalls(n.ElseList, templateCalls(n.List, names))
+	case *parse.RangeNode:
+		names = templateCalls(n.ElseList, templateCalls(n.List, names))
+	case *parse.WithNode:
+		names = templateCalls(n.ElseList, templateCalls(n.List, names))
+	}
+	return names
+}
+
+func templateField(a core.BaseAsset, field string) interface{} {
+	v, _ := fieldValue(a.Data, field)
+	return v
+}
+
+func templateDefault(def, v interface{}) interface{} {
+	switch v.(type) {
+	case nil:
+		return def
+	case string:
+		if len(v.(string)) == 0 {
+			return def
+		}
+	}
+	return v
+}
+
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/vindalu/vindalu/config"
+)
+
+func Test_ExecuteTemplate_Zone(t *testing.T) {
+	cfg := config.DNSConfig{Origin: "example.org", Nameservers: []string{"ns1", "ns2"}}
+	data := NewTemplateData(testDNSAssets, cfg, 0, nil)
+
+	var out bytes.Buffer
+	if err := ExecuteTemplate(&out, TEMPLATE_ZONE, BIND_ZONE_TEMPLATE, data); err != nil {
+		t.Fatal(err)
+	}
+	zone := out.String()
+	t.Log(zone)
+
+	for _, line := range []string{
+		"$ORIGIN example.org.",
+		"$TTL 3600",
+		"@\tIN\tSOA\tns1.example.org. hostmaster.example.org. (",
+		"\t\t1400000300\t; serial",
+		"@\tIN\tNS\tns2.example.org.",
+		"www\t300\tIN\tA\t10.0.0.2",
+		"mail.example.org.\t\tIN\tAAAA\tfe80::1",
+		"web\t\tIN\tCNAME\twww.example.org.",
+		"@\t\tIN\tTXT\t\"v=spf1 mx -all\"",
+	} {
+		if !strings.Contains(zone, line+"\n") {
+			t.Fatalf("Missing line: %q", line)
+		}
+	}
+}
+
+func Test_ExecuteTemplate_Hosts(t *testing.T) {
+	data := NewTemplateData(testDNSAssets, config.DNSConfig{Origin: "example.org"}, 0, nil)
+
+	var out bytes.Buffer
+	if err := ExecuteTemplate(&out, TEMPLATE_HOSTS, HOSTS_TEMPLATE, data); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Generated by vindalu (serial 1400000300)\n" +
+		"10.0.0.1\twww.example.org web.example.org\n" +
+		"10.0.0.2\twww.example.org web.example.org\n" +
+		"fe80::1\tmail.example.org\n"
+	if out.String() != expected {
+		t.Fatalf("Wrong hosts file:\n%s", out.String())
+	}
+}
+
+func Test_ExecuteTemplate_Funcs(t *testing.T) {
+	data := NewTemplateData(testDNSAssets[:2], config.DNSConfig{}, 0, map[string]string{"sep": ","})
+
+	tmplt := `{{range .Assets}}{{.Id}} {{join (values (field . "value")) $.Params.sep}} ` +
+		`{{default "-" (field . "name")}} {{upper .Type}}{{"\n"}}{{end}}`
+
+	var out bytes.Buffer
+	if err := ExecuteTemplate(&out, "test", tmplt, data); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "www 10.0.0.1,10.0.0.2 - DNSRECORD\nmail fe80::1 mail.example.org DNSRECORD\n"; out.String() != expected {
+		t.Fatalf("Wrong output:\n%s", out.String())
+	}
+
+	if err := ExecuteTemplate(&out, "test", "{{range .Assets}", data); err == nil {
+		t.Fatal("Invalid template should fail")
+	}
+}
+
+func Test_ExecuteTemplate_Recursion(t *testing.T) {
+	var out bytes.Buffer
+	data := NewTemplateData(nil, config.DNSConfig{}, 0, nil)
+
+	if err := ExecuteTemplate(&out, "test", `{{define "a"}}{{template "a" .}}{{end}}{{template "a" .}}`, data); err == nil {
+		t.Fatal("Recursive template should fail")
+	}
+	if err := ExecuteTemplate(&out, "test", `{{define "a"}}{{if .}}{{template "b" .}}{{end}}{{end}}`+
+		`{{define "b"}}{{range .Assets}}{{else}}{{template "a" .}}{{end}}{{end}}{{template "a" .}}`, data); err == nil {
+		t.Fatal("Indirectly recursive template should fail")
+	}
+
+	out.Reset()
+	if err := ExecuteTemplate(&out, "test", `{{define "a"}}x{{end}}{{template "a"}}{{template "a"}}`, data); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "xx" {
+		t.Fatalf("Wrong output: %s", out.String())
+	}
+}
@@ -0,0 +1,188 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Maximum nesting of decoded arrays and objects
+const MAX_DECODE_DEPTH = 100
+
+/*
+	Convert a JSON encodable value to the generic tree of map[string]interface{}, []interface{},
+	strings, booleans, nil and json.Number the encoders in this package work with.  Going through
+	JSON honors json struct tags and custom marshalers.
+*/
+func normalize(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var out interface{}
+	err = dec.Decode(&out)
+	return out, err
+}
+
+// Integer value of the number if it has no fractional part and fits in an int64
+func numberInt(n json.Number) (int64, bool) {
+	if i, err := n.Int64(); err == nil {
+		return i, true
+	}
+	if f, err := n.Float64(); err == nil && f == math.Trunc(f) && math.Abs(f) < (1<<63) {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// Replace the json.Numbers of a normalized value with an int64 if integral or float64 otherwise
+func nativeNumbers(v interface{}) interface{} {
+	switch v.(type) {
+	case json.Number:
+		if i, ok := numberInt(v.(json.Number)); ok {
+			return i
+		}
+		f, _ := v.(json.Number).Float64()
+		return f
+	case []interface{}:
+		arr := v.([]interface{})
+		for i, item := range arr {
+			arr[i] = nativeNumbers(item)
+		}
+	case map[string]interface{}:
+		obj := v.(map[string]interface{})
+		for k, item := range obj {
+			obj[k] = nativeNumbers(item)
+		}
+	}
+	return v
+}
+
+/*
+	Convert a value decoded by a yaml or msgpack library to the types encoding/json decodes to.
+	Numbers become float64, binary data strings and number or boolean map keys their string form.
+	Other map keys, non-finite numbers and types without a JSON equivalent are rejected.
+*/
+func jsonValue(v interface{}, depth int) (interface{}, error) {
+	if depth > MAX_DECODE_DEPTH {
+		return nil, fmt.Errorf("nesting deeper than %d", MAX_DECODE_DEPTH)
+	}
+
+	switch v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case []byte:
+		return string(v.([]byte)), nil
+	case []interface{}:
+		arr := make([]interface{}, len(v.([]interface{})))
+		for i, item := range v.([]interface{}) {
+			jv, err := jsonValue(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = jv
+		}
+		return arr, nil
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(v.(map[interface{}]interface{})))
+		for k, item := range v.(map[interface{}]interface{}) {
+			key, err := jsonKey(k)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := obj[key]; dup {
+				return nil, fmt.Errorf("duplicate map key '%s'", key)
+			}
+			if obj[key], err = jsonValue(item, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case map[string]interface{}:
+		obj := v.(map[string]interface{})
+		for k, item := range obj {
+			jv, err := jsonValue(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = jv
+		}
+		return obj, nil
+	}
+
+	f, ok := jsonNumber(v)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("unsupported number %v", f)
+	}
+	return f, nil
+}
+
+// Number as a float64
+func jsonNumber(v interface{}) (float64, bool) {
+	switch v.(type) {
+	case int:
+		return float64(v.(int)), true
+	case int8:
+		return float64(v.(int8)), true
+	case int16:
+		return float64(v.(int16)), true
+	case int32:
+		return float64(v.(int32)), true
+	case int64:
+		return float64(v.(int64)), true
+	case uint:
+		return float64(v.(uint)), true
+	case uint8:
+		return float64(v.(uint8)), true
+	case uint16:
+		return float64(v.(uint16)), true
+	case uint32:
+		return float64(v.(uint32)), true
+	case uint64:
+		return float64(v.(uint64)), true
+	case float32:
+		return float64(v.(float32)), true
+	case float64:
+		return v.(float64), true
+	}
+	return 0, false
+}
+
+// String form of a map key
+func jsonKey(k interface{}) (string, error) {
+	switch k.(type) {
+	case string:
+		return k.(string), nil
+	case bool:
+		return strconv.FormatBool(k.(bool)), nil
+	}
+	if f, ok := jsonNumber(k); ok {
+		return formatFloat(f), nil
+	}
+	return "", fmt.Errorf("map keys must be strings, numbers or booleans")
+}
+
+// String form of a float matching encoding/json
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Keys of the object in alphabetical order so output is stable
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,77 @@
+package formats
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type testAsset struct {
+	Id   string                 `json:"id"`
+	Data map[string]interface{} `json:"data"`
+}
+
+var testValue = []testAsset{
+	{Id: "web01", Data: map[string]interface{}{
+		"cpus":    4,
+		"load":    0.5,
+		"enabled": true,
+		"owner":   nil,
+		"roles":   []interface{}{"web", "true", []interface{}{}},
+		"labels":  map[string]interface{}{"env": "prod", "example.org/team": "web", "item": "1"},
+		"comment": "multi\nline: text # not a comment",
+		"empty":   map[string]interface{}{},
+	}},
+}
+
+// testValue as decoded from JSON
+var testDecoded = []interface{}{
+	map[string]interface{}{
+		"id": "web01",
+		"data": map[string]interface{}{
+			"cpus":    4.0,
+			"load":    0.5,
+			"enabled": true,
+			"owner":   nil,
+			"roles":   []interface{}{"web", "true", []interface{}{}},
+			"labels":  map[string]interface{}{"env": "prod", "example.org/team": "web", "item": "1"},
+			"comment": "multi\nline: text # not a comment",
+			"empty":   map[string]interface{}{},
+		},
+	},
+}
+
+func Test_normalize(t *testing.T) {
+	v, err := normalize(testValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset := v.([]interface{})[0].(map[string]interface{})
+	if asset["id"] != "web01" {
+		t.Fatalf("Struct tags not honored: %v", asset)
+	}
+	if n := asset["data"].(map[string]interface{})["cpus"]; n != json.Number("4") {
+		t.Fatalf("Numbers should be kept as json.Number: %#v", n)
+	}
+}
+
+func Test_numberInt(t *testing.T) {
+	if i, ok := numberInt(json.Number("4.0")); !ok || i != 4 {
+		t.Fatalf("Integral float should be an integer")
+	}
+	if _, ok := numberInt(json.Number("0.5")); ok {
+		t.Fatalf("Fraction should not be an integer")
+	}
+}
+
+// Raw JSON to encode
+func rawJSON(s string) *json.RawMessage {
+	raw := json.RawMessage(s)
+	return &raw
+}
+
+func assertDecoded(t *testing.T, format string, v interface{}) {
+	if !reflect.DeepEqual(v, testDecoded) {
+		t.Fatalf("Wrong %s round trip:\n%#v\n%#v", format, v, testDecoded)
+	}
+}
@@ -0,0 +1,86 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/vmihailenco/msgpack.v2"
+)
+
+/*
+	Encode a value as MessagePack.  Integral numbers are written as the smallest fitting integer
+	type, all other numbers as float64.  Object keys are sorted.
+*/
+func EncodeMsgpack(v interface{}) ([]byte, error) {
+	nv, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = msgpack.NewEncoder(&buf).SortMapKeys(true).Encode(nativeNumbers(nv)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+	Decode a MessagePack value.  Numbers are decoded as float64, binary data as strings and number
+	map keys are converted to strings.  Extension types, other map keys, duplicate keys and trailing
+	data are rejected with an error as they have no JSON equivalent.
+*/
+func DecodeMsgpack(data []byte) (interface{}, error) {
+	r := bytes.NewReader(data)
+
+	dec := msgpack.NewDecoder(r)
+	dec.DecodeMapFunc = decodeMsgpackMap
+
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, msgpackError(err)
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("Invalid msgpack: %d trailing bytes", r.Len())
+	}
+
+	jv, err := jsonValue(v, 0)
+	if err != nil {
+		return nil, msgpackError(err)
+	}
+	return jv, nil
+}
+
+// Decode a map with string keys as the library's map decoding panics on unhashable keys
+func decodeMsgpackMap(d *msgpack.Decoder) (interface{}, error) {
+	n, err := d.DecodeMapLen()
+	if err != nil || n == -1 {
+		return nil, err
+	}
+
+	obj := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		k, err := d.DecodeInterface()
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := k.([]byte); ok {
+			k = string(b)
+		}
+		key, err := jsonKey(k)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := obj[key]; dup {
+			return nil, fmt.Errorf("duplicate map key '%s'", key)
+		}
+		if obj[key], err = d.DecodeInterface(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+func msgpackError(err error) error {
+	return fmt.Errorf("Invalid msgpack: %s", strings.TrimPrefix(err.Error(), "msgpack: "))
+}
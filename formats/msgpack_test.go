@@ -0,0 +1,65 @@
+package formats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Msgpack_RoundTrip(t *testing.T) {
+	b, err := EncodeMsgpack(testValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := DecodeMsgpack(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertDecoded(t, "msgpack", v)
+}
+
+func Test_EncodeMsgpack(t *testing.T) {
+	for in, out := range map[string][]byte{
+		`1`:             {0x01},
+		`-1`:            {0xff},
+		`4.0`:           {0x04},
+		`200`:           {0xcc, 0xc8},
+		`-200`:          {0xd1, 0xff, 0x38},
+		`70000`:         {0xce, 0x00, 0x01, 0x11, 0x70},
+		`1.5`:           {0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+		`"a"`:           {0xa1, 'a'},
+		`[true,null]`:   {0x92, 0xc3, 0xc0},
+		`{"b":1,"a":2}`: {0x82, 0xa1, 'a', 0x02, 0xa1, 'b', 0x01},
+	} {
+		b, err := EncodeMsgpack(rawJSON(in))
+		if err != nil || !bytes.Equal(b, out) {
+			t.Fatalf("Wrong encoding of %s: %x %v", in, b, err)
+		}
+	}
+}
+
+func Test_DecodeMsgpack(t *testing.T) {
+	// uint8, float32, bin8 and maps with integer and boolean keys
+	v, err := DecodeMsgpack([]byte{0x85, 0xa1, 'a', 0xcc, 0xff, 0xa1, 'b', 0xca, 0x3f, 0xc0, 0, 0,
+		0xa1, 'c', 0xc4, 0x02, 'h', 'i', 0x01, 0xc2, 0xc3, 0x81, 0xc4, 0x01, 'd', 0x90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := v.(map[string]interface{})
+	if obj["a"] != 255.0 || obj["b"] != 1.5 || obj["c"] != "hi" || obj["1"] != false {
+		t.Fatalf("Wrong decoded value: %#v", obj)
+	}
+	if nested := obj["true"].(map[string]interface{}); len(nested["d"].([]interface{})) != 0 {
+		t.Fatalf("Wrong nested value: %#v", nested)
+	}
+
+	for _, b := range [][]byte{{}, {0xa2, 'a'}, {0xdc, 0xff, 0xff}, {0xc7, 0x01, 0x01, 0x00}, {0x01, 0x02},
+		{0xd4, 0x01, 0x00}, {0x81, 0x90, 0x01}, {0x81, 0x80, 0x01}, {0x82, 0xa1, 'a', 0x01, 0xa1, 'a', 0x02},
+		{0xcb, 0x7f, 0xf0, 0, 0, 0, 0, 0, 0}} {
+		if _, err := DecodeMsgpack(b); err == nil {
+			t.Fatalf("Should fail on %x", b)
+		} else if !strings.HasPrefix(err.Error(), "Invalid msgpack: ") {
+			t.Fatalf("Wrong error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Element names used in the XML form of values
+const (
+	// Document element of responses
+	XML_ROOT = "response"
+	// Array items
+	XML_ITEM = "item"
+	// Object fields whose key is not a valid element name i.e. <field name="a key">
+	XML_FIELD = "field"
+)
+
+// Values of the `type` attribute marking values that are not strings
+const (
+	XML_TYPE_NUMBER  = "number"
+	XML_TYPE_BOOLEAN = "boolean"
+	XML_TYPE_NULL    = "null"
+	XML_TYPE_ARRAY   = "array"
+	XML_TYPE_OBJECT  = "object"
+)
+
+var xmlNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+/*
+	Encode a value as an XML document with a <response> root.  Object fields become elements named
+	after the key, array items <item> elements.  Numbers, booleans, nulls and empty collections are
+	marked with a `type` attribute so they decode back to the same value i.e.
+
+		<response><id>web01</id><cpus type="number">4</cpus><roles><item>web</item></roles></response>
+*/
+func EncodeXML(v interface{}) ([]byte, error) {
+	nv, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	if err = writeXML(enc, xml.StartElement{Name: xml.Name{Local: XML_ROOT}}, nv); err != nil {
+		return nil, err
+	}
+	if err = enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+func writeXML(enc *xml.Encoder, start xml.StartElement, v interface{}) error {
+	var (
+		text     string
+		typeAttr string
+	)
+	switch v.(type) {
+	case nil:
+		typeAttr = XML_TYPE_NULL
+	case bool:
+		typeAttr, text = XML_TYPE_BOOLEAN, strconv.FormatBool(v.(bool))
+	case json.Number:
+		typeAttr, text = XML_TYPE_NUMBER, v.(json.Number).String()
+	case string:
+		text = v.(string)
+	case []interface{}:
+		if len(v.([]interface{})) == 0 {
+			typeAttr = XML_TYPE_ARRAY
+		}
+	case map[string]interface{}:
+		if len(v.(map[string]interface{})) == 0 {
+			typeAttr = XML_TYPE_OBJECT
+		}
+	default:
+		return fmt.Errorf("Cannot encode %T as xml", v)
+	}
+
+	if len(typeAttr) > 0 {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: typeAttr})
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v.(type) {
+	case []interface{}:
+		for _, item := range v.([]interface{}) {
+			if err := writeXML(enc, xml.StartElement{Name: xml.Name{Local: XML_ITEM}}, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		obj := v.(map[string]interface{})
+		for _, k := range sortedKeys(obj) {
+			if err := writeXML(enc, xmlFieldElement(k), obj[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		if len(text) > 0 {
+			if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// Element for an object field.  Keys that are not valid or reserved names use <field name="...">.
+func xmlFieldElement(key string) xml.StartElement {
+	lower := strings.ToLower(key)
+	if xmlNameRegex.MatchString(key) && key != XML_ITEM && key != XML_FIELD && !strings.HasPrefix(lower, "xml") {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{
+		Name: xml.Name{Local: XML_FIELD},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: key}},
+	}
+}
+
+/*
+	Decode an XML document in the form written by EncodeXML.  The name of the document element is
+	ignored.  Without a `type` attribute, elements with child elements decode to arrays if all
+	children are <item> elements and to objects otherwise.  Elements without children decode to
+	strings.  Numbers are decoded as float64.
+*/
+func DecodeXML(data []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("Invalid XML: document element required")
+			}
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return readXML(dec, start, 0)
+		}
+	}
+}
+
+type xmlChild struct {
+	name  string
+	value interface{}
+}
+
+func readXML(dec *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > MAX_DECODE_DEPTH {
+		return nil, fmt.Errorf("Invalid XML: nesting deeper than %d", MAX_DECODE_DEPTH)
+	}
+
+	var (
+		text     bytes.Buffer
+		children []xmlChild
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.(type) {
+		case xml.StartElement:
+			child := tok.(xml.StartElement)
+			v, err := readXML(dec, child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name := child.Name.Local
+			if name == XML_FIELD {
+				if key, ok := xmlAttr(child, "name"); ok {
+					name = key
+				}
+			}
+			children = append(children, xmlChild{name, v})
+		case xml.CharData:
+			text.Write(tok.(xml.CharData))
+		case xml.EndElement:
+			return xmlValue(start, text.String(), children)
+		}
+	}
+}
+
+func xmlAttr(el xml.StartElement, name string) (string, bool) {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func xmlValue(start xml.StartElement, text string, children []xmlChild) (interface{}, error) {
+	typeAttr, _ := xmlAttr(start, "type")
+
+	switch typeAttr {
+	case XML_TYPE_NULL:
+		return nil, nil
+	case XML_TYPE_BOOLEAN:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid XML: <%s> must be a boolean", start.Name.Local)
+		}
+		return b, nil
+	case XML_TYPE_NUMBER:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid XML: <%s> must be a number", start.Name.Local)
+		}
+		return f, nil
+	case XML_TYPE_ARRAY:
+		return xmlArray(children), nil
+	case XML_TYPE_OBJECT:
+		return xmlObject(children), nil
+	case "", "string":
+	default:
+		return nil, fmt.Errorf("Invalid XML: unsupported type '%s'", typeAttr)
+	}
+
+	if len(children) == 0 {
+		return text, nil
+	}
+	for _, c := range children {
+		if c.name != XML_ITEM {
+			return xmlObject(children), nil
+		}
+	}
+	return xmlArray(children), nil
+}
+
+func xmlArray(children []xmlChild) []interface{} {
+	arr := make([]interface{}, len(children))
+	for i, c := range children {
+		arr[i] = c.value
+	}
+	return arr
+}
+
+func xmlObject(children []xmlChild) map[string]interface{} {
+	obj := make(map[string]interface{}, len(children))
+	for _, c := range children {
+		obj[c.name] = c.value
+	}
+	return obj
+}
@@ -0,0 +1,59 @@
+package formats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_XML_RoundTrip(t *testing.T) {
+	b, err := EncodeXML(testValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := DecodeXML(b)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, b)
+	}
+	assertDecoded(t, "xml", v)
+}
+
+func Test_EncodeXML(t *testing.T) {
+	b, _ := EncodeXML(rawJSON(`{"id":"a<b","cpus":4,"roles":["web"],"a key":null}`))
+	expected := `<response><field name="a key" type="null"></field><cpus type="number">4</cpus>` +
+		`<id>a&lt;b</id><roles><item>web</item></roles></response>`
+	if !strings.HasPrefix(string(b), "<?xml") || !strings.Contains(string(b), expected) {
+		t.Fatalf("Wrong xml:\n%s", b)
+	}
+}
+
+func Test_DecodeXML(t *testing.T) {
+	doc := `<asset>
+	<status>enabled</status>
+	<cpus type="number"> 4 </cpus>
+	<roles><item>web</item><item>db</item></roles>
+	<location><dc>dc1</dc></location>
+	<field name="a key">value</field>
+</asset>`
+	v, err := DecodeXML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"status":   "enabled",
+		"cpus":     4.0,
+		"roles":    []interface{}{"web", "db"},
+		"location": map[string]interface{}{"dc": "dc1"},
+		"a key":    "value",
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("Wrong decoded value:\n%#v", v)
+	}
+
+	for _, doc := range []string{"", "<a><b>", `<a type="number">x</a>`, `<a type="date">x</a>`} {
+		if _, err = DecodeXML([]byte(doc)); err == nil {
+			t.Fatalf("Should fail on: %s", doc)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/*
+	Encode a value as a block style YAML document.  Object keys are sorted.  Strings that would be
+	read back as another type are quoted.
+*/
+func EncodeYAML(v interface{}) ([]byte, error) {
+	nv, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(nativeNumbers(nv))
+}
+
+/*
+	Decode a single YAML document.  Numbers are decoded as float64.  Duplicate keys, keys other than
+	scalars and multiple documents are rejected with an error.
+*/
+func DecodeYAML(data []byte) (interface{}, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.SetStrict(true)
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, yamlError(err)
+	}
+
+	var next interface{}
+	if err := dec.Decode(&next); err != io.EOF {
+		return nil, fmt.Errorf("Invalid YAML: multiple documents are not supported")
+	}
+
+	jv, err := jsonValue(v, 0)
+	if err != nil {
+		return nil, yamlError(err)
+	}
+	return jv, nil
+}
+
+func yamlError(err error) error {
+	return fmt.Errorf("Invalid YAML: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+}
@@ -0,0 +1,127 @@
+package formats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_YAML_RoundTrip(t *testing.T) {
+	b, err := EncodeYAML(testValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := DecodeYAML(b)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, b)
+	}
+	assertDecoded(t, "yaml", v)
+}
+
+func Test_EncodeYAML(t *testing.T) {
+	b, _ := EncodeYAML(rawJSON(`{"id":"web01","cpus":4,"load":0.5,"roles":["web","yes"],"disks":[{"size":10,"type":"ssd"}],"tags":[]}`))
+	expected := `cpus: 4
+disks:
+- size: 10
+  type: ssd
+id: web01
+load: 0.5
+roles:
+- web
+- "yes"
+tags: []
+`
+	if string(b) != expected {
+		t.Fatalf("Wrong yaml:\n%s", b)
+	}
+
+	// Strings read as other types by YAML 1.1 or 1.2 parsers must stay strings
+	for _, s := range []string{"", "1.5", "null", "~", "- a", "a: b", " a", "a\tb", "yes", "off", "012",
+		"1_000", "0b101", "0x1f", "12:30", ".inf", ".nan", "2015-10-21", "<<", "=", "#a", "10.0.0.1"} {
+		b, err := EncodeYAML(map[string]interface{}{"v": s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := DecodeYAML(b); err != nil || v.(map[string]interface{})["v"] != s {
+			t.Fatalf("Wrong round trip of '%s': %s %v", s, b, err)
+		}
+	}
+}
+
+func Test_DecodeYAML(t *testing.T) {
+	doc := `%YAML 1.1
+---
+# server
+id: web01
+cpus: 0x10
+load: .5
+enabled: True
+owner: ~
+roles: [web, 'db', "cache"]   # flow sequence
+labels: {env: prod,
+  team: web}
+disks:
+- size: 10
+  type: ssd
+- - nested
+notes: |
+  line one
+  line two
+
+summary: >-
+  folded
+  text
+"quoted key": it's
+description: a long
+  plain value
+1: number key
+...
+`
+	v, err := DecodeYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"id":          "web01",
+		"cpus":        16.0,
+		"load":        0.5,
+		"enabled":     true,
+		"owner":       nil,
+		"roles":       []interface{}{"web", "db", "cache"},
+		"labels":      map[string]interface{}{"env": "prod", "team": "web"},
+		"disks":       []interface{}{map[string]interface{}{"size": 10.0, "type": "ssd"}, []interface{}{"nested"}},
+		"notes":       "line one\nline two\n",
+		"summary":     "folded text",
+		"quoted key":  "it's",
+		"description": "a long plain value",
+		"1":           "number key",
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("Wrong decoded value:\n%#v", v)
+	}
+
+	if v, err = DecodeYAML([]byte("  \n# empty\n")); err != nil || v != nil {
+		t.Fatalf("Empty document should be null: %v %v", v, err)
+	}
+
+	for _, doc := range []string{
+		"a: 1\n  b: 2\n",
+		"a: 1\n\tb: 2\n",
+		"a: [1, 2\n",
+		"a: \"unterminated\n",
+		"a: 1\n---\nb: 2\n",
+		"- a\nb: 1\n",
+		"? [a, b]\n: 1\n",
+		"{[a, b]: 1}\n",
+		"a: 1\na: 2\n",
+		"a: {b: 1, b: 2}\n",
+		"a: .inf\n",
+	} {
+		if _, err = DecodeYAML([]byte(doc)); err == nil {
+			t.Fatalf("Should fail on:\n%s", doc)
+		} else if !strings.HasPrefix(err.Error(), "Invalid YAML: ") {
+			t.Fatalf("Wrong error: %s", err)
+		}
+	}
+}
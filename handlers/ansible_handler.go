@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+	"github.com/vindalu/vindalu/export"
+)
+
+/*
+	Render query results as an ansible dynamic inventory GET /<asset_type>/_ansible and
+	GET /search/_ansible
+
+	Takes the same filters and options as a search.  The `host_field` and `group_by` params
+	override the configured defaults.
+*/
+func (ir *VindaluApiHandler) AnsibleInventoryHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+		rsp       interface{}
+	)
+
+	assetType, userQuery, qo, err := ir.getQueryFromRequest(r, assetType, ansibleParams...)
+	if err == nil && len(qo.Aggregate) > 0 {
+		err = fmt.Errorf("Aggregation not supported for inventories")
+	}
+	if err == nil {
+		rsp, err = ir.ExecuteQuery(assetType, userQuery, &qo)
+	}
+
+	var inventory map[string]interface{}
+	if err == nil {
+		assets, _ := rsp.([]core.BaseAsset)
+		inventory, err = export.AnsibleInventory(assets, ir.ansibleConfig(r))
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(inventory)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+// Params of the ansible inventory that are not search filters
+var ansibleParams = []string{"host_field", "group_by"}
+
+// Configured ansible inventory options overridden by the request params
+func (ir *VindaluApiHandler) ansibleConfig(r *http.Request) config.AnsibleConfig {
+	cfg := ir.Config().Ansible
+	if hostField := r.URL.Query().Get("host_field"); len(hostField) > 0 {
+		cfg.HostField = hostField
+	}
+	if groupBy := listParam(r, "group_by"); len(groupBy) > 0 {
+		cfg.GroupBy = groupBy
+	}
+	return cfg
+}
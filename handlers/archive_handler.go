@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/context"
+)
+
+/*
+	Export the complete inventory as a tar archive GET /_archive (admin only)
+
+	The archive is gzip compressed if the `compress` param is set.
+*/
+func (ir *VindaluApiHandler) ArchiveExportHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		reqUser  = context.Get(r, Username).(string)
+		isAdmin  = context.Get(r, IsAdmin).(bool)
+		compress = isParamEnabled(r, "compress")
+
+		filename    = fmt.Sprintf("vindalu-%s.tar", time.Now().UTC().Format("20060102T150405Z"))
+		contentType = "application/x-tar"
+	)
+	if compress {
+		filename, contentType = filename+".gz", "application/gzip"
+	}
+
+	// The status is only written once the archive has been read from the datastore
+	aw := &archiveResponseWriter{ResponseWriter: w}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	var out io.Writer = aw
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(aw)
+		out = gz
+	}
+
+	_, err := ir.ExportArchive(out, reqUser, isAdmin)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+
+	if err != nil && aw.written == 0 {
+		w.Header().Del("Content-Disposition")
+		code, headers, data := errorResponse(err, 500)
+		ir.writeAndLogResponse(w, r, code, headers, data)
+		return
+	}
+	if err != nil {
+		ir.apiLog.Errorf("Archive export failed: %s\n", err)
+	}
+	ir.apiLog.Noticef("%s %s %d %s %d\n", r.RemoteAddr, r.Method, 200, r.RequestURI, aw.written)
+}
+
+/*
+	Restore a tar archive, optionally gzip compressed, into an empty datastore POST /_archive
+	(admin only)
+*/
+func (ir *VindaluApiHandler) ArchiveImportHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		headers = map[string]string{}
+		code    int
+		data    []byte
+
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	manifest, err := ir.ImportArchive(r.Body, reqUser, isAdmin)
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(manifest)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+// Writes the status on the first write so errors before then can still be returned
+type archiveResponseWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (aw *archiveResponseWriter) Write(b []byte) (int, error) {
+	if aw.written == 0 {
+		aw.ResponseWriter.WriteHeader(200)
+	}
+	n, err := aw.ResponseWriter.Write(b)
+	aw.written += int64(n)
+	return n, err
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
 	"strconv"
 	"strings"
 
@@ -139,14 +141,53 @@ func (ir *VindaluApiHandler) assetPostPutHandler(assetType, assetId, reqUser str
 	return
 }
 
+/*
+	Create asset with a server generated id POST /<asset_type>/_new.  Only for types with id
+	generation configured.  The location of the new asset is returned in the Location header.
+*/
+func (ir *VindaluApiHandler) AssetCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		reqUser     = context.Get(r, Username).(string)
+		isAdmin     = context.Get(r, IsAdmin).(bool)
+		assetType   = normalizeAssetType(mux.Vars(r)["asset_type"])
+		_, isImport = r.URL.Query()["import"]
+	)
+
+	reqData, err := parseRequestBody(r)
+	if err == nil {
+		var id string
+		if id, err = ir.CreateAsset(core.BaseAsset{Type: assetType, Data: reqData}, reqUser, isAdmin, isImport); err == nil {
+			code = 201
+			headers["Content-Type"] = "application/json"
+			headers["Location"] = newAssetLocation(r.URL.Path, id)
+			data = []byte(`{"id": "` + id + `"}`)
+		}
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+// Location of an asset created at /<asset_type>/_new
+func newAssetLocation(createPath, id string) string {
+	return (&url.URL{Path: path.Dir(strings.TrimSuffix(createPath, "/")) + "/" + id}).String()
+}
+
 func (ir *VindaluApiHandler) assetDeleteHandler(assetType, assetId, reqUser string) (code int, headers map[string]string, data []byte) {
 	// Remove asset providing the user so versions index can be updated.
 	// This allows us to track the person deleting the asset.
 	updatedBy := map[string]interface{}{"updated_by": reqUser}
 	err := ir.RemoveAsset(assetType, assetId, updatedBy)
 	if err != nil {
-		code, data = 500, []byte(err.Error())
-		headers = map[string]string{"Content-Type": "text/plain"}
+		code, headers, data = errorResponse(err, 500)
 	} else {
 		code, data = 200, []byte(fmt.Sprintf(`{"id":"%s"}`, assetId))
 		headers = map[string]string{"Content-Type": "application/json"}
@@ -181,9 +222,7 @@ func (ir *VindaluApiHandler) AssetWriteRequestHandler(w http.ResponseWriter, r *
 
 		var id string
 		if id, err = ir.assetPostPutHandler(assetType, assetId, reqUser, reqData, r); err != nil {
-			code = 400
-			headers = map[string]string{"Content-Type": "text/plain"}
-			data = []byte(err.Error())
+			code, headers, data = errorResponse(err, 400)
 		} else {
 			code = 200
 			headers = map[string]string{"Content-Type": "application/json"}
@@ -265,13 +304,160 @@ func (ir *VindaluApiHandler) AssetVersionsHandler(w http.ResponseWriter, r *http
 	ir.writeAndLogResponse(w, r, code, headers, data)
 }
 
+/*
+   Handle getting links referencing an asset GET /<asset_type>/<asset>/_referrers
+*/
+func (ir *VindaluApiHandler) AssetReferrersHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		headers = map[string]string{}
+		code    int
+		data    []byte
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+		assetId   = mux.Vars(r)["asset"]
+	)
+
+	links, err := ir.ListReferrers(assetType, assetId)
+	if err != nil {
+		code, headers, data = errorResponse(err, 404)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(links)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+// Request body to rename an asset.  Empty fields are left as is.
+type assetRenameRequest struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+/*
+   Handle renaming an asset and/or moving it to another type POST /<asset_type>/<asset>/_rename
+*/
+func (ir *VindaluApiHandler) AssetRenameHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		headers = map[string]string{}
+		code    int
+		data    []byte
+
+		from = core.AssetRef{
+			Type: normalizeAssetType(mux.Vars(r)["asset_type"]),
+			Id:   mux.Vars(r)["asset"],
+		}
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	var req assetRenameRequest
+	err := decodeRequestBody(r, &req)
+	if err == nil {
+		to := from
+		if len(req.Type) > 0 {
+			to.Type = normalizeAssetType(req.Type)
+		}
+		if len(req.Id) > 0 {
+			to.Id = req.Id
+		}
+
+		var ba *core.BaseAsset
+		if ba, err = ir.RenameAsset(from, to, reqUser, isAdmin); err == nil {
+			code = 200
+			headers["Content-Type"] = "application/json"
+			headers["Location"] = (&url.URL{Path: ir.Config().Endpoints.Prefix + "/" + to.String()}).String()
+			data, _ = json.Marshal(map[string]string{"type": ba.Type, "id": ba.Id})
+		} else if ba != nil {
+			// Renamed but not all links could be updated
+			code, headers, data = errorResponse(err, 500)
+			err = nil
+		}
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+   Handle traversing links of an asset GET /<asset_type>/<asset>/_graph?depth=N&direction=in|out|both&format=json|jgf|dot
+*/
+func (ir *VindaluApiHandler) AssetGraphHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		headers = map[string]string{}
+		code    int
+		data    []byte
+		err     error
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+		assetId   = mux.Vars(r)["asset"]
+		params    = r.URL.Query()
+
+		opts  = core.GraphOptions{Depth: 1, Direction: core.GRAPH_DIRECTION_BOTH}
+		graph *core.AssetGraph
+	)
+
+	if v := params.Get("depth"); len(v) > 0 {
+		if opts.Depth, err = strconv.Atoi(v); err != nil {
+			err = fmt.Errorf("Invalid depth: %s", v)
+		}
+	}
+	if v := params.Get("direction"); len(v) > 0 {
+		opts.Direction = v
+	}
+
+	if err == nil {
+		err = opts.Validate()
+	}
+
+	if err != nil {
+		code = 400
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else if graph, err = ir.AssetGraph(assetType, assetId, opts); err != nil {
+		code = errorStatusCode(err, 500)
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else {
+		code = 200
+		switch params.Get("format") {
+		case "dot":
+			headers["Content-Type"] = "text/vnd.graphviz"
+			data = []byte(graph.DOT())
+		case "jgf":
+			headers["Content-Type"] = "application/json"
+			data, _ = json.Marshal(graph.JGF())
+		default:
+			headers["Content-Type"] = "application/json"
+			data, _ = json.Marshal(graph)
+		}
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
 func (ir *VindaluApiHandler) AssetOptionsHandler(w http.ResponseWriter, r *http.Request) {
 	for k, v := range ASSET_ACLS {
 		w.Header().Set(k, v)
 	}
 	w.Header().Set("Content-Type", "text/plain")
 
-	data, err := GetOptionsText(ASSET_OPTIONS_TMPLT, NewOptionsMethodVarsFromConfig(ir.Config()))
+	// Render the rules of the requested type.  Fallback to the configured rules if the type does
+	// not exist yet.
+	assetType := normalizeAssetType(mux.Vars(r)["asset_type"])
+	assetCfg, err := ir.AssetConfigForType(assetType)
+	if err != nil {
+		assetCfg = ir.Config().AssetCfg.ForType(assetType)
+	}
+
+	data, err := GetOptionsText(ASSET_OPTIONS_TMPLT, NewOptionsMethodVars(ir.Config().Endpoints.Prefix, assetCfg))
 	if err != nil {
 		ir.writeAndLogResponse(w, r, 500, nil, []byte(err.Error()))
 	} else {
@@ -41,3 +41,14 @@ func Test_AssetVersionsOptionsHandler(t *testing.T) {
 		t.Fatalf("%v\n", w)
 	}
 }
+
+func Test_newAssetLocation(t *testing.T) {
+	for in, out := range map[string]string{
+		"/v3/change/_new":  "/v3/change/CHG-000123",
+		"/v3/change/_new/": "/v3/change/CHG-000123",
+	} {
+		if loc := newAssetLocation(in, "CHG-000123"); loc != out {
+			t.Fatalf("Wrong location for %s: %s", in, loc)
+		}
+	}
+}
@@ -1,21 +1,23 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
 
 	"github.com/vindalu/vindalu/core"
-	"github.com/vindalu/vindalu/types"
+	"github.com/vindalu/vindalu/export"
 )
 
 var ASSET_TYPE_ACLS = map[string]string{
 	"Access-Control-Allow-Origin":      "*",
 	"Access-Control-Allow-Credentials": "true",
-	"Access-Control-Allow-Methods":     "GET, POST, OPTIONS",
+	"Access-Control-Allow-Methods":     "GET, POST, PUT, DELETE, OPTIONS",
 	"Access-Control-Allow-Headers":     "Accept,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type",
 }
 
@@ -64,7 +66,17 @@ func (ir *VindaluApiHandler) AssetTypePropertiesHandler(w http.ResponseWriter, r
 		data    []byte
 	)
 
-	props, err := ir.ListTypeProperties(assetType)
+	var (
+		props interface{}
+		err   error
+	)
+	// Detailed property info i.e. mapped type, constraints, fill rate and cardinality
+	if isDetailRequested(r) {
+		props, err = ir.ListTypePropertyDetails(assetType)
+	} else {
+		props, err = ir.ListTypeProperties(assetType)
+	}
+
 	if err != nil {
 		code = 400
 		headers["Content-Type"] = "text/plain"
@@ -77,11 +89,50 @@ func (ir *VindaluApiHandler) AssetTypePropertiesHandler(w http.ResponseWriter, r
 	ir.writeAndLogResponse(w, r, code, headers, data)
 }
 
+/*
+	List existing unique constraint violations GET /<asset_type>/_unique
+*/
+func (ir *VindaluApiHandler) AssetTypeUniqueViolationsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+	)
+
+	violations, err := ir.ListUniqueViolations(assetType)
+	if err != nil {
+		code = 400
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(violations)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
 /*
    Handle requests searching within an asset type i.e GET /<asset_type>
    This handler is also used by the search endpoint with the asset type of ""
 */
 func (ir *VindaluApiHandler) AssetTypeGetHandler(w http.ResponseWriter, r *http.Request) {
+	ir.assetTypeQuery(w, r, false)
+}
+
+/*
+   Handle requests searching all versions within an asset type i.e GET /<asset_type>/_versions
+   This handler is also used by the search versions endpoint with the asset type of ""
+*/
+func (ir *VindaluApiHandler) AssetTypeVersionsGetHandler(w http.ResponseWriter, r *http.Request) {
+	ir.assetTypeQuery(w, r, true)
+}
+
+func (ir *VindaluApiHandler) assetTypeQuery(w http.ResponseWriter, r *http.Request, versionQuery bool) {
 	var (
 		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
 
@@ -92,40 +143,143 @@ func (ir *VindaluApiHandler) AssetTypeGetHandler(w http.ResponseWriter, r *http.
 		rsp interface{}
 	)
 
-	userQuery, err := parseQueryFromHttpRequest(r)
+	assetType, userQuery, qo, err := ir.getQueryFromRequest(r, assetType)
 	if err == nil {
-		var qo types.QueryOptions
-		if qo, err = types.NewQueryOptions(r.URL.Query()); err == nil {
+		if versionQuery {
+			rsp, err = ir.ExecuteVersionQuery(assetType, userQuery, &qo)
+		} else {
 			rsp, err = ir.ExecuteQuery(assetType, userQuery, &qo)
 		}
 	}
 
+	if err == nil {
+		data, headers["Content-Type"], err = encodeQueryResult(rsp, requestedFormat(r), requestedFields(r))
+	}
+
 	if err != nil {
 		data = []byte(err.Error())
 		code = 400
 		headers["Content-Type"] = "text/plain"
 	} else {
-		if data, err = json.Marshal(rsp); err != nil {
-			data = []byte(err.Error())
-			code = 400
-			headers["Content-Type"] = "text/plain"
-		} else {
-			code = 200
-			headers["Content-Type"] = "application/json"
+		code = 200
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Encode assets or aggregated items returned by a query in the given format.  Formats other than
+	csv are encoded as JSON and re-encoded when the response is written.
+*/
+func encodeQueryResult(rsp interface{}, format string, fields []string) (data []byte, contentType string, err error) {
+	switch format {
+	case FORMAT_JSON, FORMAT_YAML, FORMAT_MSGPACK, FORMAT_XML:
+		data, err = json.Marshal(rsp)
+		return data, "application/json", err
+	case FORMAT_CSV:
+		var buf bytes.Buffer
+		switch rsp.(type) {
+		case []core.BaseAsset:
+			err = export.WriteCSV(&buf, rsp.([]core.BaseAsset), fields)
+		case []core.AggregatedItem:
+			err = export.WriteAggregatedCSV(&buf, rsp.([]core.AggregatedItem))
+		default:
+			err = fmt.Errorf("Result cannot be written as csv")
 		}
+		return buf.Bytes(), "text/csv", err
+	}
+	return nil, "", fmt.Errorf("Unsupported format '%s'. Must be one of: %s", format,
+		strings.Join([]string{FORMAT_JSON, FORMAT_CSV, FORMAT_YAML, FORMAT_MSGPACK, FORMAT_XML}, ", "))
+}
+
+/*
+	Create or update assets from CSV POST /<asset_type>/_import
+
+	The header row contains the field names.  Nested fields are specified with dotted names.
+*/
+func (ir *VindaluApiHandler) AssetTypeImportHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+		reqUser   = context.Get(r, Username).(string)
+		isAdmin   = context.Get(r, IsAdmin).(bool)
+	)
+	defer r.Body.Close()
+
+	assets, err := export.ReadCSV(r.Body, assetType, ir.csvFieldTypes())
+	if err != nil {
+		code = 400
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else {
+		result := ir.ImportAssets(assets, reqUser, isAdmin)
+		code = importStatusCode(result)
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(result)
 	}
 
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	ir.writeAndLogResponse(w, r, code, headers, data)
 }
 
+// 200 if all rows were imported, 207 if only some were and 400 if none were
+func importStatusCode(result core.ImportResult) int {
+	if result.Failed == 0 {
+		return 200
+	} else if result.Created+result.Updated > 0 {
+		return 207
+	}
+	return 400
+}
+
 /*
-	Add asset type with optional properties POST /{asset_type}
+	Mapped types of the fields of each asset type for reading CSV, nested fields by their dotted
+	path.  Read from the mapping once per type.  Types that do not exist yet have no fields so all
+	their values are read as strings.
 */
-func (ir *VindaluApiHandler) AssetTypePostHandler(w http.ResponseWriter, r *http.Request) {
-	reqUser := context.Get(r, Username).(string)
-	assetType := normalizeAssetType(mux.Vars(r)["asset_type"])
-	isAdmin := context.Get(r, IsAdmin).(bool)
+func (ir *VindaluApiHandler) csvFieldTypes() export.FieldTypes {
+	cache := map[string]map[string]string{}
+	return func(assetType string) map[string]string {
+		assetType = normalizeAssetType(assetType)
+		if types, ok := cache[assetType]; ok {
+			return types
+		}
+
+		types, err := ir.TypeFieldTypes(assetType)
+		if err != nil {
+			types = map[string]string{}
+		}
+		cache[assetType] = types
+		return types
+	}
+}
+
+// Request body to create or update an asset type
+type assetTypeRequest struct {
+	Properties map[string]interface{}  `json:"properties"`
+	Metadata   *core.AssetTypeMetadata `json:"metadata"`
+}
+
+/*
+	Add asset type with optional properties and metadata POST /{asset_type}
+	Update asset type properties and metadata PUT /{asset_type}
+	Remove empty asset type DELETE /{asset_type}
+*/
+func (ir *VindaluApiHandler) AssetTypeWriteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+		err     error
+
+		reqUser   = context.Get(r, Username).(string)
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+		isAdmin   = context.Get(r, IsAdmin).(bool)
+	)
 
 	// Check if user is admin
 	if !isAdmin {
@@ -133,13 +287,40 @@ func (ir *VindaluApiHandler) AssetTypePostHandler(w http.ResponseWriter, r *http
 			[]byte(fmt.Sprintf("User '%s' not an admin!", reqUser)))
 		return
 	}
-	// TODO: Add user options ie `{"properties": { .... } }`
-	if err := ir.CreateAssetType(assetType, map[string]interface{}{}); err != nil {
-		ir.writeAndLogResponse(w, r, 500, map[string]string{"Content-Type": "text/plain"}, []byte(err.Error()))
+
+	switch r.Method {
+	case "POST", "PUT":
+		var req assetTypeRequest
+		if err = decodeRequestBody(r, &req); err != nil {
+			code = 400
+			break
+		}
+
+		if r.Method == "POST" {
+			err = ir.CreateAssetType(assetType, req.Properties, req.Metadata)
+		} else {
+			err = ir.UpdateAssetType(assetType, req.Properties, req.Metadata)
+		}
+		break
+	case "DELETE":
+		err = ir.RemoveAssetType(assetType)
+		break
+	}
+
+	if err != nil {
+		if code == 0 {
+			code = errorStatusCode(err, 500)
+		}
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
 	} else {
-		b, _ := json.Marshal(map[string]string{"status": "success"})
-		ir.writeAndLogResponse(w, r, 200, map[string]string{"Content-Type": "application/json"}, b)
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(map[string]string{"status": "success"})
 	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
 }
 
 /*
@@ -4,6 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/gorilla/context"
+
+	"github.com/vindalu/vindalu/core"
 )
 
 func Test_Inventory_ListAssetTypesHandler(t *testing.T) {
@@ -57,3 +61,30 @@ func Test_Inventory_AssetTypeOptionsHandler(t *testing.T) {
 	}
 	t.Log(w.Body.String())
 }
+
+func Test_Inventory_AssetTypeWriteRequestHandler_notAdmin(t *testing.T) {
+	r, _ := http.NewRequest("DELETE", "/v3/virtualserver", nil)
+	context.Set(r, Username, "foo")
+	context.Set(r, IsAdmin, false)
+	defer context.Clear(r)
+
+	w := httptest.NewRecorder()
+
+	testInv.AssetTypeWriteRequestHandler(w, r)
+
+	if w.Code != 401 {
+		t.Fatalf("Should be unauthorized: %v", w)
+	}
+}
+
+func Test_importStatusCode(t *testing.T) {
+	for code, result := range map[int]core.ImportResult{
+		200: {Created: 1, Updated: 1},
+		207: {Created: 1, Failed: 1},
+		400: {Failed: 2},
+	} {
+		if importStatusCode(result) != code {
+			t.Fatalf("Wrong status for %#v: %d", result, importStatusCode(result))
+		}
+	}
+}
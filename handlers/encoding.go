@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vindalu/vindalu/formats"
+)
+
+// Response formats.  csv is only supported for query results.
+const (
+	FORMAT_JSON    = "json"
+	FORMAT_CSV     = "csv"
+	FORMAT_YAML    = "yaml"
+	FORMAT_MSGPACK = "msgpack"
+	FORMAT_XML     = "xml"
+)
+
+// Media types of each format.  The first one is used as the Content-Type of responses.
+var FORMAT_MEDIA_TYPES = map[string][]string{
+	FORMAT_JSON:    {"application/json", "text/json"},
+	FORMAT_CSV:     {"text/csv"},
+	FORMAT_YAML:    {"application/x-yaml", "application/yaml", "text/yaml", "text/x-yaml"},
+	FORMAT_MSGPACK: {"application/x-msgpack", "application/msgpack", "application/vnd.msgpack"},
+	FORMAT_XML:     {"application/xml", "text/xml"},
+}
+
+/*
+	Format of a media type.  Empty if not supported.  Browsers list text/html ahead of the xml they
+	also accept and are served json.
+*/
+func mediaTypeFormat(mediaType string) string {
+	switch mediaType {
+	case "*/*", "application/*", "text/html":
+		return FORMAT_JSON
+	}
+	for format, types := range FORMAT_MEDIA_TYPES {
+		for _, t := range types {
+			if t == mediaType {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// Entry of an Accept header
+type acceptedType struct {
+	MediaType string
+	Params    map[string]string
+	Quality   float64
+}
+
+// Parse an Accept header.  Entries with a zero quality are dropped.
+func parseAccept(header string) (accepted []acceptedType) {
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+		at := acceptedType{
+			MediaType: strings.ToLower(strings.TrimSpace(parts[0])),
+			Params:    map[string]string{},
+			Quality:   1,
+		}
+		if len(at.MediaType) == 0 {
+			continue
+		}
+
+		for _, p := range parts[1:] {
+			kv := strings.SplitN(p, "=", 2)
+			key := strings.ToLower(strings.TrimSpace(kv[0]))
+			if len(kv) == 1 {
+				at.Params[key] = ""
+			} else {
+				at.Params[key] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			}
+		}
+		if q, ok := at.Params["q"]; ok {
+			at.Quality, _ = strconv.ParseFloat(q, 64)
+		}
+
+		if at.Quality > 0 {
+			accepted = append(accepted, at)
+		}
+	}
+	return
+}
+
+// Supported entry of the Accept header with the highest quality.  Earlier entries win ties.
+func preferredAcceptedType(r *http.Request) (best acceptedType, format string) {
+	for _, at := range parseAccept(r.Header.Get("Accept")) {
+		if f := mediaTypeFormat(at.MediaType); len(f) > 0 && at.Quality > best.Quality {
+			best, format = at, f
+		}
+	}
+	return
+}
+
+/*
+	Requested response format from the `format` param or the Accept header.  Defaults to json if
+	neither is given or none of the accepted types are supported.
+*/
+func requestedFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); len(format) > 0 {
+		return strings.ToLower(format)
+	}
+	if _, format := preferredAcceptedType(r); len(format) > 0 {
+		return format
+	}
+	return FORMAT_JSON
+}
+
+// Check if indented JSON is requested with the `pretty` param or i.e. Accept: application/json; pretty
+func isPrettyRequested(r *http.Request) bool {
+	if isParamEnabled(r, "pretty") {
+		return true
+	}
+	at, _ := preferredAcceptedType(r)
+	pretty, ok := at.Params["pretty"]
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(pretty)
+	return len(pretty) == 0 || (err == nil && enabled)
+}
+
+/*
+	Re-encode a JSON response body in the format requested by the client returning the data and
+	its Content-Type.  JSON is returned as is for formats only specific endpoints support.
+*/
+func encodeResponse(r *http.Request, data []byte) ([]byte, string, error) {
+	var (
+		format = requestedFormat(r)
+		raw    = json.RawMessage(data)
+		err    error
+	)
+
+	switch format {
+	case FORMAT_YAML:
+		data, err = formats.EncodeYAML(&raw)
+	case FORMAT_MSGPACK:
+		data, err = formats.EncodeMsgpack(&raw)
+	case FORMAT_XML:
+		data, err = formats.EncodeXML(&raw)
+	default:
+		format = FORMAT_JSON
+		if isPrettyRequested(r) {
+			var buf bytes.Buffer
+			if err = json.Indent(&buf, data, "", "  "); err == nil {
+				data = append(buf.Bytes(), '\n')
+			}
+		}
+	}
+	return data, FORMAT_MEDIA_TYPES[format][0], err
+}
+
+// Format of the request body from the Content-Type header.  Defaults to json.
+func requestBodyFormat(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return FORMAT_JSON
+	}
+	switch format := mediaTypeFormat(mediaType); format {
+	case FORMAT_YAML, FORMAT_MSGPACK, FORMAT_XML:
+		return format
+	}
+	return FORMAT_JSON
+}
+
+// Decode a request body in the given format
+func decodeBody(format string, body []byte) (v interface{}, err error) {
+	switch format {
+	case FORMAT_YAML:
+		return formats.DecodeYAML(body)
+	case FORMAT_MSGPACK:
+		return formats.DecodeMsgpack(body)
+	case FORMAT_XML:
+		return formats.DecodeXML(body)
+	case FORMAT_JSON:
+		err = json.Unmarshal(body, &v)
+		return
+	}
+	return nil, fmt.Errorf("Unsupported request format '%s'", format)
+}
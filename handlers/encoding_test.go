@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/vindalu/vindalu/formats"
+)
+
+func Test_requestedFormat_Accept(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://localhost:5454/v3/server", nil)
+	for accept, format := range map[string]string{
+		"application/x-yaml":                           FORMAT_YAML,
+		"text/plain, application/xml;q=0.9, */*;q=0.8": FORMAT_XML,
+		"application/json;q=0.5, application/msgpack":  FORMAT_MSGPACK,
+		"application/x-yaml;q=0, application/json":     FORMAT_JSON,
+		"text/html,application/xml;q=0.9,*/*;q=0.8":    FORMAT_JSON,
+		"text/plain": FORMAT_JSON,
+		"*/*":        FORMAT_JSON,
+	} {
+		r.Header.Set("Accept", accept)
+		if f := requestedFormat(r); f != format {
+			t.Fatalf("Wrong format for '%s': %s", accept, f)
+		}
+	}
+
+	r, _ = http.NewRequest("GET", "http://localhost:5454/v3/server?format=yaml", nil)
+	r.Header.Set("Accept", "application/xml")
+	if requestedFormat(r) != FORMAT_YAML {
+		t.Fatalf("format param should take precedence")
+	}
+}
+
+func Test_isPrettyRequested(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://localhost:5454/v3/server", nil)
+	if isPrettyRequested(r) {
+		t.Fatalf("Should not be pretty by default")
+	}
+	r.Header.Set("Accept", "application/json; pretty")
+	if !isPrettyRequested(r) {
+		t.Fatalf("Should honor Accept param")
+	}
+
+	r, _ = http.NewRequest("GET", "http://localhost:5454/v3/server?pretty", nil)
+	if !isPrettyRequested(r) {
+		t.Fatalf("Should honor pretty param")
+	}
+}
+
+func Test_encodeResponse(t *testing.T) {
+	data := []byte(`{"id":"web01","cpus":4}`)
+
+	r, _ := http.NewRequest("GET", "http://localhost:5454/v3/server/web01", nil)
+	if b, ct, err := encodeResponse(r, data); err != nil || ct != "application/json" || !bytes.Equal(b, data) {
+		t.Fatalf("JSON should be returned as is: %s %s %v", ct, b, err)
+	}
+
+	r.Header.Set("Accept", "application/json; pretty=true")
+	if b, _, _ := encodeResponse(r, data); string(b) != "{\n  \"id\": \"web01\",\n  \"cpus\": 4\n}\n" {
+		t.Fatalf("Wrong pretty json: %s", b)
+	}
+
+	r.Header.Set("Accept", "text/yaml")
+	if b, ct, err := encodeResponse(r, data); err != nil || ct != "application/x-yaml" || string(b) != "cpus: 4\nid: web01\n" {
+		t.Fatalf("Wrong yaml: %s %s %v", ct, b, err)
+	}
+
+	r.Header.Set("Accept", "application/x-msgpack")
+	b, ct, err := encodeResponse(r, data)
+	if err != nil || ct != "application/x-msgpack" {
+		t.Fatalf("Wrong msgpack: %s %v", ct, err)
+	}
+	if v, err := formats.DecodeMsgpack(b); err != nil || v.(map[string]interface{})["cpus"] != 4.0 {
+		t.Fatalf("Wrong msgpack: %v %v", v, err)
+	}
+
+	r.Header.Set("Accept", "application/xml")
+	if _, ct, err = encodeResponse(r, data); err != nil || ct != "application/xml" {
+		t.Fatalf("Wrong xml: %s %v", ct, err)
+	}
+
+	// Formats only supported by some endpoints
+	r, _ = http.NewRequest("GET", "http://localhost:5454/v3/server/web01?format=csv", nil)
+	if _, ct, _ = encodeResponse(r, data); ct != "application/json" {
+		t.Fatalf("Should fall back to json: %s", ct)
+	}
+}
+
+func Test_parseRequestBody_Formats(t *testing.T) {
+	for contentType, body := range map[string]string{
+		"application/x-yaml":              "status: enabled\ncpus: 4\n",
+		"text/yaml; charset=utf-8":        "{status: enabled, cpus: 4}",
+		"application/xml":                 `<asset><status>enabled</status><cpus type="number">4</cpus></asset>`,
+		"application/x-msgpack":           "\x82\xa6status\xa7enabled\xa4cpus\x04",
+		"application/json; charset=utf-8": `{"status": "enabled", "cpus": 4}`,
+	} {
+		r, _ := http.NewRequest("POST", "http://localhost:5454/v3/server/web01", bytes.NewBufferString(body))
+		r.Header.Set("Content-Type", contentType)
+
+		req, err := parseRequestBody(r)
+		if err != nil || req["status"] != "enabled" || req["cpus"] != 4.0 {
+			t.Fatalf("Wrong %s body: %v %v", contentType, req, err)
+		}
+	}
+
+	r, _ := http.NewRequest("POST", "http://localhost:5454/v3/server/web01", bytes.NewBufferString("- a\n- b\n"))
+	r.Header.Set("Content-Type", "application/x-yaml")
+	if _, err := parseRequestBody(r); err == nil {
+		t.Fatalf("Body should be an object")
+	}
+}
@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"bytes"
-	"text/template"
 
 	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/export"
 )
 
 const ASSET_VERSIONS_OPTIONS_TMPLT = `
@@ -38,7 +38,13 @@ POST {{.Prefix}}/<asset_type>/<asset>
         {
             {{ range $key, $value := .Enforced }}"{{$key}}": {{$value}},
             {{ end }}{{ range .Required }}"{{.}}": "...",
-            {{ end }}...
+            {{ end }}"labels": {
+                "<key>": "<value>"
+            },
+            "_links": {
+                "<relation>": "<type>/<id>" | [ "<type>/<id>", ... ]
+            },
+            ...
         }
 
 PUT {{.Prefix}}/<asset_type>/<asset>
@@ -52,7 +58,30 @@ PUT {{.Prefix}}/<asset_type>/<asset>
 
 DELETE {{.Prefix}}/<asset_type>/<asset>
 
-    Delete asset
+    Delete asset.  Assets linking to it are handled as per the on_delete behavior of the relation.
+
+GET {{.Prefix}}/<asset_type>/<asset>/_referrers
+
+    List links from other assets to the asset
+
+GET {{.Prefix}}/<asset_type>/<asset>/_graph
+
+    Graph of assets linked to the asset
+
+    Params:
+        depth       (1-10, default: 1)
+        direction   (in, out, both; default: both)
+        format      (json, jgf, dot; default: json)
+
+POST {{.Prefix}}/<asset_type>/<asset>/_rename
+
+    Rename the asset and/or move it to another type preserving its versions (admin only)
+
+    Body:
+        {
+            "type": "...",
+            "id": "..."
+        }
 
 `
 
@@ -76,37 +105,215 @@ GET {{.Prefix}}/<asset_type>
         from
         size
         aggregator
+        saved_query
+        selector    (i.e. env=prod,tier in (web,cache),!deprecated)
+        format      (json, csv, yaml, msgpack, xml; default: json)
+        fields      (csv columns i.e. id,name,labels.env)
+
+GET {{.Prefix}}/<asset_type>/_versions
+
+    Search all versions of assets by type
+
+    Params:
+        from
+        size
+        aggregator
+        saved_query
+        selector    (i.e. env=prod,tier in (web,cache),!deprecated)
+        format      (json, csv, yaml, msgpack, xml; default: json)
+        fields      (csv columns i.e. id,name,labels.env)
+
+POST {{.Prefix}}/<asset_type>/_new
+
+    Create asset with a server generated id.  Only for existing types with id_generation
+    configured.  Returns 201 with the new asset location in the Location header.
+
+    Params:
+        import
+
+    Body:
+        {
+            ...
+        }
+
+POST {{.Prefix}}/<asset_type>/_import
+
+    Create or update assets from CSV.  The header row contains the (dotted) field names and
+    must include id.
+
+GET {{.Prefix}}/<asset_type>/_ansible
+
+    Ansible dynamic inventory of the assets matching the search
+
+    Params:
+        host_field  (field containing the host name; default: id)
+        group_by    (fields whose values become groups i.e. environment,roles)
+
+GET {{.Prefix}}/<asset_type>/_prometheus
+
+    Prometheus file_sd / http_sd targets of the assets matching the search
+
+    Params:
+        target_field  (field containing the target address; default: id)
+        target_port   (port appended to targets without one)
+        label_fields  (fields added as labels i.e. environment,labels.tier:tier)
+
+GET {{.Prefix}}/<asset_type>/_zone
+
+    BIND zone file of the dns records matching the search.  The serial is derived from the
+    latest version timestamp.
+
+    Params:
+        origin  (zone origin; default: dns.origin config)
+
+GET {{.Prefix}}/<asset_type>/_hosts
+
+    /etc/hosts file of the A/AAAA (and CNAME) records matching the search
+
+    Params:
+        origin  (domain relative names are qualified with)
+
+GET {{.Prefix}}/<asset_type>/_template
+
+    Render the assets matching the search with a configured or built-in (zone, hosts) template
+
+    Params:
+        template  (template name)
+
+POST {{.Prefix}}/<asset_type>/_template
+
+    Render the assets matching the search with the text/template given as the body
+
+GET {{.Prefix}}/<asset_type>/_unique
+
+    List assets sharing the values of unique fields
+
+GET {{.Prefix}}/<asset_type>/_schema
+
+    Get JSON schema assets are validated against
+
+POST {{.Prefix}}/<asset_type>/_schema
+
+    Set JSON schema assets are validated against (admin only)
+
+    Body:
+        {
+            "type": "object",
+            "properties": { ... },
+            "required": [ ... ]
+        }
+
+DELETE {{.Prefix}}/<asset_type>/_schema
+
+    Remove JSON schema (admin only)
 
 POST {{.Prefix}}/<asset_type>
 
-    Create asset type
+    Create asset type (admin only)
 
     Body:
         {
             "properties": {
                 "...": { ... }
+            },
+            "metadata": {
+                "description": "...",
+                "owner_team": "...",
+                "contact": "...",
+                "icon": "...",
+                "fields": {
+                    "...": "..."
+                },
+                "required_fields": [ ... ],
+                "enforced_fields": {
+                    "...": [ ... ]
+                },
+                "unique_fields": [ "...", [ "...", "..." ] ],
+                "links": {
+                    "<relation>": { "type": "...", "on_delete": "block|cascade|nullify" }
+                },
+                "id_generation": { "strategy": "uuid|ulid|sequence", "prefix": "...", "width": 6 },
+                "override": false
             }
         }
 
+PUT {{.Prefix}}/<asset_type>
+
+    Add properties and/or replace metadata of asset type (admin only)
+
+    Body:
+        {
+            "properties": { ... },
+            "metadata": { ... }
+        }
+
+DELETE {{.Prefix}}/<asset_type>
+
+    Remove asset type.  Only types without assets can be removed (admin only)
+
+`
+
+const SAVED_QUERY_OPTIONS_TMPLT = `
+GET {{.Prefix}}/_queries
+
+    List saved queries
+
+    Params:
+        owner
+
+GET {{.Prefix}}/_queries/<name>
+
+    Get saved query
+
+GET {{.Prefix}}/_queries/<name>/results
+
+    Execute saved query.  Request filters and options are applied on top of the saved ones.
+
+    Params:
+        from
+        size
+        sort
+        aggregate
+
+POST {{.Prefix}}/_queries/<name>
+
+    Create/update saved query
+
+    Body:
+        {
+            "asset_type": "...",
+            "query": { ... },
+            "options": { "from": 0, "size": 100, "sort": [{"...": "asc"}], "aggregate": "..." }
+        }
+
+DELETE {{.Prefix}}/_queries/<name>
+
+    Delete saved query
+
 `
 
 /* Metadata used to normalize options templates */
 type OptionsMethodVars struct {
 	Prefix   string
-	Enforced map[string][]string
+	Enforced map[string]config.FieldRule
 	Required []string
 }
 
 func NewOptionsMethodVarsFromConfig(cfg *config.InventoryConfig) OptionsMethodVars {
+	return NewOptionsMethodVars(cfg.Endpoints.Prefix, &cfg.AssetCfg)
+}
+
+/* Options template variables using the given asset config i.e. the rules for a specific type */
+func NewOptionsMethodVars(prefix string, assetCfg *config.AssetConfig) OptionsMethodVars {
 	omv := OptionsMethodVars{
-		Prefix:   cfg.Endpoints.Prefix,
-		Enforced: cfg.AssetCfg.EnforcedFields,
+		Prefix:   prefix,
+		Enforced: assetCfg.EnforcedFields,
 		Required: []string{},
 	}
 
-	for i, v := range cfg.AssetCfg.RequiredFields {
+	for i, v := range assetCfg.RequiredFields {
 		if _, ok := omv.Enforced[v]; !ok {
-			omv.Required = append(omv.Required, cfg.AssetCfg.RequiredFields[i])
+			omv.Required = append(omv.Required, assetCfg.RequiredFields[i])
 			continue
 		}
 	}
@@ -114,7 +321,6 @@ func NewOptionsMethodVarsFromConfig(cfg *config.InventoryConfig) OptionsMethodVa
 }
 
 func GetOptionsText(tmplt string, opts OptionsMethodVars) (d bytes.Buffer, err error) {
-	t := template.Must(template.New("options").Parse(tmplt))
-	err = t.Execute(&d, opts)
+	err = export.ExecuteTemplate(&d, "options", tmplt, opts)
 	return
 }
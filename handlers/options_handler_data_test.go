@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/vindalu/vindalu/config"
 )
 
 func Test_GetOptionsText(t *testing.T) {
@@ -25,3 +28,21 @@ func Test_NewOptionsMethodVarsFromConfig(t *testing.T) {
 	}
 	t.Logf("%#v", opts)
 }
+
+func Test_NewOptionsMethodVars(t *testing.T) {
+	ac := config.AssetConfig{
+		RequiredFields: []string{"status"},
+		Types: map[string]config.TypeAssetConfig{
+			"dnsrecord": config.TypeAssetConfig{RequiredFields: []string{"zone", "ttl"}},
+		},
+	}
+	opts := NewOptionsMethodVars("/v3", ac.ForType("dnsrecord"))
+
+	data, err := GetOptionsText(ASSET_OPTIONS_TMPLT, opts)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !strings.Contains(data.String(), `"zone": "..."`) || !strings.Contains(data.String(), `"ttl": "..."`) {
+		t.Fatalf("Type rules not rendered: %s", data.String())
+	}
+}
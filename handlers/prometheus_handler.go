@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vindalu/vindalu/export"
+)
+
+/*
+	Render query results as prometheus file_sd / http_sd targets GET /<asset_type>/_prometheus and
+	GET /search/_prometheus
+
+	Takes the same filters and options as a search.  The `target_field`, `target_port` and
+	`label_fields` params override the configured defaults.
+*/
+func (ir *VindaluApiHandler) PrometheusTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+	)
+
+	bodyReq, _ := parseRequestBody(r)
+	groups, err := export.QueryPrometheusTargets(ir.VindaluCore, ir.Config().Prometheus,
+		normalizeAssetType(mux.Vars(r)["asset_type"]), r.URL.Query(), bodyReq)
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(groups)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
@@ -1,12 +1,9 @@
 package handlers
 
 import (
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
-
-	"github.com/vindalu/vindalu/core"
 )
 
 /*
@@ -15,53 +12,45 @@ import (
 */
 func (ir *VindaluApiHandler) ESSRawHandler(w http.ResponseWriter, r *http.Request) {
 	cfg := ir.Config()
-	dscfg, _ := cfg.Datastore.Config.(core.EssDatastoreConfig)
-
-	newUri := fmt.Sprintf("http://%s:%d/%s/%s", dscfg.Host, dscfg.Port, dscfg.Index,
-		strings.TrimPrefix(r.RequestURI, cfg.Endpoints.Raw))
-
-	ir.executeRawHandlerQuery(w, r, newUri)
+	ir.executeRawHandlerQuery(w, r, strings.TrimPrefix(r.URL.Path, cfg.Endpoints.Raw), false)
 }
 
 func (ir *VindaluApiHandler) ESSRawVersionsHandler(w http.ResponseWriter, r *http.Request) {
 	cfg := ir.Config()
-	dscfg, _ := cfg.Datastore.Config.(core.EssDatastoreConfig)
-
-	newUri := fmt.Sprintf("http://%s:%d/%s/%s", dscfg.Host, dscfg.Port, dscfg.VersionIndex,
-		strings.TrimPrefix(r.RequestURI, cfg.Endpoints.Raw+"/versions/"))
-
-	ir.executeRawHandlerQuery(w, r, newUri)
+	ir.executeRawHandlerQuery(w, r, strings.TrimPrefix(r.URL.Path, cfg.Endpoints.Raw+"/versions"), true)
 }
 
-func (ir *VindaluApiHandler) executeRawHandlerQuery(w http.ResponseWriter, r *http.Request, uri string) {
-	req, err := http.NewRequest(r.Method, uri, r.Body)
-	if err != nil {
-		ir.writeAndLogResponse(w, r, 400,
-			map[string]string{"Content-Type": "text/plain"}, []byte(err.Error()))
-		return
+func (ir *VindaluApiHandler) executeRawHandlerQuery(w http.ResponseWriter, r *http.Request, path string, versions bool) {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			ir.writeAndLogResponse(w, r, 400,
+				map[string]string{"Content-Type": "text/plain"}, []byte(err.Error()))
+			return
+		}
+		if len(b) > 0 {
+			body = b
+		}
 	}
-	req.Header = r.Header
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := ir.RawRequest(r.Method, path, r.URL.RawQuery, r.Header, body, versions)
 	if err != nil {
-		ir.writeAndLogResponse(w, r, resp.StatusCode,
-			map[string]string{"Content-Type": "text/plain"}, []byte(err.Error()))
+		// Elasticsearch could not be reached
+		code, headers, data := errorResponse(err, 502)
+		ir.writeAndLogResponse(w, r, code, headers, data)
 		return
 	}
-
-	w.WriteHeader(resp.StatusCode)
-	for k, v := range resp.Header {
-		if len(v) > 0 {
-			w.Header().Set(k, v[0])
-		}
-	}
+	defer resp.Body.Close()
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		ir.writeAndLogResponse(w, r, resp.StatusCode,
+		ir.writeAndLogResponse(w, r, 502,
 			map[string]string{"Content-Type": "text/plain"}, []byte(err.Error()))
 		return
 	}
-	defer resp.Body.Close()
-	w.Write(b)
+
+	// Other headers such as the content length may not apply once re-encoded
+	ir.writeAndLogResponse(w, r, resp.StatusCode,
+		map[string]string{"Content-Type": resp.Header.Get("Content-Type")}, b)
 }
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+/*
+	Reindex status GET /_reindex or start a reindex POST /_reindex (admin only)
+
+	The reindex runs in the background.  The old indices are kept if the `keep_old` param is set.
+*/
+func (ir *VindaluApiHandler) ReindexHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+		status  interface{}
+		err     error
+
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	switch r.Method {
+	case "GET":
+		code = 200
+		status, err = ir.ReindexStatus(reqUser, isAdmin)
+	case "POST":
+		code = 202
+		status, err = ir.Reindex(reqUser, isAdmin, isParamEnabled(r, "keep_old"))
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+	} else {
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(status)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
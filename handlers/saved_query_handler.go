@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+
+	"github.com/vindalu/vindalu/core"
+	"github.com/vindalu/vindalu/types"
+)
+
+var SAVED_QUERY_ACLS = map[string]string{
+	"Access-Control-Allow-Origin":      "*",
+	"Access-Control-Allow-Credentials": "true",
+	"Access-Control-Allow-Methods":     "GET, POST, DELETE, OPTIONS",
+	"Access-Control-Allow-Headers":     "Accept,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type",
+}
+
+// Request body to create or update a saved query
+type savedQueryRequest struct {
+	AssetType string                 `json:"asset_type"`
+	Query     map[string]interface{} `json:"query"`
+	Options   types.QueryOptions     `json:"options"`
+}
+
+/*
+	List saved queries GET /_queries?owner=<user>
+*/
+func (ir *VindaluApiHandler) SavedQueryListHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+	)
+
+	list, err := ir.ListSavedQueries(r.URL.Query().Get("owner"))
+	if err != nil {
+		code = 500
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(list)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Get saved query GET /_queries/<name>
+*/
+func (ir *VindaluApiHandler) SavedQueryGetHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+	)
+
+	sq, err := ir.GetSavedQuery(mux.Vars(r)["name"])
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(sq)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Execute saved query GET /_queries/<name>/results
+
+	Filters and options in the request are applied on top of the saved ones.
+*/
+func (ir *VindaluApiHandler) SavedQueryResultsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		rsp interface{}
+	)
+
+	sq, err := ir.GetSavedQuery(mux.Vars(r)["name"])
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+		ir.writeAndLogResponse(w, r, code, headers, data)
+		return
+	}
+
+	userQuery, err := parseQueryFromHttpRequest(r)
+	if err == nil {
+		var (
+			assetType string
+			query     map[string]interface{}
+			qo        types.QueryOptions
+		)
+		if assetType, query, qo, err = core.ApplySavedQuery(sq, "", userQuery, r.URL.Query()); err == nil {
+			rsp, err = ir.ExecuteQuery(assetType, query, &qo)
+		}
+	}
+
+	if err != nil {
+		code = 400
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(rsp)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Create/update saved query POST /_queries/<name>
+	Remove saved query DELETE /_queries/<name>
+*/
+func (ir *VindaluApiHandler) SavedQueryWriteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+		err     error
+
+		name    = mux.Vars(r)["name"]
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	switch r.Method {
+	case "POST":
+		var req savedQueryRequest
+		if err = decodeRequestBody(r, &req); err != nil {
+			code = 400
+			break
+		}
+
+		sq := core.SavedQuery{
+			Name:      name,
+			AssetType: normalizeAssetType(req.AssetType),
+			Query:     req.Query,
+			Options:   req.Options,
+		}
+		if err = ir.SaveQuery(sq, reqUser, isAdmin); err != nil {
+			code = errorStatusCode(err, 400)
+		}
+		break
+	case "DELETE":
+		if err = ir.RemoveSavedQuery(name, reqUser, isAdmin); err != nil {
+			code = errorStatusCode(err, 404)
+		}
+		break
+	}
+
+	if err != nil {
+		headers["Content-Type"] = "text/plain"
+		data = []byte(err.Error())
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data = []byte(fmt.Sprintf(`{"name": "%s"}`, name))
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+func (ir *VindaluApiHandler) SavedQueryOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	for k, v := range SAVED_QUERY_ACLS {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "text/plain")
+
+	data, err := GetOptionsText(SAVED_QUERY_OPTIONS_TMPLT, NewOptionsMethodVarsFromConfig(ir.Config()))
+	if err != nil {
+		ir.writeAndLogResponse(w, r, 500, nil, []byte(err.Error()))
+	} else {
+		ir.writeAndLogResponse(w, r, 200, nil, data.Bytes())
+	}
+}
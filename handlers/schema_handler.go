@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+/*
+	Get the JSON schema for a type GET /<asset_type>/_schema
+*/
+func (ir *VindaluApiHandler) AssetTypeSchemaGetHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+	)
+
+	ts, err := ir.GetTypeSchema(assetType)
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(ts)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Set the JSON schema for a type POST /<asset_type>/_schema
+	Remove the JSON schema for a type DELETE /<asset_type>/_schema
+*/
+func (ir *VindaluApiHandler) AssetTypeSchemaWriteHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+		err     error
+
+		assetType = normalizeAssetType(mux.Vars(r)["asset_type"])
+		reqUser   = context.Get(r, Username).(string)
+		isAdmin   = context.Get(r, IsAdmin).(bool)
+	)
+
+	switch r.Method {
+	case "POST":
+		var def map[string]interface{}
+		if def, err = parseRequestBody(r); err == nil {
+			err = ir.SetTypeSchema(assetType, def, reqUser, isAdmin)
+		}
+		break
+	case "DELETE":
+		err = ir.RemoveTypeSchema(assetType, reqUser, isAdmin)
+		break
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data = []byte(fmt.Sprintf(`{"asset_type": "%s"}`, assetType))
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
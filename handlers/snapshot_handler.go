@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+)
+
+/*
+	List snapshots GET /_snapshots or take one POST /_snapshots (admin only)
+
+	Taking a snapshot waits for it to complete and removes those beyond the configured retention.
+*/
+func (ir *VindaluApiHandler) SnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+		rslt    interface{}
+		err     error
+
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	switch r.Method {
+	case "GET":
+		rslt, err = ir.ListSnapshots(reqUser, isAdmin)
+	case "POST":
+		rslt, err = ir.CreateSnapshot(reqUser, isAdmin)
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(rslt)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Get GET /_snapshots/<name> or remove DELETE /_snapshots/<name> a snapshot (admin only)
+*/
+func (ir *VindaluApiHandler) SnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+		rslt    interface{}
+		err     error
+
+		name    = mux.Vars(r)["name"]
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	switch r.Method {
+	case "GET":
+		rslt, err = ir.GetSnapshot(name, reqUser, isAdmin)
+	case "DELETE":
+		err = ir.RemoveSnapshot(name, reqUser, isAdmin)
+		rslt = map[string]string{"snapshot": name}
+	}
+
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(rslt)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+/*
+	Restore the indices from a snapshot POST /_snapshots/<name>/_restore (admin only)
+
+	The indices are closed while restoring so other requests fail until it completes.
+*/
+func (ir *VindaluApiHandler) SnapshotRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+
+		name    = mux.Vars(r)["name"]
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	snap, err := ir.RestoreSnapshot(name, reqUser, isAdmin)
+	if err != nil {
+		code, headers, data = errorResponse(err, 500)
+	} else {
+		code = 200
+		headers["Content-Type"] = "application/json"
+		data, _ = json.Marshal(snap)
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+	"github.com/vindalu/vindalu/export"
+)
+
+/*
+	Render query results as a BIND zone file GET /<asset_type>/_zone and GET /search/_zone
+
+	Takes the same filters and options as a search.  The `origin` param overrides the configured
+	zone origin.
+*/
+func (ir *VindaluApiHandler) DNSZoneHandler(w http.ResponseWriter, r *http.Request) {
+	ir.templateResponse(w, r, export.TEMPLATE_ZONE, "")
+}
+
+/*
+	Render query results as an /etc/hosts file GET /<asset_type>/_hosts and GET /search/_hosts
+
+	Takes the same filters and options as a search.  The `origin` param overrides the configured
+	zone origin used to qualify relative names.
+*/
+func (ir *VindaluApiHandler) HostsFileHandler(w http.ResponseWriter, r *http.Request) {
+	ir.templateResponse(w, r, export.TEMPLATE_HOSTS, "")
+}
+
+// Maximum size of a template sent as the request body
+const MAX_TEMPLATE_SIZE = 64 * 1024
+
+// Params of the template exports that are not search filters
+var templateParams = []string{"origin", "template"}
+
+/*
+	Render query results with a template GET /<asset_type>/_template and GET /search/_template
+
+	Renders the configured or built-in template named by the `template` param.
+*/
+func (ir *VindaluApiHandler) TemplateExportHandler(w http.ResponseWriter, r *http.Request) {
+	ir.templateResponse(w, r, r.URL.Query().Get("template"), "")
+}
+
+/*
+	Render query results with the text/template given as the request body
+	POST /<asset_type>/_template and POST /search/_template (admin only)
+*/
+func (ir *VindaluApiHandler) TemplateBodyExportHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		body []byte
+		err  error
+
+		reqUser = context.Get(r, Username).(string)
+		isAdmin = context.Get(r, IsAdmin).(bool)
+	)
+
+	if !isAdmin {
+		err = &core.AccessDeniedError{User: reqUser, Reason: "admin required for ad-hoc templates"}
+	} else if body, err = ioutil.ReadAll(io.LimitReader(r.Body, MAX_TEMPLATE_SIZE+1)); err == nil {
+		if len(body) > MAX_TEMPLATE_SIZE {
+			err = fmt.Errorf("Template exceeds %d bytes", MAX_TEMPLATE_SIZE)
+		} else if len(bytes.TrimSpace(body)) == 0 {
+			err = fmt.Errorf("Template required")
+		}
+	}
+	if err != nil {
+		code, headers, data := errorResponse(err, 400)
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		ir.writeAndLogResponse(w, r, code, headers, data)
+		return
+	}
+	ir.templateResponse(w, r, "request", string(body))
+}
+
+// Render the named template, or the given template text if not empty, with the query results
+func (ir *VindaluApiHandler) templateResponse(w http.ResponseWriter, r *http.Request, name, text string) {
+	var (
+		code    int
+		headers = map[string]string{}
+		data    []byte
+	)
+
+	out, err := ir.renderTemplate(r, normalizeAssetType(mux.Vars(r)["asset_type"]), name, text)
+	if err != nil {
+		code, headers, data = errorResponse(err, 400)
+	} else {
+		code = 200
+		headers["Content-Type"] = "text/plain"
+		data = out.Bytes()
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	ir.writeAndLogResponse(w, r, code, headers, data)
+}
+
+func (ir *VindaluApiHandler) renderTemplate(r *http.Request, assetType, name, text string) (out bytes.Buffer, err error) {
+	if len(text) == 0 {
+		if text, err = ir.lookupTemplate(name); err != nil {
+			return
+		}
+	}
+
+	cfg := ir.dnsConfig(r)
+	if name == export.TEMPLATE_ZONE {
+		if len(cfg.Origin) == 0 {
+			err = fmt.Errorf("Zone origin required")
+			return
+		}
+		if len(cfg.Nameservers) == 0 {
+			err = fmt.Errorf("Zone nameservers required")
+			return
+		}
+	}
+
+	assetType, userQuery, qo, err := ir.getQueryFromRequest(r, assetType, templateParams...)
+	if err != nil {
+		return
+	}
+	if len(qo.Aggregate) > 0 {
+		err = fmt.Errorf("Aggregation not supported for templates")
+		return
+	}
+
+	rsp, err := ir.ExecuteQuery(assetType, userQuery, &qo)
+	if err != nil {
+		return
+	}
+	latest, err := ir.LatestVersionTimestamp(assetType)
+	if err != nil {
+		return
+	}
+
+	params := map[string]string{}
+	for k, v := range r.URL.Query() {
+		params[k] = v[0]
+	}
+
+	assets, _ := rsp.([]core.BaseAsset)
+	err = export.ExecuteTemplate(&out, name, text, export.NewTemplateData(assets, cfg, latest, params))
+	return
+}
+
+// Text of a configured template or, if not configured, a built-in one
+func (ir *VindaluApiHandler) lookupTemplate(name string) (string, error) {
+	if len(name) == 0 {
+		return "", fmt.Errorf("Template name required")
+	}
+
+	if path, ok := ir.Config().Templates[name]; ok {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	if text, ok := export.BUILTIN_TEMPLATES[name]; ok {
+		return text, nil
+	}
+	return "", &core.NotFoundError{Kind: "Template", Name: name}
+}
+
+// Configured dns options overridden by the request params
+func (ir *VindaluApiHandler) dnsConfig(r *http.Request) config.DNSConfig {
+	cfg := ir.Config().DNS
+	if origin := r.URL.Query().Get("origin"); len(origin) > 0 {
+		cfg.Origin = origin
+	}
+	return cfg
+}
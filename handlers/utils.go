@@ -2,15 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
-	//"fmt"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 
 	//elastigo "github.com/mattbaird/elastigo/lib"
 
 	//"github.com/vindalu/vindalu/config"
 	"github.com/vindalu/vindalu/core"
+	"github.com/vindalu/vindalu/types"
 )
 
 type customStringType string
@@ -28,7 +30,10 @@ func normalizeAssetType(assetType string) string {
 	return strings.ToLower(assetType)
 }
 
-// Unmarshal request body to req
+/*
+	Unmarshal request body to req.  The body is decoded according to the Content-Type header and
+	must be an object.  JSON is assumed for other content types.
+*/
 func parseRequestBody(r *http.Request) (req map[string]interface{}, err error) {
 	req = map[string]interface{}{}
 
@@ -47,23 +52,119 @@ func parseRequestBody(r *http.Request) (req map[string]interface{}, err error) {
 	}
 	defer r.Body.Close()
 
-	err = json.Unmarshal(body, &req)
+	format := requestBodyFormat(r)
+	if format == FORMAT_JSON {
+		err = json.Unmarshal(body, &req)
+		return
+	}
+
+	var v interface{}
+	if v, err = decodeBody(format, body); err != nil {
+		return
+	}
+	if obj, ok := v.(map[string]interface{}); ok {
+		req = obj
+	} else if v != nil {
+		err = fmt.Errorf("Request body must be an object")
+	}
+	return
+}
+
+// Unmarshal request body into the given structure
+func decodeRequestBody(r *http.Request, v interface{}) (err error) {
+	var req map[string]interface{}
+	if req, err = parseRequestBody(r); err != nil {
+		return
+	}
+
+	var b []byte
+	if b, err = json.Marshal(req); err != nil {
+		return
+	}
+	return json.Unmarshal(b, v)
+}
+
+// HTTP status code for a given error returned by core
+func errorStatusCode(err error, defaultCode int) int {
+	switch err.(type) {
+	case *core.AccessDeniedError:
+		return 403
+	case *core.NotFoundError:
+		return 404
+	case *core.ValidationError:
+		return 400
+	case *core.ConflictError:
+		return 409
+	case *core.NotSupportedError:
+		return 501
+	case *core.UnavailableError:
+		return 503
+	}
+	return defaultCode
+}
+
+// HTTP response for a given error returned by core.  Validation errors are returned as json
+// listing each violation, all others as plain text.
+func errorResponse(err error, defaultCode int) (code int, headers map[string]string, data []byte) {
+	code = errorStatusCode(err, defaultCode)
+
+	if verr, ok := err.(*core.ValidationError); ok {
+		headers = map[string]string{"Content-Type": "application/json"}
+		data, _ = json.Marshal(map[string]interface{}{
+			"error":      "Validation failed",
+			"asset_type": verr.AssetType,
+			"id":         verr.AssetId,
+			"violations": verr.Violations,
+		})
+		return
+	}
+
+	headers = map[string]string{"Content-Type": "text/plain"}
+	data = []byte(err.Error())
 	return
 }
 
+// Fields selected with the `fields` param.  Multiple params and comma separated lists are supported.
+func requestedFields(r *http.Request) []string {
+	return listParam(r, "fields")
+}
+
+// Values of a list param.  Multiple params and comma separated lists are supported.
+func listParam(r *http.Request, name string) (vals []string) {
+	for _, v := range r.URL.Query()[name] {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); len(f) > 0 {
+				vals = append(vals, f)
+			}
+		}
+	}
+	return
+}
+
+// Check the `detail` request param.  Supplying the param without a value also enables it.
+func isDetailRequested(r *http.Request) bool {
+	return isParamEnabled(r, "detail")
+}
+
+// Check a boolean request param.  Supplying the param without a value also enables it.
+func isParamEnabled(r *http.Request, name string) bool {
+	vals, ok := r.URL.Query()[name]
+	if !ok {
+		return false
+	}
+	if len(vals) < 1 || len(vals[0]) == 0 {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(vals[0])
+	return enabled
+}
+
 /*
 	Return:
 		should also return the params as elastic search global args/opts
 */
 func getQueryParamsFromRequest(r *http.Request) (req map[string]interface{}, err error) {
-	paramsQuery := r.URL.Query()
-	req = map[string]interface{}{}
-	for k, v := range paramsQuery {
-		if !core.IsSearchParamOption(k) {
-			req[k] = strings.Join(v, "|")
-		}
-	}
-	return
+	return core.ParamsFilter(r.URL.Query()), nil
 }
 
 // Parse query from http request.  This is a wrapper to handle the body and query
@@ -88,3 +189,18 @@ func parseQueryFromHttpRequest(r *http.Request) (map[string]interface{}, error)
 
 	return paramReq, nil
 }
+
+/*
+	Assemble the asset type, filter and options for a query request.  When the `saved_query` param is
+	supplied, the saved query is used as the base with the filter and options in the request applied on top.
+	The `exclude` params are options of the endpoint and not used as filters.
+*/
+func (ir *VindaluApiHandler) getQueryFromRequest(r *http.Request, assetType string, exclude ...string) (string, map[string]interface{}, types.QueryOptions, error) {
+	params := r.URL.Query()
+	for _, k := range exclude {
+		params.Del(k)
+	}
+
+	bodyReq, _ := parseRequestBody(r)
+	return ir.QueryFromParams(assetType, params, bodyReq)
+}
@@ -2,8 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
+
+	"github.com/vindalu/vindalu/core"
+	"github.com/vindalu/vindalu/schema"
 )
 
 func Test_normalizeAssetType(t *testing.T) {
@@ -40,3 +45,123 @@ func Test_getQueryParamsFromRequest(t *testing.T) {
 		t.Fatalf("Error while parsing request query params!")
 	}
 }
+
+func Test_getQueryFromRequest(t *testing.T) {
+	ir := &VindaluApiHandler{VindaluCore: &core.VindaluCore{}}
+	r, _ := http.NewRequest("GET", "http://localhost:5454/v3/dnsrecord/_zone?zone=example.org&origin=example.org&template=a", nil)
+
+	_, query, _, err := ir.getQueryFromRequest(r, "dnsrecord", templateParams...)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(query) != 1 || query["zone"] != "example.org" {
+		t.Fatalf("Endpoint params should be excluded: %v", query)
+	}
+
+	r, _ = http.NewRequest("GET", "http://localhost:5454/v3/server/_ansible?host_field=hostname&group_by=roles&os=ubuntu", nil)
+	if _, query, _, _ = ir.getQueryFromRequest(r, "server", ansibleParams...); len(query) != 1 || query["os"] != "ubuntu" {
+		t.Fatalf("Ansible params should be excluded: %v", query)
+	}
+
+	// Filters on other endpoints
+	r, _ = http.NewRequest("GET", "http://localhost:5454/v3/server?origin=example.org&template=a&host_field=hostname", nil)
+	_, query, _, _ = ir.getQueryFromRequest(r, "server")
+	if query["origin"] != "example.org" || query["template"] != "a" || query["host_field"] != "hostname" {
+		t.Fatalf("Params should be filters: %v", query)
+	}
+}
+
+func Test_isDetailRequested(t *testing.T) {
+	urls := map[string]bool{
+		"http://localhost:5454/v3/pool/properties":              false,
+		"http://localhost:5454/v3/pool/properties?detail":       true,
+		"http://localhost:5454/v3/pool/properties?detail=true":  true,
+		"http://localhost:5454/v3/pool/properties?detail=false": false,
+	}
+
+	for u, expected := range urls {
+		r, _ := http.NewRequest("GET", u, nil)
+		if isDetailRequested(r) != expected {
+			t.Fatalf("Should be %v: %s", expected, u)
+		}
+	}
+}
+
+func Test_requestedFormat(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://localhost:5454/v3/server", nil)
+	if requestedFormat(r) != FORMAT_JSON {
+		t.Fatalf("Should default to json")
+	}
+
+	r.Header.Set("Accept", "text/csv")
+	if requestedFormat(r) != FORMAT_CSV {
+		t.Fatalf("Should honor Accept header")
+	}
+
+	r, _ = http.NewRequest("GET", "http://localhost:5454/v3/server?format=CSV&fields=id,name&fields=status", nil)
+	if requestedFormat(r) != FORMAT_CSV {
+		t.Fatalf("Should honor format param")
+	}
+	if fields := requestedFields(r); len(fields) != 3 || fields[1] != "name" {
+		t.Fatalf("Wrong fields: %v", fields)
+	}
+}
+
+func Test_encodeQueryResult(t *testing.T) {
+	assets := []core.BaseAsset{{Id: "web01", Type: "server", Data: map[string]interface{}{"status": "enabled"}}}
+
+	data, contentType, err := encodeQueryResult(assets, FORMAT_CSV, []string{"_id", "status"})
+	if err != nil || contentType != "text/csv" || string(data) != "_id,status\nweb01,enabled\n" {
+		t.Fatalf("Wrong csv: %s %s %v", contentType, data, err)
+	}
+
+	if _, contentType, err = encodeQueryResult(assets, FORMAT_JSON, nil); err != nil || contentType != "application/json" {
+		t.Fatalf("Wrong json: %s %v", contentType, err)
+	}
+	if _, _, err = encodeQueryResult(assets, "xls", nil); err == nil {
+		t.Fatalf("Should fail on unsupported format")
+	}
+}
+
+func Test_errorStatusCode(t *testing.T) {
+	if errorStatusCode(&core.AccessDeniedError{User: "foo", Reason: "test"}, 400) != 403 {
+		t.Fatalf("Access denied should be 403")
+	}
+	if errorStatusCode(&core.NotFoundError{Kind: "Schema", Name: "foo"}, 500) != 404 {
+		t.Fatalf("Not found should be 404")
+	}
+	if errorStatusCode(&core.NotSupportedError{Feature: "Archives", Datastore: "opensearch"}, 500) != 501 {
+		t.Fatalf("Not supported should be 501")
+	}
+	if errorStatusCode(&core.UnavailableError{Reason: "Reindex in progress"}, 500) != 503 {
+		t.Fatalf("Unavailable should be 503")
+	}
+	if errorStatusCode(fmt.Errorf("test"), 400) != 400 {
+		t.Fatalf("Should be default code")
+	}
+}
+
+func Test_errorResponse(t *testing.T) {
+	verr := &core.ValidationError{
+		AssetType:  "server",
+		AssetId:    "test1",
+		Violations: []schema.Violation{{Path: "/status", Message: "value must be one of [\"enabled\"]"}},
+	}
+	code, headers, data := errorResponse(verr, 500)
+	if code != 400 || headers["Content-Type"] != "application/json" {
+		t.Fatalf("Wrong response: %d %v", code, headers)
+	}
+
+	var rsp map[string]interface{}
+	if err := json.Unmarshal(data, &rsp); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(rsp["violations"].([]interface{})) != 1 {
+		t.Fatalf("Violations missing: %s", data)
+	}
+
+	if code, headers, _ = errorResponse(fmt.Errorf("test"), 500); code != 500 || headers["Content-Type"] != "text/plain" {
+		t.Fatalf("Wrong response: %d %v", code, headers)
+	}
+}
+
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/nats-io/gnatsd/server"
 
@@ -18,7 +19,10 @@ func NewVindaluApiHandler(vc *core.VindaluCore, log server.Logger) *VindaluApiHa
 	return &VindaluApiHandler{vc, log}
 }
 
-/* Helper function to write http data */
+/*
+	Helper function to write http data.  JSON responses are re-encoded in the format requested by
+	the client.
+*/
 func (ir *VindaluApiHandler) writeAndLogResponse(w http.ResponseWriter, r *http.Request, code int, headers map[string]string, data []byte) {
 
 	if headers != nil {
@@ -26,6 +30,17 @@ func (ir *VindaluApiHandler) writeAndLogResponse(w http.ResponseWriter, r *http.
 			w.Header().Set(k, v)
 		}
 	}
+
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.Header().Add("Vary", "Accept")
+		if encoded, contentType, err := encodeResponse(r, data); err != nil {
+			ir.apiLog.Errorf("Failed to encode response %s: %s\n", r.RequestURI, err)
+		} else {
+			w.Header().Set("Content-Type", contentType)
+			data = encoded
+		}
+	}
+
 	w.WriteHeader(code)
 	w.Write(data)
 
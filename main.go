@@ -19,6 +19,10 @@ var (
 
 	showVersion bool
 	configFile  string
+
+	// export/import command and archive file
+	archiveCmd  string
+	archiveFile string
 )
 
 func setupFlags() {
@@ -51,14 +55,23 @@ func setupFlags() {
 }
 
 func parseFlags() {
-	// non-flag options (xtra args), currently 'version' and 'help'
-	for _, arg := range flag.Args() {
+	// non-flag options (xtra args), currently 'version', 'help', 'export' and 'import'
+	args := flag.Args()
+	for i, arg := range args {
 		switch strings.ToLower(arg) {
 		case "version":
 			service.Version()
 		case "help":
 			flag.Usage()
 			os.Exit(0)
+		case service.CMD_EXPORT, service.CMD_IMPORT:
+			archiveCmd = strings.ToLower(arg)
+			if i+1 < len(args) {
+				archiveFile = args[i+1]
+			}
+		}
+		if len(archiveCmd) > 0 {
+			break
 		}
 	}
 	if showVersion {
@@ -81,6 +94,14 @@ func main() {
 	if err := config.LoadConfig(configFile, cfg); err != nil {
 		log.Fatalf("%s\n", err)
 	}
+
+	if len(archiveCmd) > 0 {
+		if err := service.RunArchiveCommand(cfg, archiveCmd, archiveFile, log); err != nil {
+			log.Fatalf("%s\n", err)
+		}
+		os.Exit(0)
+	}
+
 	// opts - cli options passed to mq
 	svgMgr, err := service.NewServiceManager(cfg, opts, log)
 	if err != nil {
@@ -0,0 +1,419 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// Keywords that are validated
+	VALIDATION_KEYWORDS = []string{
+		"type", "enum", "properties", "required", "additionalProperties",
+		"items", "minItems", "maxItems",
+		"pattern", "minLength", "maxLength",
+		"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	}
+	// Keywords that are accepted but do not affect validation
+	ANNOTATION_KEYWORDS = []string{
+		"$schema", "id", "$id", "title", "description", "default", "examples",
+	}
+	// Valid values for the `type` keyword
+	SCHEMA_TYPES = []string{
+		"object", "array", "string", "number", "integer", "boolean", "null",
+	}
+)
+
+// A single schema violation.  Path is a JSON pointer to the offending value.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+/*
+	Compiled JSON schema.  A subset of the JSON schema specification (draft 4) is supported.
+	Schemas using unsupported keywords are rejected rather than partially applied.
+*/
+type Schema struct {
+	types      []string
+	enum       []interface{}
+	properties map[string]*Schema
+	required   []string
+	// nil when any additional property is allowed
+	additionalProperties *Schema
+	noAdditional         bool
+
+	items    *Schema
+	minItems *int
+	maxItems *int
+
+	pattern   *regexp.Regexp
+	minLength *int
+	maxLength *int
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum bool
+	exclusiveMaximum bool
+}
+
+// Compile a schema from its JSON definition
+func New(def map[string]interface{}) (*Schema, error) {
+	return compile(def, "")
+}
+
+// Compile a schema from raw JSON
+func Parse(b []byte) (*Schema, error) {
+	var def map[string]interface{}
+	if err := json.Unmarshal(b, &def); err != nil {
+		return nil, err
+	}
+	return New(def)
+}
+
+func compile(def map[string]interface{}, path string) (s *Schema, err error) {
+	s = &Schema{}
+
+	for k, v := range def {
+		if !contains(VALIDATION_KEYWORDS, k) && !contains(ANNOTATION_KEYWORDS, k) {
+			return nil, fmt.Errorf("Unsupported schema keyword at '%s': %s", rootPath(path), k)
+		}
+
+		switch k {
+		case "type":
+			if s.types, err = compileTypes(v); err != nil {
+				return nil, fmt.Errorf("Invalid type at '%s': %s", rootPath(path), err)
+			}
+		case "enum":
+			var ok bool
+			if s.enum, ok = v.([]interface{}); !ok || len(s.enum) == 0 {
+				return nil, fmt.Errorf("enum must be a non-empty array at '%s'", rootPath(path))
+			}
+		case "properties":
+			props, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("properties must be an object at '%s'", rootPath(path))
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, pdef := range props {
+				if s.properties[name], err = compileSubschema(pdef, path+"/properties/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			if s.required, err = toStringList(v); err != nil {
+				return nil, fmt.Errorf("required must be a list of strings at '%s'", rootPath(path))
+			}
+		case "additionalProperties":
+			switch v.(type) {
+			case bool:
+				s.noAdditional = !v.(bool)
+			default:
+				if s.additionalProperties, err = compileSubschema(v, path+"/additionalProperties"); err != nil {
+					return nil, err
+				}
+			}
+		case "items":
+			if s.items, err = compileSubschema(v, path+"/items"); err != nil {
+				return nil, err
+			}
+		case "minItems":
+			s.minItems, err = toNonNegativeInt(k, v, path)
+		case "maxItems":
+			s.maxItems, err = toNonNegativeInt(k, v, path)
+		case "minLength":
+			s.minLength, err = toNonNegativeInt(k, v, path)
+		case "maxLength":
+			s.maxLength, err = toNonNegativeInt(k, v, path)
+		case "pattern":
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("pattern must be a string at '%s'", rootPath(path))
+			}
+			if s.pattern, err = regexp.Compile(str); err != nil {
+				return nil, fmt.Errorf("Invalid pattern at '%s': %s", rootPath(path), err)
+			}
+		case "minimum":
+			s.minimum, err = toNumber(k, v, path)
+		case "maximum":
+			s.maximum, err = toNumber(k, v, path)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = toBool(k, v, path)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = toBool(k, v, path)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func compileSubschema(def interface{}, path string) (*Schema, error) {
+	m, ok := def.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Schema must be an object at '%s'", rootPath(path))
+	}
+	return compile(m, path)
+}
+
+func compileTypes(v interface{}) ([]string, error) {
+	var types []string
+	switch v.(type) {
+	case string:
+		types = []string{v.(string)}
+	default:
+		var err error
+		if types, err = toStringList(v); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, t := range types {
+		if !contains(SCHEMA_TYPES, t) {
+			return nil, fmt.Errorf("unknown type '%s'", t)
+		}
+	}
+	return types, nil
+}
+
+// Validate a document against the schema returning all violations.
+func (s *Schema) Validate(doc interface{}) []Violation {
+	return s.validate(doc, "")
+}
+
+func (s *Schema) validate(val interface{}, path string) (violations []Violation) {
+	addViolation := func(format string, args ...interface{}) {
+		violations = append(violations, Violation{Path: rootPath(path), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !matchesAnyType(val, s.types) {
+		addViolation("expected %s, got %s", strings.Join(s.types, " or "), typeOf(val))
+		// Remaining keywords are type specific
+		return
+	}
+
+	if s.enum != nil && !inEnum(val, s.enum) {
+		addViolation("value must be one of %s", enumString(s.enum))
+	}
+
+	switch val.(type) {
+	case map[string]interface{}:
+		obj := val.(map[string]interface{})
+
+		for _, name := range s.required {
+			if _, ok := obj[name]; !ok {
+				addViolation("missing required property '%s'", name)
+			}
+		}
+
+		// Sorted for consistent output
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propPath := path + "/" + escapePointer(name)
+			if ps, ok := s.properties[name]; ok {
+				violations = append(violations, ps.validate(obj[name], propPath)...)
+			} else if s.additionalProperties != nil {
+				violations = append(violations, s.additionalProperties.validate(obj[name], propPath)...)
+			} else if s.noAdditional {
+				addViolation("additional property '%s' not allowed", name)
+			}
+		}
+
+	case []interface{}:
+		arr := val.([]interface{})
+
+		if s.minItems != nil && len(arr) < *s.minItems {
+			addViolation("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(arr) > *s.maxItems {
+			addViolation("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range arr {
+				violations = append(violations, s.items.validate(item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+
+	case string:
+		str := val.(string)
+		length := utf8.RuneCountInString(str)
+
+		if s.minLength != nil && length < *s.minLength {
+			addViolation("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			addViolation("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			addViolation("must match pattern '%s'", s.pattern.String())
+		}
+
+	default:
+		num, ok := toFloat(val)
+		if !ok {
+			break
+		}
+		if s.minimum != nil {
+			if s.exclusiveMinimum && num <= *s.minimum {
+				addViolation("must be greater than %v", *s.minimum)
+			} else if num < *s.minimum {
+				addViolation("must be greater than or equal to %v", *s.minimum)
+			}
+		}
+		if s.maximum != nil {
+			if s.exclusiveMaximum && num >= *s.maximum {
+				addViolation("must be less than %v", *s.maximum)
+			} else if num > *s.maximum {
+				addViolation("must be less than or equal to %v", *s.maximum)
+			}
+		}
+	}
+
+	return
+}
+
+// JSON type name of a decoded value.  Integral numbers are reported as integer.
+func typeOf(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+
+	if num, ok := toFloat(val); ok {
+		if num == math.Trunc(num) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", val)
+}
+
+func matchesAnyType(val interface{}, types []string) bool {
+	actual := typeOf(val)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(val interface{}, enum []interface{}) bool {
+	num, isNum := toFloat(val)
+	for _, e := range enum {
+		if isNum {
+			if enumNum, ok := toFloat(e); ok && enumNum == num {
+				return true
+			}
+		} else if reflect.DeepEqual(val, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumString(enum []interface{}) string {
+	b, _ := json.Marshal(enum)
+	return string(b)
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch val.(type) {
+	case float64:
+		return val.(float64), true
+	case float32:
+		return float64(val.(float32)), true
+	case int:
+		return float64(val.(int)), true
+	case int64:
+		return float64(val.(int64)), true
+	case json.Number:
+		f, err := val.(json.Number).Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toNumber(keyword string, v interface{}, path string) (*float64, error) {
+	f, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number at '%s'", keyword, rootPath(path))
+	}
+	return &f, nil
+}
+
+func toNonNegativeInt(keyword string, v interface{}, path string) (*int, error) {
+	f, ok := toFloat(v)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return nil, fmt.Errorf("%s must be a non-negative integer at '%s'", keyword, rootPath(path))
+	}
+	i := int(f)
+	return &i, nil
+}
+
+func toBool(keyword string, v interface{}, path string) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s must be a boolean at '%s'", keyword, rootPath(path))
+	}
+	return b, nil
+}
+
+func toStringList(v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a list")
+	}
+	out := make([]string, len(list))
+	for i, item := range list {
+		if out[i], ok = item.(string); !ok {
+			return nil, fmt.Errorf("not a string: %v", item)
+		}
+	}
+	return out, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Escape a property name for use in a JSON pointer
+func escapePointer(name string) string {
+	return strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+}
+
+func rootPath(path string) string {
+	if len(path) == 0 {
+		return "/"
+	}
+	return path
+}
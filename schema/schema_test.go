@@ -0,0 +1,131 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var testSchema = []byte(`{
+	"$schema": "http://json-schema.org/draft-04/schema#",
+	"title": "server",
+	"type": "object",
+	"required": ["hostname", "status"],
+	"additionalProperties": false,
+	"properties": {
+		"hostname": {"type": "string", "pattern": "^[a-z0-9\\-]+$", "maxLength": 16},
+		"status":   {"enum": ["enabled", "disabled"]},
+		"cpus":     {"type": "integer", "minimum": 1, "maximum": 64},
+		"load":     {"type": "number", "minimum": 0, "exclusiveMinimum": true},
+		"tags":     {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"location": {
+			"type": "object",
+			"required": ["dc"],
+			"properties": {
+				"dc":   {"type": "string", "minLength": 2},
+				"rack": {"type": ["string", "null"]}
+			}
+		}
+	}
+}`)
+
+func parseDoc(t *testing.T, s string) map[string]interface{} {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func Test_Parse(t *testing.T) {
+	if _, err := Parse(testSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []string{
+		`{"type": "foo"}`,
+		`{"oneOf": [{"type": "string"}]}`,
+		`{"properties": {"a": {"pattern": "("}}}`,
+		`{"properties": {"a": {"minLength": -1}}}`,
+		`{"required": "a"}`,
+		`{"enum": []}`,
+		`{"items": "string"}`,
+	}
+	for _, s := range invalid {
+		if _, err := Parse([]byte(s)); err == nil {
+			t.Fatalf("Should fail: %s", s)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func Test_Schema_Validate(t *testing.T) {
+	sch, _ := Parse(testSchema)
+
+	valid := parseDoc(t, `{
+		"hostname": "web-01",
+		"status": "enabled",
+		"cpus": 8,
+		"load": 0.5,
+		"tags": ["a", "b"],
+		"location": {"dc": "us1", "rack": null}
+	}`)
+	if v := sch.Validate(valid); len(v) != 0 {
+		t.Fatalf("Should be valid: %v", v)
+	}
+
+	invalid := parseDoc(t, `{
+		"hostname": "Web_01",
+		"cpus": 8.5,
+		"load": 0,
+		"tags": ["a", 1, "c"],
+		"location": {"rack": 5},
+		"foo": "bar"
+	}`)
+	violations := sch.Validate(invalid)
+	for _, v := range violations {
+		t.Log(v)
+	}
+
+	expected := map[string]bool{
+		"/":              false, // missing status & additional foo
+		"/hostname":      false,
+		"/cpus":          false,
+		"/load":          false,
+		"/tags":          false,
+		"/tags/1":        false,
+		"/location":      false,
+		"/location/rack": false,
+	}
+	for _, v := range violations {
+		if _, ok := expected[v.Path]; !ok {
+			t.Fatalf("Unexpected violation: %s", v)
+		}
+		expected[v.Path] = true
+	}
+	for path, found := range expected {
+		if !found {
+			t.Fatalf("Missing violation for: %s", path)
+		}
+	}
+}
+
+func Test_Schema_Validate_Type(t *testing.T) {
+	sch, _ := Parse([]byte(`{"type": "object"}`))
+	if v := sch.Validate([]interface{}{}); len(v) != 1 || v[0].Message != "expected object, got array" {
+		t.Fatalf("Wrong violation: %v", v)
+	}
+
+	sch, _ = Parse([]byte(`{"type": "number"}`))
+	for _, val := range []interface{}{1, int64(2), 3.5} {
+		if v := sch.Validate(val); len(v) != 0 {
+			t.Fatalf("Should be valid: %v", v)
+		}
+	}
+}
+
+func Test_escapePointer(t *testing.T) {
+	if escapePointer("a/b~c") != "a~1b~0c" {
+		t.Fatalf("Escape failed: %s", escapePointer("a/b~c"))
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/nats-io/gnatsd/server"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+)
+
+// Commands run instead of starting the server
+const (
+	CMD_EXPORT = "export"
+	CMD_IMPORT = "import"
+)
+
+/*
+	Export the inventory to, or import it from, an archive file without starting the server.
+	Exported archives are gzip compressed if the file name ends in .gz or .tgz.
+*/
+func RunArchiveCommand(cfg *config.InventoryConfig, cmd, path string, log server.Logger) error {
+	if len(path) == 0 {
+		return fmt.Errorf("Archive file required: vindalu -c <config> %s <file>", cmd)
+	}
+
+	vc, err := core.NewVindaluCore(cfg, log)
+	if err != nil {
+		return err
+	}
+	// Nothing consumes events without the server
+	go func() {
+		for range vc.EventQ {
+		}
+	}()
+
+	username := "vindalu"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+
+	var manifest *core.ArchiveManifest
+	switch cmd {
+	case CMD_EXPORT:
+		manifest, err = exportArchiveFile(vc, path, username)
+	case CMD_IMPORT:
+		manifest, err = importArchiveFile(vc, path, username)
+	default:
+		err = fmt.Errorf("Unknown command: %s", cmd)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Noticef("%sed %s: types=%d assets=%d versions=%d meta=%d\n", strings.Title(cmd), path,
+		len(manifest.Types), manifest.Assets, manifest.Versions, manifest.MetaDocs)
+	return nil
+}
+
+func exportArchiveFile(vc *core.VindaluCore, path, username string) (*core.ArchiveManifest, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		w  io.Writer = f
+		gz *gzip.Writer
+	)
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		gz = gzip.NewWriter(f)
+		w = gz
+	}
+
+	manifest, err := vc.ExportArchive(w, username, true)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func importArchiveFile(vc *core.VindaluCore, path, username string) (*core.ArchiveManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return vc.ImportArchive(f, username, true)
+}
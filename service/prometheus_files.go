@@ -0,0 +1,114 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/gnatsd/server"
+
+	"github.com/vindalu/vindalu/config"
+	"github.com/vindalu/vindalu/core"
+	"github.com/vindalu/vindalu/export"
+)
+
+// Delay before writing target files after a change so bursts of changes result in a single write
+const PROMETHEUS_WRITE_DELAY = 1 * time.Second
+
+// Keeps prometheus file_sd target files up to date with the inventory
+type PrometheusFileWriter struct {
+	cfg config.PrometheusConfig
+	inv *core.VindaluCore
+
+	changed chan bool
+
+	log server.Logger
+}
+
+func NewPrometheusFileWriter(cfg config.PrometheusConfig, inv *core.VindaluCore, log server.Logger) *PrometheusFileWriter {
+	return &PrometheusFileWriter{cfg: cfg, inv: inv, changed: make(chan bool, 1), log: log}
+}
+
+// Signal a change in the inventory.  Does not block.
+func (pw *PrometheusFileWriter) Notify() {
+	select {
+	case pw.changed <- true:
+	default:
+	}
+}
+
+/*
+	Write all files at startup and after each change.  Changes signaled while waiting for the
+	write delay are covered by the same write.
+*/
+func (pw *PrometheusFileWriter) Start() {
+	pw.WriteAll()
+	for {
+		<-pw.changed
+		time.Sleep(PROMETHEUS_WRITE_DELAY)
+		select {
+		case <-pw.changed:
+		default:
+		}
+		pw.WriteAll()
+	}
+}
+
+/*
+	Write all files whose targets changed.  The datastore is refreshed first as changes are
+	signaled before they are searchable.
+*/
+func (pw *PrometheusFileWriter) WriteAll() {
+	if err := pw.inv.Refresh(); err != nil {
+		pw.log.Errorf("Refresh failed: %s\n", err)
+	}
+
+	for _, f := range pw.cfg.Files {
+		var groups []export.PrometheusTargetGroup
+		params, err := url.ParseQuery(f.Query)
+		if err == nil {
+			groups, err = export.QueryPrometheusTargets(pw.inv, pw.cfg, strings.ToLower(f.AssetType), params, nil)
+		}
+		if err != nil {
+			pw.log.Errorf("Failed to get prometheus targets for %s: %s\n", f.Path, err)
+			continue
+		}
+
+		var written bool
+		if written, err = writeTargetFile(f.Path, groups); err != nil {
+			pw.log.Errorf("Failed to write prometheus targets to %s: %s\n", f.Path, err)
+		} else if written {
+			pw.log.Noticef("Prometheus targets written: %s (%d)\n", f.Path, len(groups))
+		}
+	}
+}
+
+/*
+	Write the targets if they differ from the file contents.  The file is replaced by renaming a
+	temporary file so prometheus never reads a partial file.
+*/
+func writeTargetFile(path string, targets interface{}) (bool, error) {
+	b, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	b = append(b, '\n')
+
+	if existing, err := ioutil.ReadFile(path); err == nil && bytes.Equal(existing, b) {
+		return false, nil
+	}
+
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return false, err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_writeTargetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vindalu-prometheus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "targets.json")
+	targets := []map[string]interface{}{{"targets": []string{"web01:9100"}}}
+
+	if written, err := writeTargetFile(path, targets); err != nil || !written {
+		t.Fatalf("Should write file: %v", err)
+	}
+	if written, err := writeTargetFile(path, targets); err != nil || written {
+		t.Fatalf("Should not rewrite unchanged file: %v", err)
+	}
+
+	targets[0]["targets"] = []string{"web02:9100"}
+	if written, _ := writeTargetFile(path, targets); !written {
+		t.Fatalf("Should write changed file")
+	}
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("Temporary file should be renamed")
+	}
+}
@@ -35,6 +35,9 @@ type ServiceManager struct {
 	// Cluster operations
 	clusterStatus *core.VindaluClusterStatus
 
+	// Keeps prometheus target files up to date.  nil if none are configured.
+	promWriter *PrometheusFileWriter
+
 	log server.Logger
 }
 
@@ -136,21 +139,48 @@ func (sm *ServiceManager) startEventProcessor() error {
 			return fmt.Errorf("nats client failed to connect to %s: %s", svrAddrs, err)
 		}
 
-		evtProc := events.NewEventProcessor(sm.inv.EventQ, nclient, sm.log)
+		evtProc := events.NewEventProcessor(sm.eventQueue(), nclient, sm.log)
 		evtProc.Start()
 		break
 	default:
 		// Events disabled - simply drain the channel
+		evtQ := sm.eventQueue()
 		for {
-			<-sm.inv.EventQ
+			<-evtQ
 		}
 		break
 	}
 	return nil
 }
 
+/*
+	Queue of events to publish.  When prometheus target files are configured events are passed
+	through, signaling the file writer of changes.
+*/
+func (sm *ServiceManager) eventQueue() chan core.Event {
+	if sm.promWriter == nil {
+		return sm.inv.EventQ
+	}
+
+	evtQ := make(chan core.Event)
+	go func() {
+		for evt := range sm.inv.EventQ {
+			sm.promWriter.Notify()
+			evtQ <- evt
+		}
+	}()
+	return evtQ
+}
+
 func (sm *ServiceManager) Start() {
 
+	if len(sm.cfg.Prometheus.Files) > 0 {
+		sm.promWriter = NewPrometheusFileWriter(sm.cfg.Prometheus, sm.inv.VindaluCore, sm.log)
+		go sm.promWriter.Start()
+	}
+
+	go sm.inv.ScheduleSnapshots()
+
 	go func() {
 		if err := sm.startHttpApiServer(); err != nil {
 			sm.log.Fatalf("Failed to start HTTP API server: %s\n", err)
@@ -228,6 +258,18 @@ func (sm *ServiceManager) getEndpointsRouter() (rtr *mux.Router) {
 	// Search shares handler with `AssetTypeGetHandler`
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search", sm.inv.AssetTypeGetHandler).
 		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_versions", sm.inv.AssetTypeVersionsGetHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_ansible", sm.inv.AnsibleInventoryHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_prometheus", sm.inv.PrometheusTargetsHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_zone", sm.inv.DNSZoneHandler).Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_hosts", sm.inv.HostsFileHandler).Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_template", sm.inv.TemplateExportHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/search/_template", sm.authWrapper(sm.inv.TemplateBodyExportHandler)).
+		Methods("POST")
 
 	// Ess raw queries
 	rtr.HandleFunc(sm.cfg.Endpoints.Raw+"/versions/{raw:.*}", sm.inv.ESSRawVersionsHandler).Methods("GET")
@@ -239,16 +281,89 @@ func (sm *ServiceManager) getEndpointsRouter() (rtr *mux.Router) {
 
 	rtr.HandleFunc("/auth/access_token", sm.authWrapper(sm.inv.AuthTokenHandler)).Methods("POST")
 
+	// Inventory archive export and import (admin only)
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_archive", sm.authWrapper(sm.inv.ArchiveExportHandler)).Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_archive", sm.authWrapper(sm.inv.ArchiveImportHandler)).Methods("POST")
+
+	// Reindexing into new indices (admin only)
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_reindex", sm.authWrapper(sm.inv.ReindexHandler)).
+		Methods("GET", "POST")
+
+	// Index snapshots (admin only)
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_snapshots", sm.authWrapper(sm.inv.SnapshotsHandler)).
+		Methods("GET", "POST")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_snapshots/{name}/_restore",
+		sm.authWrapper(sm.inv.SnapshotRestoreHandler)).Methods("POST")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_snapshots/{name}", sm.authWrapper(sm.inv.SnapshotHandler)).
+		Methods("GET", "DELETE")
+
+	// Saved queries
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_queries", sm.inv.SavedQueryListHandler).Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_queries", sm.inv.SavedQueryOptionsHandler).Methods("OPTIONS")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_queries/{name}/results", sm.inv.SavedQueryResultsHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_queries/{name}", sm.inv.SavedQueryGetHandler).Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_queries/{name}",
+		sm.authWrapper(sm.inv.SavedQueryWriteHandler)).Methods("POST", "DELETE")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/_queries/{name}", sm.inv.SavedQueryOptionsHandler).
+		Methods("OPTIONS")
+
 	// asset version handler
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/{asset}/versions", sm.inv.AssetVersionsHandler).
 		Methods("GET")
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/{asset}/versions", sm.inv.AssetVersionsOptionsHandler).
 		Methods("OPTIONS")
 
+	// Links referencing an asset
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/{asset}/_referrers", sm.inv.AssetReferrersHandler).
+		Methods("GET")
+	// Graph of linked assets
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/{asset}/_graph", sm.inv.AssetGraphHandler).
+		Methods("GET")
+	// Rename asset
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/{asset}/_rename",
+		sm.authWrapper(sm.inv.AssetRenameHandler)).Methods("POST")
+
+	// JSON schema for an asset type
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_schema", sm.inv.AssetTypeSchemaGetHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_schema",
+		sm.authWrapper(sm.inv.AssetTypeSchemaWriteHandler)).Methods("POST", "DELETE")
+
+	// Unique constraint violations within an asset type
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_unique", sm.inv.AssetTypeUniqueViolationsHandler).
+		Methods("GET")
+	// Import assets from csv
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_import",
+		sm.authWrapper(sm.inv.AssetTypeImportHandler)).Methods("POST")
+
+	// Ansible dynamic inventory of an asset type
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_ansible", sm.inv.AnsibleInventoryHandler).
+		Methods("GET")
+	// Prometheus targets of an asset type
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_prometheus", sm.inv.PrometheusTargetsHandler).
+		Methods("GET")
+	// DNS zone and hosts files of an asset type
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_zone", sm.inv.DNSZoneHandler).Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_hosts", sm.inv.HostsFileHandler).Methods("GET")
+	// Render an asset type with a template
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_template", sm.inv.TemplateExportHandler).
+		Methods("GET")
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_template", sm.authWrapper(sm.inv.TemplateBodyExportHandler)).
+		Methods("POST")
+
+	// Search versions within an asset type
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_versions", sm.inv.AssetTypeVersionsGetHandler).
+		Methods("GET")
+
 	// List fields for an asset type
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/properties", sm.inv.AssetTypePropertiesHandler).
 		Methods("GET")
 
+	// Create asset with a server generated id
+	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/_new", sm.authWrapper(sm.inv.AssetCreateHandler)).
+		Methods("POST")
+
 	// asset handler
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}/{asset}", sm.inv.AssetGetHandler).
 		Methods("GET")
@@ -261,7 +376,7 @@ func (sm *ServiceManager) getEndpointsRouter() (rtr *mux.Router) {
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}", sm.inv.AssetTypeGetHandler).
 		Methods("GET")
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}",
-		sm.authWrapper(sm.inv.AssetTypePostHandler)).Methods("POST")
+		sm.authWrapper(sm.inv.AssetTypeWriteRequestHandler)).Methods("POST", "PUT", "DELETE")
 	rtr.HandleFunc(sm.cfg.Endpoints.Prefix+"/{asset_type}", sm.inv.AssetTypeOptionsHandler).
 		Methods("OPTIONS")
 
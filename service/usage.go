@@ -9,6 +9,8 @@ import (
 
 const usage = `
 vindalu [ options ]
+vindalu [ options ] export FILE
+vindalu [ options ] import FILE
 
  Server Options:
     -c, --config FILE               Configuration File (required)
@@ -22,6 +24,11 @@ vindalu [ options ]
     -D, --debug                     Enable debugging output
     -V, --trace                     Trace the raw protocol
 
+ Commands:
+    export FILE                     Export all types, assets and versions to a tar archive
+                                    (gzip compressed if FILE ends in .gz or .tgz)
+    import FILE                     Restore a tar archive into an empty datastore
+
  Common Options:
     -h, --help, help                Show this message
     -v, --version, version          Show version
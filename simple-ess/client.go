@@ -0,0 +1,64 @@
+package simpless
+
+import (
+	"fmt"
+
+	elastigo "github.com/mattbaird/elastigo/lib"
+)
+
+// Non 2xx response other than a 404 from elasticsearch
+type ResponseError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("Elasticsearch error (%d): %s", e.StatusCode, e.Body)
+}
+
+// Check if the error is a response with the given status code
+func IsResponseStatus(err error, code int) bool {
+	rerr, ok := err.(*ResponseError)
+	return ok && rerr.StatusCode == code
+}
+
+/*
+	Plain HTTP client used with elasticsearch 7 and later as well as OpenSearch.  Unlike the
+	elastigo connection every request with a body is sent with a JSON content type as required
+	by these versions.
+*/
+type Client struct {
+	Pool *Pool
+}
+
+func NewClient(pool *Pool) *Client {
+	return &Client{Pool: pool}
+}
+
+/*
+	Execute a request returning the response body.  The signature and the errors match the
+	elastigo connection i.e. a 404 response returns elastigo.RecordNotFound.  Strings and bytes
+	are sent as is, any other data is json encoded.
+*/
+func (c *Client) DoCommand(method, path string, args map[string]interface{}, data interface{}) ([]byte, error) {
+	return c.DoCommandWithOptions(method, path, args, data, RequestOptions{})
+}
+
+func (c *Client) DoCommandWithOptions(method, path string, args map[string]interface{}, data interface{}, opts RequestOptions) ([]byte, error) {
+	code, b, err := c.Pool.DoCommandWithOptions(method, path, args, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code == 404:
+		return b, elastigo.RecordNotFound
+	case code > 299:
+		return b, &ResponseError{StatusCode: code, Body: b}
+	}
+	return b, nil
+}
+
+func (c *Client) Close() {
+	c.Pool.Close()
+}
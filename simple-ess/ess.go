@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	elastigo "github.com/mattbaird/elastigo/lib"
 	//"github.com/nats-io/gnatsd/server"
@@ -18,6 +19,7 @@ type EssTypeMapping struct {
 	Timestamp        interface{}            `json:"_timestamp"`
 	Properties       map[string]interface{} `json:"properties"`
 	DynamicTemplates []interface{}          `json:"dynamic_templates"`
+	Meta             map[string]interface{} `json:"_meta,omitempty"`
 }
 
 //Ess response
@@ -27,8 +29,15 @@ type AggrField struct {
 	Buckets                 []AggrBucketItem `json:"buckets"`
 }
 type AggrBucketItem struct {
-	Key      interface{} `json:"key"`
-	DocCount int64       `json:"doc_count"`
+	Key         interface{} `json:"key"`
+	KeyAsString string      `json:"key_as_string,omitempty"` // e.g. ip and date fields
+	DocCount    int64       `json:"doc_count"`
+}
+
+// Single bucket (e.g. filter) or single value metric (e.g. cardinality) aggregation
+type AggrMetric struct {
+	DocCount int64 `json:"doc_count"`
+	Value    int64 `json:"value"`
 }
 
 type ElasticsearchVersion struct {
@@ -50,27 +59,66 @@ type EssInfo struct {
 /* ESS data with version index */
 type ExtendedEssConn struct {
 	*elastigo.Conn
+	// Used by DoCommand and the helpers in helpers.go.  Other elastigo helpers would bypass it.
+	Pool *Pool
 	//Index        string
 	//VersionIndex string
 	//log server.Logger
 }
 
 /*
-   Create the index if it does not exist.
-   Optionally apply a mapping if mapping file is supplied.
+   Create a connection using the hosts, scheme and credentials of the pool.
 */
-func NewExtendedEssConn(esshost string, essport int) *ExtendedEssConn {
+func NewExtendedEssConn(pool *Pool) *ExtendedEssConn {
 
 	ed := ExtendedEssConn{
 		Conn: elastigo.NewConn(),
+		Pool: pool,
 	}
 
-	ed.Domain = esshost
-	ed.Port = fmt.Sprintf("%d", essport)
+	ed.Protocol = pool.Scheme()
+	ed.Username = pool.Username()
+	ed.Password = pool.Password()
+	ed.SetHosts(pool.Hosts())
 
 	return &ed
 }
 
+/*
+	Execute a request through the pool, retrying idempotent requests on other hosts.  Errors
+	match the elastigo connection i.e. a 404 returns elastigo.RecordNotFound and an error
+	response an elastigo.ESError.
+*/
+func (e *ExtendedEssConn) DoCommand(method, path string, args map[string]interface{}, data interface{}) ([]byte, error) {
+	return e.DoCommandWithOptions(method, path, args, data, RequestOptions{})
+}
+
+func (e *ExtendedEssConn) DoCommandWithOptions(method, path string, args map[string]interface{}, data interface{}, opts RequestOptions) ([]byte, error) {
+	code, body, err := e.Pool.DoCommandWithOptions(method, path, args, data, opts)
+	if err != nil {
+		return body, err
+	}
+
+	switch {
+	case code == 404:
+		return body, elastigo.RecordNotFound
+	case code > 304:
+		var response map[string]interface{}
+		if err = json.Unmarshal(body, &response); err != nil {
+			return body, err
+		}
+		if resErr, ok := response["error"]; ok {
+			return body, elastigo.ESError{When: time.Now(), What: fmt.Sprintf("Error [%s] Status [%v]", resErr, response["status"]), Code: code}
+		}
+	}
+	return body, nil
+}
+
+func (e *ExtendedEssConn) Close() {
+	e.Conn.Close()
+	e.Pool.Close()
+}
+
 func (e *ExtendedEssConn) IndexExists(index string) bool {
 	_, err := e.DoCommand("GET", "/"+index, nil, nil)
 	if err != nil {
@@ -137,6 +185,34 @@ func (e *ExtendedEssConn) CreateFSBackupRepo(repoName, location string, compress
 }
 
 func (e *ExtendedEssConn) GetPropertiesForType(index, pType string) (props []string, err error) {
+	var propMap map[string]interface{}
+	if propMap, err = e.GetPropertyMappingsForType(index, pType); err != nil {
+		return
+	}
+
+	props = []string{"id", "timestamp"}
+	for pk, _ := range propMap {
+		props = append(props, pk)
+	}
+	return
+}
+
+/* Mapping definition of each top level property for a given type e.g. {"host": {"type": "string"}} */
+func (e *ExtendedEssConn) GetPropertyMappingsForType(index, pType string) (props map[string]interface{}, err error) {
+	var typeMap EssTypeMapping
+	if typeMap, err = e.GetTypeMapping(index, pType); err != nil {
+		return
+	}
+
+	props = typeMap.Properties
+	if props == nil {
+		props = map[string]interface{}{}
+	}
+	return
+}
+
+/* Complete mapping for a given type */
+func (e *ExtendedEssConn) GetTypeMapping(index, pType string) (typeMap EssTypeMapping, err error) {
 	var b []byte
 	if b, err = e.DoCommand("GET", fmt.Sprintf("/%s/%s/_mapping", index, pType), nil, nil); err != nil {
 		return
@@ -147,15 +223,34 @@ func (e *ExtendedEssConn) GetPropertiesForType(index, pType string) (props []str
 	}
 	//	e.log.Noticef("%#v\n", tmp)
 
-	props = []string{"id", "timestamp"}
-	if typeMap, ok := tmp[index]["mappings"][pType]; ok {
-		for pk, _ := range typeMap.Properties {
-			props = append(props, pk)
-		}
-	} else {
+	// Keyed by the concrete index as the index may be an alias
+	indices := make([]string, 0, len(tmp))
+	for idx := range tmp {
+		indices = append(indices, idx)
+	}
+	typeMap, ok := tmp[CurrentIndex(index, indices)]["mappings"][pType]
+	if !ok {
 		err = fmt.Errorf("Type not found: %s", pType)
 	}
+	return
+}
 
+/*
+	Index written to out of those an alias points to.  An alias normally points to a single
+	index, but may point to several while its indices are swapped, in which case it is the newest
+	i.e. highest numbered `<alias>_v<n>`.  Ties are broken by name.
+*/
+func CurrentIndex(alias string, indices []string) (current string) {
+	n := -1
+	for _, idx := range indices {
+		i, err := strconv.Atoi(strings.TrimPrefix(idx, alias+"_v"))
+		if !strings.HasPrefix(idx, alias+"_v") || err != nil {
+			i = 0
+		}
+		if i > n || (i == n && idx > current) {
+			current, n = idx, i
+		}
+	}
 	return
 }
 
@@ -240,7 +335,7 @@ func (e *ExtendedEssConn) readMappingFile(mapfile string) (mapdata map[string]in
 
 func (e *ExtendedEssConn) putMappingFromJSON(idx, typeName string, data []byte, ignoreConflicts bool) error {
 	//fmt.Println("mapping", idx, typeName)
-	_, err := e.Conn.DoCommand("PUT", fmt.Sprintf("/%s/%s/_mapping", idx, typeName),
+	_, err := e.DoCommand("PUT", fmt.Sprintf("/%s/%s/_mapping", idx, typeName),
 		map[string]interface{}{"ignore_conflicts": ignoreConflicts}, string(data))
 
 	return err
@@ -8,8 +8,7 @@ import (
 )
 
 var (
-	testEssHost = "localhost"
-	testEssPort = 9200
+	testPool, _ = NewPool(PoolConfig{Hosts: []string{"localhost:9200"}})
 	testIndexM  = "test_index_with_mapping"
 	testIndex   = "test_index"
 
@@ -23,7 +22,7 @@ var (
 )
 
 func Test_ExtendedEssConn_Info(t *testing.T) {
-	e := NewExtendedEssConn(testEssHost, testEssPort)
+	e := NewExtendedEssConn(testPool)
 
 	info, err := e.Info()
 	if err != nil {
@@ -37,7 +36,7 @@ func Test_ExtendedEssConn_Info(t *testing.T) {
 }
 
 func Test_ExtendedEssConn_IndexExists(t *testing.T) {
-	e := NewExtendedEssConn(testEssHost, testEssPort)
+	e := NewExtendedEssConn(testPool)
 
 	if e.IndexExists(testIndex) {
 		t.Fatalf("Index should not exist!")
@@ -48,7 +47,7 @@ func Test_ExtendedEssConn_IndexExists(t *testing.T) {
 }
 
 func Test_ExtendedEssConn_readMappingFile(t *testing.T) {
-	e := NewExtendedEssConn(testEssHost, testEssPort)
+	e := NewExtendedEssConn(testPool)
 	mapdata, err := e.readMappingFile(testMappingFile)
 	if err != nil {
 		t.Fatalf("%s", err)
@@ -58,7 +57,7 @@ func Test_ExtendedEssConn_readMappingFile(t *testing.T) {
 }
 
 func Test_ExtendedEssConn_IsVersionSupported(t *testing.T) {
-	e := NewExtendedEssConn(testEssHost, testEssPort)
+	e := NewExtendedEssConn(testPool)
 
 	if !e.IsVersionSupported() {
 		t.Fatalf("Version is supposed to be supported, check your ES version")
@@ -66,7 +65,7 @@ func Test_ExtendedEssConn_IsVersionSupported(t *testing.T) {
 }
 
 func Test_ExtendedEssConn_ApplyMappingDir(t *testing.T) {
-	e := NewExtendedEssConn(testEssHost, testEssPort)
+	e := NewExtendedEssConn(testPool)
 
 	_, err := e.CreateIndex(testIndex)
 	if err != nil {
@@ -80,7 +79,7 @@ func Test_ExtendedEssConn_ApplyMappingDir(t *testing.T) {
 }
 
 func Test_ExtendedEssConn_GetPropertiesForType(t *testing.T) {
-	e := NewExtendedEssConn(testEssHost, testEssPort)
+	e := NewExtendedEssConn(testPool)
 	_, err := e.GetPropertiesForType(testIndex, "pool")
 	if err == nil {
 		t.Fatalf("Should have errored!")
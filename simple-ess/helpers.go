@@ -0,0 +1,155 @@
+package simpless
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	elastigo "github.com/mattbaird/elastigo/lib"
+)
+
+/*
+	The elastigo helpers used by vindalu.  They have the same signatures and responses as the
+	elastigo versions but are sent through the pool so the TLS, timeout, retry and failover
+	settings apply.
+*/
+
+// Unmarshal the response of a request unless it failed
+func (e *ExtendedEssConn) doCommandInto(method, path string, args map[string]interface{}, data, v interface{}) ([]byte, error) {
+	body, err := e.DoCommand(method, path, args, data)
+	if err != nil {
+		return body, err
+	}
+	return body, json.Unmarshal(body, v)
+}
+
+func (e *ExtendedEssConn) Search(index string, _type string, args map[string]interface{}, query interface{}) (elastigo.SearchResult, error) {
+	var retval elastigo.SearchResult
+
+	path := fmt.Sprintf("/%s/_search", index)
+	if len(_type) > 0 && _type != "*" {
+		path = fmt.Sprintf("/%s/%s/_search", index, _type)
+	}
+	body, err := e.doCommandInto("POST", path, args, query, &retval)
+	if err != nil {
+		return retval, err
+	}
+	retval.RawJSON = body
+	return retval, nil
+}
+
+func (e *ExtendedEssConn) Index(index string, _type string, id string, args map[string]interface{}, data interface{}) (elastigo.BaseResponse, error) {
+	return e.IndexWithParameters(index, _type, id, "", 0, "", "", "", 0, "", "", false, args, data)
+}
+
+func (e *ExtendedEssConn) IndexWithParameters(index string, _type string, id string, parentId string, version int, op_type string,
+	routing string, timestamp string, ttl int, percolate string, timeout string, refresh bool,
+	args map[string]interface{}, data interface{}) (retval elastigo.BaseResponse, err error) {
+
+	if len(index) == 0 {
+		err = fmt.Errorf("index can not be blank")
+		return
+	}
+	if len(_type) == 0 && len(id) > 0 {
+		err = fmt.Errorf("Can't specify id when _type is blank")
+		return
+	}
+
+	path := "/" + index
+	if len(_type) > 0 {
+		path += "/" + _type
+		if len(id) > 0 {
+			path += "/" + id
+		}
+	}
+
+	params := map[string]interface{}{}
+	for k, v := range args {
+		params[k] = v
+	}
+	for k, v := range map[string]string{"parent": parentId, "routing": routing, "timestamp": timestamp,
+		"percolate": percolate, "timeout": timeout} {
+		if len(v) > 0 {
+			params[k] = v
+		}
+	}
+	if version > 0 {
+		params["version"] = version
+	}
+	if ttl > 0 {
+		params["ttl"] = ttl
+	}
+	if len(op_type) > 0 {
+		// Without an id the op_type is always create
+		params["op_type"] = op_type
+		if len(id) == 0 {
+			params["op_type"] = "create"
+		}
+	}
+	if refresh {
+		params["refresh"] = true
+	}
+
+	method := "PUT"
+	if len(id) == 0 {
+		method = "POST"
+	}
+	_, err = e.doCommandInto(method, path, params, data, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) Update(index string, _type string, id string, args map[string]interface{}, data interface{}) (retval elastigo.BaseResponse, err error) {
+	_, err = e.doCommandInto("POST", fmt.Sprintf("/%s/%s/%s/_update", index, _type, id), args, data, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) Delete(index string, _type string, id string, args map[string]interface{}) (retval elastigo.BaseResponse, err error) {
+	_, err = e.doCommandInto("DELETE", fmt.Sprintf("/%s/%s/%s", index, _type, id), args, nil, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) CreateIndex(index string) (retval elastigo.BaseResponse, err error) {
+	if len(index) == 0 {
+		err = fmt.Errorf("You must specify an index to create")
+		return
+	}
+	_, err = e.doCommandInto("PUT", "/"+index, nil, nil, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) DeleteIndex(index string) (retval elastigo.BaseResponse, err error) {
+	if len(index) == 0 {
+		err = fmt.Errorf("You must specify an index to delete")
+		return
+	}
+	_, err = e.doCommandInto("DELETE", "/"+index, nil, nil, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) PutMappingFromJSON(index string, typeName string, mapping []byte) error {
+	_, err := e.DoCommand("PUT", fmt.Sprintf("/%s/%s/_mapping", index, typeName), nil, string(mapping))
+	return err
+}
+
+func (e *ExtendedEssConn) Refresh(indices ...string) (retval elastigo.BaseResponse, err error) {
+	path := "/_refresh"
+	if len(indices) > 0 {
+		path = fmt.Sprintf("/%s/_refresh", strings.Join(indices, ","))
+	}
+	_, err = e.doCommandInto("POST", path, nil, nil, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) Health(indices ...string) (retval elastigo.ClusterHealthResponse, err error) {
+	path := "/_cluster/health"
+	if len(indices) > 0 {
+		path = fmt.Sprintf("/_cluster/health/%s", strings.Join(indices, ","))
+	}
+	_, err = e.doCommandInto("GET", path, nil, nil, &retval)
+	return
+}
+
+func (e *ExtendedEssConn) CreateSnapshotRepository(name string, args map[string]interface{}, settings interface{}) (retval elastigo.BaseResponse, err error) {
+	_, err = e.doCommandInto("POST", fmt.Sprintf("/_snapshot/%s", name), args, settings, &retval)
+	return
+}
@@ -0,0 +1,60 @@
+package simpless
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ExtendedEssConn_Helpers(t *testing.T) {
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		if _, _, ok := r.BasicAuth(); !ok {
+			w.WriteHeader(401)
+			return
+		}
+		w.Write([]byte(`{"_id":"a1","created":true,"hits":{"total":1,"hits":[]}}`))
+	}))
+	defer s.Close()
+
+	p, err := NewPool(PoolConfig{Hosts: []string{s.URL}, Username: "vindalu", Password: "secret"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	e := NewExtendedEssConn(p)
+	defer e.Close()
+
+	resp, err := e.IndexWithParameters("idx", "server", "a1", "", 0, "", "", "1000", 0, "", "", false, nil, "{}")
+	if err != nil || resp.Id != "a1" || !resp.Created {
+		t.Fatalf("Index failed: %#v %v", resp, err)
+	}
+	rslt, err := e.Search("idx", "server", nil, "{}")
+	if err != nil || rslt.Hits.Total != 1 || len(rslt.RawJSON) == 0 {
+		t.Fatalf("Search failed: %#v %v", rslt, err)
+	}
+
+	expected := []string{"PUT /idx/server/a1?timestamp=1000", "POST /idx/server/_search"}
+	if len(requests) != len(expected) {
+		t.Fatalf("Wrong requests: %v", requests)
+	}
+	for i, r := range expected {
+		if requests[i] != r {
+			t.Fatalf("Wrong request: %s != %s", requests[i], r)
+		}
+	}
+}
+
+func Test_CurrentIndex(t *testing.T) {
+	for expected, indices := range map[string][]string{
+		"vindalu":     {"vindalu"},
+		"vindalu_v2":  {"vindalu_v1", "vindalu_v2"},
+		"vindalu_v10": {"vindalu_v10", "vindalu_v9"},
+		"vindalu_v1":  {"vindalu", "vindalu_v1"},
+		"other":       {"other", "another"},
+	} {
+		if current := CurrentIndex("vindalu", indices); current != expected {
+			t.Fatalf("Wrong index for %v: %s", indices, current)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package simpless
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/gnatsd/server"
+)
+
+const (
+	DEFAULT_TIMEOUT               = 30 * time.Second
+	DEFAULT_CONNECT_TIMEOUT       = 5 * time.Second
+	DEFAULT_MAX_RETRIES           = 3
+	DEFAULT_RETRY_BACKOFF         = 100 * time.Millisecond
+	DEFAULT_HEALTH_CHECK_INTERVAL = 10 * time.Second
+)
+
+type PoolConfig struct {
+	// host:port or url of each node i.e. https://es1:9200
+	Hosts []string
+
+	Username string
+	Password string
+	// Used for https hosts.  Hosts without a scheme default to https when set.
+	TLS *tls.Config
+
+	// Time limit of a request including reading the response
+	Timeout        time.Duration
+	ConnectTimeout time.Duration
+
+	// Retries of idempotent requests.  No retries if negative.
+	MaxRetries int
+	// Wait before the first retry.  Doubled on each subsequent retry.
+	RetryBackoff time.Duration
+	// Time between checks of unavailable hosts
+	HealthCheckInterval time.Duration
+
+	// Optional.  Hosts becoming unavailable and available again are logged.
+	Log server.Logger
+}
+
+// Settings of a single request overriding the pool config
+type RequestOptions struct {
+	// Time limit of the request instead of the pool timeout.  No limit if negative.
+	Timeout time.Duration
+	// Send the request once even if it is idempotent
+	NoRetry bool
+}
+
+// Availability of a host in the pool
+type HostStatus struct {
+	Host      string `json:"host"`
+	Healthy   bool   `json:"healthy"`
+	LastError string `json:"last_error,omitempty"`
+	// Time (ms) of the last failure
+	FailedAt int64 `json:"failed_at,omitempty"`
+}
+
+type poolHost struct {
+	url *url.URL
+	HostStatus
+}
+
+/*
+	Pool of elasticsearch nodes.  Requests are sent to the healthy hosts in round-robin order.
+	A host is marked unhealthy when a request to it fails and is checked in the background
+	until it responds again.  Requests are sent to all hosts in turn if none are healthy.
+*/
+type Pool struct {
+	cfg PoolConfig
+
+	hosts []*poolHost
+	next  int
+	mu    sync.Mutex
+
+	httpClient *http.Client
+	stop       chan bool
+	closeOnce  sync.Once
+}
+
+// Base url of a host.  The scheme defaults to https if TLS is configured.
+func parseHost(host string, useTLS bool) (*url.URL, error) {
+	if !strings.Contains(host, "://") {
+		if useTLS {
+			host = "https://" + host
+		} else {
+			host = "http://" + host
+		}
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return nil, fmt.Errorf("Invalid elasticsearch host: %s", host)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u, nil
+}
+
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if len(cfg.Hosts) == 0 {
+		return nil, fmt.Errorf("No elasticsearch hosts")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DEFAULT_TIMEOUT
+	}
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = DEFAULT_CONNECT_TIMEOUT
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DEFAULT_MAX_RETRIES
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DEFAULT_RETRY_BACKOFF
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = DEFAULT_HEALTH_CHECK_INTERVAL
+	}
+
+	p := &Pool{cfg: cfg, hosts: make([]*poolHost, len(cfg.Hosts)), stop: make(chan bool)}
+	for i, h := range cfg.Hosts {
+		u, err := parseHost(h, cfg.TLS != nil)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && u.Scheme != p.hosts[0].url.Scheme {
+			return nil, fmt.Errorf("All elasticsearch hosts must use the same scheme: %s", h)
+		}
+		p.hosts[i] = &poolHost{url: u, HostStatus: HostStatus{Host: u.Host, Healthy: true}}
+	}
+
+	p.httpClient = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			Dial:                (&net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}).Dial,
+			TLSClientConfig:     cfg.TLS,
+			TLSHandshakeTimeout: cfg.ConnectTimeout,
+		},
+	}
+
+	go p.checkHosts()
+	return p, nil
+}
+
+// Scheme used by all hosts
+func (p *Pool) Scheme() string {
+	return p.hosts[0].url.Scheme
+}
+
+// host:port of each host
+func (p *Pool) Hosts() []string {
+	hosts := make([]string, len(p.hosts))
+	for i, h := range p.hosts {
+		hosts[i] = h.url.Host
+	}
+	return hosts
+}
+
+func (p *Pool) Username() string {
+	return p.cfg.Username
+}
+
+func (p *Pool) Password() string {
+	return p.cfg.Password
+}
+
+func (p *Pool) Status() []HostStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := make([]HostStatus, len(p.hosts))
+	for i, h := range p.hosts {
+		status[i] = h.HostStatus
+	}
+	return status
+}
+
+// Next healthy host in round-robin order or the next host if none are healthy.
+func (p *Pool) nextHost() *poolHost {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := 0; i < len(p.hosts); i++ {
+		h := p.hosts[(p.next+i)%len(p.hosts)]
+		if h.Healthy {
+			p.next = (p.next + i + 1) % len(p.hosts)
+			return h
+		}
+	}
+	h := p.hosts[p.next]
+	p.next = (p.next + 1) % len(p.hosts)
+	return h
+}
+
+func (p *Pool) markHost(h *poolHost, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		if !h.Healthy && p.cfg.Log != nil {
+			p.cfg.Log.Noticef("Elasticsearch host available: %s\n", h.Host)
+		}
+		h.Healthy = true
+		return
+	}
+
+	if h.Healthy && p.cfg.Log != nil {
+		p.cfg.Log.Noticef("Elasticsearch host unavailable: %s %s\n", h.Host, err)
+	}
+	h.Healthy = false
+	h.LastError = err.Error()
+	h.FailedAt = time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// Check unhealthy hosts until the pool is closed.
+func (p *Pool) checkHosts() {
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			for _, h := range p.hosts {
+				p.mu.Lock()
+				healthy := h.Healthy
+				p.mu.Unlock()
+
+				if !healthy {
+					p.markHost(h, p.ping(h))
+				}
+			}
+		}
+	}
+}
+
+// Any response other than a server error means the node is up.
+func (p *Pool) ping(h *poolHost) error {
+	resp, err := p.send(p.httpClient, h, "GET", "/", "", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+// Client with the given timeout sharing the connections of the pool
+func (p *Pool) client(timeout time.Duration) *http.Client {
+	switch {
+	case timeout == 0:
+		return p.httpClient
+	case timeout < 0:
+		timeout = 0
+	}
+	return &http.Client{Timeout: timeout, Transport: p.httpClient.Transport}
+}
+
+func (p *Pool) send(client *http.Client, h *poolHost, method, path, rawQuery string, header http.Header, body []byte) (*http.Response, error) {
+	u := *h.url
+	u.Path = h.url.Path + path
+	u.RawQuery = rawQuery
+
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), rd)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if len(p.cfg.Username) > 0 || len(p.cfg.Password) > 0 {
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+	return client.Do(req)
+}
+
+/*
+	Requests that can be repeated without side effects.  Creates and versioned writes are not
+	retried as a retry of one that succeeded would fail as a conflict, nor are snapshots as a
+	retry would fail while the first one is running.  Searches are posted but only read.
+*/
+func isIdempotent(method, path, rawQuery string) bool {
+	switch method {
+	case "GET", "HEAD", "DELETE", "OPTIONS":
+		return true
+	case "PUT":
+		if strings.HasPrefix(path, "/_snapshot/") || strings.HasSuffix(path, "/_create") {
+			return false
+		}
+		q, _ := url.ParseQuery(rawQuery)
+		return q.Get("op_type") != "create" && len(q.Get("version")) == 0 && len(q.Get("if_seq_no")) == 0
+	case "POST":
+		for _, suffix := range []string{"/_search", "/_count", "/_refresh", "/_mget", "/_msearch"} {
+			if strings.HasSuffix(path, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Responses from a proxy or a node that cannot serve the request.  Another node may be able to.
+func isRetryableStatus(code int) bool {
+	return code == 502 || code == 503 || code == 504
+}
+
+/*
+	Send a request to the next available host.  Idempotent requests are retried on the next
+	host, with backoff, if the host cannot be reached or is unavailable.  The caller must close
+	the response body.
+*/
+func (p *Pool) Do(method, path, rawQuery string, header http.Header, body []byte) (*http.Response, error) {
+	return p.DoWithOptions(method, path, rawQuery, header, body, RequestOptions{})
+}
+
+func (p *Pool) DoWithOptions(method, path, rawQuery string, header http.Header, body []byte, opts RequestOptions) (resp *http.Response, err error) {
+	attempts := 1
+	if p.cfg.MaxRetries > 0 && !opts.NoRetry && isIdempotent(method, path, rawQuery) {
+		attempts += p.cfg.MaxRetries
+	}
+	client := p.client(opts.Timeout)
+
+	backoff := p.cfg.RetryBackoff
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		h := p.nextHost()
+		if resp, err = p.send(client, h, method, path, rawQuery, header, body); err != nil {
+			p.markHost(h, err)
+			continue
+		}
+		if isRetryableStatus(resp.StatusCode) && i < attempts-1 {
+			resp.Body.Close()
+			p.markHost(h, fmt.Errorf("%s", resp.Status))
+			continue
+		}
+		return
+	}
+	return
+}
+
+// Encode the arguments as a query string and the data as the body.  Strings and bytes are sent
+// as is, any other data is json encoded.
+func encodeRequest(args map[string]interface{}, data interface{}) (rawQuery string, body []byte, err error) {
+	if len(args) > 0 {
+		q := url.Values{}
+		for k, v := range args {
+			q.Set(k, fmt.Sprintf("%v", v))
+		}
+		rawQuery = q.Encode()
+	}
+
+	switch v := data.(type) {
+	case nil:
+	case string:
+		body = []byte(v)
+	case []byte:
+		body = v
+	case io.Reader:
+		body, err = ioutil.ReadAll(v)
+	default:
+		body, err = json.Marshal(v)
+	}
+	return
+}
+
+/*
+	Execute a request returning the status code and the response body.  Requests with a body
+	are sent with a JSON content type.
+*/
+func (p *Pool) DoCommand(method, path string, args map[string]interface{}, data interface{}) (int, []byte, error) {
+	return p.DoCommandWithOptions(method, path, args, data, RequestOptions{})
+}
+
+func (p *Pool) DoCommandWithOptions(method, path string, args map[string]interface{}, data interface{}, opts RequestOptions) (int, []byte, error) {
+	rawQuery, body, err := encodeRequest(args, data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	header := http.Header{"Accept": []string{"application/json"}}
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.DoWithOptions(method, path, rawQuery, header, body, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, b, err
+}
+
+// Stop the health checks and close idle connections.
+func (p *Pool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		if t, ok := p.httpClient.Transport.(*http.Transport); ok {
+			t.CloseIdleConnections()
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package simpless
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Server counting requests and responding with the given status
+func newCountingServer(status int, count *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*count++
+		w.WriteHeader(status)
+		w.Write([]byte(`{}`))
+	}))
+}
+
+func Test_Pool_RoundRobin(t *testing.T) {
+	var c1, c2 int
+	s1 := newCountingServer(200, &c1)
+	defer s1.Close()
+	s2 := newCountingServer(200, &c2)
+	defer s2.Close()
+
+	p, err := NewPool(PoolConfig{Hosts: []string{s1.URL, s2.URL}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, _, err = p.DoCommand("GET", "/", nil, nil); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+	if c1 != 2 || c2 != 2 {
+		t.Fatalf("Requests not distributed: %d %d", c1, c2)
+	}
+}
+
+func Test_Pool_Failover(t *testing.T) {
+	var count int
+	s := newCountingServer(200, &count)
+	defer s.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	p, err := NewPool(PoolConfig{Hosts: []string{down.URL, s.URL}, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+
+	if code, _, err := p.DoCommand("GET", "/test", nil, nil); err != nil || code != 200 {
+		t.Fatalf("Should fail over: %d %v", code, err)
+	}
+	status := p.Status()
+	if status[0].Healthy || len(status[0].LastError) == 0 || !status[1].Healthy {
+		t.Fatalf("Wrong status: %#v", status)
+	}
+
+	// Unhealthy host is skipped
+	p.DoCommand("GET", "/test", nil, nil)
+	if count != 2 {
+		t.Fatalf("Should only use healthy host: %d", count)
+	}
+
+	// Creates are not retried
+	p.markHost(p.hosts[1], nil)
+	p.next = 0
+	p.markHost(p.hosts[0], nil)
+	if _, _, err = p.DoCommand("PUT", "/idx/_doc/1", map[string]interface{}{"op_type": "create"}, "{}"); err == nil {
+		t.Fatalf("Create should not be retried")
+	}
+}
+
+func Test_Pool_RetryStatus(t *testing.T) {
+	var count int
+	s := newCountingServer(503, &count)
+	defer s.Close()
+
+	p, err := NewPool(PoolConfig{Hosts: []string{s.URL}, MaxRetries: 2, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+
+	if code, _, _ := p.DoCommand("GET", "/", nil, nil); code != 503 || count != 3 {
+		t.Fatalf("Should retry: %d %d", code, count)
+	}
+	count = 0
+	if code, _, _ := p.DoCommand("POST", "/idx/_doc", nil, "{}"); code != 503 || count != 1 {
+		t.Fatalf("Should not retry: %d %d", code, count)
+	}
+}
+
+func Test_Pool_RequestOptions(t *testing.T) {
+	var count int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if r.URL.Query().Get("wait") == "true" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.WriteHeader(503)
+	}))
+	defer s.Close()
+
+	p, err := NewPool(PoolConfig{Hosts: []string{s.URL}, Timeout: 20 * time.Millisecond, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+
+	if code, _, _ := p.DoCommandWithOptions("GET", "/", nil, nil, RequestOptions{NoRetry: true}); code != 503 || count != 1 {
+		t.Fatalf("Should not retry: %d %d", code, count)
+	}
+
+	args := map[string]interface{}{"wait": true}
+	if _, _, err = p.DoCommandWithOptions("GET", "/", args, nil, RequestOptions{NoRetry: true}); err == nil {
+		t.Fatalf("Should time out")
+	}
+	if code, _, err := p.DoCommandWithOptions("GET", "/", args, nil, RequestOptions{Timeout: -1, NoRetry: true}); err != nil || code != 503 {
+		t.Fatalf("Should not time out: %d %v", code, err)
+	}
+}
+
+func Test_Pool_BasicAuth(t *testing.T) {
+	var user, pass, contentType string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ = r.BasicAuth()
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer s.Close()
+
+	p, err := NewPool(PoolConfig{Hosts: []string{strings.TrimPrefix(s.URL, "http://")}, Username: "vindalu", Password: "secret"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer p.Close()
+
+	if _, _, err = p.DoCommand("POST", "/idx/_search", nil, map[string]interface{}{"size": 0}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if user != "vindalu" || pass != "secret" || contentType != "application/json" {
+		t.Fatalf("Wrong request: %s %s %s", user, pass, contentType)
+	}
+}
+
+func Test_NewPool(t *testing.T) {
+	if _, err := NewPool(PoolConfig{}); err == nil {
+		t.Fatalf("Should fail without hosts")
+	}
+	if _, err := NewPool(PoolConfig{Hosts: []string{"http://es1:9200", "https://es2:9200"}}); err == nil {
+		t.Fatalf("Should fail with mixed schemes")
+	}
+	if _, err := NewPool(PoolConfig{Hosts: []string{"ftp://es1"}}); err == nil {
+		t.Fatalf("Should fail with invalid scheme")
+	}
+}
+
+func Test_isIdempotent(t *testing.T) {
+	cases := map[string]bool{
+		"GET /idx/_doc/1":                true,
+		"PUT /idx/_doc/1":                true,
+		"PUT /idx/_doc/1?op_type=create": false,
+		"PUT /idx/_doc/1?version=2":      false,
+		"PUT /_snapshot/repo/snap":       false,
+		"POST /idx/_search":              true,
+		"POST /idx/_update/1":            false,
+	}
+	for req, expected := range cases {
+		parts := strings.SplitN(req, " ", 2)
+		path, query := parts[1], ""
+		if i := strings.Index(path, "?"); i > 0 {
+			path, query = path[:i], path[i+1:]
+		}
+		if isIdempotent(parts[0], path, query) != expected {
+			t.Fatalf("%s should be %v", req, expected)
+		}
+	}
+}
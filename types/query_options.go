@@ -7,13 +7,20 @@ import (
 )
 
 type QueryOptions struct {
-	From      int64               // starting point
-	Size      int64               // dataset size (from starting point)
-	Sort      []map[string]string // <property>:asc, <property>:desc
-	Aggregate string              // property
+	From      int64               `json:"from"`                // starting point
+	Size      int64               `json:"size"`                // dataset size (from starting point)
+	Sort      []map[string]string `json:"sort,omitempty"`      // <property>:asc, <property>:desc
+	Aggregate string              `json:"aggregate,omitempty"` // property
+	Subnet    int64               `json:"subnet,omitempty"`    // group aggregated ip's by subnet prefix length
 }
 
 func NewQueryOptions(req map[string][]string) (qo QueryOptions, err error) {
+	err = qo.Update(req)
+	return
+}
+
+// Override options with the ones supplied in the request.  Options not in the request are left as is.
+func (qo *QueryOptions) Update(req map[string][]string) (err error) {
 	for k, v := range req {
 
 		switch k {
@@ -25,6 +32,11 @@ func NewQueryOptions(req map[string][]string) (qo QueryOptions, err error) {
 			qo.Sort, err = parseSortOptions(v)
 		case "aggregate":
 			qo.Aggregate = strings.TrimSpace(v[0])
+		case "subnet":
+			if qo.Subnet, err = strconv.ParseInt(strings.TrimPrefix(v[0], "/"), 10, 64); err == nil &&
+				(qo.Subnet < 1 || qo.Subnet > 32) {
+				err = fmt.Errorf("Subnet must be a prefix length between 1 and 32")
+			}
 		}
 
 		if err != nil {
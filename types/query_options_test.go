@@ -84,3 +84,27 @@ func Test_NewQueryOptions_error2(t *testing.T) {
 		t.Fatalf("Error not caught")
 	}
 }
+
+func Test_QueryOptions_Update(t *testing.T) {
+	qo, _ := NewQueryOptions(testQueryOpts)
+	if err := qo.Update(map[string][]string{"size": []string{"20"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if qo.Size != 20 || qo.From != 5 || qo.Aggregate != "foo" {
+		t.Fatalf("update failed: %v\n", qo)
+	}
+}
+
+func Test_QueryOptions_Subnet(t *testing.T) {
+	qo, err := NewQueryOptions(map[string][]string{"subnet": []string{"/24"}})
+	if err != nil || qo.Subnet != 24 {
+		t.Fatalf("subnet not parsed: %v %v\n", qo, err)
+	}
+
+	for _, v := range []string{"0", "33", "foo"} {
+		if _, err = NewQueryOptions(map[string][]string{"subnet": []string{v}}); err == nil {
+			t.Fatalf("should fail: %s\n", v)
+		}
+	}
+}